@@ -0,0 +1,53 @@
+package sininen
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+// ankiField escapes a text for an HTML field of an Anki TSV file, where tabs and newlines separate fields and notes.
+func ankiField(text string) string {
+	return strings.NewReplacer("\t", " ", "\n", " ").Replace(html.EscapeString(text))
+}
+
+// clozeText returns the text of a segment and its context, the matched terms being cloze deletions.
+func clozeText(segment ScoredSegment) string {
+	var sb strings.Builder
+	for _, excerpt := range segment.Before {
+		sb.WriteString(ankiField(excerpt.Text))
+		sb.WriteRune(' ')
+	}
+	for _, fragment := range segment.Fragments() {
+		if fragment.Matched {
+			fmt.Fprintf(&sb, "{{c1::%s}}", ankiField(fragment.Text))
+		} else {
+			sb.WriteString(ankiField(fragment.Text))
+		}
+	}
+	for _, excerpt := range segment.After {
+		sb.WriteRune(' ')
+		sb.WriteString(ankiField(excerpt.Text))
+	}
+	return sb.String()
+}
+
+// WriteAnki writes scored segments as a TSV file importable in Anki, with one cloze note per segment.
+// The front of the cards is the excerpt where the matched terms are to be guessed and the back links to the source
+// video at the timestamp of the segment.
+// TSV is used rather than .apkg packages because the latter are SQLite databases, which would need a cgo dependency.
+func WriteAnki(w io.Writer, segments []ScoredSegment, urls URLBuilder) error {
+	if _, err := fmt.Fprint(w, "#separator:tab\n#html:true\n#notetype:Cloze\n#columns:Text\tBack Extra\n"); err != nil {
+		return err
+	}
+	for _, segment := range segments {
+		_, err := fmt.Fprintf(w, "%s\t<a href=\"%s\">%s %s</a>\n",
+			clozeText(segment), html.EscapeString(SegmentURL(urls, segment)),
+			ankiField(segment.DisplayName()), FormatTimestamp(segment.StartTime))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,289 @@
+package sininen
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/blevesearch/bleve/v2/search"
+)
+
+/////////////////////////////
+// Search results assembly //
+/////////////////////////////
+// That is to say going from raw bleve results to results catered for audio transcriptions.
+
+// locateSegment returns the index of the segment containing the given location, -1 when the segments are inconsistent.
+func locateSegment(segments []float64, location *search.Location) int {
+	searchFailed := false
+	position := sort.Search(len(segments)/3, func(i int) bool {
+		if endPos := segments[i*3+2]; endPos > 0 {
+			return uint64(endPos) > location.Start
+		}
+		searchFailed = true
+		return false
+	})
+	if searchFailed {
+		return -1
+	}
+	return position
+}
+
+// extractDurations extracts the times of a segment from the serialized segments.
+func extractDurations(segments []float64, segmentPos int) (startTime, endTime time.Duration) {
+	return fromSeconds(segments[segmentPos*3]), fromSeconds(segments[segmentPos*3+1])
+}
+
+// textBounds returns the position of the text of a segment within the whole transcription text.
+// ok is false when the text is unavailable or inconsistent with the segments.
+func textBounds(words string, segments []float64, segmentPos int) (start, end int, ok bool) {
+	endPos := int(segments[segmentPos*3+2])
+	if endPos > len(words) {
+		return
+	}
+	if segmentPos > 0 {
+		start = int(segments[segmentPos*3-1]) + 1 // Skip the newline separating the segments.
+	}
+	if start > endPos {
+		return
+	}
+	return start, endPos, true
+}
+
+// extractText extracts the text of a segment from the whole transcription text.
+// An empty string is returned when the text is unavailable or inconsistent with the segments.
+func extractText(words string, segments []float64, segmentPos int) string {
+	start, end, ok := textBounds(words, segments, segmentPos)
+	if !ok {
+		return ""
+	}
+	return words[start:end]
+}
+
+// extractExcerpts extracts the segments in the range [from, to[, clamped to the valid segment positions.
+func extractExcerpts(words string, segments []float64, from, to int) []SegmentExcerpt {
+	if from < 0 {
+		from = 0
+	}
+	if nsegments := len(segments) / 3; to > nsegments {
+		to = nsegments
+	}
+	if from >= to {
+		return nil
+	}
+
+	result := make([]SegmentExcerpt, 0, to-from)
+	for i := from; i < to; i++ {
+		start, end := extractDurations(segments, i)
+		result = append(result, SegmentExcerpt{
+			StartTime: start,
+			EndTime:   end,
+			Text:      extractText(words, segments, i),
+		})
+	}
+	return result
+}
+
+// appendHighlight appends the position of a location relative to the text of its segment, when the text is available.
+func appendHighlight(highlights []TextSpan, words string, segments []float64, segmentPos int, location *search.Location) []TextSpan {
+	start, end, ok := textBounds(words, segments, segmentPos)
+	if !ok || int(location.Start) < start || int(location.End) > end {
+		return highlights
+	}
+	return append(highlights, TextSpan{int(location.Start) - start, int(location.End) - start})
+}
+
+// contextBounds returns the range [from, to[ of the segments surrounding the hit at segmentPos, itself included.
+func contextBounds(segments []float64, segmentPos int, options AssemblyOptions) (from, to int) {
+	from, to = segmentPos-options.Context, segmentPos+1+options.Context
+	if options.ContextDuration <= 0 {
+		return from, to
+	}
+
+	hitStart, hitEnd := extractDurations(segments, segmentPos)
+	for j := from - 1; j >= 0; j-- {
+		if _, end := extractDurations(segments, j); end < hitStart-options.ContextDuration {
+			break
+		}
+		from = j
+	}
+	for j := to; j < len(segments)/3; j++ {
+		if start, _ := extractDurations(segments, j); start > hitEnd+options.ContextDuration {
+			break
+		}
+		to = j + 1
+	}
+	return from, to
+}
+
+// addContext adds the excerpts surrounding the segment at segmentPos to its hit, as requested by the options.
+func addContext(segmentHit *SegmentHit, words string, segments []float64, segmentPos int, options AssemblyOptions) {
+	if options.Context <= 0 && options.ContextDuration <= 0 {
+		return
+	}
+	from, to := contextBounds(segments, segmentPos, options)
+	segmentHit.Before = extractExcerpts(words, segments, from, segmentPos)
+	segmentHit.After = extractExcerpts(words, segments, segmentPos+1, to)
+}
+
+// storedSegments returns the serialized segments and the transcription text stored in a bleve search hit, decoding
+// the segments once for the whole hit.
+// The segments are packed by packSegments in the recent indexes and stored as an array of numbers in the older ones,
+// Snapshot giving them as is.
+// The text is only needed for the text of the segments, which is best effort, so it is empty when missing.
+func storedSegments(hit *search.DocumentMatch) (segments []float64, words string, err error) {
+	raw, exists := hit.Fields["Segments"]
+	if !exists {
+		return nil, "", errors.New("segments are missing from bleve search results")
+	}
+	switch stored := raw.(type) {
+	case string:
+		if segments, err = unpackSegments(stored); err != nil {
+			return nil, "", err
+		}
+	case []float64:
+		segments = stored
+	case []interface{}:
+		segments = make([]float64, len(stored))
+		for i, value := range stored {
+			number, valid := value.(float64)
+			if !valid {
+				return nil, "", fmt.Errorf("expected segments[%v] to be of type float64, got %T", i, value)
+			}
+			segments[i] = number
+		}
+	default:
+		return nil, "", fmt.Errorf("segments should be an array, got %T", raw)
+	}
+	if len(segments)%3 != 0 {
+		return nil, "", fmt.Errorf("serialized segments should be a multiple of 3, got %v segments", len(segments))
+	}
+	words, _ = hit.Fields["Words"].(string)
+	return segments, words, nil
+}
+
+// AssemblyOptions tunes how raw bleve results are turned into transcription search results.
+// The zero value is a sensible default.
+type AssemblyOptions struct {
+	Context             int           // Number of segments to include before and after each hit.
+	ContextDuration     time.Duration // Include the segments overlapping this duration before and after each hit, in addition to Context.
+	MaxSegmentsPerVideo int           // Maximum number of segments kept per transcription, the best ones being kept. Unlimited when 0.
+	FirstOccurrenceOnly bool          // Only keep the earliest matching segment of each transcription.
+}
+
+// selectSegmentHits sorts the segment hits of a transcription in place, by position, from the one with the most distinct terms,
+// and keeps the ones requested by the options.
+func selectSegmentHits(hits []SegmentHit, options AssemblyOptions) []SegmentHit {
+	sortedSegments := hits
+	for i := range sortedSegments {
+		el := &sortedSegments[i]
+		if len(el.SortedTerms) > 1 { // Spares the allocations of the sorts for the segments matching a single term.
+			sort.Strings(el.SortedTerms)
+			sort.Slice(el.Highlights, func(i, j int) bool { return el.Highlights[i].Start < el.Highlights[j].Start })
+		}
+	}
+	sort.Slice(sortedSegments, func(i, j int) bool {
+		si, sj := sortedSegments[i], sortedSegments[j]
+		if len(si.SortedTerms) != len(sj.SortedTerms) { // To ensure stability of the sorting operation.
+			return len(si.SortedTerms) > len(sj.SortedTerms)
+		}
+		return si.StartTime < sj.StartTime
+	})
+	return capSegmentHits(sortedSegments, options)
+}
+
+// capSegmentHits keeps the segment hits of a transcription requested by the MaxSegmentsPerVideo and FirstOccurrenceOnly
+// options, the hits being sorted like selectSegmentHits sorts them.
+func capSegmentHits(sortedSegments []SegmentHit, options AssemblyOptions) []SegmentHit {
+	if options.FirstOccurrenceOnly && len(sortedSegments) > 0 {
+		first := 0
+		for i, segment := range sortedSegments {
+			if segment.StartTime < sortedSegments[first].StartTime {
+				first = i
+			}
+		}
+		sortedSegments = sortedSegments[first : first+1]
+	}
+	if options.MaxSegmentsPerVideo > 0 && len(sortedSegments) > options.MaxSegmentsPerVideo {
+		sortedSegments = sortedSegments[:options.MaxSegmentsPerVideo]
+	}
+	return sortedSegments
+}
+
+// sortedLocations returns the locations of a term sorted by position, as they usually are already.
+func sortedLocations(locations search.Locations) search.Locations {
+	less := func(i, j int) bool { return locations[i].Start < locations[j].Start }
+	if sort.SliceIsSorted(locations, less) {
+		return locations
+	}
+	locations = append(search.Locations{}, locations...)
+	sort.Slice(locations, less)
+	return locations
+}
+
+// assembleHit builds the transcription search result of a bleve hit, holding the stored fields of the transcription and
+// the locations of the matched terms.
+func assembleHit(hit *search.DocumentMatch, options AssemblyOptions) (SearchResult, error) {
+	segments, words, err := storedSegments(hit)
+	if err != nil {
+		return SearchResult{}, err
+	}
+
+	// The locations of each term are resolved in a single pass over the segments, the hits of a segment being shared
+	// since different terms can occur in the same segment.
+	nsegments := len(segments) / 3
+	count := 0
+	for _, locations := range hit.Locations["Words"] {
+		count += len(locations)
+	}
+	if count > nsegments {
+		count = nsegments
+	}
+	hits := make([]SegmentHit, 0, count) // Not reallocated, hence the pointers to its elements stay valid.
+	hitAt := make([]*SegmentHit, nsegments)
+	for term, locations := range hit.Locations["Words"] { // The locations in the other fields are not in segments.
+		i := 0
+		for _, location := range sortedLocations(locations) {
+			for i < nsegments && uint64(segments[i*3+2]) <= location.Start {
+				i++
+			}
+			if i == nsegments {
+				return SearchResult{}, errors.New("failed to locate segment")
+			}
+			segmentHit := hitAt[i]
+			if segmentHit == nil {
+				start, end := extractDurations(segments, i)
+				hits = append(hits, SegmentHit{StartTime: start, EndTime: end, Text: extractText(words, segments, i)})
+				segmentHit = &hits[len(hits)-1]
+				addContext(segmentHit, words, segments, i, options)
+				hitAt[i] = segmentHit
+			}
+			segmentHit.SortedTerms = append(segmentHit.SortedTerms, term) // Will sort later.
+			segmentHit.Highlights = appendHighlight(segmentHit.Highlights, words, segments, i, location)
+		}
+	}
+
+	sortedSegments := selectSegmentHits(hits, options)
+	title, _ := hit.Fields["Title"].(string)
+	seconds, _ := hit.Fields["Duration"].(float64)
+	duration := fromSeconds(seconds)
+	views, _ := hit.Fields["ViewCount"].(float64)
+	translatedFrom, _ := hit.Fields["TranslatedFrom"].(string)
+	var uploadDate time.Time
+	if raw, exists := hit.Fields["UploadDate"].(string); exists {
+		uploadDate, _ = time.Parse(time.RFC3339, raw) // Stays zero when it cannot be parsed.
+	}
+
+	return SearchResult{
+		ID:         hit.ID,
+		Title:      title,
+		Score:      hit.Score,
+		UploadDate: uploadDate,
+		Duration:   duration,
+		ViewCount:  int64(views),
+		Segments:   sortedSegments,
+
+		TranslatedFrom: translatedFrom,
+	}, nil
+}
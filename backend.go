@@ -0,0 +1,138 @@
+//go:build !js
+// +build !js
+
+package sininen
+
+import (
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+)
+
+// Backend stores the transcriptions of a language and searches through them, so that the CLI does not depend on the
+// search engine.
+// The bleve indexes are the default backend, see SQLiteBackend for the alternative.
+type Backend interface {
+	Searcher
+	// Update incrementally indexes the subtitles files of the given folder, like UpdateSubtitleIndex.
+	Update(folder, lang string, progress Progress) (IndexUpdate, error)
+	// SearchVideos makes a plain text search restricted to the given videos, e.g. the ones just added by Update, every
+	// matching transcription being retrieved.
+	SearchVideos(query string, ids []string, options AssemblyOptions) (SearchResultSequence, error)
+}
+
+// BleveBackend is the Backend of the bleve indexes, such as the ones created by CreateSubtitleIndex.
+type BleveBackend struct {
+	bleve.Index
+}
+
+func (bb BleveBackend) Update(folder, lang string, progress Progress) (IndexUpdate, error) {
+	return UpdateSubtitleIndex(bb.Index, folder, lang, progress)
+}
+
+func (bb BleveBackend) Search(query string, size int, options AssemblyOptions) (SearchResultSequence, error) {
+	defer observeSearch("bleve", time.Now())
+	raw, err := TextQuery(query, bb.Index, size)
+	if err != nil {
+		return nil, err
+	}
+	Log.Infof("%d matching videos out of %d in %v", len(raw.Hits), raw.Total, raw.Took)
+	return AssembleSearchResults(raw, options)
+}
+
+func (bb BleveBackend) SearchVideos(query string, ids []string, options AssemblyOptions) (SearchResultSequence, error) {
+	if len(ids) == 0 {
+		return SearchResultSequence{}, nil
+	}
+	defer observeSearch("bleve", time.Now())
+	raw, err := VideosTextQuery(query, ids, bb.Index)
+	if err != nil {
+		return nil, err
+	}
+	return AssembleSearchResults(raw, options)
+}
+
+// Backends searches through several backends at once, e.g. the ones of several channels or languages, which are
+// expected to be of the same kind so that their scores are comparable.
+// It cannot be updated, each backend having its own subtitles folder.
+type Backends []Backend
+
+// Search merges the results of every backend, keeping the size best transcriptions, see MultiSearcher.
+func (bs Backends) Search(query string, size int, options AssemblyOptions) (SearchResultSequence, error) {
+	searchers := make([]Searcher, len(bs))
+	for i, backend := range bs {
+		searchers[i] = backend
+	}
+	return MultiSearcher{Searchers: searchers}.Search(query, size, options)
+}
+
+// Close closes every backend, returning the first error.
+func (bs Backends) Close() error {
+	var result error
+	for _, backend := range bs {
+		if err := backend.Close(); err != nil && result == nil {
+			result = err
+		}
+	}
+	return result
+}
+
+// MultiSearcher searches through several searchers concurrently, e.g. the indexes of several channels, which are
+// expected to be of the same kind so that their scores are comparable.
+type MultiSearcher struct {
+	Searchers   []Searcher
+	Parallelism int // Maximum number of searchers queried at once, the number of usable CPUs when not positive.
+}
+
+// Search queries at most Parallelism searchers at once and merges their results, keeping the size best
+// transcriptions. When several searchers fail, the error of the first one is returned.
+func (ms MultiSearcher) Search(query string, size int, options AssemblyOptions) (SearchResultSequence, error) {
+	if size <= 0 {
+		size = 10
+	}
+	parallelism := ms.Parallelism
+	if parallelism <= 0 {
+		parallelism = runtime.GOMAXPROCS(0)
+	}
+	found := make([]SearchResultSequence, len(ms.Searchers))
+	errs := make([]error, len(ms.Searchers))
+	slots := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i, searcher := range ms.Searchers {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(i int, searcher Searcher) {
+			defer wg.Done()
+			found[i], errs[i] = searcher.Search(query, size, options)
+			<-slots
+		}(i, searcher)
+	}
+	wg.Wait()
+
+	result := SearchResultSequence{}
+	for i, videos := range found {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		result = append(result, videos...)
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Score > result[j].Score })
+	if len(result) > size {
+		result = result[:size]
+	}
+	return result, nil
+}
+
+// Close closes every searcher, returning the first error.
+func (ms MultiSearcher) Close() error {
+	var result error
+	for _, searcher := range ms.Searchers {
+		if err := searcher.Close(); err != nil && result == nil {
+			result = err
+		}
+	}
+	return result
+}
@@ -0,0 +1,117 @@
+// Package bot answers the search commands of chat platforms, such as /find channel query, with timestamped links to the
+// matching segments and their excerpts.
+// The commands are handled by Bot independently of the platform, Telegram and Discord connecting it to theirs.
+package bot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mooss/sininen"
+)
+
+// Bot answers the chat commands by searching the indexes of channels.
+// Only Open is mandatory, the zero value of the other fields being a sensible default.
+type Bot struct {
+	// Open returns the searcher of the index of a channel, or an error wrapping sininen.ErrInvalidOption when there is
+	// no such channel, the error being then shown to the user.
+	// The searcher is closed once the command is answered, it is up to Open to keep the indexes open between the
+	// commands.
+	Open func(channelName string) (sininen.Searcher, error)
+
+	URLs     sininen.URLBuilder      // Builder of the links to the segments, YouTube by default.
+	Rank     sininen.RankingStrategy // Ranking of the segments, sininen.DistinctTermsRanking by default.
+	Assembly sininen.AssemblyOptions // Assembly of the search results, e.g. to add excerpts around the segments.
+	Limit    int                     // Maximum number of segments of an answer, 5 by default.
+}
+
+// withDefaults returns the bot with its defaults filled in.
+func (b Bot) withDefaults() Bot {
+	if b.URLs == nil {
+		b.URLs = sininen.YouTube{}
+	}
+	if b.Rank == nil {
+		b.Rank = sininen.DistinctTermsRanking
+	}
+	if b.Limit <= 0 {
+		b.Limit = 5
+	}
+	return b
+}
+
+// usage is the answer to the help command and to the malformed commands.
+const usage = "/find <channel> <query> searches the transcripts of a channel and links to the best matching moments."
+
+// Answer returns the reply to a chat message, written in the given output format and at most maxLength bytes long when
+// positive.
+// The messages other than the commands of the bot, /find and /help, are not answered and ok is then false.
+// The commands may be suffixed by the name of the bot, e.g. /find@name, as is done by Telegram in group chats.
+func (b Bot) Answer(message, format string, maxLength int) (reply string, ok bool) {
+	fields := strings.Fields(message)
+	if len(fields) == 0 {
+		return "", false
+	}
+	name := fields[0]
+	if at := strings.IndexByte(name, '@'); at >= 0 {
+		name = name[:at]
+	}
+	switch name {
+	case "/find":
+		if len(fields) < 3 {
+			return "Usage: " + usage, true
+		}
+		reply, err := b.Find(fields[1], strings.Join(fields[2:], " "), format, maxLength)
+		if err != nil {
+			return errorReply(err), true
+		}
+		return reply, true
+	case "/help", "/start": // Telegram sends /start when a user opens a chat with the bot.
+		return usage, true
+	}
+	return "", false
+}
+
+// Find searches the index of a channel and returns the best matching segments, written in the given output format and
+// at most maxLength bytes long when positive, the last segments being dropped when they would not fit.
+func (b Bot) Find(channelName, query, format string, maxLength int) (string, error) {
+	b = b.withDefaults()
+	searcher, err := b.Open(channelName)
+	if err != nil {
+		return "", err
+	}
+	defer searcher.Close()
+	videos, err := searcher.Search(query, b.Limit, b.Assembly)
+	if err != nil {
+		return "", err
+	}
+	segments := videos.RankedSegments(b.Rank)
+	if len(segments) > b.Limit {
+		segments = segments[:b.Limit]
+	}
+	if len(segments) == 0 {
+		return fmt.Sprintf("Nothing matches %q in %s.", query, channelName), nil
+	}
+	options := sininen.FormatOptions{Title: query, URLs: b.URLs}
+	for ; len(segments) > 0; segments = segments[:len(segments)-1] {
+		var reply bytes.Buffer
+		if err := sininen.WriteFormat(&reply, format, segments, options); err != nil {
+			return "", err
+		}
+		if maxLength <= 0 || reply.Len() <= maxLength {
+			return reply.String(), nil
+		}
+	}
+	return "", fmt.Errorf("the matching segments do not fit in %d bytes", maxLength)
+}
+
+// errorReply returns the reply to a failed command, the details of the errors not caused by the user being left to
+// the logs.
+func errorReply(err error) string {
+	if errors.Is(err, sininen.ErrInvalidOption) || errors.Is(err, sininen.ErrIndexNotFound) {
+		return "Cannot search: " + err.Error() + "."
+	}
+	sininen.Log.Warnf("answering a command: %v", err)
+	return "The search failed, sorry."
+}
@@ -0,0 +1,191 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/mooss/sininen"
+)
+
+// discordMaxLength is the maximum length of the content of a Discord message.
+const discordMaxLength = 2000
+
+// Discord connects a bot to Discord through the interactions endpoint of an application, answering its slash commands,
+// see https://discord.com/developers/docs/interactions/receiving-and-responding.
+// Only PublicKey is mandatory to answer the commands, the zero value of the other fields being a sensible default.
+type Discord struct {
+	PublicKey ed25519.PublicKey // Public key of the application, checking that the interactions come from Discord.
+	Format    string            // Output format of the answers, markdown by default.
+
+	// ApplicationID and Token, the token of the bot of the application, are only needed by RegisterCommands.
+	ApplicationID string
+	Token         string
+	API           string       // Base URL of the Discord API, https://discord.com/api/v10 by default.
+	HTTP          *http.Client // http.DefaultClient when nil.
+}
+
+// withDefaults returns the options with their defaults filled in.
+func (d Discord) withDefaults() Discord {
+	if d.Format == "" {
+		d.Format = "markdown"
+	}
+	if d.API == "" {
+		d.API = "https://discord.com/api/v10"
+	}
+	if d.HTTP == nil {
+		d.HTTP = http.DefaultClient
+	}
+	return d
+}
+
+// ParseDiscordPublicKey parses the hexadecimal public key of a Discord application, as shown on its settings page.
+func ParseDiscordPublicKey(key string) (ed25519.PublicKey, error) {
+	raw, err := hex.DecodeString(key)
+	if err != nil || len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("%w: invalid Discord public key, expected %d hexadecimal bytes", sininen.ErrInvalidOption, ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// The types of the interactions and of their responses used by the bot.
+const (
+	discordPing               = 1
+	discordApplicationCommand = 2
+	discordPong               = 1
+	discordMessageResponse    = 4
+	discordSuppressEmbeds     = 1 << 2 // Flag of the messages whose links are not previewed.
+)
+
+// discordInteraction is the part of a Discord interaction needed to answer it.
+type discordInteraction struct {
+	Type int `json:"type"`
+	Data struct {
+		Name    string `json:"name"`
+		Options []struct {
+			Name  string      `json:"name"`
+			Value interface{} `json:"value"`
+		} `json:"options"`
+	} `json:"data"`
+}
+
+// option returns the value of an option of a command, empty when not given.
+func (di discordInteraction) option(name string) string {
+	for _, option := range di.Data.Options {
+		if option.Name == name {
+			return fmt.Sprint(option.Value)
+		}
+	}
+	return ""
+}
+
+// discordResponse is the response to an interaction.
+type discordResponse struct {
+	Type int                  `json:"type"`
+	Data *discordResponseData `json:"data,omitempty"`
+}
+
+type discordResponseData struct {
+	Content string `json:"content"`
+	Flags   int    `json:"flags"`
+}
+
+// Handler returns the handler of the interactions endpoint, to be served over HTTPS at the URL given in the settings of
+// the application.
+// The interactions whose signature does not match the public key are rejected, as required by Discord.
+func (d Discord) Handler(bot Bot) http.Handler {
+	d = d.withDefaults()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1<<20))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		signature, err := hex.DecodeString(r.Header.Get("X-Signature-Ed25519"))
+		message := append([]byte(r.Header.Get("X-Signature-Timestamp")), body...)
+		if err != nil || len(signature) != ed25519.SignatureSize || !ed25519.Verify(d.PublicKey, message, signature) {
+			http.Error(w, "invalid request signature", http.StatusUnauthorized)
+			return
+		}
+		var interaction discordInteraction
+		if err := json.Unmarshal(body, &interaction); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(d.respond(bot, interaction))
+	})
+}
+
+// respond returns the response to an interaction.
+func (d Discord) respond(bot Bot, interaction discordInteraction) discordResponse {
+	if interaction.Type == discordPing {
+		return discordResponse{Type: discordPong}
+	}
+	content := usage
+	if interaction.Type == discordApplicationCommand && interaction.Data.Name == "find" {
+		reply, err := bot.Find(interaction.option("channel"), interaction.option("query"), d.Format, discordMaxLength)
+		if err != nil {
+			reply = errorReply(err)
+		}
+		content = reply
+	}
+	return discordResponse{Type: discordMessageResponse, Data: &discordResponseData{content, discordSuppressEmbeds}}
+}
+
+// discordCommands are the slash commands of the bot, as registered by RegisterCommands.
+var discordCommands = []map[string]interface{}{
+	{
+		"name":        "find",
+		"description": "Search the transcripts of a channel and link to the best matching moments.",
+		"options": []map[string]interface{}{
+			{"type": 3, "name": "channel", "description": "Name of the channel.", "required": true},
+			{"type": 3, "name": "query", "description": "Words to search.", "required": true},
+		},
+	},
+	{"name": "help", "description": "Explain how to search the transcripts."},
+}
+
+// RegisterCommands registers the slash commands of the bot as the global commands of the application, replacing the
+// existing ones; this only needs to be done once.
+func (d Discord) RegisterCommands(ctx context.Context) error {
+	d = d.withDefaults()
+	if d.ApplicationID == "" || d.Token == "" {
+		return fmt.Errorf("%w: the application ID and the bot token are needed to register the Discord commands", sininen.ErrInvalidOption)
+	}
+	body, err := json.Marshal(discordCommands)
+	if err != nil {
+		return err
+	}
+	endpoint := d.API + "/applications/" + d.ApplicationID + "/commands"
+	request, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Authorization", "Bot "+d.Token)
+	response, err := d.HTTP.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		raw, _ := ioutil.ReadAll(io.LimitReader(response.Body, 512))
+		if raw = bytes.TrimSpace(raw); len(raw) > 0 {
+			return fmt.Errorf("registering the Discord commands: %s: %s", response.Status, raw)
+		}
+		return fmt.Errorf("registering the Discord commands: %s", response.Status)
+	}
+	return nil
+}
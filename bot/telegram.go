@@ -0,0 +1,176 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/mooss/sininen"
+)
+
+// telegramMaxLength is the maximum length of the text of a Telegram message.
+const telegramMaxLength = 4096
+
+// Telegram connects a bot to Telegram by long polling the Bot API for the messages sent to it, see
+// https://core.telegram.org/bots/api.
+// Only Token is mandatory, the zero value of the other fields being a sensible default.
+type Telegram struct {
+	Token      string        // Token of the bot, given by @BotFather.
+	API        string        // Base URL of the Bot API, https://api.telegram.org by default.
+	Format     string        // Output format of the answers, text by default.
+	Poll       time.Duration // Duration of the long polling requests, 50 seconds by default.
+	RetryDelay time.Duration // Delay before polling again after a failure, 5 seconds by default.
+	HTTP       *http.Client  // http.DefaultClient when nil.
+}
+
+// withDefaults returns the options with their defaults filled in.
+func (t Telegram) withDefaults() Telegram {
+	if t.API == "" {
+		t.API = "https://api.telegram.org"
+	}
+	if t.Format == "" {
+		t.Format = "text"
+	}
+	if t.Poll <= 0 {
+		t.Poll = 50 * time.Second
+	}
+	if t.RetryDelay <= 0 {
+		t.RetryDelay = 5 * time.Second
+	}
+	if t.HTTP == nil {
+		t.HTTP = http.DefaultClient
+	}
+	return t
+}
+
+// telegramMessage is the part of a Telegram message needed to answer it.
+type telegramMessage struct {
+	MessageID int64  `json:"message_id"`
+	Text      string `json:"text"`
+	Chat      struct {
+		ID int64 `json:"id"`
+	} `json:"chat"`
+}
+
+// telegramUpdate is an update received from the Bot API, only the new messages being answered.
+type telegramUpdate struct {
+	UpdateID int64            `json:"update_id"`
+	Message  *telegramMessage `json:"message"`
+}
+
+// telegramResponse is the envelope of the responses of the Bot API.
+type telegramResponse struct {
+	OK          bool            `json:"ok"`
+	Description string          `json:"description"`
+	ErrorCode   int             `json:"error_code"`
+	Result      json.RawMessage `json:"result"`
+}
+
+// Run answers the messages sent to the bot until the context is done, returning nil then.
+// The failures to poll are retried, except when the token is rejected, and the failures to answer are only reported.
+func (t Telegram) Run(ctx context.Context, bot Bot) error {
+	t = t.withDefaults()
+	var offset int64
+	for ctx.Err() == nil {
+		updates, err := t.updates(ctx, offset)
+		if ctx.Err() != nil {
+			break
+		}
+		if apiErr, ok := err.(telegramError); ok && (apiErr.code == http.StatusUnauthorized || apiErr.code == http.StatusNotFound) {
+			return err
+		}
+		if err != nil {
+			sininen.Log.Warnf("polling Telegram: %v", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(t.RetryDelay):
+			}
+			continue
+		}
+		for _, update := range updates {
+			offset = update.UpdateID + 1
+			if update.Message == nil {
+				continue
+			}
+			reply, ok := bot.Answer(update.Message.Text, t.Format, telegramMaxLength)
+			if !ok {
+				continue
+			}
+			if err := t.send(ctx, *update.Message, reply); err != nil {
+				sininen.Log.Warnf("answering on Telegram: %v", err)
+			}
+		}
+	}
+	return nil
+}
+
+// updates returns the updates following the given offset, waiting for them at most for the polling duration.
+func (t Telegram) updates(ctx context.Context, offset int64) ([]telegramUpdate, error) {
+	parameters := url.Values{
+		"offset":          {strconv.FormatInt(offset, 10)},
+		"timeout":         {strconv.Itoa(int(t.Poll.Seconds()))},
+		"allowed_updates": {`["message"]`},
+	}
+	var result []telegramUpdate
+	err := t.call(ctx, "getUpdates", parameters, &result)
+	return result, err
+}
+
+// send replies to a message, without previewing the links which would otherwise take most of the chat.
+func (t Telegram) send(ctx context.Context, to telegramMessage, text string) error {
+	parameters := url.Values{
+		"chat_id":                  {strconv.FormatInt(to.Chat.ID, 10)},
+		"reply_to_message_id":      {strconv.FormatInt(to.MessageID, 10)},
+		"text":                     {text},
+		"disable_web_page_preview": {"true"},
+	}
+	return t.call(ctx, "sendMessage", parameters, nil)
+}
+
+// telegramError is an error reported by the Bot API.
+type telegramError struct {
+	method      string
+	code        int
+	description string
+}
+
+func (te telegramError) Error() string {
+	return fmt.Sprintf("Telegram %s: %d %s", te.method, te.code, te.description)
+}
+
+// call calls a method of the Bot API and decodes its result in result, unless nil.
+func (t Telegram) call(ctx context.Context, method string, parameters url.Values, result interface{}) error {
+	endpoint := t.API + "/bot" + t.Token + "/" + method
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewBufferString(parameters.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	client := *t.HTTP
+	client.Timeout = t.Poll + 10*time.Second // Leaves the long polling some time to answer.
+	response, err := client.Do(request)
+	if err != nil {
+		// The URL would otherwise be part of the error, leaking the token in the logs.
+		if urlErr, ok := err.(*url.Error); ok {
+			return fmt.Errorf("Telegram %s: %w", method, urlErr.Err)
+		}
+		return err
+	}
+	defer response.Body.Close()
+	var envelope telegramResponse
+	if err := json.NewDecoder(response.Body).Decode(&envelope); err != nil {
+		return fmt.Errorf("Telegram %s: %s: %w", method, response.Status, err)
+	}
+	if !envelope.OK {
+		return telegramError{method, envelope.ErrorCode, envelope.Description}
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(envelope.Result, result)
+}
@@ -0,0 +1,106 @@
+package sininen
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Chapter is a titled part of a video, as split by its author, e.g. in the description of a YouTube video.
+type Chapter struct {
+	Title string
+	Start time.Duration
+	End   time.Duration
+}
+
+// ChapterAt returns the chapter containing a moment of a video, the chapters being sorted by start time.
+func ChapterAt(chapters []Chapter, at time.Duration) (Chapter, bool) {
+	for _, chapter := range chapters {
+		if chapter.Start <= at && at < chapter.End {
+			return chapter, true
+		}
+	}
+	return Chapter{}, false
+}
+
+// TagChapters sets the Chapter of the hits of search results to the title of the chapter their start falls in, the
+// chapters of each video being given by chapters, which returns none when they are unknown.
+func (srs SearchResultSequence) TagChapters(chapters func(id string) []Chapter) {
+	for i := range srs {
+		videoChapters := chapters(srs[i].ID)
+		if len(videoChapters) == 0 {
+			continue
+		}
+		for j := range srs[i].Segments {
+			segment := &srs[i].Segments[j]
+			if chapter, ok := ChapterAt(videoChapters, segment.StartTime); ok {
+				segment.Chapter = chapter.Title
+			}
+		}
+	}
+}
+
+// TagSegmentChapters is TagChapters for scored segments, e.g. to only tag the displayed ones.
+func TagSegmentChapters(segments []ScoredSegment, chapters func(id string) []Chapter) {
+	for i := range segments {
+		if chapter, ok := ChapterAt(chapters(segments[i].ID), segments[i].StartTime); ok {
+			segments[i].Chapter = chapter.Title
+		}
+	}
+}
+
+// ReadChapters returns the chapters found in the metadata file of a video in a folder, see ReadInfoFile, none being
+// returned when the file does not exist or is malformed.
+func ReadChapters(folder, id string) []Chapter {
+	filename := filepath.Join(folder, id+".info.json")
+	if _, err := os.Stat(filename); err != nil {
+		return nil // Metadata is optional.
+	}
+	metadata, err := ReadInfoFile(filename)
+	if err != nil {
+		Log.Warnf("ignoring the chapters of %s: %v", id, err)
+		return nil
+	}
+	return metadata.Chapters
+}
+
+// ChapterCache memoizes ReadChapters, the metadata file of a video being parsed again only once modified.
+// It is safe for concurrent use.
+type ChapterCache struct {
+	mutex   sync.Mutex
+	entries map[string]cachedChapters // By metadata filename.
+}
+
+// cachedChapters are the chapters read from a metadata file, along with its state when read.
+type cachedChapters struct {
+	modified time.Time
+	size     int64
+	chapters []Chapter
+}
+
+// NewChapterCache returns an empty cache.
+func NewChapterCache() *ChapterCache {
+	return &ChapterCache{entries: map[string]cachedChapters{}}
+}
+
+// Chapters returns the chapters of a video in a folder like ReadChapters, without parsing its metadata file again when
+// it was not modified since the last call.
+func (cc *ChapterCache) Chapters(folder, id string) []Chapter {
+	filename := filepath.Join(folder, id+".info.json")
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil // Metadata is optional.
+	}
+	cc.mutex.Lock()
+	cached, ok := cc.entries[filename]
+	cc.mutex.Unlock()
+	if ok && cached.modified.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cached.chapters
+	}
+	chapters := ReadChapters(folder, id) // Outside the lock, the metadata files weighing up to megabytes.
+	cc.mutex.Lock()
+	defer cc.mutex.Unlock()
+	cc.entries[filename] = cachedChapters{info.ModTime(), info.Size(), chapters}
+	return chapters
+}
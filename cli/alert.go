@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mooss/sininen"
+)
+
+// alertMatch is a segment of a newly indexed video matching a saved search with an alert, recorded in the alerts file
+// as a JSON line.
+type alertMatch struct {
+	Time    time.Time             `json:"time"`
+	Alert   string                `json:"alert"` // Name of the saved search.
+	Channel string                `json:"channel"`
+	Lang    string                `json:"lang"`
+	Segment sininen.ScoredSegment `json:"segment"`
+}
+
+// alertsPath returns the location of the alerts file.
+func alertsPath() (string, error) {
+	if defaults.AlertsFile != "" {
+		return defaults.AlertsFile, nil
+	}
+	return configFile("alerts.jsonl")
+}
+
+// involves tells whether a saved search covers the given channel.
+func (search savedSearch) involves(channelName string) bool {
+	for _, name := range search.Channels {
+		if name == channelName {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAlerts searches the videos just added to the index of a channel for the saved searches with an alert, and
+// appends their matching segments to the alerts file.
+// Failing to do so is only reported, so that the indexing goes on.
+func checkAlerts(backend sininen.Backend, channelName, lang string, ids []string) {
+	if len(ids) == 0 {
+		return
+	}
+	nb, err := loadNotebook()
+	if err != nil {
+		sininen.Log.Warnf("loading the alerts: %v", err)
+		return
+	}
+	rank, err := sininen.NamedRanking(defaults.Ranking)
+	if err != nil {
+		sininen.Log.Warnf("checking the alerts: %v", err)
+		return
+	}
+	matches := []alertMatch{}
+	now := time.Now()
+	for _, search := range nb.Searches {
+		if !search.Alert || !search.involves(channelName) {
+			continue
+		}
+		videos, err := backend.SearchVideos(search.Query, ids, sininen.AssemblyOptions{})
+		if err != nil {
+			sininen.Log.Warnf("checking the alert %s: %v", search.Name, err)
+			continue
+		}
+		for _, segment := range videos.RankedSegments(rank) {
+			matches = append(matches, alertMatch{now, search.Name, channelName, lang, segment})
+		}
+	}
+	if len(matches) == 0 {
+		return
+	}
+	if err := appendAlertMatches(matches); err != nil {
+		sininen.Log.Warnf("recording the matches of the alerts: %v", err)
+		return
+	}
+	sininen.Log.Infof("%d new segments of %s match the alerts", len(matches), channelName)
+}
+
+func appendAlertMatches(matches []alertMatch) error {
+	filename, err := alertsPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	for _, match := range matches {
+		if err := sininen.WriteJSON(file, match); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	return file.Close()
+}
+
+// readAlertMatches returns the recorded matches of an alert, from the most recent to the oldest, at most limit of them.
+// Malformed lines are skipped with a warning.
+func readAlertMatches(name string, limit int) ([]alertMatch, error) {
+	filename, err := alertsPath()
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return []alertMatch{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	matches := []alertMatch{}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		var match alertMatch
+		if err := json.Unmarshal(scanner.Bytes(), &match); err != nil {
+			sininen.Log.Warnf("skipping line %d of %s: %v", line, filename, err)
+			continue
+		}
+		if match.Alert == name {
+			matches = append(matches, match)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	result := make([]alertMatch, 0, limit)
+	for i := len(matches) - 1; i >= 0 && len(result) < limit; i-- {
+		result = append(result, matches[i])
+	}
+	return result, nil
+}
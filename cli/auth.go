@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// apiKey is a key accepted by the server, along with its quotas.
+type apiKey struct {
+	Name       string `toml:"name"` // Identifies the key in the logs and the metrics, instead of the key itself.
+	Key        string `toml:"key"`
+	Rate       int    `toml:"rate"`        // Maximum number of requests per minute, unlimited when 0.
+	MaxResults int    `toml:"max_results"` // Maximum number of segments or terms of a response, unlimited when 0.
+}
+
+// errUnauthenticated is returned for the requests without a known API key.
+var errUnauthenticated = errors.New("missing or unknown API key")
+
+// authentications counts the requests by API key and by result.
+var authentications = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "sininen",
+	Subsystem: "server",
+	Name:      "authentications_total",
+	Help:      "Requests by API key name, empty for the unknown keys, and by result (accepted, unknown or throttled).",
+}, []string{"key", "result"})
+
+// keyUsage is an API key along with the requests made with it.
+type keyUsage struct {
+	apiKey
+	bucket *tokenBucket
+}
+
+// authenticator checks the API keys of the requests and enforces their rates.
+type authenticator struct {
+	mutex sync.Mutex // Guards the usages.
+	keys  []*keyUsage
+}
+
+// newAuthenticator returns an authenticator accepting the given keys, or nil when there are none, meaning that the
+// server is open to everyone.
+func newAuthenticator(keys []apiKey) (*authenticator, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	result := &authenticator{}
+	seen := map[string]bool{}
+	for _, key := range keys {
+		switch {
+		case key.Name == "" || key.Key == "":
+			return nil, fmt.Errorf("API keys: every key needs a name and a key")
+		case seen[key.Name]:
+			return nil, fmt.Errorf("API keys: several keys are named %s", key.Name)
+		case key.Rate < 0 || key.MaxResults < 0:
+			return nil, fmt.Errorf("API keys: the quotas of %s cannot be negative", key.Name)
+		}
+		seen[key.Name] = true
+		result.keys = append(result.keys, &keyUsage{key, newTokenBucket(key.Rate, time.Now())})
+	}
+	return result, nil
+}
+
+// authenticate returns the API key matching a secret, counting a request against its rate.
+func (a *authenticator) authenticate(secret string, now time.Time) (apiKey, error) {
+	var usage *keyUsage
+	for _, candidate := range a.keys {
+		if subtle.ConstantTimeCompare([]byte(candidate.Key), []byte(secret)) == 1 {
+			usage = candidate // Not breaking the loop, so that the time taken does not tell which key matched.
+		}
+	}
+	if usage == nil {
+		authentications.WithLabelValues("", "unknown").Inc()
+		return apiKey{}, errUnauthenticated
+	}
+	if err := a.take(usage, now); err != nil {
+		return apiKey{}, err
+	}
+	return usage.apiKey, nil
+}
+
+// charge counts a search made within an authenticated request against the rate of its API key, given by name.
+func (a *authenticator) charge(name string, now time.Time) error {
+	for _, usage := range a.keys {
+		if usage.Name == name {
+			return a.take(usage, now)
+		}
+	}
+	return errUnauthenticated
+}
+
+// take counts a request against the rate of a key.
+func (a *authenticator) take(usage *keyUsage, now time.Time) error {
+	if usage.Rate == 0 {
+		authentications.WithLabelValues(usage.Name, "accepted").Inc()
+		return nil
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if wait, ok := usage.bucket.take(now); !ok {
+		authentications.WithLabelValues(usage.Name, "throttled").Inc()
+		return quotaError{"API key " + usage.Name, usage.Rate, wait}
+	}
+	authentications.WithLabelValues(usage.Name, "accepted").Inc()
+	return nil
+}
+
+// apiKeyParameter is the query parameter giving the API key, for the clients that cannot set headers, e.g. feed readers
+// and the WebSockets of browsers.
+const apiKeyParameter = "api_key"
+
+// redactedURL returns a URL with the value of its API key parameter hidden, e.g. to be logged.
+func redactedURL(location *url.URL) string {
+	parameters := location.Query()
+	if !parameters.Has(apiKeyParameter) {
+		return location.String()
+	}
+	parameters.Set(apiKeyParameter, "redacted")
+	redacted := *location
+	redacted.RawQuery = parameters.Encode()
+	return redacted.String()
+}
+
+// bearerSecret returns the secret of an Authorization header of the Bearer scheme, or the value itself otherwise.
+func bearerSecret(authorization string) string {
+	if fields := strings.Fields(authorization); len(fields) == 2 && strings.EqualFold(fields[0], "bearer") {
+		return fields[1]
+	}
+	return authorization
+}
+
+// apiKeyContext is the key of the API key of a request in its context.
+type apiKeyContext struct{}
+
+// requestKey returns the API key of the request of a context, false when the server requires none.
+func requestKey(ctx context.Context) (apiKey, bool) {
+	key, ok := ctx.Value(apiKeyContext{}).(apiKey)
+	return key, ok
+}
+
+// authorized restricts a handler to the requests giving a known API key, in a Bearer Authorization header, an
+// X-API-Key header or the api_key parameter, which is removed from the query of the request.
+// The key is added to the context of the request, see requestKey.
+func (s *server) authorized(handler func(w http.ResponseWriter, r *http.Request) error) func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if s.auth == nil {
+			return handler(w, r)
+		}
+		parameters := r.URL.Query()
+		secret := parameters.Get(apiKeyParameter)
+		if header := r.Header.Get("X-API-Key"); header != "" {
+			secret = header
+		}
+		if header := r.Header.Get("Authorization"); header != "" {
+			secret = bearerSecret(header)
+		}
+		key, err := s.auth.authenticate(secret, time.Now())
+		if errors.Is(err, errUnauthenticated) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="sininen"`)
+			return err
+		} else if err != nil {
+			return retryLater(w, err)
+		}
+
+		r = r.WithContext(context.WithValue(r.Context(), apiKeyContext{}, key))
+		if parameters.Has(apiKeyParameter) {
+			parameters.Del(apiKeyParameter)
+			withoutKey := *r.URL
+			withoutKey.RawQuery = parameters.Encode()
+			r.URL = &withoutKey
+		}
+		return handler(w, r)
+	}
+}
+
+// chargeSearch counts a search made within a request whose context is given, e.g. a query of a live search or a search
+// field of a GraphQL request, against the rates of its address and of its API key, as if it were a request of its own.
+func (s *server) chargeSearch(ctx context.Context) error {
+	if address, ok := ctx.Value(addressContext{}).(string); ok && s.limits.addresses != nil {
+		if err := s.limits.addresses.allow(address, time.Now()); err != nil {
+			return err
+		}
+	}
+	if key, ok := requestKey(ctx); ok && s.auth != nil {
+		return s.auth.charge(key.Name, time.Now())
+	}
+	return nil
+}
+
+// authorizedHandler is authorized for the handlers that do not fail.
+func (s *server) authorizedHandler(handler http.Handler) func(w http.ResponseWriter, r *http.Request) error {
+	return s.authorized(func(w http.ResponseWriter, r *http.Request) error {
+		handler.ServeHTTP(w, r)
+		return nil
+	})
+}
+
+// grpcContext authenticates a gRPC call by its authorization or x-api-key metadata, adding the key to its context,
+// once checked that its address does not exceed its rate.
+func (s *server) grpcContext(ctx context.Context) (context.Context, error) {
+	if s.limits.addresses != nil {
+		if err := s.limits.addresses.allowCall(ctx, time.Now()); err != nil {
+			return nil, grpcError(err)
+		}
+	}
+	if s.auth == nil {
+		return ctx, nil
+	}
+	var secret string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("x-api-key"); len(values) > 0 {
+			secret = values[0]
+		}
+		if values := md.Get("authorization"); len(values) > 0 {
+			secret = bearerSecret(values[0])
+		}
+	}
+	key, err := s.auth.authenticate(secret, time.Now())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return context.WithValue(ctx, apiKeyContext{}, key), nil
+}
+
+// authorizedStream is a server stream whose context holds its API key.
+type authorizedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (as authorizedStream) Context() context.Context { return as.ctx }
+
+// grpcInterceptors returns the options of a gRPC server authenticating and limiting its calls like the HTTP requests.
+func (s *server) grpcInterceptors() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.UnaryInterceptor(func(ctx context.Context, request interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			ctx, err := s.grpcContext(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return handler(ctx, request)
+		}),
+		grpc.StreamInterceptor(func(service interface{}, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			ctx, err := s.grpcContext(stream.Context())
+			if err != nil {
+				return err
+			}
+			return handler(service, authorizedStream{stream, ctx})
+		}),
+	}
+}
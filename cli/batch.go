@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mooss/sininen"
+)
+
+var batchCommand = &command{
+	name:        "batch",
+	arguments:   "channel-id... queries-file",
+	description: "Run the search queries of a file, one per line, and output the results of each query as a JSON line.",
+	details:     "Empty lines and lines starting with # are ignored, and the queries are read from stdin when the file is -.",
+	run:         runBatch,
+}
+
+// batchResult holds the results of one of the queries of a batch.
+type batchResult struct {
+	Query    string                  `json:"query"`
+	Segments []sininen.ScoredSegment `json:"segments"`
+}
+
+// readQueries reads the queries of a batch file, skipping empty lines and comments.
+func readQueries(r io.Reader) ([]string, error) {
+	result := []string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		result = append(result, line)
+	}
+	return result, scanner.Err()
+}
+
+func runBatch(cmd *command, args []string) {
+	flags := cmd.flagSet()
+	settings := addSearchFlags(flags)
+	cmd.parseMinArgs(flags, args, 1)
+	perhapsExit(settings.check(), exitUsage)
+
+	channelNames, err := settings.channels(flags.Args()[:flags.NArg()-1])
+	perhapsExit(err, exitUsage)
+	input := os.Stdin
+	if filename := flags.Arg(flags.NArg() - 1); filename != "-" {
+		input, err = os.Open(filename)
+		perhapsExit(err, exitUsage)
+		defer input.Close()
+	}
+	queries, err := readQueries(input)
+	perhapsExit(err, exitUsage)
+
+	index, err := settings.open(channelNames)
+	perhapsExit(err, exitIndex)
+	encoder := json.NewEncoder(os.Stdout)
+	for _, query := range queries {
+		videos, err := settings.search(index, query)
+		perhapsExit(err, exitQuery)
+		scoredSegments := settings.segments(context.Background(), videos)
+		if *settings.notes != "" {
+			perhapsExit(settings.render(os.Stdout, strings.Join(channelNames, ", "), query, scoredSegments), exitOutput)
+			continue
+		}
+		perhapsExit(encoder.Encode(batchResult{query, scoredSegments}), exitOutput)
+	}
+}
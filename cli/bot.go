@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	"github.com/mooss/sininen"
+	"github.com/mooss/sininen/bot"
+)
+
+var botCommand = &command{
+	name:        "bot",
+	arguments:   "",
+	description: "Answer the /find <channel> <query> commands of Telegram and Discord chats with links to the matching segments, until interrupted.",
+	details: "The bot runs on Telegram when -telegram-token is given, and on Discord when -discord-public-key is given, serving the " +
+		"interactions endpoint of the application at -addr, which must be reachable by Discord over HTTPS e.g. through a reverse proxy. " +
+		"With -discord-register, the /find and /help slash commands are first registered with the application ID and the bot token.\n" +
+		"The channels are searched through their bleve indexes, opened the first time they are needed.",
+	run: runBot,
+}
+
+// releasedSearcher searches indexes kept open by the server, closing it only releases them.
+type releasedSearcher struct {
+	sininen.BleveBackend
+	release func()
+}
+
+func (rs releasedSearcher) Close() error {
+	rs.release()
+	return nil
+}
+
+func runBot(cmd *command, args []string) {
+	flags := cmd.flagSet()
+	telegramToken := flags.String("telegram-token", defaults.TelegramToken, "Token of the Telegram bot, given by @BotFather.")
+	telegramAPI := flags.String("telegram-api", "", "Base URL of the Telegram Bot API, e.g. of a local Bot API server (https://api.telegram.org by default).")
+	publicKey := flags.String("discord-public-key", defaults.DiscordPublicKey, "Public key of the Discord application, in hexadecimal.")
+	addr := flags.String("addr", "localhost:8081", "Address the Discord interactions endpoint listens on.")
+	register := flags.Bool("discord-register", false, "Register the slash commands of the Discord application at start.")
+	appID := flags.String("discord-app-id", defaults.DiscordAppID, "ID of the Discord application, needed by -discord-register.")
+	discordToken := flags.String("discord-token", defaults.DiscordToken, "Token of the bot of the Discord application, needed by -discord-register.")
+	maxIndexes := flags.Int("max-open-indexes", 64, "Maximum number of indexes kept open, the least recently used one being closed to open another (unlimited when 0).")
+	limit := flags.Int("limit", 5, "Maximum number of segments of an answer.")
+	excerpts := addContextFlag(flags)
+	platform := flags.String("platform", "youtube", "Platform the links point to, either youtube, peertube, vimeo or twitch.")
+	platformBase := flags.String("platform-base", "", "Instance URL for the peertube platform (peertube_instance of the configuration by default).")
+	langs := addLangFlag(flags)
+	cmd.parseArgs(flags, args, 0)
+
+	if *telegramToken == "" && *publicKey == "" {
+		perhapsExit(fmt.Errorf("%w: give -telegram-token or -discord-public-key, or set them in the configuration file", sininen.ErrInvalidOption), exitUsage)
+	}
+	urls, err := newURLBuilder(*platform, *platformBase)
+	perhapsExit(err, exitUsage)
+	rank, err := sininen.NamedRanking(defaults.Ranking)
+	perhapsExit(err, exitUsage)
+	discord := bot.Discord{ApplicationID: *appID, Token: *discordToken}
+	if *publicKey != "" {
+		discord.PublicKey, err = bot.ParseDiscordPublicKey(*publicKey)
+		perhapsExit(err, exitUsage)
+	}
+
+	s := newServer(*maxIndexes, 0)
+	chatBot := bot.Bot{
+		Open: func(channelName string) (sininen.Searcher, error) {
+			index, release, err := s.openIndexes([]string{channelName}, langs.orDefault())
+			if err != nil {
+				return nil, err
+			}
+			return releasedSearcher{sininen.BleveBackend{Index: index}, release}, nil
+		},
+		URLs:     urls,
+		Rank:     rank,
+		Assembly: sininen.AssemblyOptions{Context: excerpts.segments, ContextDuration: excerpts.duration},
+		Limit:    *limit,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if *register {
+		perhapsExit(discord.RegisterCommands(ctx), exitUsage)
+		inform("Registered the Discord commands.\n")
+	}
+
+	// The first failure of either platform stops the other one.
+	failures := make(chan error, 2)
+	var wg sync.WaitGroup
+	if *telegramToken != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			inform("Answering on Telegram.\n")
+			if err := (bot.Telegram{Token: *telegramToken, API: *telegramAPI}).Run(ctx, chatBot); err != nil {
+				failures <- err
+				stop()
+			}
+		}()
+	}
+	if *publicKey != "" {
+		httpServer := &http.Server{Addr: *addr, Handler: discord.Handler(chatBot)}
+		go func() {
+			<-ctx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			httpServer.Shutdown(shutdownCtx)
+		}()
+		inform("Discord interactions endpoint listening on http://%s.\n", *addr)
+		if err := httpServer.ListenAndServe(); err != http.ErrServerClosed {
+			failures <- err
+			stop()
+		}
+	}
+	wg.Wait()
+	close(failures)
+	s.close()
+	perhapsExit(<-failures, exitUsage)
+}
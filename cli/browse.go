@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mooss/sininen"
+	"github.com/muesli/reflow/truncate"
+	"github.com/muesli/reflow/wordwrap"
+)
+
+var browseCommand = &command{
+	name:        "browse",
+	arguments:   "channel-id... search-query",
+	description: "Browse the search results in a terminal interface, opening the matching moments on demand.",
+	details:     "The query is read from stdin when it is -.",
+	run:         runBrowse,
+}
+
+const browseKeys = "↑/k ↓/j move • pgup/pgdown scroll • enter/o open • q quit"
+
+// browser is the terminal interface listing scored segments.
+type browser struct {
+	segments []sininen.ScoredSegment
+	urls     sininen.URLBuilder
+	platform string
+	player   string
+	cursor   int
+	offset   int // Index of the first segment displayed in the list.
+	width    int
+	height   int
+	status   string
+}
+
+func (b *browser) Init() tea.Cmd { return nil }
+
+// listHeight returns the number of segments displayed at once, leaving room for the details of the selected one.
+func (b *browser) listHeight() int {
+	result := b.height / 2
+	if result < 1 {
+		return 1
+	}
+	return result
+}
+
+// move moves the cursor by delta segments, scrolling the list to keep it visible.
+func (b *browser) move(delta int) {
+	b.cursor += delta
+	if b.cursor >= len(b.segments) {
+		b.cursor = len(b.segments) - 1
+	}
+	if b.cursor < 0 {
+		b.cursor = 0
+	}
+	if b.cursor < b.offset {
+		b.offset = b.cursor
+	}
+	if b.cursor >= b.offset+b.listHeight() {
+		b.offset = b.cursor - b.listHeight() + 1
+	}
+}
+
+func (b *browser) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		b.width, b.height = msg.Width, msg.Height
+		b.move(0)
+	case tea.KeyMsg:
+		b.status = ""
+		switch msg.String() {
+		case "q", "esc", "ctrl+c":
+			return b, tea.Quit
+		case "up", "k":
+			b.move(-1)
+		case "down", "j":
+			b.move(1)
+		case "pgup":
+			b.move(-b.listHeight())
+		case "pgdown":
+			b.move(b.listHeight())
+		case "home", "g":
+			b.move(-len(b.segments))
+		case "end", "G":
+			b.move(len(b.segments))
+		case "enter", "o":
+			b.open()
+		}
+	}
+	return b, nil
+}
+
+// open opens the selected segment with the player.
+func (b *browser) open() {
+	if len(b.segments) == 0 {
+		return
+	}
+	opened, err := play(b.player, b.platform, b.urls, b.segments[b.cursor])
+	if err != nil {
+		b.status = err.Error()
+		return
+	}
+	b.status = "Opened " + opened
+}
+
+func (b *browser) View() string {
+	if len(b.segments) == 0 {
+		return "No matching segments.\n\n" + browseKeys + "\n"
+	}
+
+	var view strings.Builder
+	end := b.offset + b.listHeight()
+	if end > len(b.segments) {
+		end = len(b.segments)
+	}
+	for i := b.offset; i < end; i++ {
+		segment := b.segments[i]
+		cursor := "  "
+		if i == b.cursor {
+			cursor = "> "
+		}
+		line := fmt.Sprintf("%s%s %s %v %s", cursor, sininen.FormatTimestamp(segment.StartTime),
+			segment.DisplayName(), segment.SortedTerms, segment.Text)
+		view.WriteString(truncate.String(line, uint(b.width)))
+		view.WriteByte('\n')
+	}
+	view.WriteString(strings.Repeat("─", b.width))
+	view.WriteByte('\n')
+
+	selected := b.segments[b.cursor]
+	fmt.Fprintf(&view, "%s (%d/%d, score=%.3f)\n\n", selected.DisplayName(), b.cursor+1, len(b.segments), selected.Score)
+	var text strings.Builder
+	for _, excerpt := range selected.Before {
+		fmt.Fprintf(&text, "%s %s\n", sininen.FormatTimestamp(excerpt.StartTime), excerpt.Text)
+	}
+	for _, fragment := range selected.Fragments() {
+		if fragment.Matched {
+			text.WriteString("\x1b[7m" + fragment.Text + "\x1b[27m")
+		} else {
+			text.WriteString(fragment.Text)
+		}
+	}
+	text.WriteByte('\n')
+	for _, excerpt := range selected.After {
+		fmt.Fprintf(&text, "%s %s\n", sininen.FormatTimestamp(excerpt.StartTime), excerpt.Text)
+	}
+	view.WriteString(wordwrap.String(text.String(), b.width))
+	view.WriteString("\n" + browseKeys + "\n")
+	if b.status != "" {
+		view.WriteString(truncate.String(b.status, uint(b.width)) + "\n")
+	}
+	return view.String()
+}
+
+func runBrowse(cmd *command, args []string) {
+	flags := cmd.flagSet()
+	settings := addSearchFlags(flags)
+	cmd.parseMinArgs(flags, args, 1)
+	perhapsExit(settings.check(), exitUsage)
+
+	channelNames, err := settings.channels(flags.Args()[:flags.NArg()-1])
+	perhapsExit(err, exitUsage)
+	index, err := settings.open(channelNames)
+	perhapsExit(err, exitIndex)
+	query, err := readQuery(flags.Arg(flags.NArg() - 1))
+	perhapsExit(err, exitUsage)
+	videos, err := settings.search(index, query)
+	perhapsExit(err, exitQuery)
+	recordSearch(channelNames, query, videos)
+
+	model := &browser{segments: settings.segments(context.Background(), videos), urls: settings.urls, platform: *settings.platform, player: *settings.player, width: 80, height: 24}
+	options := []tea.ProgramOption{tea.WithAltScreen()}
+	if !isTerminal(os.Stdin) {
+		options = append(options, tea.WithInputTTY()) // The keys are read from the terminal when stdin is used for the query.
+	}
+	perhapsExit(tea.NewProgram(model, options...).Start(), exitOutput)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+)
+
+// lruCache holds at most capacity values, evicting the least recently used one to make room for a new value.
+// It is safe for concurrent use.
+type lruCache struct {
+	capacity int                                 // Unlimited when not positive.
+	onEvict  func(key string, value interface{}) // Called without the lock held on every value leaving the cache, unless nil.
+
+	mutex   sync.Mutex
+	order   *list.List               // Of *lruEntry, from the most to the least recently used.
+	entries map[string]*list.Element // By key.
+}
+
+type lruEntry struct {
+	key   string
+	value interface{}
+}
+
+// newLRUCache returns an empty cache.
+func newLRUCache(capacity int, onEvict func(key string, value interface{})) *lruCache {
+	return &lruCache{capacity: capacity, onEvict: onEvict, order: list.New(), entries: map[string]*list.Element{}}
+}
+
+// get returns the value of a key, marking it as the most recently used.
+func (lc *lruCache) get(key string) (interface{}, bool) {
+	lc.mutex.Lock()
+	defer lc.mutex.Unlock()
+	element, ok := lc.entries[key]
+	if !ok {
+		return nil, false
+	}
+	lc.order.MoveToFront(element)
+	return element.Value.(*lruEntry).value, true
+}
+
+// peek returns the value of a key without marking it as used.
+func (lc *lruCache) peek(key string) (interface{}, bool) {
+	lc.mutex.Lock()
+	defer lc.mutex.Unlock()
+	element, ok := lc.entries[key]
+	if !ok {
+		return nil, false
+	}
+	return element.Value.(*lruEntry).value, true
+}
+
+// full tells whether adding a new key would evict a value.
+func (lc *lruCache) full() bool {
+	lc.mutex.Lock()
+	defer lc.mutex.Unlock()
+	return lc.capacity > 0 && lc.order.Len() >= lc.capacity
+}
+
+// keys returns the keys of the values, from the most to the least recently used, without marking them as used.
+func (lc *lruCache) keys() []string {
+	lc.mutex.Lock()
+	defer lc.mutex.Unlock()
+	result := make([]string, 0, len(lc.entries))
+	for element := lc.order.Front(); element != nil; element = element.Next() {
+		result = append(result, element.Value.(*lruEntry).key)
+	}
+	return result
+}
+
+// add sets the value of a key as the most recently used one, evicting the previous value of the key if any and the
+// least recently used values beyond the capacity.
+func (lc *lruCache) add(key string, value interface{}) {
+	lc.mutex.Lock()
+	evicted := []*lruEntry{}
+	if element, ok := lc.entries[key]; ok {
+		evicted = append(evicted, lc.unlink(element))
+	}
+	lc.entries[key] = lc.order.PushFront(&lruEntry{key, value})
+	for lc.capacity > 0 && lc.order.Len() > lc.capacity {
+		evicted = append(evicted, lc.unlink(lc.order.Back()))
+	}
+	lc.mutex.Unlock()
+	lc.evict(evicted)
+}
+
+// removeIf removes the values for which remove returns true, e.g. the ones made stale by a reindexing.
+func (lc *lruCache) removeIf(remove func(key string, value interface{}) bool) {
+	lc.mutex.Lock()
+	evicted := []*lruEntry{}
+	for element := lc.order.Front(); element != nil; {
+		next := element.Next()
+		if entry := element.Value.(*lruEntry); remove(entry.key, entry.value) {
+			evicted = append(evicted, lc.unlink(element))
+		}
+		element = next
+	}
+	lc.mutex.Unlock()
+	lc.evict(evicted)
+}
+
+// purge removes every value.
+func (lc *lruCache) purge() {
+	lc.removeIf(func(string, interface{}) bool { return true })
+}
+
+// unlink removes an element from the cache, the lock being held.
+func (lc *lruCache) unlink(element *list.Element) *lruEntry {
+	entry := lc.order.Remove(element).(*lruEntry)
+	delete(lc.entries, entry.key)
+	return entry
+}
+
+// evict calls onEvict on the removed entries.
+func (lc *lruCache) evict(entries []*lruEntry) {
+	if lc.onEvict == nil {
+		return
+	}
+	for _, entry := range entries {
+		lc.onEvict(entry.key, entry.value)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// highlightANSI displays text in bold red on terminals.
+func highlightANSI(text string) string {
+	return "\x1b[1;31m" + text + "\x1b[0m"
+}
+
+// isTerminal tells whether a file is a terminal rather than a pipe or a regular file.
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// colorHighlight returns the function highlighting the matched terms according to the color mode, either auto, always or never.
+// In auto mode, colors are used when writing to a terminal and the NO_COLOR environment variable is not set.
+func colorHighlight(mode string) (func(string) string, error) {
+	switch mode {
+	case "always":
+		return highlightANSI, nil
+	case "never":
+		return nil, nil
+	case "auto":
+		if _, noColor := os.LookupEnv("NO_COLOR"); noColor || !isTerminal(os.Stdout) {
+			return nil, nil
+		}
+		return highlightANSI, nil
+	}
+	return nil, fmt.Errorf("unknown color mode %q", mode)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// config holds the defaults of the CLI, read from the configuration file and overridden by the environment variables and then the flags.
+type config struct {
+	SubtitlesRoot string   `toml:"subtitles_root"` // Folder containing the subtitles folder of each channel.
+	IndexRoot     string   `toml:"index_root"`     // Folder containing the index folder of each channel, SubtitlesRoot when empty.
+	Languages     []string `toml:"languages"`
+	Format        string   `toml:"format"`
+	Ranking       string   `toml:"ranking"`
+	Backend       string   `toml:"backend"`       // Storage of the indexes, either bleve or sqlite.
+	Parallelism   int      `toml:"parallelism"`   // Indexes searched at once, the number of CPUs when not positive.
+	HalfLife      string   `toml:"half_life"`     // Parsed by time.ParseDuration.
+	HistoryFile   string   `toml:"history_file"`  // File where the searches are recorded, history.jsonl in the configuration folder when empty.
+	NotebookFile  string   `toml:"notebook_file"` // File where the saved searches and bookmarks are kept, notebook.json in the configuration folder when empty.
+	AlertsFile    string   `toml:"alerts_file"`   // File where the matches of the alerts are recorded, alerts.jsonl in the configuration folder when empty.
+	OEmbedFile    string   `toml:"oembed_file"`   // File caching the videos resolved by search -oembed, oembed.json in the configuration folder when empty.
+	YouTubeAPIKey string   `toml:"youtube_api_key"`
+	ElasticURL    string   `toml:"elastic_url"` // Elasticsearch or OpenSearch cluster of the elastic command.
+	ElasticUser   string   `toml:"elastic_user"`
+	ElasticPass   string   `toml:"elastic_password"`
+	WhisperModel  string   `toml:"whisper_model"`   // ggml model of whisper.cpp used by the whisper command.
+	WhisperAPIURL string   `toml:"whisper_api_url"` // OpenAI-compatible transcriptions endpoint used by whisper -api.
+	WhisperAPIKey string   `toml:"whisper_api_key"`
+	TranslateURL  string   `toml:"translate_url"` // LibreTranslate server of the translate command.
+	TranslateKey  string   `toml:"translate_key"`
+	PeerTube      string   `toml:"peertube_instance"` // Instance of the peertube command and platform when none is given.
+	SyncChannels  []string `toml:"sync_channels"`     // Channels of the sync command when none is given.
+	SyncSchedule  string   `toml:"sync_schedule"`     // Parsed by sininen.ParseSchedule.
+	Webhooks      []string `toml:"webhooks"`          // URLs notified by the watch and sync commands of the newly indexed videos.
+	APIKeys       []apiKey `toml:"api_keys"`          // Keys accepted by the serve command, which requires one of them when there are any.
+
+	TelegramToken    string `toml:"telegram_token"`     // Token of the Telegram bot of the bot command.
+	DiscordPublicKey string `toml:"discord_public_key"` // Public key of the Discord application of the bot command.
+	DiscordAppID     string `toml:"discord_app_id"`
+	DiscordToken     string `toml:"discord_token"`
+
+	EmbeddingsProvider string `toml:"embeddings_provider"` // Provider of the embeddings of the semantic command, ollama or openai.
+	EmbeddingsURL      string `toml:"embeddings_url"`
+	EmbeddingsKey      string `toml:"embeddings_key"`
+	EmbeddingsModel    string `toml:"embeddings_model"`
+
+	halfLife time.Duration
+}
+
+var defaults = config{
+	SubtitlesRoot: "subtitles",
+	Languages:     []string{"en"},
+	Format:        "urls",
+	Ranking:       "distinct",
+	Backend:       "bleve",
+	ElasticURL:    "http://localhost:9200",
+	SyncSchedule:  "@every 6h",
+
+	EmbeddingsProvider: "ollama",
+}
+
+// configFile returns the location of a file of the sininen folder of the user configuration folder.
+func configFile(name string) (string, error) {
+	folder, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(folder, "sininen", name), nil
+}
+
+// configPath returns the location of the configuration file, i.e. sininen/config.toml in the user configuration folder.
+func configPath() (string, error) {
+	return configFile("config.toml")
+}
+
+// environment lists the environment variables overriding the defaults, taking precedence over the configuration file.
+var environment = []struct {
+	name  string
+	value *string
+}{
+	{"SININEN_SUBTITLES_ROOT", &defaults.SubtitlesRoot},
+	{"SININEN_INDEX_ROOT", &defaults.IndexRoot},
+	{"SININEN_FORMAT", &defaults.Format},
+	{"SININEN_RANKING", &defaults.Ranking},
+	{"SININEN_BACKEND", &defaults.Backend},
+	{"SININEN_HALF_LIFE", &defaults.HalfLife},
+	{"SININEN_HISTORY_FILE", &defaults.HistoryFile},
+	{"SININEN_NOTEBOOK_FILE", &defaults.NotebookFile},
+	{"SININEN_ALERTS_FILE", &defaults.AlertsFile},
+	{"SININEN_OEMBED_FILE", &defaults.OEmbedFile},
+	{"SININEN_YOUTUBE_API_KEY", &defaults.YouTubeAPIKey},
+	{"SININEN_ELASTIC_URL", &defaults.ElasticURL},
+	{"SININEN_ELASTIC_USER", &defaults.ElasticUser},
+	{"SININEN_ELASTIC_PASSWORD", &defaults.ElasticPass},
+	{"SININEN_WHISPER_MODEL", &defaults.WhisperModel},
+	{"SININEN_WHISPER_API_URL", &defaults.WhisperAPIURL},
+	{"SININEN_WHISPER_API_KEY", &defaults.WhisperAPIKey},
+	{"SININEN_TRANSLATE_URL", &defaults.TranslateURL},
+	{"SININEN_TRANSLATE_KEY", &defaults.TranslateKey},
+	{"SININEN_PEERTUBE_INSTANCE", &defaults.PeerTube},
+	{"SININEN_SYNC_SCHEDULE", &defaults.SyncSchedule},
+	{"SININEN_TELEGRAM_TOKEN", &defaults.TelegramToken},
+	{"SININEN_DISCORD_PUBLIC_KEY", &defaults.DiscordPublicKey},
+	{"SININEN_DISCORD_APP_ID", &defaults.DiscordAppID},
+	{"SININEN_DISCORD_TOKEN", &defaults.DiscordToken},
+	{"SININEN_EMBEDDINGS_PROVIDER", &defaults.EmbeddingsProvider},
+	{"SININEN_EMBEDDINGS_URL", &defaults.EmbeddingsURL},
+	{"SININEN_EMBEDDINGS_KEY", &defaults.EmbeddingsKey},
+	{"SININEN_EMBEDDINGS_MODEL", &defaults.EmbeddingsModel},
+}
+
+// languagesVariable is the environment variable overriding the default languages, separated by commas.
+const languagesVariable = "SININEN_LANG"
+
+// loadConfig reads the configuration file into defaults, if it exists, and then the environment variables.
+func loadConfig() error {
+	if err := loadConfigFile(); err != nil {
+		return err
+	}
+	for _, variable := range environment {
+		if env, ok := os.LookupEnv(variable.name); ok {
+			*variable.value = env
+		}
+	}
+	if env, ok := os.LookupEnv(languagesVariable); ok {
+		defaults.Languages = strings.Split(env, ",")
+	}
+
+	if defaults.HalfLife != "" {
+		var err error
+		if defaults.halfLife, err = time.ParseDuration(defaults.HalfLife); err != nil {
+			return fmt.Errorf("half life: %w", err)
+		}
+	}
+	return nil
+}
+
+// loadConfigFile reads the configuration file into defaults, if it exists.
+func loadConfigFile() error {
+	filename, err := configPath()
+	if err != nil {
+		return nil // No configuration folder means no configuration file.
+	}
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		return nil
+	}
+	metadata, err := toml.DecodeFile(filename, &defaults)
+	if err != nil {
+		return err
+	}
+	if undecoded := metadata.Undecoded(); len(undecoded) > 0 {
+		keys := make([]string, 0, len(undecoded))
+		for _, key := range undecoded {
+			keys = append(keys, key.String())
+		}
+		return fmt.Errorf("%s: unknown configuration keys %s", filename, strings.Join(keys, ", "))
+	}
+	return nil
+}
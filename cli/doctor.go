@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mooss/sininen"
+)
+
+var doctorCommand = &command{
+	name:        "doctor",
+	arguments:   "channel-id",
+	description: "Check that the index of a channel is usable and consistent with its subtitles, and repair it with -repair.",
+	details:     "The exit code is 3 if a problem was found and not repaired.",
+	run:         runDoctor,
+}
+
+// printHealth displays the problems of an index, returning whether it is healthy.
+func printHealth(lang string, health sininen.IndexHealth) bool {
+	if health.Healthy() {
+		fmt.Printf("%s: healthy\n", lang)
+		return true
+	}
+	if health.OpenError != nil {
+		fmt.Printf("%s: cannot be opened: %v\n", lang, health.OpenError)
+	}
+	if len(health.MissingFields) > 0 {
+		fmt.Printf("%s: created by an older version, missing fields %s\n", lang, strings.Join(health.MissingFields, ", "))
+	}
+	problems := []struct {
+		description string
+		ids         []string
+	}{
+		{"not indexed", health.Added},
+		{"modified since indexed", health.Updated},
+		{"orphaned, their subtitles no longer exist", health.Removed},
+	}
+	for _, problem := range problems {
+		if len(problem.ids) > 0 {
+			fmt.Printf("%s: %d videos %s: %s\n", lang, len(problem.ids), problem.description, strings.Join(problem.ids, " "))
+		}
+	}
+	return false
+}
+
+func runDoctor(cmd *command, args []string) {
+	flags := cmd.flagSet()
+	repair := flags.Bool("repair", false, "Rebuild the indexes that cannot be opened or lack fields, and update the others.")
+	ngramMode := flags.String("ngram", "", "Index n-grams of the words when rebuilding, either edge (prefixes) or full.")
+	langs := addLangFlag(flags)
+	cmd.parseArgs(flags, args, 1)
+
+	subtitlesFolder := channelFolder(flags.Arg(0))
+	indexFolder, err := indexFolder(flags.Arg(0))
+	perhapsExit(err, exitIndex)
+	healthy := true
+	for _, lang := range langs.orDefault() {
+		var health sininen.IndexHealth
+		if *repair {
+			health, err = sininen.RepairSubtitleIndex(subtitlesFolder, lang, sininen.IndexOptions{
+				Folder:   indexFolder,
+				NGram:    sininen.NGramMode(*ngramMode),
+				Progress: newProgress("Repairing " + lang),
+			})
+		} else {
+			health, err = sininen.CheckSubtitleIndex(subtitlesFolder, indexFolder, lang)
+		}
+		perhapsExit(err, exitIndex)
+		if !printHealth(lang, health) {
+			if *repair {
+				fmt.Printf("%s: repaired\n", lang)
+			} else {
+				healthy = false
+			}
+		}
+	}
+	if !healthy {
+		inform("Run with -repair to fix the problems.\n")
+		os.Exit(exitIndex)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+
+	"github.com/mooss/sininen"
+)
+
+var downloadCommand = &command{
+	name:        "download",
+	arguments:   "channel-name",
+	description: "Download the subtitles and the metadata of every video of a YouTube channel with youtube-dl, or with yt-dlp.",
+	details: "The channel is given by its name, its @handle or its ID, or by the login of the streamer with -twitch. The subtitles already downloaded are skipped.\n" +
+		"-native needs neither youtube-dl nor yt-dlp, but only downloads the subtitles uploaded for the few dozens most recent videos, without their metadata.",
+	run: runDownload,
+}
+
+func runDownload(cmd *command, args []string) {
+	flags := cmd.flagSet()
+	withYoutubeDL := flags.Bool("youtube-dl", false, "Download the subtitles, the automatic captions and the metadata of every video with youtube-dl, which is the default.")
+	withYTDLP := flags.Bool("yt-dlp", false, "Download the subtitles and the metadata of the videos not downloaded yet with yt-dlp, and index them.")
+	native := flags.Bool("native", false, "Only download the subtitles uploaded for the most recent videos, without youtube-dl nor yt-dlp and without the metadata.")
+	twitch := flags.Bool("twitch", false, "Download the captions of the VODs of a Twitch channel instead, with yt-dlp like -yt-dlp.")
+	autoSubs := flags.Bool("auto-subs", true, "Also download the automatic captions with -youtube-dl and -yt-dlp.")
+	var langs languages
+	flags.Var(&langs, "lang", "Language of the subtitles to download, can be repeated to download several languages (all of them by default).")
+	cmd.parseArgs(flags, args, 1)
+
+	channelName := flags.Arg(0)
+	destFolder := filepath.Join(defaults.SubtitlesRoot, channelName)
+	if *withYoutubeDL && (*withYTDLP || *twitch) {
+		perhapsExit(fmt.Errorf("%w: -youtube-dl is incompatible with -yt-dlp and -twitch", sininen.ErrInvalidOption), exitUsage)
+	}
+	if *native && (*withYoutubeDL || *withYTDLP || *twitch) {
+		perhapsExit(fmt.Errorf("%w: -native is incompatible with -youtube-dl, -yt-dlp and -twitch", sininen.ErrInvalidOption), exitUsage)
+	}
+	if !*native && !*withYTDLP && !*twitch {
+		runYoutubeDL(channelName, destFolder, langs, *autoSubs)
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if *twitch {
+		runYTDLP(ctx, channelName, sininen.TwitchVODsURL(channelName), destFolder, langs, *autoSubs)
+		return
+	}
+	if *withYTDLP {
+		runYTDLP(ctx, channelName, sininen.YouTubeClient{}.ChannelURL(channelName), destFolder, langs, *autoSubs)
+		return
+	}
+	written, err := sininen.YouTubeClient{}.DownloadChannelSubtitles(ctx, channelName, destFolder, langs, newProgress("Downloading"))
+	perhapsExit(err, exitDownload)
+	inform("Downloaded %d subtitles files in %s.\n", written, destFolder)
+}
+
+// runYoutubeDL downloads the subtitles and the metadata of a channel with youtube-dl.
+func runYoutubeDL(channelName, destFolder string, langs languages, autoSubs bool) {
+	perhapsExit(os.MkdirAll(destFolder, 0755), exitDownload)
+	youtubeDLArgs := []string{"--skip-download", "--write-info-json",
+		"https://www.youtube.com/c/" + channelName + "/videos", "-o", filepath.Join(destFolder, "%(id)s.%(ext)s")}
+	if len(langs) == 0 {
+		youtubeDLArgs = append(youtubeDLArgs, "--all-subs")
+	} else {
+		youtubeDLArgs = append(youtubeDLArgs, "--write-sub", "--sub-lang", langs.String())
+		if autoSubs {
+			youtubeDLArgs = append(youtubeDLArgs, "--write-auto-sub")
+		}
+	}
+	if quiet() {
+		youtubeDLArgs = append(youtubeDLArgs, "--quiet")
+	}
+	youtubeDL := exec.Command("youtube-dl", youtubeDLArgs...)
+	youtubeDL.Stdout = os.Stdout
+	youtubeDL.Stderr = os.Stderr
+	perhapsExit(youtubeDL.Run(), exitDownload)
+}
+
+// runYTDLP downloads the subtitles and the metadata of the videos of a channel, listed at the given URL, with yt-dlp,
+// and then updates the indexes of the languages of the new subtitles.
+// The downloaded videos are recorded in <channel>.archive.txt next to the subtitles folder of the channel, so that they are skipped next time.
+func runYTDLP(ctx context.Context, channelName, channelURL, destFolder string, langs languages, autoSubs bool) {
+	files, err := downloadWithYTDLP(ctx, channelName, channelURL, destFolder, langs, autoSubs)
+	perhapsExit(err, exitDownload)
+	inform("Downloaded %d subtitles files in %s.\n", len(files), destFolder)
+	indexDownloaded(channelName, destFolder, files)
+}
+
+// downloadWithYTDLP downloads the subtitles and the metadata of the videos of a channel not downloaded yet with yt-dlp,
+// and returns the subtitles files written.
+func downloadWithYTDLP(ctx context.Context, channelName, channelURL, destFolder string, langs languages, autoSubs bool) ([]string, error) {
+	if err := os.MkdirAll(destFolder, 0755); err != nil {
+		return nil, err
+	}
+	var stderr io.Writer = os.Stderr
+	if quiet() {
+		stderr = nil
+	}
+	return sininen.DownloadWithYTDLP(ctx, channelURL, destFolder, sininen.YTDLPOptions{
+		Langs:       langs,
+		AutoSubs:    autoSubs,
+		ArchiveFile: filepath.Join(defaults.SubtitlesRoot, channelName+".archive.txt"),
+		Stderr:      stderr,
+	}, func(filename string) {
+		sininen.Log.Infof("downloaded %s", filename)
+	})
+}
+
+// indexDownloaded updates the indexes of the languages of freshly downloaded subtitles files.
+func indexDownloaded(channelName, destFolder string, files []string) {
+	downloadedLangs := map[string]bool{}
+	for _, filename := range files {
+		downloadedLangs[sininen.SubtitlesLanguage(filename)] = true
+	}
+	for lang := range downloadedLangs {
+		if lang == "" {
+			continue
+		}
+		indexes, err := openChannelIndexes(channelName, []string{lang})
+		perhapsExit(err, exitIndex)
+		changes, err := sininen.UpdateSubtitleIndex(indexes[0], destFolder, lang, newProgress("Indexing "+lang))
+		perhapsExit(err, exitIndex)
+		perhapsExit(indexes[0].Close(), exitIndex)
+		inform("Added %d and updated %d videos in the %s index.\n", len(changes.Added), len(changes.Updated), lang)
+	}
+}
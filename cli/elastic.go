@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/mooss/sininen"
+)
+
+var elasticCommand = &command{
+	name:        "elastic",
+	arguments:   "channel-id",
+	description: "Export the indexed transcriptions of a channel to an Elasticsearch or OpenSearch index.",
+	details:     "The videos already exported are replaced. The credentials can also be given with the elastic_user and elastic_password configuration keys.",
+	run:         runElastic,
+}
+
+func runElastic(cmd *command, args []string) {
+	flags := cmd.flagSet()
+	clusterURL := flags.String("url", defaults.ElasticURL, "Base URL of the cluster.")
+	indexName := flags.String("index", "", "Name of the index receiving the transcriptions, sininen-<channel>-<lang> by default.")
+	user := flags.String("user", defaults.ElasticUser, "User of the basic authentication (none by default).")
+	batchSize := flags.Int("batch-size", 500, "Number of transcriptions sent per bulk request.")
+	langs := addLangFlag(flags)
+	cmd.parseArgs(flags, args, 1)
+
+	channelName := flags.Arg(0)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	for _, lang := range langs.orDefault() {
+		indexes, err := openChannelIndexes(channelName, []string{lang})
+		perhapsExit(err, exitIndex)
+		exporter := sininen.ElasticExporter{
+			URL:       *clusterURL,
+			Index:     *indexName,
+			Username:  *user,
+			Password:  defaults.ElasticPass,
+			BatchSize: *batchSize,
+		}
+		if exporter.Index == "" {
+			exporter.Index = strings.ToLower("sininen-" + channelName + "-" + lang) // Index names cannot contain uppercase letters.
+		}
+		perhapsExit(exporter.CreateIndex(ctx, lang), exitOutput)
+		exported, err := exporter.ExportIndex(ctx, indexes[0], newProgress("Exporting "+lang))
+		perhapsExit(err, exitOutput)
+		perhapsExit(indexes[0].Close(), exitIndex)
+		inform("Exported %d transcriptions to %s.\n", exported, exporter.Index)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/mooss/sininen"
+)
+
+// Exit codes of the CLI, documented in the README so that scripts can rely on them.
+const (
+	exitNoChannel     = 1  // The subtitles folder of the channel does not exist.
+	exitNotAFolder    = 2  // The subtitles folder of the channel is not a folder.
+	exitIndex         = 3  // The index could not be opened or created.
+	exitQuery         = 4  // The query failed.
+	exitAssembly      = 5  // The results of the query could not be assembled.
+	exitUsage         = 6  // Invalid arguments or configuration.
+	exitDownload      = 7  // The download of the subtitles failed.
+	exitBadFormat     = 8  // A subtitles or metadata file is malformed or misnamed.
+	exitIndexNotFound = 9  // The index does not exist.
+	exitOutput        = 10 // The results could not be written.
+	exitVideoNotFound = 11 // The video is not in the index.
+	exitNoSubtitles   = 12 // The channel has no subtitles in the requested language.
+)
+
+// exitCodes maps the errors of the library to the exit codes, taking precedence over the code given to perhapsExit.
+var exitCodes = []struct {
+	err  error
+	code int
+}{
+	{sininen.ErrAssembly, exitAssembly},
+	{sininen.ErrNoSubtitles, exitNoSubtitles},
+	{sininen.ErrBadFormat, exitBadFormat},
+	{sininen.ErrBadName, exitBadFormat},
+	{sininen.ErrEmptyTranscript, exitBadFormat},
+	{sininen.ErrIndexNotFound, exitIndexNotFound},
+	{sininen.ErrInvalidOption, exitUsage},
+	{sininen.ErrVideoNotFound, exitVideoNotFound},
+}
+
+// exitCode returns the exit code of an error, fallback being used for the errors not coming from the library.
+func exitCode(err error, fallback int) int {
+	for _, mapping := range exitCodes {
+		if errors.Is(err, mapping.err) {
+			return mapping.code
+		}
+	}
+	return fallback
+}
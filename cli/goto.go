@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mooss/sininen"
+)
+
+var gotoCommand = &command{
+	name:        "goto",
+	arguments:   "channel-id video-id timestamp",
+	description: "Print the transcript of an indexed video around a timestamp, e.g. 12:34.",
+	details:     "The timestamp is formatted as [[hh:]mm:]ss or as a duration like 12m34s.",
+	run:         runGoto,
+}
+
+func runGoto(cmd *command, args []string) {
+	flags := cmd.flagSet()
+	span := flags.Duration("span", 30*time.Second, "Transcript printed before and after the timestamp.")
+	langs := addLangFlag(flags)
+	cmd.parseArgs(flags, args, 3)
+	at, err := sininen.ParseTimestamp(flags.Arg(2))
+	perhapsExit(err, exitUsage)
+
+	transcript, err := readTranscript(flags.Arg(0), flags.Arg(1), langs.orDefault(), "")
+	perhapsExit(err, exitIndex)
+	around := sininen.TranscriptAround(transcript, at, *span)
+	if len(around) == 0 {
+		perhapsExit(fmt.Errorf("%w: %s is past the end of the transcript", sininen.ErrInvalidOption, sininen.FormatTimestamp(at)), exitUsage)
+	}
+
+	writer := bufio.NewWriter(os.Stdout)
+	for _, segment := range around {
+		marker := "    "
+		if segment.StartTime <= at && at < segment.EndTime {
+			marker = "  > " // The segment spoken at the timestamp.
+		}
+		fmt.Fprintf(writer, "%s%s %s\n", marker, sininen.FormatTimestamp(segment.StartTime), strings.ReplaceAll(segment.Text, "\n", " "))
+	}
+	perhapsExit(writer.Flush(), exitOutput)
+}
@@ -0,0 +1,502 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/graph-gophers/graphql-go"
+	"github.com/mooss/sininen"
+)
+
+//go:embed schema.graphql
+var graphqlSchema string // Resolved by graphqlQuery.
+
+// graphqlDate is the layout of the dates of the GraphQL API, like the ones of the JSON output.
+const graphqlDate = "2006-01-02"
+
+// serveGraphQL returns the handler of the GraphQL queries, sent as JSON objects with query, operationName and
+// variables fields.
+// The errors of the resolvers are part of the response, as required by GraphQL, only the malformed requests failing.
+func (s *server) serveGraphQL() func(w http.ResponseWriter, r *http.Request) error {
+	schema := graphql.MustParseSchema(graphqlSchema, &graphqlQuery{s}) // Cannot fail, the resolvers matching the schema.
+	return func(w http.ResponseWriter, r *http.Request) error {
+		var params struct {
+			Query         string                 `json:"query"`
+			OperationName string                 `json:"operationName"`
+			Variables     map[string]interface{} `json:"variables"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			return fmt.Errorf("%w: malformed GraphQL request: %v", sininen.ErrInvalidOption, err)
+		}
+		return writeJSONResponse(w, schema.Exec(r.Context(), params.Query, params.OperationName, params.Variables))
+	}
+}
+
+// optionalString returns a pointer to a string, or nil when it is empty so that it is resolved as null.
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+// optionalTime formats a time with a layout, or returns nil when it is zero.
+func optionalTime(value time.Time, layout string) *string {
+	if value.IsZero() {
+		return nil
+	}
+	return optionalString(value.Format(layout))
+}
+
+// graphqlQuery resolves the root fields of the GraphQL API with the indexes opened by the server.
+type graphqlQuery struct {
+	*server
+}
+
+func (gq *graphqlQuery) Channels() ([]string, error) {
+	return allChannels()
+}
+
+// graphqlSearchArgs are the arguments of the search field, named after the flags of the search command.
+type graphqlSearchArgs struct {
+	Query        string
+	Channels     *[]string
+	All          *bool
+	Langs        *[]string
+	Limit        *int32
+	Offset       *int32
+	MaxVideos    *int32
+	MaxPerVideo  *int32
+	First        *bool
+	Context      *string
+	Video        *string
+	Chapter      *string
+	Playlist     *string
+	Prefix       *bool
+	MinViews     *float64
+	After        *string
+	Before       *string
+	Ranking      *string
+	HalfLife     *string
+	Normalize    *bool
+	Thumbnails   *bool
+	Sponsors     *string
+	SkipSponsors *bool
+	Platform     *string
+	PlatformBase *string
+	Clips        *bool
+}
+
+// flags returns the flags of the search command corresponding to the arguments.
+func (args graphqlSearchArgs) flags() []string {
+	result := []string{}
+	addString := func(name string, value *string) {
+		if value != nil {
+			result = append(result, "-"+name+"="+*value)
+		}
+	}
+	addInt := func(name string, value *int32) {
+		if value != nil {
+			result = append(result, "-"+name+"="+strconv.Itoa(int(*value)))
+		}
+	}
+	addBool := func(name string, value *bool) {
+		if value != nil {
+			result = append(result, "-"+name+"="+strconv.FormatBool(*value))
+		}
+	}
+	addBool("all", args.All)
+	if args.Langs != nil {
+		for _, lang := range *args.Langs {
+			result = append(result, "-lang="+lang)
+		}
+	}
+	addInt("limit", args.Limit)
+	addInt("offset", args.Offset)
+	addInt("max-videos", args.MaxVideos)
+	addInt("max-per-video", args.MaxPerVideo)
+	addBool("first", args.First)
+	addString("context", args.Context)
+	addString("video", args.Video)
+	addString("chapter", args.Chapter)
+	addString("playlist", args.Playlist)
+	addBool("prefix", args.Prefix)
+	if args.MinViews != nil {
+		result = append(result, "-min-views="+strconv.FormatInt(int64(*args.MinViews), 10))
+	}
+	addString("after", args.After)
+	addString("before", args.Before)
+	addString("ranking", args.Ranking)
+	addString("half-life", args.HalfLife)
+	addBool("normalize", args.Normalize)
+	addBool("thumbnails", args.Thumbnails)
+	addString("sponsors", args.Sponsors)
+	addBool("skip-sponsors", args.SkipSponsors)
+	addString("platform", args.Platform)
+	addString("platform-base", args.PlatformBase)
+	addBool("clips", args.Clips)
+	return result
+}
+
+func (gq *graphqlQuery) Search(ctx context.Context, args graphqlSearchArgs) (*graphqlSearch, error) {
+	if err := gq.chargeSearch(ctx); err != nil { // Counted once per field, a request being able to alias many.
+		return nil, err
+	}
+	flags := flag.NewFlagSet("search", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	settings := addSearchFlags(flags)
+	if err := flags.Parse(args.flags()); err != nil {
+		return nil, fmt.Errorf("%w: %v", sininen.ErrInvalidOption, err)
+	}
+	if err := settings.check(); err != nil {
+		return nil, err
+	}
+	gq.limitResults(ctx, settings)
+	if args.Query == "" {
+		return nil, fmt.Errorf("%w: the query is mandatory", sininen.ErrInvalidOption)
+	}
+	var channelNames []string
+	if args.Channels != nil {
+		channelNames = *args.Channels
+	}
+	channelNames, err := settings.channels(channelNames)
+	if err != nil {
+		return nil, err
+	}
+	return &graphqlSearch{server: gq.server, settings: settings, channelNames: channelNames, query: args.Query}, nil
+}
+
+func (gq *graphqlQuery) Videos(args struct {
+	Channel string
+	Lang    *string
+}) ([]*graphqlVideo, error) {
+	lang := defaults.Languages[0]
+	if args.Lang != nil {
+		lang = *args.Lang
+	}
+	index, err := gq.channelIndex(args.Channel, lang)
+	if err != nil {
+		return nil, err
+	}
+	defer gq.release(index)
+	videos, err := sininen.ListVideos(index.index)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*graphqlVideo, len(videos))
+	for i, video := range videos {
+		result[i] = &graphqlVideo{video, sininen.YouTube{}}
+	}
+	return result, nil
+}
+
+func (gq *graphqlQuery) Stats(args struct {
+	Channel  string
+	Langs    *[]string
+	TopTerms int32
+}) ([]*graphqlStats, error) {
+	var langs languages
+	if args.Langs != nil {
+		langs = *args.Langs
+	}
+	result := []*graphqlStats{}
+	for _, lang := range langs.orDefault() {
+		stats, err := gq.indexStats(args.Channel, lang, int(args.TopTerms))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, &graphqlStats{lang, stats})
+	}
+	return result, nil
+}
+
+func (gq *graphqlQuery) Suggestions(ctx context.Context, args struct {
+	Prefix   string
+	Channels *[]string
+	All      *bool
+	Langs    *[]string
+	Limit    int32
+}) ([]*graphqlTermCount, error) {
+	if args.Prefix == "" || args.Limit < 1 {
+		return nil, fmt.Errorf("%w: expected a prefix and a positive limit", sininen.ErrInvalidOption)
+	}
+	if err := gq.chargeSearch(ctx); err != nil {
+		return nil, err
+	}
+	var channelNames []string
+	if args.Channels != nil {
+		channelNames = *args.Channels
+	}
+	channelNames, err := selectChannels(channelNames, args.All != nil && *args.All)
+	if err != nil {
+		return nil, err
+	}
+	var langs languages
+	if args.Langs != nil {
+		langs = *args.Langs
+	}
+	terms, err := gq.suggest(channelNames, langs.orDefault(), args.Prefix, gq.maxResults(ctx, int(args.Limit)))
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*graphqlTermCount, len(terms))
+	for i, term := range terms {
+		result[i] = &graphqlTermCount{term}
+	}
+	return result, nil
+}
+
+// graphqlSearch resolves the results of a search, the search being made once by the first field needing it.
+type graphqlSearch struct {
+	server       *server
+	settings     *searchSettings
+	channelNames []string
+	query        string
+
+	once   sync.Once
+	videos sininen.SearchResultSequence
+	err    error
+}
+
+// results returns the results of the search, through the cache of the server.
+func (gs *graphqlSearch) results() (sininen.SearchResultSequence, error) {
+	gs.once.Do(func() {
+		gs.videos, gs.err = gs.server.search(gs.settings, gs.channelNames, gs.query)
+	})
+	return gs.videos, gs.err
+}
+
+func (gs *graphqlSearch) Segments(ctx context.Context) ([]*graphqlSegment, error) {
+	videos, err := gs.results()
+	if err != nil {
+		return nil, err
+	}
+	return gs.resolveSegments(gs.settings.segments(ctx, videos)), nil
+}
+
+func (gs *graphqlSearch) Videos() ([]*graphqlVideoMatch, error) {
+	videos, err := gs.results()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*graphqlVideoMatch, len(videos))
+	for i, video := range videos {
+		result[i] = &graphqlVideoMatch{gs, video}
+	}
+	return result, nil
+}
+
+func (gs *graphqlSearch) Facets(args struct{ Size int32 }) (*graphqlFacets, error) {
+	index, release, err := gs.server.openIndexes(gs.channelNames, gs.settings.langs.orDefault())
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	facets, err := sininen.SearchFacets(index, gs.query, *gs.settings.playlist, int(args.Size))
+	return &graphqlFacets{facets}, err
+}
+
+// resolveSegments returns the resolvers of scored segments, linked to the platform of the search.
+func (gs *graphqlSearch) resolveSegments(segments []sininen.ScoredSegment) []*graphqlSegment {
+	result := make([]*graphqlSegment, len(segments))
+	for i, segment := range segments {
+		result[i] = &graphqlSegment{segment, gs.settings.urls}
+	}
+	return result
+}
+
+// graphqlVideoMatch resolves a video matching a search.
+type graphqlVideoMatch struct {
+	search *graphqlSearch
+	result sininen.SearchResult
+}
+
+func (gvm *graphqlVideoMatch) Video() *graphqlVideo {
+	return &graphqlVideo{sininen.IndexedVideo{
+		ID:             gvm.result.ID,
+		Title:          gvm.result.Title,
+		UploadDate:     gvm.result.UploadDate,
+		Duration:       gvm.result.Duration,
+		ViewCount:      gvm.result.ViewCount,
+		TranslatedFrom: gvm.result.TranslatedFrom,
+	}, gvm.search.settings.urls}
+}
+
+func (gvm *graphqlVideoMatch) Score() float64 {
+	return gvm.result.Score
+}
+
+func (gvm *graphqlVideoMatch) Segments(ctx context.Context) []*graphqlSegment {
+	return gvm.search.resolveSegments(gvm.search.settings.displayed(ctx, sininen.SearchResultSequence{gvm.result}.RankedSegments(gvm.search.settings.rank)))
+}
+
+// graphqlSegment resolves a scored segment.
+type graphqlSegment struct {
+	segment sininen.ScoredSegment
+	urls    sininen.URLBuilder
+}
+
+func (gs *graphqlSegment) Video() *graphqlVideo {
+	return &graphqlVideo{sininen.IndexedVideo{
+		ID:             gs.segment.ID,
+		Title:          gs.segment.Title,
+		UploadDate:     gs.segment.UploadDate,
+		Duration:       gs.segment.VideoDuration,
+		ViewCount:      gs.segment.ViewCount,
+		TranslatedFrom: gs.segment.TranslatedFrom,
+	}, gs.urls}
+}
+
+func (gs *graphqlSegment) Start() float64     { return gs.segment.StartTime.Seconds() }
+func (gs *graphqlSegment) End() float64       { return gs.segment.EndTime.Seconds() }
+func (gs *graphqlSegment) Text() string       { return gs.segment.Text }
+func (gs *graphqlSegment) Terms() []string    { return gs.segment.SortedTerms }
+func (gs *graphqlSegment) Score() float64     { return gs.segment.Score }
+func (gs *graphqlSegment) URL() string        { return sininen.SegmentURL(gs.urls, gs.segment) }
+func (gs *graphqlSegment) Thumbnail() *string { return optionalString(gs.segment.Thumbnail) }
+func (gs *graphqlSegment) Sponsor() *string   { return optionalString(gs.segment.Sponsor) }
+func (gs *graphqlSegment) Chapter() *string   { return optionalString(gs.segment.Chapter) }
+
+func (gs *graphqlSegment) Before() []*graphqlExcerpt { return resolveExcerpts(gs.segment.Before) }
+func (gs *graphqlSegment) After() []*graphqlExcerpt  { return resolveExcerpts(gs.segment.After) }
+
+// graphqlExcerpt resolves a segment surrounding a matching one.
+type graphqlExcerpt struct {
+	excerpt sininen.SegmentExcerpt
+}
+
+// resolveExcerpts returns the resolvers of excerpts.
+func resolveExcerpts(excerpts []sininen.SegmentExcerpt) []*graphqlExcerpt {
+	result := make([]*graphqlExcerpt, len(excerpts))
+	for i, excerpt := range excerpts {
+		result[i] = &graphqlExcerpt{excerpt}
+	}
+	return result
+}
+
+func (ge *graphqlExcerpt) Start() float64 { return ge.excerpt.StartTime.Seconds() }
+func (ge *graphqlExcerpt) End() float64   { return ge.excerpt.EndTime.Seconds() }
+func (ge *graphqlExcerpt) Text() string   { return ge.excerpt.Text }
+
+// graphqlVideo resolves a video, its link pointing to a given platform by default.
+type graphqlVideo struct {
+	video sininen.IndexedVideo
+	urls  sininen.URLBuilder
+}
+
+func (gv *graphqlVideo) ID() string              { return gv.video.ID }
+func (gv *graphqlVideo) Title() *string          { return optionalString(gv.video.Title) }
+func (gv *graphqlVideo) UploadDate() *string     { return optionalTime(gv.video.UploadDate, graphqlDate) }
+func (gv *graphqlVideo) IndexedAt() *string      { return optionalTime(gv.video.IndexedAt, time.RFC3339) }
+func (gv *graphqlVideo) TranslatedFrom() *string { return optionalString(gv.video.TranslatedFrom) }
+
+func (gv *graphqlVideo) Duration() *float64 {
+	if gv.video.Duration <= 0 {
+		return nil
+	}
+	seconds := gv.video.Duration.Seconds()
+	return &seconds
+}
+
+func (gv *graphqlVideo) ViewCount() *float64 {
+	if gv.video.ViewCount <= 0 {
+		return nil
+	}
+	views := float64(gv.video.ViewCount)
+	return &views
+}
+
+func (gv *graphqlVideo) URL(args struct {
+	Platform     *string
+	PlatformBase *string
+}) (string, error) {
+	urls := gv.urls
+	if args.Platform != nil || args.PlatformBase != nil {
+		platform, base := "youtube", ""
+		if args.Platform != nil {
+			platform = *args.Platform
+		}
+		if args.PlatformBase != nil {
+			base = *args.PlatformBase
+		}
+		var err error
+		if urls, err = newURLBuilder(platform, base); err != nil {
+			return "", err
+		}
+	}
+	return urls.URL(gv.video.ID, 0), nil
+}
+
+// graphqlFacets resolves the facets of a search.
+type graphqlFacets struct {
+	facets sininen.Facets
+}
+
+func (gf *graphqlFacets) Videos() float64 {
+	return float64(gf.facets.Total)
+}
+
+func (gf *graphqlFacets) Years() []*graphqlFacetCount {
+	return resolveFacetCounts(gf.facets.Years)
+}
+
+func (gf *graphqlFacets) Playlists() []*graphqlFacetCount {
+	return resolveFacetCounts(gf.facets.Playlists)
+}
+
+func (gf *graphqlFacets) TranslatedFrom() []*graphqlFacetCount {
+	return resolveFacetCounts(gf.facets.TranslatedFrom)
+}
+
+// graphqlFacetCount resolves a value of a facet.
+type graphqlFacetCount struct {
+	count sininen.FacetCount
+}
+
+// resolveFacetCounts returns the resolvers of the values of a facet.
+func resolveFacetCounts(counts []sininen.FacetCount) []*graphqlFacetCount {
+	result := make([]*graphqlFacetCount, len(counts))
+	for i, count := range counts {
+		result[i] = &graphqlFacetCount{count}
+	}
+	return result
+}
+
+func (gfc *graphqlFacetCount) Value() string { return gfc.count.Value }
+func (gfc *graphqlFacetCount) Count() int32  { return int32(gfc.count.Count) }
+
+// graphqlStats resolves the statistics of the index of a language.
+type graphqlStats struct {
+	lang  string
+	stats sininen.IndexStats
+}
+
+func (gs *graphqlStats) Lang() string             { return gs.lang }
+func (gs *graphqlStats) Videos() int32            { return int32(gs.stats.Videos) }
+func (gs *graphqlStats) TranscriptHours() float64 { return gs.stats.TranscriptDuration.Hours() }
+func (gs *graphqlStats) Size() float64            { return float64(gs.stats.Size) }
+func (gs *graphqlStats) LastUpdate() *string      { return optionalTime(gs.stats.LastUpdate, time.RFC3339) }
+
+func (gs *graphqlStats) TopTerms() []*graphqlTermCount {
+	result := make([]*graphqlTermCount, len(gs.stats.TopTerms))
+	for i, term := range gs.stats.TopTerms {
+		result[i] = &graphqlTermCount{term}
+	}
+	return result
+}
+
+// graphqlTermCount resolves a term of the statistics of an index.
+type graphqlTermCount struct {
+	term sininen.TermCount
+}
+
+func (gtc *graphqlTermCount) Term() string { return gtc.term.Term }
+func (gtc *graphqlTermCount) Count() int32 { return int32(gtc.term.Count) }
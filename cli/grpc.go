@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/mooss/sininen"
+	"github.com/mooss/sininen/sininenpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcServer implements the gRPC API on top of the indexes opened by the HTTP server.
+type grpcServer struct {
+	sininenpb.UnimplementedSininenServer
+	*server
+}
+
+// grpcError converts an error to a gRPC status error.
+func grpcError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err).Err()
+	}
+	for _, mapping := range serverErrors {
+		if errors.Is(err, mapping.err) {
+			return status.Error(mapping.code, err.Error())
+		}
+	}
+	return status.Error(codes.Unknown, err.Error())
+}
+
+// searchArgs returns the flags of the search command corresponding to a search request.
+func searchArgs(request *sininenpb.SearchRequest) []string {
+	result := []string{}
+	add := func(name, value string, set bool) {
+		if set {
+			result = append(result, "-"+name+"="+value)
+		}
+	}
+	add("all", "true", request.All)
+	for _, lang := range request.Langs {
+		add("lang", lang, true)
+	}
+	add("offset", strconv.Itoa(int(request.Offset)), request.Offset != 0)
+	add("limit", strconv.Itoa(int(request.Limit)), request.Limit != 0)
+	add("max-videos", strconv.Itoa(int(request.MaxVideos)), request.MaxVideos != 0)
+	add("max-per-video", strconv.Itoa(int(request.MaxPerVideo)), request.MaxPerVideo != 0)
+	add("first", "true", request.FirstOnly)
+	add("video", request.Video, request.Video != "")
+	add("playlist", request.Playlist, request.Playlist != "")
+	add("min-views", strconv.FormatInt(request.MinViews, 10), request.MinViews != 0)
+	add("after", request.After, request.After != "")
+	add("before", request.Before, request.Before != "")
+	add("ranking", request.Ranking, request.Ranking != "")
+	add("half-life", request.HalfLife.AsDuration().String(), request.HalfLife != nil)
+	add("normalize", "true", request.Normalize)
+	add("platform", request.Platform, request.Platform != "")
+	add("platform-base", request.PlatformBase, request.PlatformBase != "")
+	return result
+}
+
+func (gs grpcServer) Search(request *sininenpb.SearchRequest, stream sininenpb.Sininen_SearchServer) error {
+	flags := flag.NewFlagSet("search", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	settings := addSearchFlags(flags)
+	if err := flags.Parse(searchArgs(request)); err != nil {
+		return grpcError(fmt.Errorf("%w: %v", sininen.ErrInvalidOption, err))
+	}
+	if err := settings.check(); err != nil {
+		return grpcError(err)
+	}
+	gs.limitResults(stream.Context(), settings)
+	if request.Query == "" {
+		return grpcError(fmt.Errorf("%w: the query is mandatory", sininen.ErrInvalidOption))
+	}
+	channelNames, err := settings.channels(request.Channels)
+	if err != nil {
+		return grpcError(err)
+	}
+	videos, err := gs.search(settings, channelNames, request.Query)
+	if err != nil {
+		return grpcError(err)
+	}
+	for _, segment := range settings.segments(stream.Context(), videos) {
+		message := &sininenpb.Segment{
+			Id:        segment.ID,
+			Title:     segment.Title,
+			Url:       settings.urls.URL(segment.ID, segment.StartTime),
+			Start:     durationpb.New(segment.StartTime),
+			End:       durationpb.New(segment.EndTime),
+			Text:      segment.Text,
+			Terms:     segment.SortedTerms,
+			Score:     segment.Score,
+			ViewCount: segment.ViewCount,
+		}
+		if !segment.UploadDate.IsZero() {
+			message.UploadDate = timestamppb.New(segment.UploadDate)
+		}
+		if err := stream.Send(message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (gs grpcServer) Index(ctx context.Context, request *sininenpb.IndexRequest) (*sininenpb.IndexResponse, error) {
+	result := &sininenpb.IndexResponse{}
+	for _, lang := range languages(request.Langs).orDefault() {
+		changes, err := gs.reindex(request.Channel, lang)
+		if err != nil {
+			return nil, grpcError(err)
+		}
+		result.Updates = append(result.Updates, &sininenpb.LanguageUpdate{
+			Lang:    lang,
+			Added:   changes.Added,
+			Updated: changes.Updated,
+			Removed: changes.Removed,
+		})
+	}
+	return result, nil
+}
+
+func (gs grpcServer) Stats(ctx context.Context, request *sininenpb.StatsRequest) (*sininenpb.StatsResponse, error) {
+	result := &sininenpb.StatsResponse{}
+	for _, lang := range languages(request.Langs).orDefault() {
+		stats, err := gs.indexStats(request.Channel, lang, int(request.TopTerms))
+		if err != nil {
+			return nil, grpcError(err)
+		}
+		message := &sininenpb.LanguageStats{
+			Lang:               lang,
+			Videos:             int32(stats.Videos),
+			TranscriptDuration: durationpb.New(stats.TranscriptDuration),
+			Size:               stats.Size,
+			LastUpdate:         timestamppb.New(stats.LastUpdate),
+		}
+		for _, term := range stats.TopTerms {
+			message.TopTerms = append(message.TopTerms, &sininenpb.TermCount{Term: term.Term, Count: int64(term.Count)})
+		}
+		result.Stats = append(result.Stats, message)
+	}
+	return result, nil
+}
+
+// Watch watches the indexes of every language concurrently, like the watch command does.
+func (gs grpcServer) Watch(request *sininenpb.WatchRequest, stream sininenpb.Sininen_WatchServer) error {
+	interval := time.Minute
+	if request.Interval != nil {
+		interval = request.Interval.AsDuration()
+	}
+	if interval <= 0 {
+		return grpcError(fmt.Errorf("%w: the interval must be positive", sininen.ErrInvalidOption))
+	}
+	langs := languages(request.Langs).orDefault()
+	indexes := make([]*sharedIndex, 0, len(langs))
+	defer func() { gs.release(indexes...) }()
+	for _, lang := range langs {
+		index, err := gs.channelIndex(request.Channel, lang)
+		if err != nil {
+			return grpcError(err)
+		}
+		indexes = append(indexes, index)
+	}
+
+	ctx, cancel := context.WithCancel(stream.Context()) // Stops watching the other languages when the update of one fails.
+	defer cancel()
+	var sending sync.Mutex // Send cannot be called concurrently.
+	var sendErr error
+	send := func(lang string, ids []string, kind sininenpb.IndexChange_Kind) {
+		sending.Lock()
+		defer sending.Unlock()
+		for _, id := range ids {
+			if sendErr != nil {
+				return
+			}
+			if sendErr = stream.Send(&sininenpb.IndexChange{Lang: lang, Id: id, Kind: kind}); sendErr != nil {
+				cancel()
+			}
+		}
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, len(indexes))
+	folder := filepath.Join(defaults.SubtitlesRoot, request.Channel)
+	for i, lang := range langs {
+		wg.Add(1)
+		go func(index bleve.Index, lang string) {
+			defer wg.Done()
+			defer cancel()
+			errs <- sininen.WatchSubtitleIndex(ctx, index, folder, lang, interval, func(changes sininen.IndexUpdate) {
+				gs.invalidate(request.Channel, lang)
+				send(lang, changes.Added, sininenpb.IndexChange_ADDED)
+				send(lang, changes.Updated, sininenpb.IndexChange_UPDATED)
+				send(lang, changes.Removed, sininenpb.IndexChange_REMOVED)
+			})
+		}(indexes[i].index, lang)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			return grpcError(err)
+		}
+	}
+	return sendErr
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mooss/sininen"
+)
+
+// healthTimeout is the time an index is given to answer the query of a health check.
+const healthTimeout = 5 * time.Second
+
+// indexHealth is the result of the health check of an index.
+type indexHealth struct {
+	Lang    string  `json:"lang"`
+	Healthy bool    `json:"healthy"`
+	Videos  uint64  `json:"videos"`
+	Latency float64 `json:"latency_seconds"` // Of opening the index if needed and of answering the query.
+	Error   string  `json:"error,omitempty"`
+	Skipped string  `json:"skipped,omitempty"` // Why the index was not probed while still deemed healthy, e.g. not created yet.
+}
+
+// channelHealth is the result of the health checks of the indexes of a channel.
+type channelHealth struct {
+	Channel string        `json:"channel"`
+	Healthy bool          `json:"healthy"`
+	Indexes []indexHealth `json:"indexes"`
+}
+
+// healthReport is the result of the health checks of the indexes of several channels, healthy when all of them are.
+type healthReport struct {
+	Healthy  bool            `json:"healthy"`
+	Channels []channelHealth `json:"channels"`
+}
+
+// add adds the health of an index of a channel to the report, the channels being listed in the order of their first
+// index.
+func (hr *healthReport) add(channelName string, health indexHealth) {
+	hr.Healthy = hr.Healthy && health.Healthy
+	for i := range hr.Channels {
+		if channel := &hr.Channels[i]; channel.Channel == channelName {
+			channel.Healthy = channel.Healthy && health.Healthy
+			channel.Indexes = append(channel.Indexes, health)
+			return
+		}
+	}
+	hr.Channels = append(hr.Channels, channelHealth{channelName, health.Healthy, []indexHealth{health}})
+}
+
+// probeOptions tell how the indexes not open are checked by probeIndex.
+type probeOptions struct {
+	open   bool // Opens the indexes created but not open, unless the server keeps as many indexes open as it can.
+	strict bool // Fails on the indexes not created yet instead of skipping them.
+}
+
+// probeIndex checks that the index of a channel in a language answers a trivial query, returning its number of videos
+// or why it was skipped. Unlike a search, a missing index is never created and the indexes kept open by the server are
+// never evicted to make room for the probed one.
+func (s *server) probeIndex(ctx context.Context, channelName, lang string, options probeOptions) (uint64, string, error) {
+	if err := checkLang(lang); err != nil {
+		return 0, "", err
+	}
+	if err := checkChannel(channelName); err != nil {
+		return 0, "", err
+	}
+	index, ok := s.openIndex(channelName, lang)
+	if !ok {
+		folder, err := indexFolder(channelName)
+		if err != nil {
+			return 0, "", err
+		}
+		switch {
+		case !sininen.TranscriptionIndexExists(folder, lang) && options.strict:
+			return 0, "", fmt.Errorf("%w: the %s index of %s was not created yet", sininen.ErrIndexNotFound, lang, channelName)
+		case !sininen.TranscriptionIndexExists(folder, lang):
+			return 0, "not created yet", nil
+		case !options.open:
+			return 0, "not open", nil
+		case s.indexes.full():
+			return 0, "not open, the server keeping as many indexes open as -max-open-indexes", nil
+		}
+		if index, err = s.channelIndex(channelName, lang); err != nil {
+			return 0, "", err
+		}
+	}
+	defer s.release(index)
+	ctx, cancel := context.WithTimeout(ctx, healthTimeout)
+	defer cancel()
+	videos, err := sininen.ProbeIndex(ctx, index.index)
+	return videos, "", err
+}
+
+// checkIndex returns the health of the index of a channel in a language, see probeIndex.
+func (s *server) checkIndex(ctx context.Context, channelName, lang string, options probeOptions) indexHealth {
+	start := time.Now()
+	videos, skipped, err := s.probeIndex(ctx, channelName, lang, options)
+	result := indexHealth{Lang: lang, Healthy: err == nil, Videos: videos, Latency: time.Since(start).Seconds(), Skipped: skipped}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// writeHealth writes a health report, with the 503 Service Unavailable status when it is not healthy so that the
+// orchestrators notice it without parsing it.
+func writeHealth(w http.ResponseWriter, report healthReport) error {
+	if report.Channels == nil {
+		report.Channels = []channelHealth{}
+	}
+	w.Header().Set("Content-Type", contentTypes["json"])
+	w.Header().Set("Cache-Control", "no-store")
+	if !report.Healthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	return json.NewEncoder(w).Encode(report)
+}
+
+// serveHealth checks the liveness of the server, i.e. that the indexes it keeps open still answer, without opening the
+// others so that it stays cheap enough to be polled often.
+func (s *server) serveHealth(w http.ResponseWriter, r *http.Request) error {
+	report := healthReport{Healthy: true}
+	s.checkOpenIndexes(r.Context(), &report, probeOptions{strict: true})
+	return writeHealth(w, report)
+}
+
+// checkOpenIndexes adds the health of the indexes kept open by the server to a report.
+func (s *server) checkOpenIndexes(ctx context.Context, report *healthReport, options probeOptions) {
+	keys := s.indexes.keys()
+	sort.Strings(keys)
+	for _, key := range keys {
+		parts := strings.SplitN(key, "/", 2) // See indexKey, channel names cannot contain slashes.
+		report.add(parts[0], s.checkIndex(ctx, parts[0], parts[1], options))
+	}
+}
+
+// serveReady checks the readiness of the server, i.e. that the indexes of the channels given by the channel parameters,
+// or of the sync_channels of the configuration, in the languages of the request answer a trivial query, or that the
+// indexes kept open do without any of them.
+// The indexes created but not open are opened as long as no open index has to be evicted for them, and the indexes not
+// created yet are only failures with the strict parameter, so that a channel being downloaded does not keep the server
+// unready.
+func (s *server) serveReady(w http.ResponseWriter, r *http.Request) error {
+	parameters := r.URL.Query()
+	options := probeOptions{open: true}
+	if strict := parameters.Get("strict"); strict != "" {
+		var err error
+		if options.strict, err = strconv.ParseBool(strict); err != nil {
+			return fmt.Errorf("%w: strict expects a boolean, got %q", sininen.ErrInvalidOption, strict)
+		}
+	}
+	channelNames := parameters["channel"]
+	if len(channelNames) == 0 {
+		channelNames = defaults.SyncChannels
+	}
+	report := healthReport{Healthy: true}
+	if len(channelNames) == 0 {
+		s.checkOpenIndexes(r.Context(), &report, options)
+		return writeHealth(w, report)
+	}
+	for _, channelName := range channelNames {
+		for _, lang := range requestLangs(r) {
+			report.add(channelName, s.checkIndex(r.Context(), channelName, lang, options))
+		}
+	}
+	return writeHealth(w, report)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mooss/sininen"
+)
+
+var historyCommand = &command{
+	name:        "history",
+	arguments:   "[channel-id...]",
+	description: "List the previous searches, with the number of matching segments, optionally only those of the given channels.",
+	run:         runHistory,
+}
+
+// historyEntry is a search recorded in the history file, as a JSON line.
+type historyEntry struct {
+	Time     time.Time `json:"time"`
+	Channels []string  `json:"channels"`
+	Query    string    `json:"query"`
+	Hits     int       `json:"hits"` // Number of matching segments.
+}
+
+// historyPath returns the location of the history file.
+func historyPath() (string, error) {
+	if defaults.HistoryFile != "" {
+		return defaults.HistoryFile, nil
+	}
+	return configFile("history.jsonl")
+}
+
+// recordSearch appends a search to the history file.
+// Failing to do so is only reported, since the history is a convenience that should not prevent searching.
+func recordSearch(channelNames []string, query string, videos sininen.SearchResultSequence) {
+	hits := 0
+	for _, video := range videos {
+		hits += len(video.Segments)
+	}
+	if err := appendHistory(historyEntry{time.Now(), channelNames, query, hits}); err != nil {
+		sininen.Log.Warnf("recording the search in the history: %v", err)
+	}
+}
+
+func appendHistory(entry historyEntry) error {
+	filename, err := historyPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if err := sininen.WriteJSON(file, entry); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// readHistory returns the recorded searches, from the oldest to the most recent.
+// Malformed lines are skipped with a warning.
+func readHistory() ([]historyEntry, error) {
+	filename, err := historyPath()
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	result := []historyEntry{}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		var entry historyEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			sininen.Log.Warnf("skipping line %d of %s: %v", line, filename, err)
+			continue
+		}
+		result = append(result, entry)
+	}
+	return result, scanner.Err()
+}
+
+// involves tells whether the search was made in one of the given channels.
+func (he historyEntry) involves(channelNames []string) bool {
+	for _, wanted := range channelNames {
+		for _, channel := range he.Channels {
+			if channel == wanted {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func runHistory(cmd *command, args []string) {
+	flags := cmd.flagSet()
+	limit := flags.Int("limit", 20, "Number of most recent searches listed, all of them when 0.")
+	clearHistory := flags.Bool("clear", false, "Delete the history instead of listing it.")
+	cmd.parseMinArgs(flags, args, 0)
+
+	if *clearHistory {
+		filename, err := historyPath()
+		perhapsExit(err, exitUsage)
+		if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+			perhapsExit(err, exitOutput)
+		}
+		return
+	}
+
+	entries, err := readHistory()
+	perhapsExit(err, exitUsage)
+	if flags.NArg() > 0 {
+		kept := []historyEntry{}
+		for _, entry := range entries {
+			if entry.involves(flags.Args()) {
+				kept = append(kept, entry)
+			}
+		}
+		entries = kept
+	}
+	if *limit > 0 && len(entries) > *limit {
+		entries = entries[len(entries)-*limit:]
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "TIME\tHITS\tCHANNELS\tQUERY")
+	for _, entry := range entries {
+		fmt.Fprintf(writer, "%s\t%d\t%s\t%s\n", entry.Time.Local().Format("2006-01-02 15:04"), entry.Hits,
+			strings.Join(entry.Channels, ","), entry.Query)
+	}
+	perhapsExit(writer.Flush(), exitOutput)
+}
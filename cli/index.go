@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mooss/sininen"
+)
+
+var indexCommand = &command{
+	name:        "index",
+	arguments:   "channel-id",
+	description: "Create the subtitle index of a downloaded channel, or refresh it after downloading new subtitles.",
+	run:         runIndex,
+}
+
+func runIndex(cmd *command, args []string) {
+	flags := cmd.flagSet()
+	ngramMode := flags.String("ngram", "", "Index n-grams of the words to match partial words, either edge (prefixes) or full.")
+	rebuild := flags.Bool("rebuild", false, "Delete the existing index and create it again from scratch.")
+	update := flags.Bool("update", false, "Only index the new and modified subtitles and forget the deleted ones, creating the index if needed.")
+	omitText := flags.Bool("omit-text", false, "Index the words without storing the text, shrinking the index but leaving the matching segments without text, until the index is rebuilt without it.")
+	langs := addLangFlag(flags)
+	backend := addBackendFlag(flags)
+	cmd.parseArgs(flags, args, 1)
+	if *rebuild && *update {
+		perhapsExit(fmt.Errorf("-rebuild and -update are mutually exclusive"), exitUsage)
+	}
+	perhapsExit(checkBackend(*backend), exitUsage)
+
+	subtitlesFolder := channelFolder(flags.Arg(0))
+	indexFolder, err := indexFolder(flags.Arg(0))
+	perhapsExit(err, exitIndex)
+	if *backend == sqliteBackend {
+		if *ngramMode != "" {
+			perhapsExit(fmt.Errorf("%w: -ngram is only supported by the bleve backend", sininen.ErrInvalidOption), exitUsage)
+		}
+		if *omitText {
+			perhapsExit(fmt.Errorf("%w: -omit-text is only supported by the bleve backend", sininen.ErrInvalidOption), exitUsage)
+		}
+		runSQLiteIndex(subtitlesFolder, indexFolder, langs.orDefault(), *rebuild)
+		return
+	}
+	for _, lang := range langs.orDefault() {
+		if *update {
+			index, err := sininen.OpenTranscriptionIndex(indexFolder, lang)
+			if err == nil {
+				changes, err := sininen.UpdateSubtitleIndex(index, subtitlesFolder, lang, newProgress("Updating "+lang))
+				perhapsExit(err, exitIndex)
+				perhapsExit(index.Close(), exitIndex)
+				inform("Added %d, updated %d and removed %d videos in %s.\n",
+					len(changes.Added), len(changes.Updated), len(changes.Removed), lang)
+				continue
+			}
+			if !errors.Is(err, sininen.ErrIndexNotFound) {
+				perhapsExit(err, exitIndex)
+			}
+		}
+		if *rebuild {
+			perhapsExit(sininen.DeleteTranscriptionIndex(indexFolder, lang), exitIndex)
+		}
+
+		index, err := sininen.CreateSubtitleIndex(subtitlesFolder, lang, sininen.IndexOptions{
+			Folder:   indexFolder,
+			NGram:    sininen.NGramMode(*ngramMode),
+			OmitText: *omitText,
+			Progress: newProgress("Indexing " + lang),
+		})
+		perhapsExit(err, exitIndex)
+		count, err := index.DocCount()
+		perhapsExit(err, exitIndex)
+		perhapsExit(index.Close(), exitIndex)
+		inform("Indexed %d videos in %s.\n", count, lang)
+	}
+}
+
+// runSQLiteIndex fills the SQLite databases of a channel, which are always updated incrementally unless rebuilt.
+func runSQLiteIndex(subtitlesFolder, indexFolder string, langs []string, rebuild bool) {
+	for _, lang := range langs {
+		if rebuild {
+			perhapsExit(sininen.DeleteSQLiteBackend(indexFolder, lang), exitIndex)
+		}
+		backend, err := sininen.OpenSQLiteBackend(indexFolder, lang)
+		perhapsExit(err, exitIndex)
+		changes, err := backend.Update(subtitlesFolder, lang, newProgress("Indexing "+lang))
+		perhapsExit(err, exitIndex)
+		count, err := backend.VideoCount()
+		perhapsExit(err, exitIndex)
+		perhapsExit(backend.Close(), exitIndex)
+		inform("Added %d, updated %d and removed %d videos in %s, which holds %d videos.\n",
+			len(changes.Added), len(changes.Updated), len(changes.Removed), lang, count)
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mooss/sininen"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// tokenBucket accepts a number of requests per minute, up to a minute of them at once.
+type tokenBucket struct {
+	rate     int // Requests per minute.
+	tokens   float64
+	refilled time.Time
+}
+
+// newTokenBucket returns a full bucket.
+func newTokenBucket(rate int, now time.Time) *tokenBucket {
+	return &tokenBucket{rate: rate, tokens: float64(rate), refilled: now}
+}
+
+// take counts a request, returning false along with the time until the next request is accepted when the rate is
+// exceeded.
+func (tb *tokenBucket) take(now time.Time) (time.Duration, bool) {
+	perSecond := float64(tb.rate) / 60
+	tb.tokens = math.Min(float64(tb.rate), tb.tokens+now.Sub(tb.refilled).Seconds()*perSecond)
+	tb.refilled = now
+	if tb.tokens < 1 {
+		return time.Duration((1 - tb.tokens) / perSecond * float64(time.Second)), false
+	}
+	tb.tokens--
+	return 0, true
+}
+
+// errQuotaExceeded is returned for the requests exceeding the rate of their API key or of their address.
+var errQuotaExceeded = errors.New("quota exceeded")
+
+// quotaError is the error of a request exceeding a rate.
+type quotaError struct {
+	limited    string        // What the rate applies to, e.g. an API key.
+	rate       int           // Requests per minute.
+	retryAfter time.Duration // Until the next request is accepted.
+}
+
+func (qe quotaError) Error() string {
+	return fmt.Sprintf("%v: %s is limited to %d requests per minute", errQuotaExceeded, qe.limited, qe.rate)
+}
+
+func (qe quotaError) Unwrap() error { return errQuotaExceeded }
+
+// retryLater tells the clients of the requests failing with a quotaError when to retry them.
+func retryLater(w http.ResponseWriter, err error) error {
+	var exceeded quotaError
+	if errors.As(err, &exceeded) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(exceeded.retryAfter.Seconds()))))
+	}
+	return err
+}
+
+// maxLimitedAddresses is the number of client addresses whose rate is tracked, the least recently seen ones being
+// forgotten, which is harmless when they are not seen for a minute since their buckets are full again by then.
+const maxLimitedAddresses = 65536
+
+// addressLimiter enforces a rate of requests per client address.
+type addressLimiter struct {
+	rate    int
+	header  string       // Header set by a reverse proxy holding the client address, the peer address being used when empty.
+	proxies []*net.IPNet // Peers whose header is trusted, the header of the others being ignored.
+	mutex   sync.Mutex   // Guards the buckets, lruCache only guarding its own operations.
+	buckets *lruCache    // Of *tokenBucket, by address.
+}
+
+// newAddressLimiter returns a limiter of the rate of requests per minute of each client address, or nil when rate is
+// not positive, meaning unlimited.
+func newAddressLimiter(rate int, header string, proxies []*net.IPNet) *addressLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &addressLimiter{rate: rate, header: header, proxies: proxies, buckets: newLRUCache(maxLimitedAddresses, nil)}
+}
+
+// parseNetworks parses a comma-separated list of IP addresses and CIDR networks, such as 10.0.0.0/8,::1.
+func parseNetworks(list string) ([]*net.IPNet, error) {
+	result := []*net.IPNet{}
+	for _, field := range strings.Split(list, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if !strings.Contains(field, "/") {
+			ip := net.ParseIP(field)
+			if ip == nil {
+				return nil, fmt.Errorf("%w: invalid IP address %q", sininen.ErrInvalidOption, field)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			result = append(result, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(field)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", sininen.ErrInvalidOption, err)
+		}
+		result = append(result, network)
+	}
+	return result, nil
+}
+
+// clientAddress returns the address of a client, given by the forwarded value of the header of the limiter when its
+// peer is a trusted proxy, or by the remote address of its connection otherwise, so that the clients reaching the
+// server directly cannot choose their address.
+func (al *addressLimiter) clientAddress(forwarded, remote string) string {
+	host, _, err := net.SplitHostPort(remote)
+	if err != nil {
+		host = remote
+	}
+	if forwarded == "" || !al.trusts(host) {
+		return host
+	}
+	// The last address of X-Forwarded-For is the one added by the proxy, the previous ones being given by the client.
+	addresses := strings.Split(forwarded, ",")
+	if address := strings.TrimSpace(addresses[len(addresses)-1]); address != "" {
+		return address
+	}
+	return host
+}
+
+// trusts tells whether the header of a peer is trusted, i.e. whether its address is one of the proxies.
+func (al *addressLimiter) trusts(host string) bool {
+	ip := net.ParseIP(host)
+	for _, proxy := range al.proxies {
+		if ip != nil && proxy.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// requestAddress returns the address of the client of a request.
+func (al *addressLimiter) requestAddress(r *http.Request) string {
+	var forwarded string
+	if al.header != "" {
+		forwarded = r.Header.Get(al.header)
+	}
+	return al.clientAddress(forwarded, r.RemoteAddr)
+}
+
+// allowCall counts a gRPC call against the rate of its address, the header of the limiter being looked up in its
+// metadata.
+func (al *addressLimiter) allowCall(ctx context.Context, now time.Time) error {
+	var forwarded, remote string
+	if md, ok := metadata.FromIncomingContext(ctx); ok && al.header != "" {
+		if values := md.Get(al.header); len(values) > 0 {
+			forwarded = values[len(values)-1]
+		}
+	}
+	if client, ok := peer.FromContext(ctx); ok {
+		remote = client.Addr.String()
+	}
+	return al.allow(al.clientAddress(forwarded, remote), now)
+}
+
+// allow counts a request against the rate of an address.
+func (al *addressLimiter) allow(address string, now time.Time) error {
+	al.mutex.Lock()
+	defer al.mutex.Unlock()
+	var bucket *tokenBucket
+	if value, ok := al.buckets.get(address); ok {
+		bucket = value.(*tokenBucket)
+	} else {
+		bucket = newTokenBucket(al.rate, now)
+		al.buckets.add(address, bucket)
+	}
+	if wait, ok := bucket.take(now); !ok {
+		return quotaError{"address " + address, al.rate, wait}
+	}
+	return nil
+}
+
+// limits are the guards of a server exposed to the public.
+type limits struct {
+	addresses      *addressLimiter // nil when the requests of an address are not limited.
+	maxQueryLength int             // In characters, unlimited when 0.
+	maxResults     int             // Segments or terms of a response, unlimited when 0.
+	maxVideos      int             // Videos retrieved by a search, unlimited when 0.
+}
+
+// maxRequestBody is the maximum size of the body of the requests, in bytes.
+const maxRequestBody = 1 << 20
+
+// addressContext is the key of the client address of a request in its context, set when the addresses are limited.
+type addressContext struct{}
+
+// limited rejects the requests exceeding the rate of their address, before handling the others.
+// The address is added to the context of the request, so that the searches made within it can be charged to it too.
+func (s *server) limited(handler func(w http.ResponseWriter, r *http.Request) error) func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		if s.limits.addresses != nil {
+			address := s.limits.addresses.requestAddress(r)
+			if err := s.limits.addresses.allow(address, time.Now()); err != nil {
+				return retryLater(w, err)
+			}
+			r = r.WithContext(context.WithValue(r.Context(), addressContext{}, address))
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBody)
+		return handler(w, r)
+	}
+}
+
+// checkQuery rejects the queries longer than the maximum length of the server.
+func (s *server) checkQuery(query string) error {
+	if s.limits.maxQueryLength > 0 && utf8.RuneCountInString(query) > s.limits.maxQueryLength {
+		return fmt.Errorf("%w: the queries are limited to %d characters", sininen.ErrInvalidOption, s.limits.maxQueryLength)
+	}
+	return nil
+}
+
+// maxResults returns the number of results of a response to a request whose context is given, i.e. the requested
+// number, unlimited when not positive, capped by the maximum of the server and of the API key of the request.
+func (s *server) maxResults(ctx context.Context, requested int) int {
+	result := capped(requested, s.limits.maxResults)
+	if key, ok := requestKey(ctx); ok {
+		result = capped(result, key.MaxResults)
+	}
+	return result
+}
+
+// capped returns a number, unlimited when not positive, capped by a maximum, none when not positive.
+func capped(number, maximum int) int {
+	if maximum <= 0 || number > 0 && number <= maximum {
+		return number
+	}
+	return maximum
+}
+
+// limitResults caps the number of segments and of videos of a search to the maximum of the server and of the API key
+// of its request.
+func (s *server) limitResults(ctx context.Context, settings *searchSettings) {
+	*settings.limit = s.maxResults(ctx, *settings.limit)
+	if *settings.sample > 0 {
+		*settings.sample = s.maxResults(ctx, *settings.sample)
+	}
+	if s.limits.maxVideos > 0 && *settings.maxVideos > s.limits.maxVideos {
+		*settings.maxVideos = s.limits.maxVideos // Not when 0, meaning the default of bleve.
+	}
+}
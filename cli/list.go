@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mooss/sininen"
+)
+
+var listCommand = &command{
+	name:        "list",
+	arguments:   "channel-id",
+	description: "List the indexed videos of a channel, with their duration and indexing date.",
+	run:         runList,
+}
+
+// dateFlag is a flag holding a date formatted as YYYY-MM-DD.
+type dateFlag struct{ time.Time }
+
+func (df *dateFlag) String() string {
+	if df.IsZero() {
+		return ""
+	}
+	return df.Format("2006-01-02")
+}
+
+func (df *dateFlag) Set(value string) error {
+	date, err := time.Parse("2006-01-02", value)
+	df.Time = date
+	return err
+}
+
+func addDateFlag(flags *flag.FlagSet, name, usage string) *dateFlag {
+	result := &dateFlag{}
+	flags.Var(result, name, usage)
+	return result
+}
+
+// formatDate formats a date, or returns - when it is unknown.
+func formatDate(date time.Time, layout string) string {
+	if date.IsZero() {
+		return "-"
+	}
+	return date.Local().Format(layout)
+}
+
+func runList(cmd *command, args []string) {
+	flags := cmd.flagSet()
+	glob := flags.String("match", "", "Only list the videos whose ID or title matches the given glob, e.g. 'Rome*'.")
+	after := addDateFlag(flags, "after", "Only list the videos uploaded on or after the given date, formatted as YYYY-MM-DD.")
+	before := addDateFlag(flags, "before", "Only list the videos uploaded before the given date, formatted as YYYY-MM-DD.")
+	langs := addLangFlag(flags)
+	cmd.parseArgs(flags, args, 1)
+	if _, err := path.Match(*glob, ""); err != nil {
+		perhapsExit(fmt.Errorf("%w: -match: %v", sininen.ErrInvalidOption, err), exitUsage)
+	}
+
+	indexFolder, err := indexFolder(flags.Arg(0))
+	perhapsExit(err, exitIndex)
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "ID\tLANG\tUPLOADED\tDURATION\tVIEWS\tINDEXED\tTITLE")
+	for _, lang := range langs.orDefault() {
+		index, err := sininen.OpenTranscriptionIndex(indexFolder, lang)
+		perhapsExit(err, exitIndex)
+		videos, err := sininen.ListVideos(index)
+		perhapsExit(err, exitIndex)
+		perhapsExit(index.Close(), exitIndex)
+
+		for _, video := range videos {
+			if *glob != "" {
+				idMatch, _ := path.Match(*glob, video.ID)
+				titleMatch, _ := path.Match(*glob, video.Title)
+				if !idMatch && !titleMatch {
+					continue
+				}
+			}
+			if (!after.IsZero() || !before.IsZero()) && video.UploadDate.IsZero() {
+				continue // Unknown upload dates are outside of any range.
+			}
+			if !after.IsZero() && video.UploadDate.Before(after.Time) ||
+				!before.IsZero() && !video.UploadDate.Before(before.Time) {
+				continue
+			}
+			views := "-"
+			if video.ViewCount > 0 {
+				views = strconv.FormatInt(video.ViewCount, 10)
+			}
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", video.ID, lang, formatDate(video.UploadDate, "2006-01-02"),
+				sininen.FormatTimestamp(video.Duration), views, formatDate(video.IndexedAt, "2006-01-02 15:04"), video.Title)
+		}
+	}
+	perhapsExit(writer.Flush(), exitOutput)
+}
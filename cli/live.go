@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mooss/sininen"
+	"golang.org/x/net/websocket"
+)
+
+// liveSearchDefaults are the flags of the live searches before the parameters of the requests, the last word of the
+// queries being matched as a prefix since it is being typed.
+var liveSearchDefaults = []string{"-format=json", "-color=never", "-prefix", "-limit=10"}
+
+// liveResults are the best segments of a query of a live search, sent as JSON.
+type liveResults struct {
+	Query    string                  `json:"q"`
+	Segments []sininen.ScoredSegment `json:"segments"`
+	Total    int                     `json:"total"` // Number of matching segments, beyond the limit.
+	Error    string                  `json:"error,omitempty"`
+}
+
+// serveLive searches a query as it is typed over a WebSocket, the parameters other than channel being parsed as the
+// flags of the search command, like serveSearch.
+// Each text message received is the whole query typed so far, searched once no other message has been received for the
+// debounce duration of the server, so that a fast typist does not trigger a search per keystroke.
+// Each search counts as a request against the rates of the API key and of the address of the client.
+func (s *server) serveLive(w http.ResponseWriter, r *http.Request) error {
+	settings, channelNames, err := parseSearchParameters(r.URL.Query(), liveSearchDefaults)
+	if err != nil {
+		return err
+	}
+	s.limitResults(r.Context(), settings)
+	websocket.Server{Handshake: checkOrigin, Handler: func(conn *websocket.Conn) {
+		defer conn.Close()
+		conn.MaxPayloadBytes = maxRequestBody
+		s.streamLive(r.Context(), conn, settings, channelNames)
+	}}.ServeHTTP(w, r)
+	return nil
+}
+
+// checkOrigin rejects the WebSockets opened by the pages of other sites, which browsers let open them with the API key
+// of the user in the api_key parameter, the origin having to be the host of the server when given.
+// The clients other than browsers send no origin and are accepted.
+func checkOrigin(config *websocket.Config, r *http.Request) error {
+	origin, err := websocket.Origin(config, r)
+	if err != nil {
+		return err
+	}
+	if origin != nil && !strings.EqualFold(origin.Host, r.Host) {
+		return fmt.Errorf("%w: the origin %s is not the host of the server", sininen.ErrInvalidOption, origin)
+	}
+	return nil
+}
+
+// streamLive answers the queries received over a WebSocket until it is closed.
+func (s *server) streamLive(ctx context.Context, conn *websocket.Conn, settings *searchSettings, channelNames []string) {
+	queries := make(chan string)
+	go func() {
+		defer close(queries)
+		for {
+			var query string
+			if err := websocket.Message.Receive(conn, &query); err != nil {
+				return
+			}
+			queries <- query
+		}
+	}()
+
+	var pending, sent string
+	var debounced <-chan time.Time
+	for {
+		select {
+		case query, open := <-queries:
+			if !open {
+				return
+			}
+			pending = query
+			debounced = time.After(s.debounce) // Restarted by every new query.
+		case <-debounced:
+			debounced = nil
+			if pending == sent {
+				continue // E.g. a character typed and erased.
+			}
+			sent = pending
+			if err := websocket.JSON.Send(conn, s.liveSearch(ctx, settings, channelNames, pending)); err != nil {
+				sininen.Log.Warnf("live search: %v", err)
+				conn.Close()
+				for range queries {
+				} // Until the receiving goroutine notices the closing.
+				return
+			}
+		}
+	}
+}
+
+// liveSearch returns the best segments of a query of a live search, no segment matching a blank query.
+// The search is charged to the client of the live search, whose request has the given context.
+func (s *server) liveSearch(ctx context.Context, settings *searchSettings, channelNames []string, query string) liveResults {
+	result := liveResults{Query: query, Segments: []sininen.ScoredSegment{}}
+	if strings.TrimSpace(query) == "" {
+		return result
+	}
+	if err := s.chargeSearch(ctx); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	videos, err := s.search(settings, channelNames, query)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	segments := settings.unpaginatedSegments(videos)
+	result.Total = len(segments)
+	result.Segments = settings.displayed(ctx, sininen.PaginateSegments(segments, *settings.offset, *settings.limit))
+	return result
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/mooss/sininen"
+)
+
+// levelFlag is a boolean flag setting the level of the library logger when given.
+type levelFlag sininen.Level
+
+func (lf levelFlag) String() string   { return "false" }
+func (lf levelFlag) IsBoolFlag() bool { return true }
+
+func (lf levelFlag) Set(value string) error {
+	enabled, err := strconv.ParseBool(value)
+	if enabled {
+		sininen.Log.Level = sininen.Level(lf)
+	}
+	return err
+}
+
+// addVerbosityFlags adds the flags selecting the messages displayed on stderr.
+func addVerbosityFlags(flags *flag.FlagSet) {
+	flags.Var(levelFlag(sininen.LevelError), "quiet", "Only display errors.")
+	flags.Var(levelFlag(sininen.LevelInfo), "verbose", "Display the progress of the main steps.")
+	flags.Var(levelFlag(sininen.LevelDebug), "debug", "Display details about each processed file.")
+}
+
+// quiet tells whether the -quiet flag was given.
+func quiet() bool {
+	return sininen.Log.Level > sininen.LevelWarning
+}
+
+// inform displays a message on stderr, unless the -quiet flag was given.
+func inform(format string, args ...interface{}) {
+	if !quiet() {
+		fmt.Fprintf(os.Stderr, format, args...)
+	}
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mooss/sininen"
+	"github.com/mooss/sininen/mcp"
+)
+
+var mcpCommand = &command{
+	name:        "mcp",
+	arguments:   "",
+	description: "Serve the searches as Model Context Protocol tools over stdin and stdout, for LLM assistants to cite the transcripts.",
+	details: "The assistant, or any other MCP client, starts the command and can then list the channels, " +
+		"search their transcripts for timestamped links to the matching moments and read the transcript around a moment, " +
+		"so that its answers cite their sources.\n" +
+		"The channels are searched through their bleve indexes, opened the first time they are needed.",
+	run: runMCP,
+}
+
+// mcpMaxLimit is the maximum number of segments returned by a search of an assistant.
+const mcpMaxLimit = 50
+
+// mcpSearchArguments are the arguments of the search_transcripts tool.
+type mcpSearchArguments struct {
+	Query    string   `json:"query"`
+	Channels []string `json:"channels"`
+	Limit    int      `json:"limit"`
+	Langs    []string `json:"langs"`
+	Video    string   `json:"video"`
+	After    string   `json:"after"`
+	Before   string   `json:"before"`
+	Tokens   int      `json:"tokens"` // Only for retrieve_context.
+}
+
+// mcpTranscriptArguments are the arguments of the get_transcript tool.
+type mcpTranscriptArguments struct {
+	Channel   string   `json:"channel"`
+	Video     string   `json:"video"`
+	Timestamp string   `json:"timestamp"`
+	Span      float64  `json:"span"` // In seconds.
+	Langs     []string `json:"langs"`
+}
+
+// mcpSchema returns the JSON schema of the arguments of a tool, from the schemas of its properties.
+func mcpSchema(properties map[string]interface{}, required ...string) map[string]interface{} {
+	return map[string]interface{}{"type": "object", "properties": properties, "required": append([]string{}, required...)}
+}
+
+// mcpProperty returns the JSON schema of an argument of a tool.
+func mcpProperty(kind, description string) map[string]interface{} {
+	if kind == "array" {
+		return map[string]interface{}{"type": kind, "items": map[string]interface{}{"type": "string"}, "description": description}
+	}
+	return map[string]interface{}{"type": kind, "description": description}
+}
+
+// unmarshalArguments reads the arguments of a call of a tool.
+func unmarshalArguments(raw json.RawMessage, arguments interface{}) error {
+	if err := json.Unmarshal(raw, arguments); err != nil {
+		return fmt.Errorf("%w: invalid arguments: %v", sininen.ErrInvalidOption, err)
+	}
+	return nil
+}
+
+func runMCP(cmd *command, args []string) {
+	flags := cmd.flagSet()
+	maxIndexes := flags.Int("max-open-indexes", 64, "Maximum number of indexes kept open, the least recently used one being closed to open another (unlimited when 0).")
+	limit := flags.Int("limit", 10, "Number of segments returned by a search when the assistant does not ask for another number, at most "+strconv.Itoa(mcpMaxLimit)+".")
+	excerpts := addContextFlag(flags)
+	platform := flags.String("platform", "youtube", "Platform the links point to, either youtube, peertube, vimeo or twitch.")
+	platformBase := flags.String("platform-base", "", "Instance URL for the peertube platform (peertube_instance of the configuration by default).")
+	langs := addLangFlag(flags)
+	cmd.parseArgs(flags, args, 0)
+	if *limit <= 0 || *limit > mcpMaxLimit {
+		perhapsExit(fmt.Errorf("%w: -limit must be between 1 and %d", sininen.ErrInvalidOption, mcpMaxLimit), exitUsage)
+	}
+	_, err := newURLBuilder(*platform, *platformBase)
+	perhapsExit(err, exitUsage) // Rather than failing every search.
+	defaultFlags := []string{"-color=never", "-context=" + excerpts.String(), "-platform=" + *platform, "-platform-base=" + *platformBase}
+	retrieveFlags := defaultFlags
+	if excerpts.String() == "0" {
+		retrieveFlags = append(defaultFlags[:len(defaultFlags):len(defaultFlags)], "-context=2") // Like the retrieve command.
+	}
+	filters := map[string]interface{}{
+		"query":    mcpProperty("string", "Plain words searched in any order, without phrase or exclusion syntax, the segments matching more of them ranking first."),
+		"channels": mcpProperty("array", "Channels searched, every downloaded one by default."),
+		"langs":    mcpProperty("array", "Languages of the searched transcripts, e.g. en."),
+		"video":    mcpProperty("string", "Only search the video with this ID, e.g. to find more moments of a video."),
+		"after":    mcpProperty("string", "Only search the videos uploaded since this date, as YYYY-MM-DD."),
+		"before":   mcpProperty("string", "Only search the videos uploaded before this date, as YYYY-MM-DD."),
+	}
+	withFilters := func(name string, property map[string]interface{}) map[string]interface{} {
+		result := map[string]interface{}{name: property}
+		for filter, schema := range filters {
+			result[filter] = schema
+		}
+		return result
+	}
+
+	s := newServer(*maxIndexes, 0)
+	defer s.close()
+	server := mcp.Server{
+		Name:    "sininen",
+		Version: openAPIVersion,
+		Instructions: "Search the transcripts of the user's video channels with search_transcripts or retrieve_context, " +
+			"read more of a video with get_transcript, and cite the timestamped links of the moments backing your answers.",
+		Tools: []mcp.Tool{
+			{
+				Name:        "list_channels",
+				Description: "List the downloaded channels whose transcripts can be searched.",
+				InputSchema: mcpSchema(map[string]interface{}{}),
+				Call: func(ctx context.Context, raw json.RawMessage) (string, error) {
+					channelNames, err := allChannels()
+					if err != nil {
+						return "", err
+					}
+					return strings.Join(channelNames, "\n"), nil
+				},
+			},
+			{
+				Name: "search_transcripts",
+				Description: "Search the transcripts of channels for a full-text query, returning the best matching moments as " +
+					"markdown, grouped by video, each one with a link to its timestamp to cite and what is said around it.",
+				InputSchema: mcpSchema(withFilters("limit",
+					mcpProperty("integer", fmt.Sprintf("Maximum number of moments returned, %d by default, at most %d.", *limit, mcpMaxLimit))),
+					"query"),
+				Call: func(ctx context.Context, raw json.RawMessage) (string, error) {
+					var arguments mcpSearchArguments
+					if err := unmarshalArguments(raw, &arguments); err != nil {
+						return "", err
+					}
+					return s.mcpSearch(ctx, arguments, defaultFlags, *limit, langs.orDefault())
+				},
+			},
+			{
+				Name: "retrieve_context",
+				Description: "Retrieve the excerpts of the transcripts of channels best matching a full-text query that fit in " +
+					"a token budget, deduplicated and ordered chronologically, each one preceded by a citation line with " +
+					"the title of its video, its times and its link, to ground a whole answer rather than to list moments.",
+				InputSchema: mcpSchema(withFilters("tokens",
+					mcpProperty("integer", fmt.Sprintf("Token budget of the excerpts, %d by default.", defaultTokenBudget))),
+					"query"),
+				Call: func(ctx context.Context, raw json.RawMessage) (string, error) {
+					var arguments mcpSearchArguments
+					if err := unmarshalArguments(raw, &arguments); err != nil {
+						return "", err
+					}
+					return s.mcpRetrieve(ctx, arguments, retrieveFlags, langs.orDefault())
+				},
+			},
+			{
+				Name: "get_transcript",
+				Description: "Read the transcript of a video around a timestamp, e.g. to check the context of a moment " +
+					"found by search_transcripts.",
+				InputSchema: mcpSchema(map[string]interface{}{
+					"channel":   mcpProperty("string", "Channel of the video."),
+					"video":     mcpProperty("string", "ID of the video, as given by search_transcripts."),
+					"timestamp": mcpProperty("string", "Moment read, as [[hh:]mm:]ss, 0 by default."),
+					"span":      mcpProperty("number", "Seconds of transcript read before and after the timestamp, 60 by default."),
+					"langs":     mcpProperty("array", "Languages of the transcript, e.g. en."),
+				}, "channel", "video"),
+				Call: func(ctx context.Context, raw json.RawMessage) (string, error) {
+					var arguments mcpTranscriptArguments
+					if err := unmarshalArguments(raw, &arguments); err != nil {
+						return "", err
+					}
+					if len(arguments.Langs) == 0 {
+						arguments.Langs = langs.orDefault()
+					}
+					return s.mcpTranscript(arguments)
+				},
+			},
+		},
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	inform("Serving the MCP tools over stdin and stdout.\n")
+	perhapsExit(server.Serve(ctx, os.Stdin, os.Stdout), exitOutput)
+}
+
+// mcpResults searches the query of a call of the search_transcripts or retrieve_context tool, the settings of the
+// search being parsed from the given default flags and the arguments of the call. The number of segments is not
+// limited when limit is not positive.
+func (s *server) mcpResults(arguments mcpSearchArguments, defaultFlags []string, limit int, langs []string) (*searchSettings, []string, sininen.SearchResultSequence, error) {
+	if strings.TrimSpace(arguments.Query) == "" {
+		return nil, nil, nil, fmt.Errorf("%w: the query is empty", sininen.ErrInvalidOption)
+	}
+	if len(arguments.Langs) > 0 {
+		langs = arguments.Langs
+	}
+	parameters := url.Values{"channel": arguments.Channels, "lang": langs}
+	if limit > 0 {
+		parameters.Set("limit", strconv.Itoa(limit))
+	}
+	if len(arguments.Channels) == 0 {
+		parameters.Set("all", "true")
+	}
+	for name, value := range map[string]string{"video": arguments.Video, "after": arguments.After, "before": arguments.Before} {
+		if value != "" {
+			parameters.Set(name, value)
+		}
+	}
+	settings, channelNames, err := parseSearchParameters(parameters, defaultFlags)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	videos, err := s.search(settings, channelNames, arguments.Query)
+	return settings, channelNames, videos, err
+}
+
+// mcpSearch answers a call of the search_transcripts tool with the best matching segments, as markdown.
+func (s *server) mcpSearch(ctx context.Context, arguments mcpSearchArguments, defaultFlags []string, limit int, langs []string) (string, error) {
+	if arguments.Limit > 0 {
+		limit = arguments.Limit
+	}
+	if limit > mcpMaxLimit {
+		limit = mcpMaxLimit
+	}
+	settings, channelNames, videos, err := s.mcpResults(arguments, defaultFlags, limit, langs)
+	if err != nil {
+		return "", err
+	}
+	segments := settings.segments(ctx, videos)
+	if len(segments) == 0 {
+		return fmt.Sprintf("Nothing matches %q in %s.", arguments.Query, strings.Join(channelNames, ", ")), nil
+	}
+	var result bytes.Buffer
+	if err := sininen.WriteMarkdown(&result, segments, settings.urls); err != nil {
+		return "", err
+	}
+	return result.String(), nil
+}
+
+// mcpRetrieve answers a call of the retrieve_context tool with the excerpts fitting in its token budget, as written by
+// sininen.WriteContext.
+func (s *server) mcpRetrieve(ctx context.Context, arguments mcpSearchArguments, defaultFlags []string, langs []string) (string, error) {
+	tokens := arguments.Tokens
+	if tokens <= 0 {
+		tokens = defaultTokenBudget
+	}
+	if tokens > maxTokenBudget {
+		tokens = maxTokenBudget
+	}
+	settings, channelNames, videos, err := s.mcpResults(arguments, defaultFlags, 0, langs)
+	if err != nil {
+		return "", err
+	}
+	excerpts := settings.retrieveContext(ctx, videos, tokens)
+	if len(excerpts) == 0 {
+		return fmt.Sprintf("Nothing matches %q in %s.", arguments.Query, strings.Join(channelNames, ", ")), nil
+	}
+	var result bytes.Buffer
+	if err := sininen.WriteContext(&result, excerpts); err != nil {
+		return "", err
+	}
+	return result.String(), nil
+}
+
+// readTranscript reads the transcript of a video like the readTranscript function, but from the indexes kept open by the
+// server, which cannot be opened a second time.
+func (s *server) readTranscript(channelName, id string, langs []string) ([]sininen.SegmentHit, error) {
+	for _, lang := range langs {
+		index, err := s.channelIndex(channelName, lang)
+		if err != nil {
+			return nil, err
+		}
+		transcript, err := sininen.ReadTranscript(index.index, id, "")
+		s.release(index)
+		if errors.Is(err, sininen.ErrVideoNotFound) {
+			continue
+		}
+		return transcript, err
+	}
+	return nil, fmt.Errorf("%w: %s in %s for languages %s", sininen.ErrVideoNotFound, id, channelName, strings.Join(langs, ", "))
+}
+
+// mcpTranscript answers a call of the get_transcript tool, one line per segment starting with its timestamp.
+func (s *server) mcpTranscript(arguments mcpTranscriptArguments) (string, error) {
+	var at time.Duration
+	if arguments.Timestamp != "" {
+		var err error
+		if at, err = sininen.ParseTimestamp(arguments.Timestamp); err != nil {
+			return "", err
+		}
+	}
+	span := time.Minute
+	if arguments.Span > 0 {
+		span = time.Duration(arguments.Span * float64(time.Second))
+	}
+	transcript, err := s.readTranscript(arguments.Channel, arguments.Video, arguments.Langs)
+	if err != nil {
+		return "", err
+	}
+	around := sininen.TranscriptAround(transcript, at, span)
+	if len(around) == 0 {
+		return "", fmt.Errorf("%w: %s is past the end of the transcript", sininen.ErrInvalidOption, sininen.FormatTimestamp(at))
+	}
+	var result strings.Builder
+	for _, segment := range around {
+		fmt.Fprintf(&result, "%s %s\n", sininen.FormatTimestamp(segment.StartTime), strings.ReplaceAll(segment.Text, "\n", " "))
+	}
+	return result.String(), nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/mooss/sininen"
+)
+
+var metadataCommand = &command{
+	name:        "metadata",
+	arguments:   "channel-id",
+	description: "Fetch the title, upload date, duration and view count of the indexed videos from the YouTube Data API.",
+	details:     "An API key is needed, given with -key, the youtube_api_key configuration key or SININEN_YOUTUBE_API_KEY.",
+	run:         runMetadata,
+}
+
+func runMetadata(cmd *command, args []string) {
+	flags := cmd.flagSet()
+	key := flags.String("key", defaults.YouTubeAPIKey, "YouTube Data API key.")
+	langs := addLangFlag(flags)
+	cmd.parseArgs(flags, args, 1)
+
+	indexFolder, err := indexFolder(flags.Arg(0))
+	perhapsExit(err, exitIndex)
+	indexes := map[string]bleve.Index{}
+	ids := []string{}
+	seen := map[string]bool{}
+	for _, lang := range langs.orDefault() {
+		index, err := sininen.OpenTranscriptionIndex(indexFolder, lang)
+		perhapsExit(err, exitIndex)
+		indexes[lang] = index
+		videos, err := sininen.ListVideos(index)
+		perhapsExit(err, exitIndex)
+		for _, video := range videos {
+			if !seen[video.ID] {
+				seen[video.ID] = true
+				ids = append(ids, video.ID)
+			}
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	metadata, err := sininen.YouTubeDataAPI{Key: *key}.FetchMetadata(ctx, ids)
+	perhapsExit(err, exitDownload)
+	for lang, index := range indexes {
+		updated, err := sininen.StoreMetadata(index, metadata)
+		perhapsExit(err, exitIndex)
+		perhapsExit(index.Close(), exitIndex)
+		inform("Updated the metadata of %d videos out of %d in %s.\n", updated, len(ids), lang)
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mooss/sininen"
+)
+
+var saveCommand = &command{
+	name:        "save",
+	arguments:   "name channel-id... search-query",
+	description: "Save a search under a name, to run it later with search -saved name.",
+	details: "With -alert, the segments of the videos newly indexed by the watch, sync and serve commands that match the search " +
+		"are recorded, and served as a feed by GET /alerts?name= of the serve command.",
+	run: runSave,
+}
+
+var bookmarksCommand = &command{
+	name:        "bookmarks",
+	arguments:   "",
+	description: "List or export the bookmarked segments, added with search -bookmark, or list the saved searches.",
+	run:         runBookmarks,
+}
+
+// savedSearch is a search saved under a name.
+type savedSearch struct {
+	Name     string    `json:"name"`
+	Channels []string  `json:"channels"`
+	Query    string    `json:"query"`
+	Saved    time.Time `json:"saved"`
+	Alert    bool      `json:"alert,omitempty"` // Whether the matches in the newly indexed videos are recorded, see checkAlerts.
+}
+
+// bookmark is a segment kept from the results of a search.
+type bookmark struct {
+	Added    time.Time             `json:"added"`
+	Channels []string              `json:"channels"`
+	Query    string                `json:"query"`
+	Segment  sininen.ScoredSegment `json:"segment"`
+}
+
+// notebook holds the saved searches and the bookmarks, persisted as a JSON file.
+type notebook struct {
+	Searches  []savedSearch `json:"searches"`
+	Bookmarks []bookmark    `json:"bookmarks"`
+}
+
+// notebookPath returns the location of the notebook file.
+func notebookPath() (string, error) {
+	if defaults.NotebookFile != "" {
+		return defaults.NotebookFile, nil
+	}
+	return configFile("notebook.json")
+}
+
+// loadNotebook reads the notebook file, an empty notebook being returned when it does not exist.
+func loadNotebook() (*notebook, error) {
+	filename, err := notebookPath()
+	if err != nil {
+		return nil, err
+	}
+	raw, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return &notebook{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	result := &notebook{}
+	if err := json.Unmarshal(raw, result); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", sininen.ErrBadFormat, filename, err)
+	}
+	return result, nil
+}
+
+// store writes the notebook file, replacing it only once it is completely written.
+func (nb *notebook) store() error {
+	filename, err := notebookPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	raw, err := json.MarshalIndent(nb, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filename+".tmp", raw, 0644); err != nil {
+		return err
+	}
+	return os.Rename(filename+".tmp", filename)
+}
+
+// search returns the search saved under the given name.
+func (nb *notebook) search(name string) (savedSearch, error) {
+	for _, search := range nb.Searches {
+		if search.Name == name {
+			return search, nil
+		}
+	}
+	return savedSearch{}, fmt.Errorf("%w: no search saved as %q", sininen.ErrInvalidOption, name)
+}
+
+// save saves a search, replacing the one saved under the same name.
+func (nb *notebook) save(search savedSearch) {
+	for i := range nb.Searches {
+		if nb.Searches[i].Name == search.Name {
+			nb.Searches[i] = search
+			return
+		}
+	}
+	nb.Searches = append(nb.Searches, search)
+}
+
+// updateNotebook loads the notebook, modifies it and stores it back.
+func updateNotebook(modify func(nb *notebook) error) error {
+	nb, err := loadNotebook()
+	if err != nil {
+		return err
+	}
+	if err := modify(nb); err != nil {
+		return err
+	}
+	return nb.store()
+}
+
+// bookmarkRank bookmarks the segment of the given rank, starting from 1.
+func bookmarkRank(channelNames []string, query string, scoredSegments []sininen.ScoredSegment, rank int) error {
+	if rank < 1 || rank > len(scoredSegments) {
+		return fmt.Errorf("no matching segment of rank %d", rank)
+	}
+	return updateNotebook(func(nb *notebook) error {
+		nb.Bookmarks = append(nb.Bookmarks, bookmark{time.Now(), channelNames, query, scoredSegments[rank-1]})
+		return nil
+	})
+}
+
+func runSave(cmd *command, args []string) {
+	flags := cmd.flagSet()
+	alert := flags.Bool("alert", false, "Record the segments of the newly indexed videos matching the search, to follow it as a feed.")
+	cmd.parseMinArgs(flags, args, 3)
+	search := savedSearch{
+		Name:     flags.Arg(0),
+		Channels: flags.Args()[1 : flags.NArg()-1],
+		Query:    flags.Arg(flags.NArg() - 1),
+		Saved:    time.Now(),
+		Alert:    *alert,
+	}
+	perhapsExit(updateNotebook(func(nb *notebook) error {
+		nb.save(search)
+		return nil
+	}), exitOutput)
+}
+
+func runBookmarks(cmd *command, args []string) {
+	flags := cmd.flagSet()
+	format := flags.String("format", "", "Export the bookmarked segments in the given format, one of "+strings.Join(sininen.FormatterNames(), ", ")+", instead of listing them.")
+	platform := flags.String("platform", "youtube", "Platform the exported links point to, either youtube, peertube, vimeo, twitch, file or mpv.")
+	platformBase := flags.String("platform-base", "", "Instance URL for the peertube platform (peertube_instance of the configuration by default), video folder for the file and mpv platforms.")
+	remove := flags.Int("delete", 0, "Delete the bookmark with the given number instead of listing them.")
+	searches := flags.Bool("searches", false, "List the saved searches instead of the bookmarks.")
+	unsave := flags.String("unsave", "", "Delete the search saved under the given name instead of listing them.")
+	cmd.parseArgs(flags, args, 0)
+
+	switch {
+	case *remove != 0:
+		perhapsExit(updateNotebook(func(nb *notebook) error {
+			if *remove < 1 || *remove > len(nb.Bookmarks) {
+				return fmt.Errorf("%w: no bookmark %d", sininen.ErrInvalidOption, *remove)
+			}
+			nb.Bookmarks = append(nb.Bookmarks[:*remove-1], nb.Bookmarks[*remove:]...)
+			return nil
+		}), exitOutput)
+		return
+	case *unsave != "":
+		perhapsExit(updateNotebook(func(nb *notebook) error {
+			if _, err := nb.search(*unsave); err != nil {
+				return err
+			}
+			kept := []savedSearch{}
+			for _, search := range nb.Searches {
+				if search.Name != *unsave {
+					kept = append(kept, search)
+				}
+			}
+			nb.Searches = kept
+			return nil
+		}), exitOutput)
+		return
+	}
+
+	nb, err := loadNotebook()
+	perhapsExit(err, exitUsage)
+	if *searches {
+		writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(writer, "NAME\tSAVED\tCHANNELS\tALERT\tQUERY")
+		for _, search := range nb.Searches {
+			alert := "no"
+			if search.Alert {
+				alert = "yes"
+			}
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\n", search.Name, search.Saved.Local().Format("2006-01-02 15:04"),
+				strings.Join(search.Channels, ","), alert, search.Query)
+		}
+		perhapsExit(writer.Flush(), exitOutput)
+		return
+	}
+
+	if *format != "" {
+		urls, err := newURLBuilder(*platform, *platformBase)
+		perhapsExit(err, exitUsage)
+		segments := make([]sininen.ScoredSegment, 0, len(nb.Bookmarks))
+		for _, bookmark := range nb.Bookmarks {
+			segments = append(segments, bookmark.Segment)
+		}
+		perhapsExit(sininen.WriteFormat(os.Stdout, *format, segments, sininen.FormatOptions{Title: "Bookmarks", URLs: urls}), exitOutput)
+		return
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "#\tADDED\tVIDEO\tTIME\tQUERY\tTEXT")
+	for i, bookmark := range nb.Bookmarks {
+		segment := bookmark.Segment
+		fmt.Fprintf(writer, "%d\t%s\t%s\t%s\t%s\t%s\n", i+1, bookmark.Added.Local().Format("2006-01-02 15:04"),
+			segment.DisplayName(), sininen.FormatTimestamp(segment.StartTime), bookmark.Query, strings.ReplaceAll(segment.Text, "\n", " "))
+	}
+	perhapsExit(writer.Flush(), exitOutput)
+}
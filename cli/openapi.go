@@ -0,0 +1,376 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/mooss/sininen"
+)
+
+// openAPIObject is an object of the OpenAPI description of the server.
+type openAPIObject = map[string]interface{}
+
+// openAPIVersion is the version of the HTTP API described by openAPISpec, to be increased on breaking changes.
+const openAPIVersion = "1.0.0"
+
+// jsonRef returns a reference to a schema of the components of the description.
+func jsonRef(name string) openAPIObject {
+	return openAPIObject{"$ref": "#/components/schemas/" + name}
+}
+
+// arrayOf returns the schema of an array of items.
+func arrayOf(items openAPIObject) openAPIObject {
+	return openAPIObject{"type": "array", "items": items}
+}
+
+// jsonContent returns the content of a JSON response or request body.
+func jsonContent(schema openAPIObject) openAPIObject {
+	return openAPIObject{contentTypes["json"]: openAPIObject{"schema": schema}}
+}
+
+// queryParameter returns a parameter of the query string.
+func queryParameter(name, description string, schema openAPIObject, required bool) openAPIObject {
+	return openAPIObject{"name": name, "in": "query", "description": description, "required": required, "schema": schema}
+}
+
+// flagSchema returns the schema of the values of a flag, deduced from the type of the value, along with its default.
+func flagSchema(f *flag.Flag) openAPIObject {
+	switch value := f.Value.(type) {
+	case *languages:
+		return arrayOf(openAPIObject{"type": "string"})
+	case *dateFlag:
+		return openAPIObject{"type": "string", "format": "date"}
+	case flag.Getter:
+		switch typed := value.Get().(type) {
+		case bool:
+			return openAPIObject{"type": "boolean", "default": typed}
+		case int:
+			return openAPIObject{"type": "integer", "default": typed}
+		case int64:
+			return openAPIObject{"type": "integer", "format": "int64", "default": typed}
+		case float64:
+			return openAPIObject{"type": "number", "default": typed}
+		case time.Duration:
+			return openAPIObject{"type": "string", "example": "8760h", "default": typed.String()}
+		case string:
+			if typed == "" {
+				return openAPIObject{"type": "string"}
+			}
+			return openAPIObject{"type": "string", "default": typed}
+		}
+	}
+	return openAPIObject{"type": "string"}
+}
+
+// searchParameters returns the parameters of the search endpoint, i.e. the flags of the search command that the server
+// accepts with the given defaults, generated from their definitions so that the description always matches them.
+func searchParameters(defaults []string) []openAPIObject {
+	result := []openAPIObject{
+		queryParameter("channel", "Channel to search, can be repeated to search several channels.", arrayOf(openAPIObject{"type": "string"}), false),
+		queryParameter("q", "Search query.", openAPIObject{"type": "string"}, true),
+		queryParameter("cursor", "Cursor of the page, given by the X-Next-Cursor header of the previous page. "+
+			"Unlike offset, it keeps its position when the indexes are updated between the pages.", openAPIObject{"type": "string"}, false),
+	}
+	flags := flag.NewFlagSet("search", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	addSearchFlags(flags)
+	flags.Parse(defaults) // Cannot fail, the defaults being valid flags.
+	flags.VisitAll(func(f *flag.Flag) {
+		if unservedSearchFlags[f.Name] {
+			return
+		}
+		schema := flagSchema(f)
+		if f.Name == "format" {
+			schema["enum"] = sininen.FormatterNames()
+		}
+		result = append(result, queryParameter(f.Name, f.Usage, schema, false))
+	})
+	return result
+}
+
+// searchContent returns the content of the responses of the search endpoint, in the output formats served.
+func searchContent() openAPIObject {
+	result := openAPIObject{"text/plain": openAPIObject{"schema": openAPIObject{"type": "string"}}}
+	for format, contentType := range contentTypes {
+		if format == "json" {
+			result[contentType] = openAPIObject{"schema": arrayOf(jsonRef("Segment"))}
+		} else if _, exists := result[contentType]; !exists {
+			result[contentType] = openAPIObject{"schema": openAPIObject{"type": "string"}}
+		}
+	}
+	return result
+}
+
+// openAPISchemas returns the schemas of the JSON objects of the API.
+func openAPISchemas() openAPIObject {
+	str := openAPIObject{"type": "string"}
+	number := openAPIObject{"type": "number"}
+	integer := openAPIObject{"type": "integer"}
+	boolean := openAPIObject{"type": "boolean"}
+	timing := openAPIObject{
+		"start_time":      openAPIObject{"type": "number", "description": "In seconds."},
+		"start_timestamp": openAPIObject{"type": "string", "example": "00:01:05"},
+		"end_time":        number,
+		"end_timestamp":   str,
+	}
+	withTiming := func(properties openAPIObject) openAPIObject {
+		for name, schema := range timing {
+			properties[name] = schema
+		}
+		return properties
+	}
+	return openAPIObject{
+		"Excerpt": openAPIObject{
+			"type":       "object",
+			"properties": withTiming(openAPIObject{"text": str}),
+		},
+		"TextSpan": openAPIObject{
+			"type":       "object",
+			"properties": openAPIObject{"start": integer, "end": openAPIObject{"type": "integer", "description": "Exclusive, in bytes."}},
+		},
+		"Segment": openAPIObject{
+			"type":     "object",
+			"required": []string{"start_time", "end_time", "text", "sorted_terms", "score", "id"},
+			"properties": withTiming(openAPIObject{
+				"text":            str,
+				"sorted_terms":    arrayOf(str),
+				"term_counts":     openAPIObject{"type": "object", "additionalProperties": integer},
+				"highlights":      arrayOf(jsonRef("TextSpan")),
+				"before":          arrayOf(jsonRef("Excerpt")),
+				"after":           arrayOf(jsonRef("Excerpt")),
+				"chapter":         openAPIObject{"type": "string", "description": "Title of the chapter of the video the segment falls in."},
+				"score":           number,
+				"id":              openAPIObject{"type": "string", "description": "ID of the video."},
+				"title":           str,
+				"author":          openAPIObject{"type": "string", "description": "Channel of the video, resolved with oembed."},
+				"upload_date":     openAPIObject{"type": "string", "format": "date"},
+				"video_duration":  openAPIObject{"type": "number", "description": "In seconds."},
+				"view_count":      openAPIObject{"type": "integer", "format": "int64"},
+				"thumbnail":       openAPIObject{"type": "string", "format": "uri"},
+				"sponsor":         openAPIObject{"type": "string", "description": "Category of the sponsor segment the segment falls inside."},
+				"translated_from": openAPIObject{"type": "string", "description": "Original language of the machine-translated subtitles."},
+			}),
+		},
+		"ContextExcerpt": openAPIObject{
+			"type":     "object",
+			"required": []string{"id", "start_time", "end_time", "text", "url", "score", "tokens"},
+			"properties": openAPIObject{
+				"id":          openAPIObject{"type": "string", "description": "ID of the video."},
+				"title":       str,
+				"upload_date": openAPIObject{"type": "string", "format": "date"},
+				"start_time":  openAPIObject{"type": "number", "description": "In seconds."},
+				"end_time":    number,
+				"text":        str,
+				"url":         openAPIObject{"type": "string", "format": "uri", "description": "Link citing the excerpt."},
+				"score":       number,
+				"tokens":      openAPIObject{"type": "integer", "description": "Estimated number of tokens of the excerpt and its citation."},
+			},
+		},
+		"Stats": openAPIObject{
+			"type": "object",
+			"properties": openAPIObject{
+				"lang":             str,
+				"videos":           integer,
+				"transcript_hours": number,
+				"size":             openAPIObject{"type": "integer", "format": "int64", "description": "In bytes."},
+				"last_update":      openAPIObject{"type": "string", "format": "date-time"},
+				"top_terms":        arrayOf(jsonRef("TermCount")),
+			},
+		},
+		"TermCount": openAPIObject{
+			"type":       "object",
+			"properties": openAPIObject{"term": str, "count": openAPIObject{"type": "integer", "description": "Number of videos."}},
+		},
+		"Update": openAPIObject{
+			"type": "object",
+			"properties": openAPIObject{
+				"lang":    str,
+				"added":   arrayOf(str),
+				"updated": arrayOf(str),
+				"removed": arrayOf(str),
+			},
+		},
+		"Health": openAPIObject{
+			"type": "object",
+			"properties": openAPIObject{
+				"healthy": boolean,
+				"channels": arrayOf(openAPIObject{
+					"type": "object",
+					"properties": openAPIObject{
+						"channel": str,
+						"healthy": boolean,
+						"indexes": arrayOf(openAPIObject{
+							"type": "object",
+							"properties": openAPIObject{
+								"lang":            str,
+								"healthy":         boolean,
+								"videos":          integer,
+								"latency_seconds": number,
+								"error":           str,
+								"skipped":         str,
+							},
+						}),
+					},
+				}),
+			},
+		},
+		"Error": openAPIObject{
+			"type":       "object",
+			"properties": openAPIObject{"error": str},
+		},
+	}
+}
+
+// openAPIOperation returns an operation of the API, its failures being described by the Error schema.
+func openAPIOperation(id, summary string, parameters []openAPIObject, content openAPIObject) openAPIObject {
+	failure := openAPIObject{"description": "Failure.", "content": jsonContent(jsonRef("Error"))}
+	return openAPIObject{
+		"operationId": id,
+		"summary":     summary,
+		"parameters":  parameters,
+		"responses": openAPIObject{
+			"200":     openAPIObject{"description": "Success.", "content": content},
+			"default": failure,
+		},
+	}
+}
+
+// healthOperation returns an operation checking the health of indexes, which needs no API key and answers with the
+// 503 status when one is not healthy.
+func healthOperation(id, summary string, parameters []openAPIObject) openAPIObject {
+	result := openAPIOperation(id, summary, parameters, jsonContent(jsonRef("Health")))
+	result["responses"].(openAPIObject)["503"] = openAPIObject{"description": "Unhealthy.", "content": jsonContent(jsonRef("Health"))}
+	result["security"] = []openAPIObject{}
+	return result
+}
+
+// openAPISpec returns the OpenAPI description of the HTTP API of the server, from which clients can be generated.
+func openAPISpec() openAPIObject {
+	channel := queryParameter("channel", "Channel of the indexes.", openAPIObject{"type": "string"}, true)
+	lang := queryParameter("lang", "Language of the indexes, can be repeated (the configured languages by default).",
+		arrayOf(openAPIObject{"type": "string"}), false)
+
+	search := openAPIOperation("search", "Search the transcripts of channels.", searchParameters(servedSearchDefaults), searchContent())
+	search["responses"].(openAPIObject)["200"].(openAPIObject)["headers"] = openAPIObject{
+		"X-Next-Cursor": openAPIObject{
+			"description": "Cursor of the next page, given when other segments follow the page.",
+			"schema":      openAPIObject{"type": "string"},
+		},
+		"Link": openAPIObject{
+			"description": `URL of the next page, as <url>; rel="next".`,
+			"schema":      openAPIObject{"type": "string"},
+		},
+	}
+	var liveParameters []openAPIObject
+	for _, parameter := range searchParameters(liveSearchDefaults) {
+		if name := parameter["name"]; name != "q" && name != "cursor" && name != "format" {
+			liveParameters = append(liveParameters, parameter)
+		}
+	}
+	live := openAPIOperation("live", "Search the queries sent as text messages over a WebSocket as they are typed, "+
+		"each answered by a JSON message with the q, segments, total and error fields.", liveParameters, nil)
+	live["responses"] = openAPIObject{
+		"101":     openAPIObject{"description": "Switching to the WebSocket protocol."},
+		"default": live["responses"].(openAPIObject)["default"],
+	}
+	var contextParameters []openAPIObject
+	for _, parameter := range searchParameters(contextSearchDefaults) {
+		if parameter["name"] == "cursor" {
+			continue // The excerpts are not paginated.
+		}
+		if parameter["name"] == "format" {
+			parameter["schema"] = openAPIObject{"type": "string", "enum": []string{"json", "text"}, "default": "json"}
+		}
+		contextParameters = append(contextParameters, parameter)
+	}
+	contextParameters = append(contextParameters, queryParameter("tokens", "Token budget of the excerpts, citations included.",
+		openAPIObject{"type": "integer", "default": defaultTokenBudget, "minimum": 1, "maximum": maxTokenBudget}, false))
+	retrieval := openAPIOperation("context", "Retrieve the excerpts of the transcripts best matching a query that fit in a token budget, "+
+		"deduplicated and ordered chronologically, for retrieval-augmented generation.", contextParameters, openAPIObject{
+		contentTypes["json"]: openAPIObject{"schema": arrayOf(jsonRef("ContextExcerpt"))},
+		"text/plain":         openAPIObject{"schema": openAPIObject{"type": "string"}},
+	})
+	suggest := openAPIOperation("suggest", "Suggest the most frequent terms of the indexes starting with a prefix.", []openAPIObject{
+		queryParameter("channel", "Channel of the indexes, can be repeated.", arrayOf(openAPIObject{"type": "string"}), false),
+		queryParameter("all", "Suggest the terms of every downloaded channel instead.", openAPIObject{"type": "boolean", "default": false}, false),
+		lang,
+		queryParameter("prefix", "Start of the terms.", openAPIObject{"type": "string"}, true),
+		queryParameter("limit", "Maximum number of terms.", openAPIObject{"type": "integer", "default": 10, "minimum": 1}, false),
+	}, jsonContent(arrayOf(jsonRef("TermCount"))))
+	alertFormats := openAPIObject{"type": "string", "enum": []string{"atom", "rss"}, "default": "atom"}
+	alerts := openAPIOperation("alerts", "Feed of the matches of an alert.", []openAPIObject{
+		queryParameter("name", "Name of the saved search with an alert.", openAPIObject{"type": "string"}, true),
+		queryParameter("format", "Format of the feed.", alertFormats, false),
+		queryParameter("platform", "Platform the links point to.", openAPIObject{"type": "string", "default": "youtube"}, false),
+		queryParameter("platform-base", "Instance URL for the peertube platform.", openAPIObject{"type": "string"}, false),
+	}, openAPIObject{
+		contentTypes["atom"]: openAPIObject{"schema": openAPIObject{"type": "string"}},
+		contentTypes["rss"]:  openAPIObject{"schema": openAPIObject{"type": "string"}},
+	})
+	graphql := openAPIOperation("graphql", "Run a GraphQL query, see cli/schema.graphql.", []openAPIObject{},
+		jsonContent(openAPIObject{"type": "object"}))
+	graphql["requestBody"] = openAPIObject{
+		"required": true,
+		"content": jsonContent(openAPIObject{
+			"type":     "object",
+			"required": []string{"query"},
+			"properties": openAPIObject{
+				"query":         openAPIObject{"type": "string"},
+				"operationName": openAPIObject{"type": "string"},
+				"variables":     openAPIObject{"type": "object"},
+			},
+		}),
+	}
+
+	healthz := healthOperation("healthz", "Check that the indexes kept open by the server still answer a query.", []openAPIObject{})
+	readyz := healthOperation("readyz", "Check that the indexes of the channels answer a query, without evicting the open indexes.", []openAPIObject{
+		queryParameter("channel", "Channel of the indexes, can be repeated (the sync_channels of the configuration by default, or else the open indexes).",
+			arrayOf(openAPIObject{"type": "string"}), false),
+		lang,
+		queryParameter("strict", "Fail on the indexes not created yet instead of skipping them.", openAPIObject{"type": "boolean", "default": false}, false),
+	})
+
+	return openAPIObject{
+		"openapi": "3.0.3",
+		"info": openAPIObject{
+			"title":       "sininen",
+			"description": "Search through the transcripts of online videos.",
+			"version":     openAPIVersion,
+		},
+		"paths": openAPIObject{
+			"/channels": openAPIObject{"get": openAPIOperation("channels", "List the downloaded channels.", []openAPIObject{},
+				jsonContent(arrayOf(openAPIObject{"type": "string"})))},
+			"/search":  openAPIObject{"get": search},
+			"/context": openAPIObject{"get": retrieval},
+			"/suggest": openAPIObject{"get": suggest},
+			"/status": openAPIObject{"get": openAPIOperation("status", "Describe the indexes of a channel.", []openAPIObject{channel, lang},
+				jsonContent(arrayOf(jsonRef("Stats"))))},
+			"/reindex": openAPIObject{"post": openAPIOperation("reindex", "Index the new and modified subtitles of a channel.",
+				[]openAPIObject{channel, lang}, jsonContent(arrayOf(jsonRef("Update"))))},
+			"/live":    openAPIObject{"get": live},
+			"/alerts":  openAPIObject{"get": alerts},
+			"/graphql": openAPIObject{"post": graphql},
+			"/metrics": openAPIObject{"get": openAPIOperation("metrics", "Metrics in the Prometheus format.", []openAPIObject{},
+				openAPIObject{"text/plain": openAPIObject{"schema": openAPIObject{"type": "string"}}})},
+			"/healthz": openAPIObject{"get": healthz},
+			"/readyz":  openAPIObject{"get": readyz},
+		},
+		"components": openAPIObject{
+			"schemas": openAPISchemas(),
+			"securitySchemes": openAPIObject{
+				"bearer": openAPIObject{"type": "http", "scheme": "bearer"},
+				"header": openAPIObject{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+				"query":  openAPIObject{"type": "apiKey", "in": "query", "name": apiKeyParameter},
+			},
+		},
+		// The keys are only required when api_keys is configured.
+		"security": []openAPIObject{{}, {"bearer": []string{}}, {"header": []string{}}, {"query": []string{}}},
+	}
+}
+
+// serveOpenAPI writes the OpenAPI description of the API.
+func (s *server) serveOpenAPI(w http.ResponseWriter, r *http.Request) error {
+	return writeJSONResponse(w, openAPISpec())
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+
+	"github.com/mooss/sininen"
+)
+
+var peertubeCommand = &command{
+	name:        "peertube",
+	arguments:   "channel-handle",
+	description: "Download the captions of the videos of a PeerTube channel through the REST API of its instance, and index them.",
+	details:     "The channel is given by its handle, e.g. name or name@instance, and its subtitles folder is named after it. The captions already downloaded are skipped. The results link to the videos with -platform peertube.",
+	run:         runPeerTube,
+}
+
+func runPeerTube(cmd *command, args []string) {
+	flags := cmd.flagSet()
+	instance := flags.String("instance", defaults.PeerTube, "URL of the PeerTube instance hosting the channel, e.g. https://framatube.org.")
+	var langs languages
+	flags.Var(&langs, "lang", "Language of the captions to download, can be repeated to download several languages (all of them by default).")
+	cmd.parseArgs(flags, args, 1)
+	if *instance == "" {
+		perhapsExit(fmt.Errorf("%w: the instance is given by -instance or peertube_instance in the configuration", sininen.ErrInvalidOption), exitUsage)
+	}
+
+	channelName := flags.Arg(0)
+	destFolder := filepath.Join(defaults.SubtitlesRoot, channelName)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	files, err := sininen.PeerTubeClient{Instance: *instance}.DownloadChannelCaptions(ctx, channelName, destFolder, langs, newProgress("Downloading"))
+	perhapsExit(err, exitDownload)
+	inform("Downloaded %d captions files in %s.\n", len(files), destFolder)
+	indexDownloaded(channelName, destFolder, files)
+}
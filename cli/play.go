@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strconv"
+
+	"github.com/mooss/sininen"
+)
+
+// playCommand returns the command opening a segment with a player, either browser or mpv.
+// The links of the mpv platform are already command lines and are run as is.
+func playCommand(player, platform string, urls sininen.URLBuilder, segment sininen.ScoredSegment) (*exec.Cmd, error) {
+	url := sininen.SegmentURL(urls, segment)
+	if platform == "mpv" {
+		return exec.Command("sh", "-c", url), nil
+	}
+
+	switch player {
+	case "mpv":
+		if platform == "file" {
+			return nil, fmt.Errorf("local files are played with mpv through -platform mpv")
+		}
+		// mpv relies on youtube-dl to play online videos, and starts them with --start rather than from the link.
+		return exec.Command("mpv", "--start="+strconv.Itoa(int(segment.StartTime.Seconds())), url), nil
+	case "browser":
+		switch runtime.GOOS {
+		case "darwin":
+			return exec.Command("open", url), nil
+		case "windows":
+			return exec.Command("rundll32", "url.dll,FileProtocolHandler", url), nil
+		}
+		return exec.Command("xdg-open", url), nil
+	}
+	return nil, fmt.Errorf("unknown player %q", player)
+}
+
+// play opens a segment with a player without waiting for it to exit, returning what was opened.
+func play(player, platform string, urls sininen.URLBuilder, segment sininen.ScoredSegment) (string, error) {
+	cmd, err := playCommand(player, platform, urls, segment)
+	if err != nil {
+		return "", err
+	}
+	if err := cmd.Start(); err != nil {
+		return "", err
+	}
+	go cmd.Wait()
+	return sininen.SegmentURL(urls, segment), nil
+}
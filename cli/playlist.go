@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"path/filepath"
+
+	"github.com/mooss/sininen"
+)
+
+var playlistCommand = &command{
+	name:        "playlist",
+	arguments:   "channel-id playlist-id",
+	description: "Download and index the subtitles of the videos of a YouTube playlist, to search through them with search -playlist.",
+	details:     "The subtitles are saved in the folder of the channel, which can be a new one dedicated to the playlist.",
+	run:         runPlaylist,
+}
+
+func runPlaylist(cmd *command, args []string) {
+	flags := cmd.flagSet()
+	noDownload := flags.Bool("no-download", false, "Only tag the videos of the playlist whose subtitles were already downloaded and indexed.")
+	langs := addLangFlag(flags)
+	cmd.parseArgs(flags, args, 2)
+
+	channelName, playlistID := flags.Arg(0), flags.Arg(1)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	client := sininen.YouTubeClient{}
+	ids, err := client.PlaylistVideos(ctx, playlistID)
+	perhapsExit(err, exitDownload)
+	inform("Found %d videos in the playlist %s.\n", len(ids), playlistID)
+	if !*noDownload {
+		destFolder := filepath.Join(defaults.SubtitlesRoot, channelName)
+		written, err := client.DownloadVideosSubtitles(ctx, ids, destFolder, langs.orDefault(), newProgress("Downloading"))
+		perhapsExit(err, exitDownload)
+		inform("Downloaded %d subtitles files in %s.\n", written, destFolder)
+	}
+
+	subtitlesFolder := channelFolder(channelName)
+	for _, lang := range langs.orDefault() {
+		indexes, err := openChannelIndexes(channelName, []string{lang})
+		perhapsExit(err, exitIndex)
+		_, err = sininen.UpdateSubtitleIndex(indexes[0], subtitlesFolder, lang, newProgress("Indexing "+lang))
+		perhapsExit(err, exitIndex)
+		tagged, err := sininen.TagPlaylist(indexes[0], playlistID, ids)
+		perhapsExit(err, exitIndex)
+		perhapsExit(indexes[0].Close(), exitIndex)
+		inform("Tagged %d videos of the playlist in the %s index.\n", tagged, lang)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"path/filepath"
+
+	"github.com/mooss/sininen"
+)
+
+var podcastCommand = &command{
+	name:        "podcast",
+	arguments:   "channel-name feed-url",
+	description: "Download the transcripts linked by the episodes of a podcast RSS feed and index them under a channel name.",
+	details:     "The episodes are identified by their GUID. Only the transcripts of the podcast namespace are supported, in the WebVTT, SRT and JSON formats.",
+	run:         runPodcast,
+}
+
+func runPodcast(cmd *command, args []string) {
+	flags := cmd.flagSet()
+	lang := flags.String("lang", "", "Language of the transcripts, the language of the feed by default.")
+	cmd.parseArgs(flags, args, 2)
+
+	channelName := flags.Arg(0)
+	destFolder := filepath.Join(defaults.SubtitlesRoot, channelName)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	client := sininen.PodcastClient{}
+	feed, err := client.FetchFeed(ctx, flags.Arg(1))
+	perhapsExit(err, exitDownload)
+	if *lang == "" {
+		*lang = feed.FeedLanguage()
+	}
+	if *lang == "" {
+		*lang = defaults.Languages[0]
+	}
+	written, err := client.DownloadTranscripts(ctx, feed, destFolder, *lang, newProgress("Downloading"))
+	perhapsExit(err, exitDownload)
+	inform("Downloaded %d %s transcripts of %q in %s.\n", written, *lang, feed.Title, destFolder)
+	if written == 0 {
+		return
+	}
+
+	indexes, err := openChannelIndexes(channelName, []string{*lang})
+	perhapsExit(err, exitIndex)
+	changes, err := sininen.UpdateSubtitleIndex(indexes[0], destFolder, *lang, newProgress("Indexing "+*lang))
+	perhapsExit(err, exitIndex)
+	perhapsExit(indexes[0].Close(), exitIndex)
+	inform("Added %d and updated %d episodes in the %s index.\n", len(changes.Added), len(changes.Updated), *lang)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mooss/sininen"
+)
+
+const progressWidth = 30 // Number of characters of the progress bar itself.
+
+// progressBar displays the progress of an indexing on a terminal line, along with its speed and estimated remaining time.
+type progressBar struct {
+	out   io.Writer
+	label string
+	start time.Time
+	last  time.Time // Time of the last display, to avoid flooding slow terminals.
+}
+
+// newProgress returns a progress function displaying a progress bar with the given label on stderr.
+// Nothing is displayed when stderr is not a terminal, so that logs and scripts are left clean, or with the -quiet flag.
+func newProgress(label string) sininen.Progress {
+	if quiet() || !isTerminal(os.Stderr) {
+		return nil
+	}
+	bar := &progressBar{out: os.Stderr, label: label}
+	return bar.update
+}
+
+func (pb *progressBar) update(done, total int) {
+	if total == 0 {
+		return // Nothing to index.
+	}
+	now := time.Now()
+	if pb.start.IsZero() {
+		pb.start = now
+	}
+	if done < total && now.Sub(pb.last) < 100*time.Millisecond {
+		return
+	}
+	pb.last = now
+
+	filled := progressWidth * done / total
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", progressWidth-filled)
+	line := fmt.Sprintf("%s [%s] %d/%d files", pb.label, bar, done, total)
+	if elapsed := now.Sub(pb.start).Seconds(); elapsed > 0 && done > 0 {
+		speed := float64(done) / elapsed
+		eta := time.Duration(float64(total-done) / speed * float64(time.Second)).Round(time.Second)
+		line += fmt.Sprintf(", %.1f files/s, ETA %v", speed, eta)
+	}
+	fmt.Fprintf(pb.out, "\r%s\x1b[K", line)
+	if done == total {
+		fmt.Fprintln(pb.out)
+	}
+}
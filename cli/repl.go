@@ -0,0 +1,417 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/mooss/sininen"
+	"golang.org/x/term"
+)
+
+var replCommand = &command{
+	name:        "repl",
+	arguments:   "channel-id...",
+	description: "Interactively run successive searches through the subtitles of channels, keeping their indexes open.",
+	details: "With -mpv-socket, the segments are played by a running mpv started with --input-ipc-server=<socket>, " +
+		"which is driven by :play, :next, :previous and :loop to watch the results one after the other.",
+	run: runRepl,
+}
+
+const replHelp = `Type a search query to run it, or one of the following commands:
+  :refine query  Keep only the segments of the current results also matching query.
+  :back          Return to the previous results.
+  :history       List the previous queries.
+  :suggest word  List the most frequent terms starting with word, which tab completes in the queries.
+  !n             Run the n-th query of the history again.
+  :format name   Change the output format.
+  :play n        Open the n-th displayed segment with the player, or play it in mpv with -mpv-socket.
+  :next          Play the displayed segment following the one played in mpv.
+  :previous      Play the displayed segment preceding the one played in mpv.
+  :loop          Repeat the segment played in mpv, or stop repeating it.
+  :bookmark n    Bookmark the n-th displayed segment.
+  :save name     Save the current query under the given name.
+  :help          Display this help.
+  :quit          Exit (end of input works too).
+`
+
+// repl is an interactive search session over an open index.
+type repl struct {
+	channelNames []string
+	channelName  string
+	index        bleve.Index
+	indexes      []bleve.Index // Searched together through index, listed to suggest terms.
+	settings     *searchSettings
+	out          io.Writer
+	history      []string
+	stack        []sininen.SearchResultSequence // Results of the query and of its successive refinements.
+	queries      []string                       // Query of each level of the stack.
+	shown        []sininen.ScoredSegment        // Segments displayed last.
+
+	mpv *sininen.MPVSession // Plays the displayed segments with -mpv-socket, nil otherwise.
+
+	// results caches the results of the recent queries, which remain valid since the index is kept open, and thus
+	// locked, for the whole session.
+	results *lruCache
+}
+
+// replSuggestions is the number of terms suggested by a repl session to complete a word.
+const replSuggestions = 10
+
+// suggest returns the most frequent terms starting with a prefix, the failures being logged.
+func (r *repl) suggest(prefix string) []sininen.TermCount {
+	terms, err := sininen.SuggestTerms(prefix, replSuggestions, r.indexes...)
+	if err != nil {
+		sininen.Log.Warnf("suggesting terms: %v", err)
+	}
+	return terms
+}
+
+// replCachedQueries is the number of queries whose results are cached by a repl session.
+const replCachedQueries = 64
+
+// show displays the results at the top of the stack.
+func (r *repl) show() error {
+	if len(r.stack) == 0 {
+		return nil
+	}
+	top := len(r.stack) - 1
+	r.shown = r.settings.segments(context.Background(), r.stack[top])
+	if r.mpv != nil {
+		if err := r.mpv.Reset(r.shown); err != nil {
+			sininen.Log.Warnf("stopping the loop of mpv: %v", err)
+		}
+	}
+	return r.settings.render(r.out, r.channelName, r.queries[top], r.shown)
+}
+
+// query runs a new search, replacing the current results.
+func (r *repl) query(query string) error {
+	var results sininen.SearchResultSequence
+	if cached, ok := r.results.get(query); ok {
+		results = cached.(sininen.SearchResultSequence)
+	} else {
+		var err error
+		if results, err = r.settings.search(sininen.BleveBackend{Index: r.index}, query); err != nil {
+			return err
+		}
+		r.results.add(query, results)
+	}
+	recordSearch(r.channelNames, query, results)
+	r.history = append(r.history, query)
+	r.stack = []sininen.SearchResultSequence{results}
+	r.queries = []string{query}
+	return r.show()
+}
+
+// refine narrows down the current results.
+func (r *repl) refine(query string) error {
+	if len(r.stack) == 0 {
+		return fmt.Errorf("no results to refine")
+	}
+	results, err := r.stack[len(r.stack)-1].Refine(query, r.index, r.settings.assemblyOptions())
+	if err != nil {
+		return err
+	}
+	r.history = append(r.history, ":refine "+query)
+	r.stack = append(r.stack, results)
+	r.queries = append(r.queries, r.queries[len(r.queries)-1]+" "+query)
+	return r.show()
+}
+
+// back returns to the results preceding the last refinement.
+func (r *repl) back() error {
+	if len(r.stack) < 2 {
+		return fmt.Errorf("no previous results")
+	}
+	r.stack = r.stack[:len(r.stack)-1]
+	r.queries = r.queries[:len(r.queries)-1]
+	return r.show()
+}
+
+// recall runs again the n-th entry of the history, starting from 1.
+func (r *repl) recall(n string) error {
+	i, err := strconv.Atoi(n)
+	if err != nil || i < 1 || i > len(r.history) {
+		return fmt.Errorf("no history entry %q", n)
+	}
+	fmt.Fprintln(r.out, r.history[i-1])
+	return r.execute(r.history[i-1])
+}
+
+// playing displays the segment played in mpv, unless it failed to be played.
+func (r *repl) playing(err error) error {
+	if err != nil {
+		return err
+	}
+	segment, position, _ := r.mpv.Current()
+	fmt.Fprintf(r.out, "Playing %d/%d: %s at %s\n", position+1, len(r.shown), segment.DisplayName(), sininen.FormatTimestamp(segment.StartTime))
+	return nil
+}
+
+// execute interprets one line of input, returning io.EOF when the session should end.
+func (r *repl) execute(line string) error {
+	if strings.HasPrefix(line, "!") {
+		return r.recall(line[1:])
+	}
+	if !strings.HasPrefix(line, ":") {
+		return r.query(line)
+	}
+
+	name, argument := line, ""
+	if i := strings.IndexByte(line, ' '); i >= 0 {
+		name, argument = line[:i], strings.TrimSpace(line[i+1:])
+	}
+	switch name {
+	case ":refine":
+		return r.refine(argument)
+	case ":back":
+		return r.back()
+	case ":history":
+		for i, query := range r.history {
+			fmt.Fprintf(r.out, "%4d  %s\n", i+1, query)
+		}
+		return nil
+	case ":suggest":
+		if argument == "" {
+			return fmt.Errorf("expected the start of a word")
+		}
+		for _, term := range r.suggest(argument) {
+			fmt.Fprintf(r.out, "%s (%d)\n", term.Term, term.Count)
+		}
+		return nil
+	case ":format":
+		*r.settings.format = argument
+		return r.show()
+	case ":play":
+		rank, err := strconv.Atoi(argument)
+		if err != nil {
+			return fmt.Errorf("expected the rank of a segment, got %q", argument)
+		}
+		if r.mpv != nil {
+			return r.playing(r.mpv.Play(rank - 1))
+		}
+		return r.settings.playRank(r.shown, rank)
+	case ":next", ":previous", ":loop":
+		if r.mpv == nil {
+			return fmt.Errorf("%s drives mpv, give -mpv-socket", name)
+		}
+		switch name {
+		case ":next":
+			return r.playing(r.mpv.Next())
+		case ":previous":
+			return r.playing(r.mpv.Previous())
+		}
+		looping, err := r.mpv.ToggleLoop()
+		if err == nil && looping {
+			fmt.Fprintln(r.out, "Looping.")
+		} else if err == nil {
+			fmt.Fprintln(r.out, "Not looping anymore.")
+		}
+		return err
+	case ":bookmark":
+		rank, err := strconv.Atoi(argument)
+		if err != nil {
+			return fmt.Errorf("expected the rank of a segment, got %q", argument)
+		}
+		if len(r.queries) == 0 {
+			return fmt.Errorf("no results to bookmark")
+		}
+		return bookmarkRank(r.channelNames, r.queries[len(r.queries)-1], r.shown, rank)
+	case ":save":
+		if len(r.queries) == 0 || argument == "" {
+			return fmt.Errorf("expected a name and a current query to save")
+		}
+		search := savedSearch{Name: argument, Channels: r.channelNames, Query: r.queries[len(r.queries)-1], Saved: time.Now()}
+		return updateNotebook(func(nb *notebook) error {
+			nb.save(search)
+			return nil
+		})
+	case ":help":
+		fmt.Fprint(r.out, replHelp)
+		return nil
+	case ":quit", ":q":
+		return io.EOF
+	}
+	return fmt.Errorf("unknown command %q, type :help to list the commands", name)
+}
+
+// lineReader reads the lines typed in the REPL.
+type lineReader interface {
+	ReadLine() (string, error)
+}
+
+// scannerReader reads lines from an input that is not a terminal, prompting for each of them.
+type scannerReader struct{ *bufio.Scanner }
+
+func (sr scannerReader) ReadLine() (string, error) {
+	fmt.Fprint(os.Stderr, "> ")
+	if sr.Scan() {
+		return sr.Text(), nil
+	}
+	if err := sr.Err(); err != nil {
+		return "", err
+	}
+	fmt.Fprintln(os.Stderr)
+	return "", io.EOF
+}
+
+// switchWriter is a writer whose destination can be changed.
+type switchWriter struct{ io.Writer }
+
+// wordCompleter completes the word before the cursor with the suggested terms, successive tabs cycling through them.
+type wordCompleter struct {
+	suggest func(prefix string) []string
+
+	candidates []string // Suggestions for the word completed last.
+	next       int      // Index of the candidate inserted by the next tab.
+	head, tail []rune   // Line before and after the completed word.
+	line       string   // Line after the last completion, telling whether a tab follows it.
+	pos        int
+}
+
+// complete is the term.Terminal.AutoCompleteCallback of the completer, pos being the cursor position in runes.
+func (wc *wordCompleter) complete(line string, pos int, key rune) (string, int, bool) {
+	if key != '\t' {
+		return "", 0, false
+	}
+	if line != wc.line || pos != wc.pos || len(wc.candidates) == 0 {
+		runes := []rune(line)
+		start := pos
+		for start > 0 && !unicode.IsSpace(runes[start-1]) {
+			start--
+		}
+		word := string(runes[start:pos])
+		if word == "" || start == 0 && strings.ContainsAny(word[:1], ":!") {
+			return "", 0, false // Only the query words are completed, not the commands.
+		}
+		wc.candidates = wc.suggest(word)
+		if len(wc.candidates) == 0 {
+			return "", 0, false
+		}
+		wc.head, wc.tail, wc.next = runes[:start], runes[pos:], 0
+	}
+	candidate := []rune(wc.candidates[wc.next])
+	wc.next = (wc.next + 1) % len(wc.candidates)
+	wc.line = string(wc.head) + string(candidate) + string(wc.tail)
+	wc.pos = len(wc.head) + len(candidate)
+	return wc.line, wc.pos, true
+}
+
+// terminalReader reads lines from a terminal with line editing, the up and down arrows recalling the previous lines.
+type terminalReader struct {
+	terminal *term.Terminal
+	fd       int
+}
+
+// newTerminalReader reads lines from stdin, which must be a terminal, whose history starts with the recalled lines,
+// the tab key completing the words with the suggestions of complete.
+func newTerminalReader(recalled []string, complete *wordCompleter) *terminalReader {
+	output := &switchWriter{ioutil.Discard}
+	var input io.Reader = os.Stdin
+	if len(recalled) > 0 {
+		input = io.MultiReader(strings.NewReader(strings.Join(recalled, "\r")+"\r"), os.Stdin)
+	}
+	terminal := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{input, output}, "> ")
+	for range recalled {
+		terminal.ReadLine() // Typing the recalled lines silently is the only way to fill the history of the terminal.
+	}
+	output.Writer = os.Stderr
+	terminal.AutoCompleteCallback = complete.complete
+	return &terminalReader{terminal, int(os.Stdin.Fd())}
+}
+
+func (tr *terminalReader) ReadLine() (string, error) {
+	state, err := term.MakeRaw(tr.fd)
+	if err != nil {
+		return "", err
+	}
+	defer term.Restore(tr.fd, state) // The search results and the player expect a terminal in its normal state.
+	if width, height, err := term.GetSize(tr.fd); err == nil && width > 0 {
+		tr.terminal.SetSize(width, height)
+	}
+	return tr.terminal.ReadLine()
+}
+
+// recalledQueries returns the most recent queries of the history made in the given channels, from the oldest to the
+// most recent.
+func recalledQueries(channelNames []string, count int) []string {
+	entries, err := readHistory()
+	if err != nil {
+		sininen.Log.Warnf("reading the search history: %v", err)
+	}
+	result := []string{}
+	for i := len(entries) - 1; i >= 0 && len(result) < count; i-- {
+		if entries[i].involves(channelNames) && !strings.ContainsAny(entries[i].Query, "\r\n\x1b") {
+			result = append(result, entries[i].Query)
+		}
+	}
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	return result
+}
+
+func runRepl(cmd *command, args []string) {
+	flags := cmd.flagSet()
+	settings := addSearchFlags(flags)
+	mpvSocket := flags.String("mpv-socket", "", "IPC socket of a running mpv playing the segments, see --input-ipc-server.")
+	cmd.parseMinArgs(flags, args, 0)
+	perhapsExit(settings.check(), exitUsage)
+	if *settings.backend != bleveBackend {
+		perhapsExit(fmt.Errorf("%w: the repl refines its searches with bleve, -backend sqlite is not supported", sininen.ErrInvalidOption), exitUsage)
+	}
+
+	channelNames, err := settings.channels(flags.Args())
+	perhapsExit(err, exitUsage)
+	channelName := strings.Join(channelNames, ", ")
+	indexes, err := openIndexList(channelNames, settings.langs.orDefault())
+	perhapsExit(err, exitIndex)
+	perhapsExit(settings.useChannels(channelNames), exitIndex)
+
+	session := &repl{channelNames: channelNames, channelName: channelName, index: aliasIndexes(indexes), indexes: indexes,
+		settings: settings, out: os.Stdout, results: newLRUCache(replCachedQueries, nil)}
+	if *mpvSocket != "" {
+		client, err := sininen.DialMPV(*mpvSocket)
+		perhapsExit(err, exitUsage)
+		defer client.Close()
+		session.mpv = sininen.NewMPVSession(client, settings.urls, nil)
+	}
+	var reader lineReader = scannerReader{bufio.NewScanner(os.Stdin)}
+	if isTerminal(os.Stdin) {
+		reader = newTerminalReader(recalledQueries(channelNames, 100), &wordCompleter{suggest: func(prefix string) []string {
+			result := []string{}
+			for _, term := range session.suggest(prefix) {
+				result = append(result, term.Term)
+			}
+			return result
+		}})
+	}
+	fmt.Fprintln(os.Stderr, "Type :help to list the commands.")
+	for {
+		line, err := reader.ReadLine()
+		if err == io.EOF {
+			return
+		}
+		perhapsExit(err, exitUsage)
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		if err := session.execute(line); err == io.EOF {
+			return
+		} else if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
+}
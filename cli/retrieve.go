@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/mooss/sininen"
+)
+
+var retrieveCommand = &command{
+	name:        "retrieve",
+	arguments:   "channel-id... query",
+	description: "Print the excerpts of the transcripts best matching a query that fit in a token budget, to feed a language model.",
+	details: "The excerpts are deduplicated, ordered chronologically and each one is preceded by a citation line with the title " +
+		"of its video, its times and its link. They include 2 segments before and after each match unless -context is given, " +
+		"and are written as JSON with -format json, as text otherwise.\n" +
+		"The tokens are estimated at about four characters each, as with the usual tokenizers.",
+	run: runRetrieve,
+}
+
+// defaultTokenBudget is the token budget of the retrievals not giving one.
+const defaultTokenBudget = 2000
+
+// maxTokenBudget is the maximum token budget of a retrieval served over HTTP.
+const maxTokenBudget = 100000
+
+// contextSearchDefaults are the flags of the searches of the retrievals, before the flags of the command or the
+// parameters of the requests.
+var contextSearchDefaults = []string{"-format=json", "-color=never", "-context=2"}
+
+// retrieveContext returns the excerpts around the segments of the search results that fit in a token budget, the
+// segments being limited by -limit and -offset before being selected.
+func (ss *searchSettings) retrieveContext(ctx context.Context, videos sininen.SearchResultSequence, tokenBudget int) []sininen.ContextExcerpt {
+	return sininen.SelectContext(ss.segments(ctx, videos), tokenBudget, sininen.RetrievalOptions{URLs: ss.urls})
+}
+
+// writeContext writes context excerpts as JSON with the json format, as text otherwise.
+func writeContext(w io.Writer, format string, excerpts []sininen.ContextExcerpt) error {
+	if format == "json" {
+		return sininen.WriteJSON(w, excerpts)
+	}
+	return sininen.WriteContext(w, excerpts)
+}
+
+func runRetrieve(cmd *command, args []string) {
+	flags := cmd.flagSet()
+	settings := addSearchFlags(flags)
+	tokens := flags.Int("tokens", defaultTokenBudget, "Token budget of the excerpts, citations included.")
+	perhapsExit(flags.Parse([]string{"-context=2"}), exitUsage) // Overridden by the -context of the arguments.
+	cmd.parseMinArgs(flags, args, 1)
+	perhapsExit(settings.check(), exitUsage)
+	if *tokens <= 0 {
+		perhapsExit(fmt.Errorf("%w: -tokens must be positive", sininen.ErrInvalidOption), exitUsage)
+	}
+
+	channelNames, err := settings.channels(flags.Args()[:flags.NArg()-1])
+	perhapsExit(err, exitUsage)
+	query, err := readQuery(flags.Arg(flags.NArg() - 1))
+	perhapsExit(err, exitUsage)
+	index, err := settings.open(channelNames)
+	perhapsExit(err, exitIndex)
+	videos, err := settings.search(index, query)
+	perhapsExit(err, exitQuery)
+	perhapsExit(writeContext(os.Stdout, *settings.format, settings.retrieveContext(context.Background(), videos, *tokens)), exitOutput)
+}
+
+// parseTokenBudget parses the tokens parameter of a request, defaultTokenBudget by default and at most maxTokenBudget.
+func parseTokenBudget(parameters url.Values) (int, error) {
+	raw := parameters.Get("tokens")
+	if raw == "" {
+		return defaultTokenBudget, nil
+	}
+	tokens, err := strconv.Atoi(raw)
+	if err != nil || tokens <= 0 {
+		return 0, fmt.Errorf("%w: the tokens parameter must be a positive integer", sininen.ErrInvalidOption)
+	}
+	if tokens > maxTokenBudget {
+		tokens = maxTokenBudget
+	}
+	return tokens, nil
+}
+
+// serveContext retrieves the excerpts of the transcripts best matching the q parameter that fit in the token budget of
+// the tokens parameter, the other parameters being parsed as the flags of the search command like serveSearch.
+func (s *server) serveContext(w http.ResponseWriter, r *http.Request) error {
+	parameters := r.URL.Query()
+	query := parameters.Get("q")
+	if query == "" {
+		return fmt.Errorf("%w: the q parameter is mandatory", sininen.ErrInvalidOption)
+	}
+	tokens, err := parseTokenBudget(parameters)
+	if err != nil {
+		return err
+	}
+	parameters.Del("tokens")
+	settings, channelNames, err := parseSearchParameters(parameters, contextSearchDefaults)
+	if err != nil {
+		return err
+	}
+	s.limitResults(r.Context(), settings)
+	videos, err := s.search(settings, channelNames, query)
+	if err != nil {
+		return err
+	}
+	excerpts := settings.retrieveContext(r.Context(), videos, tokens)
+	if *settings.format == "json" {
+		w.Header().Set("Content-Type", contentTypes["json"])
+	} else {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	}
+	return writeContext(w, *settings.format, excerpts)
+}
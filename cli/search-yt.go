@@ -1,62 +1,260 @@
 package main
 
 import (
-	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
-	"path"
+	"path/filepath"
+	"strings"
 
+	"github.com/blevesearch/bleve/v2"
 	"github.com/mooss/sininen"
 )
 
 func perhapsExit(err error, code int) {
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
-		os.Exit(code)
+		os.Exit(exitCode(err, code))
 	}
 }
 
-func main() {
-	jsonFlag := flag.Bool("json", false, "Output search results as JSON.")
-	flag.Parse()
-	if flag.NArg() != 2 {
-		fmt.Fprintf(os.Stderr, "Usage: %s channel-id search-query [-json]\n\nchannel-id must have been downloaded with the script download-channel-subtitles.sh.\n", os.Args[0])
-		os.Exit(6)
+// command is a subcommand of the CLI, with its own flags.
+type command struct {
+	name        string
+	arguments   string // Positional arguments, as displayed in the usage.
+	description string
+	details     string // Displayed after the description in the usage of the command, if not empty.
+	run         func(cmd *command, args []string)
+}
+
+// flagSet creates the flag set of a command, with a usage message describing the command and the flags common to all commands.
+func (cmd *command) flagSet() *flag.FlagSet {
+	flags := flag.NewFlagSet(cmd.name, flag.ExitOnError)
+	flags.Usage = func() {
+		description := cmd.description
+		if cmd.details != "" {
+			description += "\n" + cmd.details
+		}
+		fmt.Fprintf(flags.Output(), "Usage: %s %s [flags] %s\n\n%s\n\nFlags:\n", os.Args[0], cmd.name, cmd.arguments, description)
+		flags.PrintDefaults()
 	}
+	flags.StringVar(&defaults.SubtitlesRoot, "subtitles-root", defaults.SubtitlesRoot,
+		"Folder containing the subtitles folder of each channel (SININEN_SUBTITLES_ROOT).")
+	flags.StringVar(&defaults.IndexRoot, "index-path", defaults.IndexRoot,
+		"Folder containing the index folder of each channel, the subtitles root by default (SININEN_INDEX_ROOT).")
+	addVerbosityFlags(flags)
+	return flags
+}
 
-	channelName := flag.Arg(0)
-	textQuery := flag.Arg(1)
-	subtitlesFolder := path.Join("subtitles", channelName)
-	info, err := os.Stat(subtitlesFolder)
-	perhapsExit(err, 1)
-	if !info.IsDir() {
-		fmt.Fprintf(os.Stderr, "%s is not a dir.\n", subtitlesFolder)
-		os.Exit(2)
+// parseArgs parses the flags of a command and checks that the expected number of positional arguments is given.
+func (cmd *command) parseArgs(flags *flag.FlagSet, args []string, nargs int) {
+	perhapsExit(flags.Parse(args), exitUsage)
+	if flags.NArg() != nargs {
+		flags.Usage()
+		os.Exit(exitUsage)
 	}
+}
+
+// parseMinArgs parses the flags of a command and checks that at least minArgs positional arguments are given.
+func (cmd *command) parseMinArgs(flags *flag.FlagSet, args []string, minArgs int) {
+	perhapsExit(flags.Parse(args), exitUsage)
+	if flags.NArg() < minArgs {
+		flags.Usage()
+		os.Exit(exitUsage)
+	}
+}
+
+var commands = []*command{searchCommand, retrieveCommand, batchCommand, replCommand, browseCommand, indexCommand, watchCommand, statsCommand, validateCommand, doctorCommand, listCommand, transcriptCommand, gotoCommand, historyCommand, saveCommand, bookmarksCommand, metadataCommand, sponsorblockCommand, playlistCommand, downloadCommand, serveCommand, elasticCommand, whisperCommand, translateCommand, podcastCommand, peertubeCommand, semanticCommand, syncCommand, botCommand, mcpCommand, snapshotCommand}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s command [flags] arguments...\n\nCommands:\n", os.Args[0])
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.description)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun %s command -h to display the flags of a command.\n", os.Args[0])
+}
+
+// languages is a repeatable flag listing subtitle languages.
+type languages []string
 
-	lang := "en"
-	index, err := sininen.OpenTranscriptionIndex(subtitlesFolder, lang)
+func (l *languages) String() string { return strings.Join(*l, ",") }
+
+func (l *languages) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// orDefault returns the languages, or the configured ones if none was given.
+func (l languages) orDefault() []string {
+	if len(l) == 0 {
+		return defaults.Languages
+	}
+	return l
+}
+
+// addLangFlag adds the repeatable -lang flag to a flag set.
+func addLangFlag(flags *flag.FlagSet) *languages {
+	result := &languages{}
+	flags.Var(result, "lang", fmt.Sprintf("Language of the subtitles, can be repeated to use several languages (%s by default).",
+		strings.Join(defaults.Languages, ",")))
+	return result
+}
+
+// indexFolder returns the folder where the indexes of a channel are stored, creating it if needed.
+func indexFolder(channelName string) (string, error) {
+	if defaults.IndexRoot == "" {
+		return filepath.Join(defaults.SubtitlesRoot, channelName), nil
+	}
+	result := filepath.Join(defaults.IndexRoot, channelName)
+	return result, os.MkdirAll(result, 0755)
+}
+
+// allChannels returns the names of all the downloaded channels, i.e. the folders of the subtitles root.
+func allChannels() ([]string, error) {
+	files, err := ioutil.ReadDir(defaults.SubtitlesRoot)
 	if err != nil {
-		index, err = sininen.CreateSubtitleIndex(subtitlesFolder, lang)
+		return nil, err
+	}
+	result := []string{}
+	for _, file := range files {
+		if file.IsDir() {
+			result = append(result, file.Name())
+		}
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no channel downloaded in %s", defaults.SubtitlesRoot)
 	}
-	perhapsExit(err, 3)
+	return result, nil
+}
 
-	raw, err := sininen.TextQuery(textQuery, index)
-	perhapsExit(err, 4)
+// openIndexList opens the indexes of the given languages of channels, creating the missing ones.
+func openIndexList(channelNames []string, langs []string) ([]bleve.Index, error) {
+	indexes := make([]bleve.Index, 0, len(channelNames)*len(langs))
+	for _, channelName := range channelNames {
+		channelIndexes, err := openChannelIndexes(channelName, langs)
+		if err != nil {
+			return nil, err
+		}
+		indexes = append(indexes, channelIndexes...)
+	}
+	return indexes, nil
+}
+
+// aliasIndexes returns an index searching several indexes together, or the index itself when there is only one.
+func aliasIndexes(indexes []bleve.Index) bleve.Index {
+	if len(indexes) == 1 {
+		return indexes[0]
+	}
+	return bleve.NewIndexAlias(indexes...)
+}
+
+// Names of the backends storing the indexes.
+const (
+	bleveBackend  = "bleve"
+	sqliteBackend = "sqlite"
+)
+
+// addBackendFlag adds the -backend flag, selecting where the indexes are stored.
+func addBackendFlag(flags *flag.FlagSet) *string {
+	return flags.String("backend", defaults.Backend, "Storage of the indexes, either bleve or sqlite (a single .db file per language, needing a build with -tags sqlite_fts5).")
+}
+
+// checkBackend validates the name of a backend.
+func checkBackend(backend string) error {
+	if backend != bleveBackend && backend != sqliteBackend {
+		return fmt.Errorf("%w: unknown backend %q", sininen.ErrInvalidOption, backend)
+	}
+	return nil
+}
+
+// openSQLiteBackends opens the SQLite databases of the given languages of channels, filling the new ones.
+func openSQLiteBackends(channelNames, langs []string) (sininen.Backends, error) {
+	result := sininen.Backends{}
+	for _, channelName := range channelNames {
+		subtitlesFolder := channelFolder(channelName)
+		indexFolder, err := indexFolder(channelName)
+		if err != nil {
+			return result, err
+		}
+		for _, lang := range langs {
+			backend, err := sininen.OpenSQLiteBackend(indexFolder, lang)
+			if err != nil {
+				return result, err
+			}
+			result = append(result, backend)
+			count, err := backend.VideoCount()
+			if err != nil {
+				return result, err
+			}
+			if count > 0 {
+				sininen.Log.Infof("opened the %s database of %s", lang, channelName)
+				continue
+			}
+			sininen.Log.Infof("filling the %s database of %s in %s", lang, channelName, indexFolder)
+			if _, err := backend.Update(subtitlesFolder, lang, newProgress("Indexing "+lang)); err != nil {
+				return result, err
+			}
+		}
+	}
+	return result, nil
+}
+
+// openChannelIndexes opens the indexes of the given languages of a channel, creating the missing ones.
+func openChannelIndexes(channelName string, langs []string) ([]bleve.Index, error) {
+	subtitlesFolder := channelFolder(channelName)
+	indexFolder, err := indexFolder(channelName)
+	if err != nil {
+		return nil, err
+	}
+	indexes := make([]bleve.Index, 0, len(langs))
+	for _, lang := range langs {
+		index, err := sininen.OpenTranscriptionIndex(indexFolder, lang)
+		if err == nil {
+			sininen.Log.Infof("opened the %s index of %s", lang, channelName)
+		} else if errors.Is(err, sininen.ErrIndexNotFound) {
+			sininen.Log.Infof("creating the %s index of %s in %s", lang, channelName, indexFolder)
+			index, err = sininen.CreateSubtitleIndex(subtitlesFolder, lang, sininen.IndexOptions{
+				Folder:   indexFolder,
+				Progress: newProgress("Indexing " + lang),
+			})
+		}
+		if err != nil {
+			return nil, err
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, nil
+}
 
-	videos, err := sininen.AssembleSearchResults(raw)
-	perhapsExit(err, 5)
+// channelFolder returns the folder where the subtitles of a channel are stored, exiting if it does not exist.
+func channelFolder(channelName string) string {
+	subtitlesFolder := filepath.Join(defaults.SubtitlesRoot, channelName)
+	info, err := os.Stat(subtitlesFolder)
+	perhapsExit(err, exitNoChannel)
+	if !info.IsDir() {
+		fmt.Fprintf(os.Stderr, "%s is not a dir.\n", subtitlesFolder)
+		os.Exit(exitNotAFolder)
+	}
+	return subtitlesFolder
+}
 
-	scoredSegments := videos.ScoredSegments()
-	if *jsonFlag {
-		marshalledBytes, err := json.Marshal(scoredSegments)
-		perhapsExit(err, 6)
-		fmt.Println(string(marshalledBytes))
-	} else {
-		for _, segment := range scoredSegments {
-			fmt.Printf("https://www.youtube.com/watch?v=%s&t=%vs (%v, score=%.3f)\n",
-				segment.ID, int(segment.StartTime.Seconds()), segment.SortedTerms, segment.Score)
+func main() {
+	perhapsExit(loadConfig(), exitUsage)
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(exitUsage)
+	}
+	for _, cmd := range commands {
+		if cmd.name == os.Args[1] {
+			cmd.run(cmd, os.Args[2:])
+			return
 		}
 	}
+	if os.Args[1] != "-h" && os.Args[1] != "-help" && os.Args[1] != "help" {
+		fmt.Fprintf(os.Stderr, "Unknown command %q.\n\n", os.Args[1])
+	}
+	usage()
+	os.Exit(exitUsage)
 }
@@ -0,0 +1,559 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/mooss/sininen"
+)
+
+var searchCommand = &command{
+	name:        "search",
+	arguments:   "channel-id... search-query",
+	description: "Search through the subtitles of downloaded channels, creating their index if needed.",
+	details:     "The query is read from stdin when it is -. With -saved, the channels and the query of the saved search are used instead.",
+	run:         runSearch,
+}
+
+// contextFlag is the amount of transcript surrounding the matching segments, either a number of segments or a duration.
+type contextFlag struct {
+	segments int
+	duration time.Duration
+}
+
+func (cf *contextFlag) String() string {
+	if cf.duration > 0 {
+		return cf.duration.String()
+	}
+	return strconv.Itoa(cf.segments)
+}
+
+func (cf *contextFlag) Set(value string) error {
+	if segments, err := strconv.Atoi(value); err == nil {
+		*cf = contextFlag{segments: segments}
+		return nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("expected a number of segments or a duration, got %q", value)
+	}
+	*cf = contextFlag{duration: duration}
+	return nil
+}
+
+func addContextFlag(flags *flag.FlagSet) *contextFlag {
+	result := &contextFlag{}
+	flags.Var(result, "context", "Transcript to include before and after each matching segment, "+
+		"either a number of segments (e.g. 2) or a duration (e.g. 10s).")
+	return result
+}
+
+// searchSettings holds the flags shared by the commands performing searches.
+type searchSettings struct {
+	format       *string
+	notes        *string
+	fps          *int
+	platform     *string
+	platformBase *string
+	clips        *bool
+	video        *string
+	chapter      *string
+	playlist     *string
+	prefix       *bool
+	context      *contextFlag
+	maxPerVideo  *int
+	firstOnly    *bool
+	sample       *int
+	thumbnails   *bool
+	oembed       *bool
+	sponsors     *string
+	skipSponsors *bool
+	normalize    *bool
+	langs        *languages
+	limit        *int
+	offset       *int
+	maxVideos    *int
+	ranking      *string
+	halfLife     *time.Duration
+	color        *string
+	player       *string
+	all          *bool
+	minViews     *int64
+	after        *dateFlag
+	before       *dateFlag
+	backend      *string
+	parallelism  *int
+
+	rankedAt    time.Time          // Time of the recency ranking of -half-life, the current time when zero.
+	urls        sininen.URLBuilder // Set by check.
+	rank        sininen.RankingStrategy
+	highlight   func(string) string
+	sponsorData sininen.SponsorSegments // Set by useChannels, nil when the sponsor segments are ignored.
+	folders     []string                // Subtitles folders of the searched channels, set by useChannels.
+}
+
+func addSearchFlags(flags *flag.FlagSet) *searchSettings {
+	return &searchSettings{
+		format:       flags.String("format", defaults.Format, "Output format of the search results, one of "+strings.Join(sininen.FormatterNames(), ", ")+"."),
+		notes:        flags.String("notes", "", "Write the search results as Markdown notes in the given folder, e.g. an Obsidian vault, instead of displaying them."),
+		fps:          flags.Int("fps", 25, "Frame rate of the timecodes of the edl format."),
+		platform:     flags.String("platform", "youtube", "Platform the links point to, either youtube, peertube, vimeo, twitch, file or mpv."),
+		platformBase: flags.String("platform-base", "", "Instance URL for the peertube platform (peertube_instance of the configuration by default), video folder for the file and mpv platforms."),
+		clips:        flags.Bool("clips", false, "Make the links stop at the end of the matching segments, when the platform allows it."),
+		video:        flags.String("video", "", "Restrict the search results to the video with the given ID."),
+		chapter:      flags.String("chapter", "", "Restrict the search results to the chapters of videos whose title contains the given text, ignoring case, when the metadata files give chapters."),
+		playlist:     flags.String("playlist", "", "Restrict the search to the videos of the playlist with the given ID, see the playlist command."),
+		prefix:       flags.Bool("prefix", false, "Also match the last word of the query as a prefix, unless the query ends with a space, e.g. to search as the query is typed."),
+		context:      addContextFlag(flags),
+		maxPerVideo:  flags.Int("max-per-video", 0, "Maximum number of segments displayed per video (unlimited by default)."),
+		firstOnly:    flags.Bool("first", false, "Only display the first matching segment of each video."),
+		sample:       flags.Int("sample", 0, "Display the given number of matching segments picked at random instead of the best ones."),
+		thumbnails:   flags.Bool("thumbnails", false, "Include preview images of the matching moments, when the platform provides them."),
+		oembed:       flags.Bool("oembed", false, "Fetch the titles and channels of the displayed YouTube videos without metadata file from the oEmbed endpoint of YouTube, caching them in oembed.json of the configuration folder."),
+		sponsors:     flags.String("sponsors", "", "Either flag or hide the segments falling inside the sponsor segments of their video, see the sponsorblock command."),
+		skipSponsors: flags.Bool("skip-sponsors", false, "Make the segments starting within a sponsor segment start at its end, so that their links skip past it, see the sponsorblock command."),
+		normalize:    flags.Bool("normalize", false, "Rescale the scores between 0 and 1, relative to the best matching segment."),
+		langs:        addLangFlag(flags),
+		limit:        flags.Int("limit", 0, "Maximum number of matching segments displayed (unlimited by default)."),
+		offset:       flags.Int("offset", 0, "Number of matching segments to skip, to page through the results with -limit."),
+		maxVideos:    flags.Int("max-videos", 100, "Maximum number of videos retrieved from the index."),
+		ranking:      flags.String("ranking", defaults.Ranking, "Ranking strategy of the segments, either distinct (number of distinct matching terms) or total (number of occurrences of the matching terms)."),
+		halfLife:     flags.Duration("half-life", defaults.halfLife, "Halve the scores of videos every given duration since their upload, e.g. 8760h for a year (disabled by default)."),
+		all:          flags.Bool("all", false, "Search through every downloaded channel instead of the given ones."),
+		player:       flags.String("player", "browser", "Player opening the matching segments, either browser (the default browser) or mpv (playing online videos through youtube-dl)."),
+		minViews:     flags.Int64("min-views", 0, "Only display the segments of videos viewed at least the given number of times, see the metadata command."),
+		after:        addDateFlag(flags, "after", "Only display the segments of videos uploaded on or after the given date, formatted as YYYY-MM-DD."),
+		before:       addDateFlag(flags, "before", "Only display the segments of videos uploaded before the given date, formatted as YYYY-MM-DD."),
+		backend:      addBackendFlag(flags),
+		parallelism:  flags.Int("parallelism", defaults.Parallelism, "Maximum number of indexes searched at once when searching several channels or languages (the number of CPUs by default)."),
+		color:        flags.String("color", "auto", "Colorize the matched terms of the text and table formats, either auto (when writing to a terminal, unless NO_COLOR is set), always or never."),
+	}
+}
+
+// newURLBuilder returns the URL builder of a platform, like sininen.NewURLBuilder, the configured instance being the
+// default base of the peertube platform.
+func newURLBuilder(platform, base string) (sininen.URLBuilder, error) {
+	if platform == "peertube" && base == "" {
+		base = defaults.PeerTube
+	}
+	return sininen.NewURLBuilder(platform, base)
+}
+
+// check validates the settings once the flags are parsed.
+func (ss *searchSettings) check() error {
+	urls, err := newURLBuilder(*ss.platform, *ss.platformBase)
+	if err != nil {
+		return err
+	}
+	if *ss.clips {
+		urls = sininen.Clips{URLBuilder: urls}
+	}
+	ss.urls = urls
+	if err := checkBackend(*ss.backend); err != nil {
+		return err
+	}
+	if *ss.prefix && *ss.playlist != "" {
+		return fmt.Errorf("%w: -prefix cannot be combined with -playlist", sininen.ErrInvalidOption)
+	}
+	if *ss.sponsors != "" && *ss.sponsors != "flag" && *ss.sponsors != "hide" {
+		return fmt.Errorf("%w: -sponsors expects flag or hide, got %q", sininen.ErrInvalidOption, *ss.sponsors)
+	}
+	if *ss.sample < 0 {
+		return fmt.Errorf("%w: -sample cannot be negative, got %d", sininen.ErrInvalidOption, *ss.sample)
+	}
+	if ss.highlight, err = colorHighlight(*ss.color); err != nil {
+		return err
+	}
+	ss.rank, err = sininen.NamedRanking(*ss.ranking)
+	return err
+}
+
+// channels returns the channels given as positional arguments, or every downloaded channel with -all.
+func (ss *searchSettings) channels(args []string) ([]string, error) {
+	if *ss.all {
+		if len(args) > 0 {
+			return nil, fmt.Errorf("%w: no channel can be given with -all", sininen.ErrInvalidOption)
+		}
+		return allChannels()
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("%w: expected at least one channel, or -all", sininen.ErrInvalidOption)
+	}
+	return args, nil
+}
+
+// readQuery returns the search query given as argument, or reads it from stdin when the argument is -.
+// The lines of a query read from stdin are joined by spaces.
+func readQuery(arg string) (string, error) {
+	if arg != "-" {
+		return arg, nil
+	}
+	raw, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return "", err
+	}
+	query := strings.Join(strings.Fields(string(raw)), " ")
+	if query == "" {
+		return "", fmt.Errorf("%w: empty query read from stdin", sininen.ErrInvalidOption)
+	}
+	return query, nil
+}
+
+// useChannels prepares the settings to search channels, by remembering their subtitles folders, where the chapters of
+// their videos are looked up, and by reading the sponsor segments of their videos when the settings use them.
+func (ss *searchSettings) useChannels(channelNames []string) error {
+	ss.folders = make([]string, 0, len(channelNames))
+	for _, channelName := range channelNames {
+		if err := checkChannel(channelName); err != nil {
+			return err
+		}
+		ss.folders = append(ss.folders, filepath.Join(defaults.SubtitlesRoot, channelName))
+	}
+	if *ss.sponsors == "" && !*ss.skipSponsors {
+		return nil
+	}
+	ss.sponsorData = sininen.SponsorSegments{}
+	for _, folder := range ss.folders {
+		sponsors, err := sininen.LoadSponsorSegments(folder)
+		if err != nil {
+			return err
+		}
+		for id, segments := range sponsors {
+			ss.sponsorData[id] = segments
+		}
+	}
+	return nil
+}
+
+// chapterCache memoizes the chapters of the videos, shared by the searches.
+var chapterCache = sininen.NewChapterCache()
+
+// chapters returns the chapters of a video of the searched channels, see useChannels.
+func (ss *searchSettings) chapters(id string) []sininen.Chapter {
+	for _, folder := range ss.folders {
+		if chapters := chapterCache.Chapters(folder, id); len(chapters) > 0 {
+			return chapters
+		}
+	}
+	return nil
+}
+
+// open opens the indexes of channels in the backend of the settings, see also useChannels.
+func (ss *searchSettings) open(channelNames []string) (sininen.Searcher, error) {
+	if err := ss.useChannels(channelNames); err != nil {
+		return nil, err
+	}
+	if *ss.backend == sqliteBackend {
+		backends, err := openSQLiteBackends(channelNames, ss.langs.orDefault())
+		if err != nil {
+			return backends, err
+		}
+		searchers := make([]sininen.Searcher, len(backends))
+		for i, backend := range backends {
+			searchers[i] = backend
+		}
+		return ss.multiSearcher(searchers), nil
+	}
+	indexes, err := openIndexList(channelNames, ss.langs.orDefault())
+	if err != nil {
+		return nil, err
+	}
+	return ss.bleveSearcher(indexes), nil
+}
+
+// bleveSearcher returns a searcher querying bleve indexes concurrently, see multiSearcher.
+func (ss *searchSettings) bleveSearcher(indexes []bleve.Index) sininen.Searcher {
+	searchers := make([]sininen.Searcher, len(indexes))
+	for i, index := range indexes {
+		searchers[i] = sininen.BleveBackend{Index: index}
+	}
+	return ss.multiSearcher(searchers)
+}
+
+// multiSearcher returns a searcher querying searchers concurrently, at most -parallelism at once, or the searcher
+// itself when there is only one.
+func (ss *searchSettings) multiSearcher(searchers []sininen.Searcher) sininen.Searcher {
+	if len(searchers) == 1 {
+		return searchers[0]
+	}
+	return sininen.MultiSearcher{Searchers: searchers, Parallelism: *ss.parallelism}
+}
+
+// searchedIndex returns the bleve index searched by a searcher of bleveSearcher, an alias of its indexes when there
+// are several, and false for the other searchers.
+func searchedIndex(searcher sininen.Searcher) (bleve.Index, bool) {
+	switch searcher := searcher.(type) {
+	case sininen.BleveBackend:
+		return searcher.Index, true
+	case sininen.MultiSearcher:
+		indexes := make([]bleve.Index, len(searcher.Searchers))
+		for i, searcher := range searcher.Searchers {
+			index, ok := searchedIndex(searcher)
+			if !ok {
+				return nil, false
+			}
+			indexes[i] = index
+		}
+		return aliasIndexes(indexes), true
+	}
+	return nil, false
+}
+
+// search queries the index and assembles the results, whose hits are tagged with the chapters of their videos when
+// -chapter filters them, the displayed segments being tagged otherwise, see displayed.
+// The search restricted to a playlist and the prefix search are only supported by the bleve backend.
+func (ss *searchSettings) search(searcher sininen.Searcher, query string) (sininen.SearchResultSequence, error) {
+	videos, err := ss.searchBackend(searcher, query)
+	if err == nil && *ss.chapter != "" {
+		videos.TagChapters(ss.chapters)
+	}
+	return videos, err
+}
+
+// searchBackend queries the index and assembles the results.
+func (ss *searchSettings) searchBackend(searcher sininen.Searcher, query string) (sininen.SearchResultSequence, error) {
+	if *ss.playlist == "" && !*ss.prefix {
+		return searcher.Search(query, *ss.maxVideos, ss.assemblyOptions())
+	}
+	index, ok := searchedIndex(searcher)
+	if !ok && *ss.prefix {
+		return nil, fmt.Errorf("%w: -prefix is only supported by the bleve backend", sininen.ErrInvalidOption)
+	} else if !ok {
+		return nil, fmt.Errorf("%w: -playlist is only supported by the bleve backend", sininen.ErrInvalidOption)
+	}
+	var raw *bleve.SearchResult
+	var err error
+	if *ss.prefix {
+		raw, err = sininen.PrefixTextQuery(query, index, *ss.maxVideos)
+	} else {
+		raw, err = sininen.PlaylistTextQuery(query, *ss.playlist, index, *ss.maxVideos)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sininen.Log.Infof("%d matching videos out of %d in %v", len(raw.Hits), raw.Total, raw.Took)
+	return sininen.AssembleSearchResults(raw, ss.assemblyOptions())
+}
+
+// searchKey identifies a search of channels made with the settings, the settings only affecting the ranking, the
+// filtering and the rendering of its results being left out.
+// Whether -chapter is given is kept, since search only tags the chapters of the results filtered by it.
+func (ss *searchSettings) searchKey(channelNames []string, query string) string {
+	return fmt.Sprintf("%q %q %q %d %q %t %t %+v", channelNames, ss.langs.orDefault(), query, *ss.maxVideos, *ss.playlist, *ss.prefix,
+		*ss.chapter != "", ss.assemblyOptions())
+}
+
+func (ss *searchSettings) assemblyOptions() sininen.AssemblyOptions {
+	return sininen.AssemblyOptions{
+		Context:             ss.context.segments,
+		ContextDuration:     ss.context.duration,
+		MaxSegmentsPerVideo: *ss.maxPerVideo,
+		FirstOccurrenceOnly: *ss.firstOnly,
+	}
+}
+
+// segments ranks, filters and paginates the segments of search results.
+func (ss *searchSettings) segments(ctx context.Context, videos sininen.SearchResultSequence) []sininen.ScoredSegment {
+	return ss.displayed(ctx, sininen.PaginateSegments(ss.unpaginatedSegments(videos), *ss.offset, *ss.limit))
+}
+
+// displayed completes the displayed segments of search results with the chapters of their videos, unless already
+// tagged by search, and with their titles, see addTitles.
+func (ss *searchSettings) displayed(ctx context.Context, segments []sininen.ScoredSegment) []sininen.ScoredSegment {
+	if *ss.chapter == "" {
+		sininen.TagSegmentChapters(segments, ss.chapters)
+	}
+	return ss.addTitles(ctx, segments)
+}
+
+// oembedResolver is the resolver of the titles of videos shared by the searches, see -oembed and sharedOEmbedResolver.
+var (
+	oembedResolver *sininen.OEmbedResolver
+	oembedOnce     sync.Once
+)
+
+// sharedOEmbedResolver returns oembedResolver, created on first use.
+func sharedOEmbedResolver() *sininen.OEmbedResolver {
+	oembedOnce.Do(func() {
+		filename := defaults.OEmbedFile
+		if filename == "" {
+			var err error
+			if filename, err = configFile("oembed.json"); err != nil {
+				sininen.Log.Warnf("not caching the oEmbed titles: %v", err)
+			}
+		}
+		oembedResolver = &sininen.OEmbedResolver{HTTP: &http.Client{Timeout: 10 * time.Second}, CacheFile: filename}
+	})
+	return oembedResolver
+}
+
+// addTitles sets the titles of the displayed segments of YouTube videos without metadata file with oEmbed, when the
+// settings ask for it. Failing to do so is only reported, since the titles are a convenience.
+func (ss *searchSettings) addTitles(ctx context.Context, segments []sininen.ScoredSegment) []sininen.ScoredSegment {
+	if !*ss.oembed || *ss.platform != "youtube" {
+		return segments
+	}
+	result, err := sininen.AddOEmbedTitles(ctx, segments, sharedOEmbedResolver())
+	if err != nil {
+		sininen.Log.Warnf("resolving the titles with oEmbed: %v", err)
+	}
+	return result
+}
+
+// unpaginatedSegments ranks and filters the segments of search results.
+func (ss *searchSettings) unpaginatedSegments(videos sininen.SearchResultSequence) []sininen.ScoredSegment {
+	rank := ss.rank
+	if *ss.halfLife > 0 {
+		now := ss.rankedAt
+		if now.IsZero() {
+			now = time.Now()
+		}
+		rank = sininen.RecencyRanking(rank, *ss.halfLife, now)
+	}
+	scoredSegments := videos.RankedSegments(rank)
+	if *ss.normalize {
+		scoredSegments = sininen.NormalizeScores(scoredSegments)
+	}
+	if *ss.video != "" {
+		scoredSegments = sininen.SegmentsOfVideo(scoredSegments, *ss.video)
+	}
+	if *ss.chapter != "" {
+		chapter := strings.ToLower(*ss.chapter)
+		scoredSegments = sininen.FilterSegments(scoredSegments, func(segment sininen.ScoredSegment) bool {
+			return strings.Contains(strings.ToLower(segment.Chapter), chapter)
+		})
+	}
+	if *ss.minViews > 0 || !ss.after.IsZero() || !ss.before.IsZero() {
+		scoredSegments = sininen.FilterSegments(scoredSegments, ss.matchesMetadata)
+	}
+	if ss.sponsorData != nil {
+		scoredSegments = sininen.ApplySponsorSegments(scoredSegments, ss.sponsorData, *ss.skipSponsors)
+		if *ss.sponsors == "hide" {
+			scoredSegments = sininen.FilterSegments(scoredSegments, func(segment sininen.ScoredSegment) bool { return segment.Sponsor == "" })
+		}
+	}
+	if *ss.thumbnails {
+		scoredSegments = sininen.AddThumbnails(scoredSegments, ss.urls)
+	}
+	if *ss.sample > 0 {
+		scoredSegments = sininen.SampleSegments(scoredSegments, *ss.sample, rand.New(rand.NewSource(time.Now().UnixNano())))
+	}
+	return scoredSegments
+}
+
+// matchesMetadata tells whether the video of a segment passes the metadata filters, unknown values never passing them.
+func (ss *searchSettings) matchesMetadata(segment sininen.ScoredSegment) bool {
+	if *ss.minViews > 0 && segment.ViewCount < *ss.minViews {
+		return false
+	}
+	if (!ss.after.IsZero() || !ss.before.IsZero()) && segment.UploadDate.IsZero() {
+		return false
+	}
+	return !(!ss.after.IsZero() && segment.UploadDate.Before(ss.after.Time) ||
+		!ss.before.IsZero() && !segment.UploadDate.Before(ss.before.Time))
+}
+
+// render writes scored segments in the chosen format, or as notes if a notes folder was given.
+func (ss *searchSettings) render(w io.Writer, channelName, query string, scoredSegments []sininen.ScoredSegment) error {
+	if *ss.notes != "" {
+		return sininen.WriteNotes(*ss.notes, channelName, query, scoredSegments, ss.urls)
+	}
+	return sininen.WriteFormat(w, *ss.format, scoredSegments, sininen.FormatOptions{
+		Title:     query,
+		URLs:      ss.urls,
+		FPS:       *ss.fps,
+		Highlight: ss.highlight,
+	})
+}
+
+// playRank opens the segment of the given rank, starting from 1, with the player and waits for it to exit.
+func (ss *searchSettings) playRank(scoredSegments []sininen.ScoredSegment, rank int) error {
+	if rank < 1 || rank > len(scoredSegments) {
+		return fmt.Errorf("no matching segment of rank %d", rank)
+	}
+	player, err := playCommand(*ss.player, *ss.platform, ss.urls, scoredSegments[rank-1])
+	if err != nil {
+		return err
+	}
+	player.Stdin, player.Stdout, player.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return player.Run()
+}
+
+func runSearch(cmd *command, args []string) {
+	flags := cmd.flagSet()
+	settings := addSearchFlags(flags)
+	playRank := flags.Int("play", 0, "Open the matching segment of the given rank with the player, after displaying the results.")
+	bookmark := flags.Int("bookmark", 0, "Bookmark the matching segment of the given rank, see the bookmarks command.")
+	saved := flags.String("saved", "", "Run the search saved under the given name by the save command.")
+	cut := flags.String("cut", "", "Cut the matching segments out of their videos with ffmpeg into clips in the given folder, after displaying the results.")
+	padding := flags.Duration("cut-padding", 0, "Duration added before and after each segment cut by -cut.")
+	videoFolder := flags.String("videos", "", "Folder of the video files cut by -cut, named after the ID of their video (the videos are streamed with yt-dlp by default).")
+	reencode := flags.Bool("reencode", false, "Re-encode the clips of -cut so that they start exactly with the segments, instead of at the preceding keyframe.")
+	cmd.parseMinArgs(flags, args, 0)
+	perhapsExit(settings.check(), exitUsage)
+
+	var channelNames []string
+	var textQuery string
+	if *saved != "" {
+		if flags.NArg() > 0 {
+			perhapsExit(fmt.Errorf("%w: no argument can be given with -saved", sininen.ErrInvalidOption), exitUsage)
+		}
+		nb, err := loadNotebook()
+		perhapsExit(err, exitUsage)
+		search, err := nb.search(*saved)
+		perhapsExit(err, exitUsage)
+		channelNames, textQuery = search.Channels, search.Query
+	} else {
+		if flags.NArg() == 0 {
+			flags.Usage()
+			os.Exit(exitUsage)
+		}
+		var err error
+		channelNames, err = settings.channels(flags.Args()[:flags.NArg()-1])
+		perhapsExit(err, exitUsage)
+		textQuery, err = readQuery(flags.Arg(flags.NArg() - 1))
+		perhapsExit(err, exitUsage)
+	}
+	channelName := strings.Join(channelNames, ", ")
+	index, err := settings.open(channelNames)
+	perhapsExit(err, exitIndex)
+
+	videos, err := settings.search(index, textQuery)
+	perhapsExit(err, exitQuery)
+	recordSearch(channelNames, textQuery, videos)
+	scoredSegments := settings.segments(context.Background(), videos)
+	perhapsExit(settings.render(os.Stdout, channelName, textQuery, scoredSegments), exitOutput)
+	if *bookmark > 0 {
+		perhapsExit(bookmarkRank(channelNames, textQuery, scoredSegments, *bookmark), exitOutput)
+	}
+	if *playRank > 0 {
+		perhapsExit(settings.playRank(scoredSegments, *playRank), exitOutput)
+	}
+	if *cut != "" {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		videoFiles := *videoFolder
+		if videoFiles == "" && (*settings.platform == "file" || *settings.platform == "mpv") {
+			videoFiles = *settings.platformBase // The folder of the videos of these platforms, which cannot be streamed.
+		}
+		clipper := sininen.Clipper{Videos: videoFiles, URLs: settings.urls, Padding: *padding, Reencode: *reencode}
+		clips, err := clipper.CutClips(ctx, scoredSegments, *cut, newProgress("Cutting the clips"))
+		for _, clip := range clips {
+			inform("%s\n", clip.File)
+		}
+		perhapsExit(err, exitOutput)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/mooss/sininen"
+)
+
+var semanticCommand = &command{
+	name:        "semantic",
+	arguments:   "channel-name... query",
+	description: "Find the segments closest in meaning to a query, even without any word in common, with embeddings computed by Ollama or an OpenAI-compatible endpoint.",
+	details: "The embeddings of the segments are computed for the new and modified subtitles before searching and stored next to the index, " +
+		"which can take a while the first time. They must be computed again after changing the provider or the model, with -rebuild.",
+	run: runSemantic,
+}
+
+// newEmbedder returns the embedder of a provider, either ollama or openai.
+func newEmbedder(provider, url, key, model string) (sininen.Embedder, error) {
+	switch provider {
+	case "ollama":
+		return sininen.Ollama{URL: url, Model: model}, nil
+	case "openai":
+		return sininen.EmbeddingsAPI{URL: url, Key: key, Model: model}, nil
+	}
+	return nil, fmt.Errorf("%w: unknown embeddings provider %q", sininen.ErrInvalidOption, provider)
+}
+
+func runSemantic(cmd *command, args []string) {
+	flags := cmd.flagSet()
+	provider := flags.String("provider", defaults.EmbeddingsProvider, "Provider of the embeddings, either ollama (a local model) or openai (an OpenAI-compatible endpoint).")
+	url := flags.String("url", defaults.EmbeddingsURL, "URL of the Ollama server or of the embeddings endpoint, the default one of the provider when empty.")
+	key := flags.String("key", defaults.EmbeddingsKey, "Key of the embeddings endpoint of the openai provider.")
+	model := flags.String("model", defaults.EmbeddingsModel, "Embedding model, nomic-embed-text for ollama and text-embedding-3-small for openai by default.")
+	update := flags.Bool("update", true, "Compute the embeddings of the new and modified subtitles before searching.")
+	rebuild := flags.Bool("rebuild", false, "Compute all the embeddings again from scratch, e.g. after changing the model.")
+	limit := flags.Int("limit", 10, "Maximum number of segments displayed.")
+	format := flags.String("format", defaults.Format, "Output format of the search results, one of "+strings.Join(sininen.FormatterNames(), ", ")+".")
+	platform := flags.String("platform", "youtube", "Platform the links point to, either youtube, peertube, vimeo, twitch, file or mpv.")
+	platformBase := flags.String("platform-base", "", "Instance URL for the peertube platform (peertube_instance of the configuration by default), video folder for the file and mpv platforms.")
+	langs := addLangFlag(flags)
+	cmd.parseMinArgs(flags, args, 2)
+
+	embedder, err := newEmbedder(*provider, *url, *key, *model)
+	perhapsExit(err, exitUsage)
+	urls, err := newURLBuilder(*platform, *platformBase)
+	perhapsExit(err, exitUsage)
+	query, err := readQuery(flags.Arg(flags.NArg() - 1))
+	perhapsExit(err, exitUsage)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	indexes := []*sininen.EmbeddingIndex{}
+	for _, channelName := range flags.Args()[:flags.NArg()-1] {
+		subtitlesFolder := channelFolder(channelName)
+		indexFolder, err := indexFolder(channelName)
+		perhapsExit(err, exitIndex)
+		for _, lang := range langs.orDefault() {
+			index, err := sininen.LoadEmbeddingIndex(indexFolder, lang)
+			if *rebuild || errors.Is(err, sininen.ErrIndexNotFound) {
+				index, err = sininen.NewEmbeddingIndex(embedder), nil
+			}
+			perhapsExit(err, exitIndex)
+			if *update || *rebuild {
+				changes, err := index.Update(ctx, embedder, subtitlesFolder, lang, newProgress("Embedding "+lang))
+				if !changes.Empty() {
+					perhapsExit(index.Save(indexFolder, lang), exitIndex) // Keeps the embeddings computed before a failure.
+				}
+				perhapsExit(err, exitIndex)
+				if !changes.Empty() {
+					inform("Embedded %d and removed %d videos of %s in %s.\n", len(changes.Added)+len(changes.Updated), len(changes.Removed), channelName, lang)
+				}
+			}
+			indexes = append(indexes, index)
+		}
+	}
+
+	segments, err := sininen.SemanticSearch(ctx, embedder, query, *limit, indexes...)
+	perhapsExit(err, exitQuery)
+	perhapsExit(sininen.WriteFormat(os.Stdout, *format, segments, sininen.FormatOptions{Title: query, URLs: urls}), exitOutput)
+}
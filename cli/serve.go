@@ -0,0 +1,830 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/mooss/sininen"
+	"github.com/mooss/sininen/sininenpb"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+var serveCommand = &command{
+	name:        "serve",
+	arguments:   "",
+	description: "Serve the searches, the status of the indexes and their updates over HTTP, as JSON by default.",
+	details: "The web frontend is served at the root. Endpoints:\n" +
+		"  GET /channels                  the downloaded channels\n" +
+		"  GET /search?channel=&q=        search, taking the flags of the search command as parameters, e.g. limit=10&offset=10&format=csv,\n" +
+		"                                 and a cursor parameter continuing from the X-Next-Cursor header of the previous page\n" +
+		"  GET /context?channel=&q=       excerpts of the transcripts best matching q with their citations, fitting in the token budget\n" +
+		"                                 of the tokens parameter (2000 by default), taking the flags of the search command like /search\n" +
+		"  GET /suggest?channel=&prefix=  most frequent terms of the indexes starting with prefix, to complete queries, taking\n" +
+		"                                 the lang, limit (10 by default) and all parameters\n" +
+		"  GET /status?channel=[&lang=]   statistics of the indexes of a channel\n" +
+		"  POST /reindex?channel=[&lang=] index the new and modified subtitles of a channel\n" +
+		"  GET /alerts?name=[&format=]    feed of the matches of an alert, see save -alert, as Atom by default or as RSS with format=rss,\n" +
+		"                                 taking the platform and platform-base parameters like search\n" +
+		"  POST /graphql                  GraphQL API over the videos, the segments, the facets and the statistics of the indexes,\n" +
+		"                                 see cli/schema.graphql\n" +
+		"  GET /live?channel=             WebSocket streaming the best segments of each query sent as a text message, as JSON, while\n" +
+		"                                 it is typed, taking the flags of the search command as parameters like /search\n" +
+		"  GET /openapi.json              OpenAPI description of the endpoints, to generate clients\n" +
+		"  GET /metrics                   metrics of the indexing, the searches and the server, in the Prometheus format\n" +
+		"  GET /healthz                   checks that the indexes kept open still answer a query, failing with 503 otherwise\n" +
+		"  GET /readyz?[channel=][&lang=][&strict=]\n" +
+		"                                 checks that the indexes of the channels, the sync_channels by default, answer a query,\n" +
+		"                                 without evicting open indexes, failing with 503 otherwise and with strict=true on\n" +
+		"                                 the indexes not created yet\n" +
+		"With api_keys in the configuration, the endpoints other than the web frontend, /openapi.json, /healthz and /readyz\n" +
+		"require a key, given by an Authorization: Bearer or X-API-Key header or by the api_key parameter, and by the\n" +
+		"authorization metadata in gRPC.\n" +
+		"The -ip-rate, -max-query-length, -max-results and -max-videos flags guard a public instance from abusive or\n" +
+		"pathological requests, whose bodies are limited to 1 MiB.",
+	run: runServe,
+}
+
+//go:embed web
+var webFiles embed.FS
+
+// unservedSearchFlags are the search flags that cannot be given to the server, because they act on its machine, e.g.
+// oembed making it fetch the titles and write them to its cache file, or because the server only opens bleve indexes.
+var unservedSearchFlags = map[string]bool{"notes": true, "player": true, "backend": true, "parallelism": true, "oembed": true}
+
+// servedSearchDefaults are the flags of the searches of the server before the parameters of the requests.
+var servedSearchDefaults = []string{"-format=json", "-color=never"}
+
+// contentTypes maps the output formats to the content type of their responses, text/plain being used for the others.
+var contentTypes = map[string]string{
+	"json":         "application/json",
+	"grouped-json": "application/json",
+	"jsonl":        "application/x-ndjson",
+	"csv":          "text/csv",
+	"html":         "text/html",
+	"markdown":     "text/markdown",
+	"vtt":          "text/vtt",
+	"srt":          "application/x-subrip",
+	"m3u":          "audio/x-mpegurl",
+	"xspf":         "application/xspf+xml",
+	"anki":         "text/tab-separated-values",
+	"gob":          "application/octet-stream",
+	"atom":         "application/atom+xml",
+	"rss":          "application/rss+xml",
+}
+
+// serverErrors maps the errors of the library to HTTP statuses and gRPC codes, 500 and Unknown being used for the others.
+var serverErrors = []struct {
+	err    error
+	status int
+	code   codes.Code
+}{
+	{sininen.ErrInvalidOption, http.StatusBadRequest, codes.InvalidArgument},
+	{sininen.ErrIndexNotFound, http.StatusNotFound, codes.NotFound},
+	{sininen.ErrVideoNotFound, http.StatusNotFound, codes.NotFound},
+	{sininen.ErrNoSubtitles, http.StatusNotFound, codes.NotFound},
+	{sininen.ErrTextNotStored, http.StatusConflict, codes.FailedPrecondition},
+	{errUnauthenticated, http.StatusUnauthorized, codes.Unauthenticated},
+	{errQuotaExceeded, http.StatusTooManyRequests, codes.ResourceExhausted},
+}
+
+// server answers the HTTP requests, keeping the most recently used indexes open and the results of the most recent
+// searches between them.
+type server struct {
+	mutex     sync.Mutex              // Guards the indexes and their users.
+	indexes   *lruCache               // Of *sharedIndex, by channel and language, see indexKey.
+	lingering map[string]*sharedIndex // Indexes evicted from the cache but still in use, by key.
+	results   *lruCache               // Of cachedSearch, see searchSettings.searchKey, nil when the searches are not cached.
+	updates   map[string]int          // Number of invalidations of each index, by key, guarded by mutex, see invalidate.
+	debounce  time.Duration           // Time the live searches wait for their query to stop changing, see serveLive.
+	auth      *authenticator          // Checks the API keys of the requests, nil when the server is open to everyone.
+	limits    limits
+}
+
+// sharedIndex is an index kept open by the server, closed once evicted from the cache and released by its last user.
+type sharedIndex struct {
+	index bleve.Index
+	key   string // See indexKey.
+	users int
+}
+
+// cachedSearch is the result of a search kept by the server, along with the indexes it was found in.
+type cachedSearch struct {
+	videos  sininen.SearchResultSequence
+	indexes []string // See indexKey.
+}
+
+// indexLookups counts the lookups of the indexes kept open by the server, the misses being the indexes opened.
+var indexLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "sininen",
+	Subsystem: "server",
+	Name:      "index_cache_lookups_total",
+	Help:      "Lookups of the indexes kept open by the server, by result (hit or miss).",
+}, []string{"result"})
+
+// searchLookups counts the lookups of the search results cached by the server, the misses being the searches made.
+var searchLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "sininen",
+	Subsystem: "server",
+	Name:      "search_cache_lookups_total",
+	Help:      "Lookups of the search results cached by the server, by result (hit or miss).",
+}, []string{"result"})
+
+// newServer returns a server keeping at most maxIndexes indexes open, or all of them when not positive, and the results
+// of the maxSearches most recent searches, none of them when not positive.
+func newServer(maxIndexes, maxSearches int) *server {
+	s := &server{lingering: map[string]*sharedIndex{}, updates: map[string]int{}}
+	s.indexes = newLRUCache(maxIndexes, func(key string, value interface{}) {
+		if shared := value.(*sharedIndex); shared.users == 0 {
+			closeShared(shared)
+		} else {
+			s.lingering[key] = shared
+		}
+	})
+	if maxSearches > 0 {
+		s.results = newLRUCache(maxSearches, nil)
+	}
+	return s
+}
+
+// jsonUpdate is the JSON representation of the changes made to an index by a reindexing.
+type jsonUpdate struct {
+	Lang    string   `json:"lang"`
+	Added   []string `json:"added"`
+	Updated []string `json:"updated"`
+	Removed []string `json:"removed"`
+}
+
+// indexKey returns the key of the index of a channel in a language.
+func indexKey(channelName, lang string) string {
+	return channelName + "/" + lang
+}
+
+// checkChannel fails when a channel was not downloaded, or when its name would lead outside the subtitles root.
+func checkChannel(channelName string) error {
+	if channelName == "" || channelName == "." || channelName == ".." || strings.ContainsAny(channelName, `/\`) {
+		return fmt.Errorf("%w: invalid channel %q", sininen.ErrInvalidOption, channelName)
+	}
+	info, err := os.Stat(filepath.Join(defaults.SubtitlesRoot, channelName))
+	if err != nil || !info.IsDir() {
+		return fmt.Errorf("%w: no channel %q downloaded", sininen.ErrInvalidOption, channelName)
+	}
+	return nil
+}
+
+// checkLang returns an error unless a language given by a client can be part of the name of an index, so that it
+// cannot reach outside the index folder of the channel.
+func checkLang(lang string) error {
+	if lang == "" || strings.Contains(lang, "..") || strings.ContainsAny(lang, `/\`) {
+		return fmt.Errorf("%w: invalid language %q", sininen.ErrInvalidOption, lang)
+	}
+	return nil
+}
+
+// channelIndex returns the index of a channel in a language, opening or creating it when it is not open.
+// The index must be given back to release once done with it.
+func (s *server) channelIndex(channelName, lang string) (*sharedIndex, error) {
+	if err := checkLang(lang); err != nil {
+		return nil, err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	key := indexKey(channelName, lang)
+	if value, ok := s.indexes.get(key); ok {
+		indexLookups.WithLabelValues("hit").Inc()
+		shared := value.(*sharedIndex)
+		shared.users++
+		return shared, nil
+	}
+	indexLookups.WithLabelValues("miss").Inc()
+	if shared, ok := s.lingering[key]; ok { // Still open, the index cannot be opened again.
+		delete(s.lingering, key)
+		shared.users++
+		s.indexes.add(key, shared)
+		return shared, nil
+	}
+	if err := checkChannel(channelName); err != nil {
+		return nil, err
+	}
+	indexes, err := openChannelIndexes(channelName, []string{lang})
+	if err != nil {
+		return nil, err
+	}
+	shared := &sharedIndex{index: indexes[0], key: key, users: 1}
+	s.indexes.add(key, shared) // May evict the least recently used index, the mutex being held.
+	return shared, nil
+}
+
+// openIndex returns the index of a channel in a language when the server keeps it open, without opening it nor marking
+// it as used, and false otherwise. The index must be given back to release once done with it.
+func (s *server) openIndex(channelName, lang string) (*sharedIndex, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	key := indexKey(channelName, lang)
+	shared, ok := s.lingering[key]
+	if value, cached := s.indexes.peek(key); cached {
+		shared, ok = value.(*sharedIndex), true
+	}
+	if ok {
+		shared.users++
+	}
+	return shared, ok
+}
+
+// release gives back indexes returned by channelIndex or openIndex, closing the evicted ones no longer used.
+func (s *server) release(indexes ...*sharedIndex) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, shared := range indexes {
+		if shared.users--; shared.users == 0 && s.lingering[shared.key] == shared {
+			delete(s.lingering, shared.key)
+			closeShared(shared)
+		}
+	}
+}
+
+// closeShared closes an index no longer kept by the server, the failures being only reported.
+func closeShared(shared *sharedIndex) {
+	if err := shared.index.Close(); err != nil {
+		sininen.Log.Warnf("closing the index %s: %v", shared.key, err)
+	}
+}
+
+// acquireIndexes returns the indexes of the given languages of channels and the function releasing them once done.
+func (s *server) acquireIndexes(channelNames, langs []string) ([]bleve.Index, func(), error) {
+	indexes := make([]*sharedIndex, 0, len(channelNames)*len(langs))
+	release := func() { s.release(indexes...) }
+	for _, channelName := range channelNames {
+		for _, lang := range langs {
+			index, err := s.channelIndex(channelName, lang)
+			if err != nil {
+				release()
+				return nil, nil, err
+			}
+			indexes = append(indexes, index)
+		}
+	}
+	result := make([]bleve.Index, len(indexes))
+	for i, index := range indexes {
+		result[i] = index.index
+	}
+	return result, release, nil
+}
+
+// openIndexes returns the indexes of the given languages of channels, searched together through an index alias, and
+// the function releasing them once done.
+func (s *server) openIndexes(channelNames, langs []string) (bleve.Index, func(), error) {
+	indexes, release, err := s.acquireIndexes(channelNames, langs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return aliasIndexes(indexes), release, nil
+}
+
+// search searches the indexes of channels with the given settings, reusing the results of an identical search when
+// they are cached.
+func (s *server) search(settings *searchSettings, channelNames []string, query string) (sininen.SearchResultSequence, error) {
+	if err := s.checkQuery(query); err != nil {
+		return nil, err
+	}
+	if err := settings.useChannels(channelNames); err != nil {
+		return nil, err
+	}
+	langs := settings.langs.orDefault()
+	key := settings.searchKey(channelNames, query)
+	if s.results != nil {
+		if value, ok := s.results.get(key); ok {
+			searchLookups.WithLabelValues("hit").Inc()
+			return value.(cachedSearch).videos, nil
+		}
+		searchLookups.WithLabelValues("miss").Inc()
+	}
+	keys := make([]string, 0, len(channelNames)*len(langs))
+	for _, channelName := range channelNames {
+		for _, lang := range langs {
+			keys = append(keys, indexKey(channelName, lang))
+		}
+	}
+	s.mutex.Lock()
+	generation := s.generation(keys)
+	s.mutex.Unlock()
+	indexes, release, err := s.acquireIndexes(channelNames, langs)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	videos, err := settings.search(settings.bleveSearcher(indexes), query)
+	if err != nil || s.results == nil {
+		return videos, err
+	}
+	s.storeSearch(key, cachedSearch{videos, keys}, generation)
+	return videos, nil
+}
+
+// generation returns the number of invalidations of the indexes of the given keys, the mutex being held.
+func (s *server) generation(keys []string) int {
+	result := 0
+	for _, key := range keys {
+		result += s.updates[key]
+	}
+	return result
+}
+
+// storeSearch caches the results of a search, unless one of its indexes was invalidated since the generation was read
+// before searching, in which case the results may be stale.
+func (s *server) storeSearch(key string, search cachedSearch, generation int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.generation(search.indexes) == generation {
+		s.results.add(key, search)
+	}
+}
+
+// invalidate forgets the cached results of the searches of an index, for instance because it was updated, including the
+// ones of the searches still running, see storeSearch.
+func (s *server) invalidate(channelName, lang string) {
+	if s.results == nil {
+		return
+	}
+	key := indexKey(channelName, lang)
+	s.mutex.Lock()
+	s.updates[key]++ // Before the removal, so that the searches running meanwhile do not store their results.
+	s.mutex.Unlock()
+	s.results.removeIf(func(_ string, value interface{}) bool {
+		for _, searched := range value.(cachedSearch).indexes {
+			if searched == key {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// close closes all the opened indexes, the ones still in use being closed once released.
+func (s *server) close() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.indexes.purge()
+}
+
+// handler returns the handler of the endpoints.
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	api := func(handler func(w http.ResponseWriter, r *http.Request) error) func(w http.ResponseWriter, r *http.Request) error {
+		return s.limited(s.authorized(handler))
+	}
+	mux.HandleFunc("/channels", onlyMethod(http.MethodGet, api(s.serveChannels)))
+	mux.HandleFunc("/search", onlyMethod(http.MethodGet, api(s.serveSearch)))
+	mux.HandleFunc("/status", onlyMethod(http.MethodGet, api(s.serveStatus)))
+	mux.HandleFunc("/reindex", onlyMethod(http.MethodPost, api(s.serveReindex)))
+	mux.HandleFunc("/alerts", onlyMethod(http.MethodGet, api(s.serveAlerts)))
+	mux.HandleFunc("/graphql", onlyMethod(http.MethodPost, api(s.serveGraphQL())))
+	mux.HandleFunc("/openapi.json", onlyMethod(http.MethodGet, s.limited(s.serveOpenAPI))) // Public, to let clients be generated.
+	mux.HandleFunc("/context", onlyMethod(http.MethodGet, api(s.serveContext)))
+	mux.HandleFunc("/suggest", onlyMethod(http.MethodGet, api(s.serveSuggest)))
+	mux.HandleFunc("/live", onlyMethod(http.MethodGet, api(s.serveLive)))
+	mux.HandleFunc("/metrics", onlyMethod(http.MethodGet, s.limited(s.authorizedHandler(metricsHandler()))))
+	mux.HandleFunc("/healthz", onlyMethod(http.MethodGet, s.serveHealth)) // Public and unlimited, for the probes of orchestrators.
+	mux.HandleFunc("/readyz", onlyMethod(http.MethodGet, s.limited(s.serveReady)))
+	web, _ := fs.Sub(webFiles, "web") // Cannot fail, web being a valid path.
+	mux.Handle("/", http.FileServer(http.FS(web)))
+	return mux
+}
+
+// metricsHandler returns the handler exporting the metrics of the library and of the server, along with the ones of
+// the Go runtime and of the process.
+func metricsHandler() http.Handler {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}), indexLookups, searchLookups, authentications)
+	sininen.RegisterMetrics(registry) // Cannot fail, the registry being new.
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// onlyMethod restricts a handler to a method, failing with a JSON error for the others.
+func onlyMethod(method string, handler func(w http.ResponseWriter, r *http.Request) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		location := redactedURL(r.URL)
+		sininen.Log.Infof("%s %s", r.Method, location)
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("%s expected", method))
+			return
+		}
+		if err := handler(w, r); err != nil {
+			status := http.StatusInternalServerError
+			for _, mapping := range serverErrors {
+				if errors.Is(err, mapping.err) {
+					status = mapping.status
+					break
+				}
+			}
+			sininen.Log.Warnf("%s %s: %v", r.Method, location, err)
+			writeError(w, status, err)
+		}
+	}
+}
+
+// writeError writes an error as a JSON object with an error field.
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", contentTypes["json"])
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+// writeJSONResponse writes a value as JSON.
+func writeJSONResponse(w http.ResponseWriter, value interface{}) error {
+	w.Header().Set("Content-Type", contentTypes["json"])
+	return json.NewEncoder(w).Encode(value)
+}
+
+// nonNil returns ids, or an empty slice when it is nil so that it is encoded as an empty JSON array.
+func nonNil(ids []string) []string {
+	if ids == nil {
+		return []string{}
+	}
+	return ids
+}
+
+// requestLangs returns the languages given by the lang parameters of a request, or the default ones.
+func requestLangs(r *http.Request) []string {
+	return languages(r.URL.Query()["lang"]).orDefault()
+}
+
+func (s *server) serveChannels(w http.ResponseWriter, r *http.Request) error {
+	channelNames, err := allChannels()
+	if err != nil {
+		return err
+	}
+	return writeJSONResponse(w, channelNames)
+}
+
+// parseSearchParameters parses the parameters of a search request other than channel, q and cursor as the flags of
+// the search command, after the given defaults, and returns them along with the searched channels.
+func parseSearchParameters(parameters url.Values, defaults []string) (*searchSettings, []string, error) {
+	flags := flag.NewFlagSet("search", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	settings := addSearchFlags(flags)
+	args := append([]string{}, defaults...)
+	for name, values := range parameters {
+		if name == "channel" || name == "q" || name == "cursor" {
+			continue
+		}
+		if unservedSearchFlags[name] {
+			return nil, nil, fmt.Errorf("%w: %s cannot be given to the server", sininen.ErrInvalidOption, name)
+		}
+		for _, value := range values {
+			args = append(args, "-"+name+"="+value)
+		}
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", sininen.ErrInvalidOption, err)
+	}
+	if err := settings.check(); err != nil {
+		return nil, nil, err
+	}
+	channelNames, err := settings.channels(parameters["channel"])
+	if err != nil {
+		return nil, nil, err
+	}
+	return settings, channelNames, nil
+}
+
+// serveSearch runs a search, the parameters other than channel and q being parsed as the flags of the search command.
+func (s *server) serveSearch(w http.ResponseWriter, r *http.Request) error {
+	query := r.URL.Query().Get("q")
+	if query == "" {
+		return fmt.Errorf("%w: the q parameter is mandatory", sininen.ErrInvalidOption)
+	}
+	settings, channelNames, err := parseSearchParameters(r.URL.Query(), servedSearchDefaults)
+	if err != nil {
+		return err
+	}
+	s.limitResults(r.Context(), settings)
+	videos, err := s.search(settings, channelNames, query)
+	if err != nil {
+		return err
+	}
+	segments, err := pageSegments(w, r, settings, videos)
+	if err != nil {
+		return err
+	}
+	contentType, ok := contentTypes[*settings.format]
+	if !ok {
+		contentType = "text/plain; charset=utf-8"
+	}
+	w.Header().Set("Content-Type", contentType)
+	return settings.render(w, strings.Join(channelNames, ", "), query, segments)
+}
+
+// searchDigest identifies the search of a request, i.e. its parameters other than its position in the results.
+func searchDigest(parameters url.Values) string {
+	remaining := url.Values{}
+	for name, values := range parameters {
+		if name != "cursor" && name != "offset" {
+			remaining[name] = values
+		}
+	}
+	digest := fnv.New64a()
+	digest.Write([]byte(remaining.Encode())) // Encode sorts the parameters by name.
+	return strconv.FormatUint(digest.Sum64(), 36)
+}
+
+// pageSegments returns the page of the segments of search results requested by the cursor, offset and limit
+// parameters, the segments starting after the cursor when one is given.
+// When other segments follow the page, the cursor of the next page is given by the X-Next-Cursor header, and the URL
+// of the next page by the Link header.
+func pageSegments(w http.ResponseWriter, r *http.Request, settings *searchSettings, videos sininen.SearchResultSequence) ([]sininen.ScoredSegment, error) {
+	search := searchDigest(r.URL.Query())
+	var cursor *sininen.Cursor
+	settings.rankedAt = time.Now()
+	if token := r.URL.Query().Get("cursor"); token != "" {
+		if *settings.sample > 0 {
+			return nil, fmt.Errorf("%w: a cursor cannot page through sampled segments", sininen.ErrInvalidOption)
+		}
+		parsed, err := sininen.ParseCursor(token)
+		if err != nil {
+			return nil, err
+		}
+		if parsed.Search != search {
+			return nil, fmt.Errorf("%w: the cursor belongs to another search", sininen.ErrInvalidOption)
+		}
+		cursor = &parsed
+		if !parsed.RankedAt.IsZero() {
+			settings.rankedAt = parsed.RankedAt // Ranking the pages alike, see -half-life.
+		}
+	}
+	segments := settings.unpaginatedSegments(videos)
+	if cursor != nil {
+		segments = sininen.SegmentsAfter(segments, *cursor)
+	}
+	page := settings.displayed(r.Context(), sininen.PaginateSegments(segments, *settings.offset, *settings.limit))
+	if len(page) == 0 || *settings.offset+len(page) >= len(segments) || *settings.sample > 0 {
+		return page, nil
+	}
+	next := sininen.NewCursor(page[len(page)-1], search, settings.rankedAt).Encode()
+	parameters := r.URL.Query()
+	parameters.Del("offset")
+	parameters.Set("cursor", next)
+	w.Header().Set("X-Next-Cursor", next)
+	w.Header().Set("Link", fmt.Sprintf(`<%s?%s>; rel="next"`, r.URL.Path, parameters.Encode()))
+	return page, nil
+}
+
+// requestChannels returns the channels of a request, given by its channel parameters or by all=true for every
+// downloaded channel.
+func requestChannels(r *http.Request) ([]string, error) {
+	channelNames := r.URL.Query()["channel"]
+	all := false
+	if raw := r.URL.Query().Get("all"); raw != "" {
+		var err error
+		if all, err = strconv.ParseBool(raw); err != nil {
+			return nil, fmt.Errorf("%w: all expects a boolean, got %q", sininen.ErrInvalidOption, raw)
+		}
+	}
+	return selectChannels(channelNames, all)
+}
+
+// selectChannels returns the given channels, or every downloaded channel when all is set, which excludes giving some.
+func selectChannels(channelNames []string, all bool) ([]string, error) {
+	if all && len(channelNames) > 0 {
+		return nil, fmt.Errorf("%w: no channel can be given with all", sininen.ErrInvalidOption)
+	}
+	if all {
+		return allChannels()
+	}
+	if len(channelNames) == 0 {
+		return nil, fmt.Errorf("%w: expected at least one channel, or all", sininen.ErrInvalidOption)
+	}
+	return channelNames, nil
+}
+
+// suggest returns the most frequent terms of the indexes of channels starting with a prefix, see sininen.SuggestTerms.
+func (s *server) suggest(channelNames, langs []string, prefix string, nterms int) ([]sininen.TermCount, error) {
+	if err := s.checkQuery(prefix); err != nil {
+		return nil, err
+	}
+	indexes, release, err := s.acquireIndexes(channelNames, langs)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return sininen.SuggestTerms(prefix, nterms, indexes...)
+}
+
+// serveSuggest suggests the terms completing the prefix parameter, in the indexes of the channels of the request.
+func (s *server) serveSuggest(w http.ResponseWriter, r *http.Request) error {
+	prefix := r.URL.Query().Get("prefix")
+	if prefix == "" {
+		return fmt.Errorf("%w: the prefix parameter is mandatory", sininen.ErrInvalidOption)
+	}
+	limit := 10
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		var err error
+		if limit, err = strconv.Atoi(raw); err != nil || limit < 1 {
+			return fmt.Errorf("%w: limit expects a positive integer, got %q", sininen.ErrInvalidOption, raw)
+		}
+	}
+	channelNames, err := requestChannels(r)
+	if err != nil {
+		return err
+	}
+	terms, err := s.suggest(channelNames, requestLangs(r), prefix, s.maxResults(r.Context(), limit))
+	if err != nil {
+		return err
+	}
+	return writeJSONResponse(w, terms)
+}
+
+// indexStats computes the statistics of the index of a channel in a language.
+func (s *server) indexStats(channelName, lang string, nterms int) (sininen.IndexStats, error) {
+	index, err := s.channelIndex(channelName, lang)
+	if err != nil {
+		return sininen.IndexStats{}, err
+	}
+	defer s.release(index)
+	folder, err := indexFolder(channelName)
+	if err != nil {
+		return sininen.IndexStats{}, err
+	}
+	return sininen.ComputeIndexStats(index.index, folder, lang, nterms)
+}
+
+// reindex indexes the new and modified subtitles of a channel in a language, forgets the results of its searches and
+// checks the alerts against the added videos.
+func (s *server) reindex(channelName, lang string) (sininen.IndexUpdate, error) {
+	index, err := s.channelIndex(channelName, lang)
+	if err != nil {
+		return sininen.IndexUpdate{}, err
+	}
+	defer s.release(index)
+	changes, err := sininen.UpdateSubtitleIndex(index.index, filepath.Join(defaults.SubtitlesRoot, channelName), lang, nil)
+	if err == nil && !changes.Empty() {
+		sininen.Log.Infof("reindexed %s (%s): %d added, %d updated, %d removed", channelName, lang,
+			len(changes.Added), len(changes.Updated), len(changes.Removed))
+		s.invalidate(channelName, lang)
+		checkAlerts(sininen.BleveBackend{Index: index.index}, channelName, lang, changes.Added)
+	}
+	return changes, err
+}
+
+func (s *server) serveStatus(w http.ResponseWriter, r *http.Request) error {
+	result := []jsonStats{}
+	for _, lang := range requestLangs(r) {
+		stats, err := s.indexStats(r.URL.Query().Get("channel"), lang, 10)
+		if err != nil {
+			return err
+		}
+		result = append(result, jsonStats{
+			Lang:            lang,
+			Videos:          stats.Videos,
+			TranscriptHours: stats.TranscriptDuration.Hours(),
+			Size:            stats.Size,
+			LastUpdate:      stats.LastUpdate,
+			TopTerms:        stats.TopTerms,
+		})
+	}
+	return writeJSONResponse(w, result)
+}
+
+func (s *server) serveReindex(w http.ResponseWriter, r *http.Request) error {
+	result := []jsonUpdate{}
+	for _, lang := range requestLangs(r) {
+		changes, err := s.reindex(r.URL.Query().Get("channel"), lang)
+		if err != nil {
+			return err
+		}
+		result = append(result, jsonUpdate{lang, nonNil(changes.Added), nonNil(changes.Updated), nonNil(changes.Removed)})
+	}
+	return writeJSONResponse(w, result)
+}
+
+// alertFeedLength is the number of matches in the feeds of the alerts.
+const alertFeedLength = 100
+
+// serveAlerts writes the feed of the most recent matches of an alert.
+func (s *server) serveAlerts(w http.ResponseWriter, r *http.Request) error {
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		return fmt.Errorf("%w: the name parameter is mandatory", sininen.ErrInvalidOption)
+	}
+	nb, err := loadNotebook()
+	if err != nil {
+		return err
+	}
+	search, err := nb.search(name)
+	if err != nil {
+		return err
+	}
+	if !search.Alert {
+		return fmt.Errorf("%w: the search saved as %q has no alert", sininen.ErrInvalidOption, name)
+	}
+	write, contentType := sininen.WriteAtom, contentTypes["atom"]
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "atom":
+	case "rss":
+		write, contentType = sininen.WriteRSS, contentTypes["rss"]
+	default:
+		return fmt.Errorf("%w: unknown feed format %q, expected atom or rss", sininen.ErrInvalidOption, format)
+	}
+	matches, err := readAlertMatches(name, alertFeedLength)
+	if err != nil {
+		return err
+	}
+	feed := sininen.Feed{
+		Title: fmt.Sprintf("%s in %s", search.Query, strings.Join(search.Channels, ", ")),
+		Link:  "http://" + r.Host + r.URL.RequestURI(),
+	}
+	for _, match := range matches {
+		feed.Entries = append(feed.Entries, sininen.FeedEntry{Segment: match.Segment, Published: match.Time})
+	}
+	urls, err := newURLBuilder(r.URL.Query().Get("platform"), r.URL.Query().Get("platform-base"))
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", contentType)
+	return write(w, feed, urls)
+}
+
+func runServe(cmd *command, args []string) {
+	flags := cmd.flagSet()
+	addr := flags.String("addr", "localhost:8080", "Address the server listens on.")
+	grpcAddr := flags.String("grpc-addr", "", "Address the gRPC API listens on, see sininenpb/sininen.proto (disabled by default).")
+	maxIndexes := flags.Int("max-open-indexes", 64, "Maximum number of indexes kept open, the least recently used one being closed to open another (unlimited when 0).")
+	maxSearches := flags.Int("cache-searches", 256, "Number of recent search results kept to answer the same searches again, until their indexes are updated (0 disables the cache).")
+	debounce := flags.Duration("debounce", 150*time.Millisecond, "Time the live searches wait for the query to stop changing before searching it, see GET /live.")
+	ipRate := flags.Int("ip-rate", 0, "Maximum number of requests per minute from an IP address, up to a minute of them at once (unlimited when 0).")
+	ipHeader := flags.String("ip-header", "", "Header holding the IP address of the clients, e.g. X-Forwarded-For or X-Real-IP when behind a reverse proxy (the address of the connection by default), only trusted from the -trusted-proxies.")
+	trustedProxies := flags.String("trusted-proxies", "127.0.0.0/8,::1", "Comma-separated addresses or CIDR networks of the reverse proxies whose -ip-header is trusted, the header of the other clients being ignored.")
+	maxQueryLength := flags.Int("max-query-length", 500, "Maximum number of characters of the queries (unlimited when 0).")
+	maxResults := flags.Int("max-results", 0, "Maximum number of segments or terms of a response, capping the limit parameter (unlimited when 0).")
+	maxVideos := flags.Int("max-videos", 1000, "Maximum number of videos retrieved by a search, capping the max-videos parameter (unlimited when 0).")
+	printOpenAPI := flags.Bool("openapi", false, "Write the OpenAPI description of the endpoints to stdout and exit, e.g. to generate clients.")
+	cmd.parseArgs(flags, args, 0)
+
+	if *printOpenAPI {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		perhapsExit(encoder.Encode(openAPISpec()), exitUsage)
+		return
+	}
+	s := newServer(*maxIndexes, *maxSearches)
+	s.debounce = *debounce
+	auth, err := newAuthenticator(defaults.APIKeys)
+	perhapsExit(err, exitUsage)
+	s.auth = auth
+	proxies, err := parseNetworks(*trustedProxies)
+	perhapsExit(err, exitUsage)
+	s.limits = limits{newAddressLimiter(*ipRate, *ipHeader, proxies), *maxQueryLength, *maxResults, *maxVideos}
+	httpServer := &http.Server{Addr: *addr, Handler: s.handler(), ReadHeaderTimeout: 10 * time.Second}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	var rpcServer *grpc.Server
+	if *grpcAddr != "" {
+		listener, err := net.Listen("tcp", *grpcAddr)
+		perhapsExit(err, exitUsage)
+		rpcServer = grpc.NewServer(s.grpcInterceptors()...)
+		sininenpb.RegisterSininenServer(rpcServer, grpcServer{server: s})
+		inform("gRPC API listening on %s.\n", *grpcAddr)
+		go func() {
+			if err := rpcServer.Serve(listener); err != nil {
+				sininen.Log.Warnf("gRPC API: %v", err)
+			}
+		}()
+	}
+	go func() {
+		<-ctx.Done()
+		if rpcServer != nil {
+			rpcServer.Stop() // Not GracefulStop, which would wait for the Watch calls forever.
+		}
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+
+	inform("Listening on http://%s.\n", *addr)
+	if err := httpServer.ListenAndServe(); err != http.ErrServerClosed {
+		perhapsExit(err, exitUsage)
+	}
+	s.close()
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/mooss/sininen"
+)
+
+var snapshotCommand = &command{
+	name:        "snapshot",
+	arguments:   "channel-id",
+	description: "Write a snapshot of the indexed transcriptions of a channel, searchable without the index, e.g. by the WebAssembly build in a browser.",
+	details: "One snapshot is written per language, as <channel>.<lang>.snapshot.gz in the folder given by -output, " +
+		"the current folder by default.\n" +
+		"The snapshots of the n-gram indexes only match whole words.",
+	run: runSnapshot,
+}
+
+func runSnapshot(cmd *command, args []string) {
+	flags := cmd.flagSet()
+	output := flags.String("output", ".", "Folder of the snapshots.")
+	langs := addLangFlag(flags)
+	cmd.parseArgs(flags, args, 1)
+
+	channelName := flags.Arg(0)
+	perhapsExit(os.MkdirAll(*output, 0755), exitOutput)
+	for _, lang := range langs.orDefault() {
+		indexes, err := openChannelIndexes(channelName, []string{lang})
+		perhapsExit(err, exitIndex)
+		snapshot, err := sininen.NewSnapshot(indexes[0], lang)
+		perhapsExit(err, exitIndex)
+		perhapsExit(indexes[0].Close(), exitIndex)
+
+		var buffer bytes.Buffer
+		perhapsExit(snapshot.WriteSnapshot(&buffer), exitOutput)
+		filename := filepath.Join(*output, sininen.SnapshotName(channelName, lang))
+		perhapsExit(ioutil.WriteFile(filename+".tmp", buffer.Bytes(), 0644), exitOutput)
+		perhapsExit(os.Rename(filename+".tmp", filename), exitOutput)
+		inform("Wrote the %d transcriptions of %s to %s.\n", len(snapshot.Videos), lang, filename)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/mooss/sininen"
+)
+
+var sponsorblockCommand = &command{
+	name:        "sponsorblock",
+	arguments:   "channel-id",
+	description: "Fetch the sponsor, intro and other skippable segments of the indexed videos from SponsorBlock.",
+	details: "The segments are stored in the sponsorblock.json file of the subtitles folder of the channel, and used by " +
+		"the -sponsors and -skip-sponsors flags of the searches.",
+	run: runSponsorBlock,
+}
+
+func runSponsorBlock(cmd *command, args []string) {
+	flags := cmd.flagSet()
+	endpoint := flags.String("url", "https://sponsor.ajay.app", "URL of the SponsorBlock server.")
+	categories := flags.String("categories", strings.Join(sininen.DefaultSponsorCategories, ","), "Comma-separated SponsorBlock categories of the fetched segments.")
+	missing := flags.Bool("missing", false, "Only fetch the segments of the videos never fetched before, instead of refreshing them all.")
+	langs := addLangFlag(flags)
+	cmd.parseArgs(flags, args, 1)
+
+	subtitlesFolder := channelFolder(flags.Arg(0))
+	indexFolder, err := indexFolder(flags.Arg(0))
+	perhapsExit(err, exitIndex)
+	sponsors, err := sininen.LoadSponsorSegments(subtitlesFolder)
+	perhapsExit(err, exitIndex)
+	ids := []string{}
+	seen := map[string]bool{}
+	for _, lang := range langs.orDefault() {
+		index, err := sininen.OpenTranscriptionIndex(indexFolder, lang)
+		perhapsExit(err, exitIndex)
+		videos, err := sininen.ListVideos(index)
+		perhapsExit(err, exitIndex)
+		perhapsExit(index.Close(), exitIndex)
+		for _, video := range videos {
+			if _, known := sponsors[video.ID]; !seen[video.ID] && !(*missing && known) {
+				seen[video.ID] = true
+				ids = append(ids, video.ID)
+			}
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	api := sininen.SponsorBlockAPI{Endpoint: *endpoint, Categories: strings.Split(*categories, ",")}
+	fetched, fetchErr := api.FetchSponsorSegments(ctx, ids)
+	sponsored := 0
+	for id, segments := range fetched {
+		sponsors[id] = segments
+		if len(segments) > 0 {
+			sponsored++
+		}
+	}
+	perhapsExit(sponsors.Save(subtitlesFolder), exitIndex) // Even on failure, not to fetch the same videos again.
+	inform("Fetched the segments of %d videos, %d of them having some.\n", len(fetched), sponsored)
+	perhapsExit(fetchErr, exitDownload)
+}
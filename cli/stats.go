@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mooss/sininen"
+)
+
+var statsCommand = &command{
+	name:        "stats",
+	arguments:   "channel-id",
+	description: "Describe the index of a channel: videos, transcript hours, size, top terms and last update.",
+	run:         runStats,
+}
+
+// jsonStats is the JSON representation of the statistics of an index.
+type jsonStats struct {
+	Lang            string              `json:"lang"`
+	Videos          int                 `json:"videos"`
+	TranscriptHours float64             `json:"transcript_hours"`
+	Size            int64               `json:"size"`
+	LastUpdate      time.Time           `json:"last_update"`
+	TopTerms        []sininen.TermCount `json:"top_terms"`
+}
+
+// formatSize formats a number of bytes with a binary unit.
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	value, exponent := float64(size)/unit, 0
+	for value >= unit && exponent < 4 {
+		value /= unit
+		exponent++
+	}
+	return fmt.Sprintf("%.1f %ciB", value, "KMGTP"[exponent])
+}
+
+func runStats(cmd *command, args []string) {
+	flags := cmd.flagSet()
+	nterms := flags.Int("top", 10, "Number of most frequent terms displayed.")
+	jsonOutput := flags.Bool("json", false, "Output the statistics of each language as a JSON line.")
+	langs := addLangFlag(flags)
+	cmd.parseArgs(flags, args, 1)
+
+	channelName := flags.Arg(0)
+	indexFolder, err := indexFolder(channelName)
+	perhapsExit(err, exitIndex)
+	for _, lang := range langs.orDefault() {
+		index, err := sininen.OpenTranscriptionIndex(indexFolder, lang)
+		perhapsExit(err, exitIndex)
+		stats, err := sininen.ComputeIndexStats(index, indexFolder, lang, *nterms)
+		perhapsExit(err, exitIndex)
+		perhapsExit(index.Close(), exitIndex)
+
+		if *jsonOutput {
+			perhapsExit(sininen.WriteJSON(os.Stdout, jsonStats{
+				Lang:            lang,
+				Videos:          stats.Videos,
+				TranscriptHours: stats.TranscriptDuration.Hours(),
+				Size:            stats.Size,
+				LastUpdate:      stats.LastUpdate,
+				TopTerms:        stats.TopTerms,
+			}), exitOutput)
+			continue
+		}
+		terms := make([]string, 0, len(stats.TopTerms))
+		for _, term := range stats.TopTerms {
+			terms = append(terms, fmt.Sprintf("%s (%d)", term.Term, term.Count))
+		}
+		writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(writer, "Language:\t%s\n", lang)
+		fmt.Fprintf(writer, "Videos:\t%d\n", stats.Videos)
+		fmt.Fprintf(writer, "Transcript hours:\t%.1f\n", stats.TranscriptDuration.Hours())
+		fmt.Fprintf(writer, "Index size:\t%s\n", formatSize(stats.Size))
+		fmt.Fprintf(writer, "Last update:\t%s\n", stats.LastUpdate.Format("2006-01-02 15:04:05"))
+		fmt.Fprintf(writer, "Top terms:\t%s\n", strings.Join(terms, ", "))
+		perhapsExit(writer.Flush(), exitOutput)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+
+	"github.com/mooss/sininen"
+)
+
+var syncCommand = &command{
+	name:        "sync",
+	arguments:   "[channel-name...]",
+	description: "Periodically download the new subtitles of channels and index them, until interrupted.",
+	details: "The channels are the sync_channels of the configuration file when none is given. " +
+		"They are synced at start and then on the schedule given by -schedule, either @every <duration>, @hourly, @daily, @weekly, @monthly " +
+		"or a cron expression such as \"0 */6 * * *\". A line is printed for each channel synced, the failures not stopping the next syncs.\n" +
+		"The webhooks are notified of the videos added to the indexes and the alerts are checked against them, except when the indexes are created.",
+	run: runSync,
+}
+
+// syncer downloads and indexes the new subtitles of channels.
+type syncer struct {
+	channelNames []string
+	langs        languages // Languages downloaded, all of them when empty.
+	native       bool      // Downloads the recent subtitles with sininen.YouTubeClient instead of yt-dlp.
+	autoSubs     bool
+	backend      string
+	webhooks     sininen.Webhooks
+	logger       *log.Logger
+}
+
+// sync syncs every channel and returns the number of failures.
+func (s syncer) sync(ctx context.Context) int {
+	failures := 0
+	for _, channelName := range s.channelNames {
+		if ctx.Err() != nil {
+			return failures
+		}
+		if err := s.syncChannel(ctx, channelName); err != nil {
+			s.logger.Printf("syncing %s: %v", channelName, err)
+			failures++
+		}
+	}
+	return failures
+}
+
+// syncChannel downloads the new subtitles of a channel and updates its indexes of the default languages, or of the
+// downloaded ones when given.
+func (s syncer) syncChannel(ctx context.Context, channelName string) error {
+	destFolder := filepath.Join(defaults.SubtitlesRoot, channelName)
+	downloaded := 0
+	if s.native {
+		written, err := sininen.YouTubeClient{}.DownloadChannelSubtitles(ctx, channelName, destFolder, s.langs, nil)
+		if err != nil {
+			return err
+		}
+		downloaded = written
+	} else {
+		files, err := downloadWithYTDLP(ctx, channelName, sininen.YouTubeClient{}.ChannelURL(channelName), destFolder, s.langs, s.autoSubs)
+		if err != nil {
+			return err
+		}
+		downloaded = len(files)
+	}
+	s.logger.Printf("downloaded %d subtitles files of %s", downloaded, channelName)
+	for _, lang := range s.langs.orDefault() {
+		if err := s.updateIndex(ctx, channelName, destFolder, lang); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateIndex indexes the new and modified subtitles of a channel in a language, creating its index if needed, notifies
+// the webhooks of the added videos and checks the alerts against them.
+func (s syncer) updateIndex(ctx context.Context, channelName, subtitlesFolder, lang string) error {
+	indexFolder, err := indexFolder(channelName)
+	if err != nil {
+		return err
+	}
+	var backend sininen.Backend
+	created := false
+	if s.backend == sqliteBackend {
+		sqlite, err := sininen.OpenSQLiteBackend(indexFolder, lang)
+		if err != nil {
+			return err
+		}
+		count, err := sqlite.VideoCount()
+		if err != nil {
+			sqlite.Close()
+			return err
+		}
+		backend, created = sqlite, count == 0
+	} else {
+		index, err := sininen.OpenTranscriptionIndex(indexFolder, lang)
+		if errors.Is(err, sininen.ErrIndexNotFound) {
+			index, err = sininen.CreateSubtitleIndex(subtitlesFolder, lang, sininen.IndexOptions{Folder: indexFolder})
+			if errors.Is(err, sininen.ErrNoSubtitles) {
+				return nil // Nothing to index in this language yet.
+			}
+			if err != nil {
+				return err
+			}
+			count, err := index.DocCount()
+			if err != nil {
+				index.Close()
+				return err
+			}
+			s.logger.Printf("created the %s index of %s with %d videos", lang, channelName, count)
+			return index.Close()
+		}
+		if err != nil {
+			return err
+		}
+		backend = sininen.BleveBackend{Index: index}
+	}
+	changes, err := backend.Update(subtitlesFolder, lang, nil)
+	if err != nil {
+		backend.Close()
+		return err
+	}
+	s.logger.Printf("added %d, updated %d and removed %d videos of %s (%s)",
+		len(changes.Added), len(changes.Updated), len(changes.Removed), channelName, lang)
+	if !created {
+		notifyIndexed(ctx, s.webhooks, channelName, subtitlesFolder, lang, changes.Added)
+		checkAlerts(backend, channelName, lang, changes.Added)
+	}
+	return backend.Close()
+}
+
+func runSync(cmd *command, args []string) {
+	flags := cmd.flagSet()
+	spec := flags.String("schedule", defaults.SyncSchedule, "When the channels are synced, after the sync at start.")
+	once := flags.Bool("once", false, "Sync the channels once and exit, failing if any sync failed.")
+	ytdlp := flags.Bool("yt-dlp", false, "Download the subtitles and the metadata of every video not downloaded yet with yt-dlp, which is the default.")
+	native := flags.Bool("native", false, "Only download the subtitles uploaded for the most recent videos, without yt-dlp and without the metadata.")
+	autoSubs := flags.Bool("auto-subs", true, "Also download the automatic captions with yt-dlp.")
+	backend := addBackendFlag(flags)
+	webhookURLs := addWebhookFlag(flags)
+	var langs languages
+	flags.Var(&langs, "lang", "Language of the subtitles to download and index, can be repeated (all of them are downloaded and the default ones indexed by default).")
+	cmd.parseMinArgs(flags, args, 0)
+	perhapsExit(checkBackend(*backend), exitUsage)
+	if *ytdlp && *native {
+		perhapsExit(fmt.Errorf("%w: -yt-dlp and -native are mutually exclusive", sininen.ErrInvalidOption), exitUsage)
+	}
+	schedule, err := sininen.ParseSchedule(*spec)
+	perhapsExit(err, exitUsage)
+
+	channelNames := flags.Args()
+	if len(channelNames) == 0 {
+		channelNames = defaults.SyncChannels
+	}
+	if len(channelNames) == 0 {
+		perhapsExit(fmt.Errorf("%w: no channel to sync, give some or set sync_channels in the configuration file", sininen.ErrInvalidOption), exitUsage)
+	}
+	s := syncer{
+		channelNames: channelNames,
+		langs:        langs,
+		native:       *native,
+		autoSubs:     *autoSubs,
+		backend:      *backend,
+		webhooks:     webhookURLs.webhooks(),
+		logger:       log.New(os.Stdout, "", log.LstdFlags),
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if failures := s.sync(ctx); *once {
+		if failures > 0 {
+			perhapsExit(fmt.Errorf("%d of %d channels failed to sync", failures, len(channelNames)), exitDownload)
+		}
+		return
+	}
+	perhapsExit(sininen.RunSchedule(ctx, schedule, func(ctx context.Context) { s.sync(ctx) }), exitUsage)
+}
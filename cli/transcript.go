@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mooss/sininen"
+)
+
+var transcriptCommand = &command{
+	name:        "transcript",
+	arguments:   "channel-id video-id",
+	description: "Print the whole transcript of an indexed video with its timestamps.",
+	run:         runTranscript,
+}
+
+// readTranscript reads the transcript of a video from the first index of the channel containing it, in the order of the languages.
+func readTranscript(channelName, id string, langs []string, query string) ([]sininen.SegmentHit, error) {
+	indexFolder, err := indexFolder(channelName)
+	if err != nil {
+		return nil, err
+	}
+	for _, lang := range langs {
+		index, err := sininen.OpenTranscriptionIndex(indexFolder, lang)
+		if err != nil {
+			return nil, err
+		}
+		transcript, err := sininen.ReadTranscript(index, id, query)
+		if closeErr := index.Close(); err == nil {
+			err = closeErr
+		}
+		if errors.Is(err, sininen.ErrVideoNotFound) {
+			continue
+		}
+		return transcript, err
+	}
+	return nil, fmt.Errorf("%w: %s in %s for languages %s", sininen.ErrVideoNotFound, id, channelName, strings.Join(langs, ", "))
+}
+
+// writeTranscript writes one line per segment, with its timestamp and its text transformed by highlight, if not nil.
+func writeTranscript(w io.Writer, transcript []sininen.SegmentHit, highlight func(string) string) error {
+	buffered := bufio.NewWriter(w)
+	for _, segment := range transcript {
+		text := segment.Text
+		if highlight != nil {
+			text = segment.HighlightedText(highlight)
+		}
+		fmt.Fprintf(buffered, "%s %s\n", sininen.FormatTimestamp(segment.StartTime), strings.ReplaceAll(text, "\n", " "))
+	}
+	return buffered.Flush()
+}
+
+func runTranscript(cmd *command, args []string) {
+	flags := cmd.flagSet()
+	query := flags.String("query", "", "Highlight the terms of the transcript matching the given search query.")
+	color := flags.String("color", "auto", "Colorize the terms matching -query, either auto (when writing to a terminal, unless NO_COLOR is set), always or never.")
+	langs := addLangFlag(flags)
+	cmd.parseArgs(flags, args, 2)
+	highlight, err := colorHighlight(*color)
+	perhapsExit(err, exitUsage)
+
+	transcript, err := readTranscript(flags.Arg(0), flags.Arg(1), langs.orDefault(), *query)
+	perhapsExit(err, exitIndex)
+	perhapsExit(writeTranscript(os.Stdout, transcript, highlight), exitOutput)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+
+	"github.com/mooss/sininen"
+)
+
+var translateCommand = &command{
+	name:        "translate",
+	arguments:   "channel-name",
+	description: "Machine-translate the subtitles of a channel with LibreTranslate, and index the translations with the subtitles of the target language.",
+	details: "Only the videos without subtitles in the target language are translated, into files named like the downloaded subtitles. " +
+		"The translated segments are flagged with their original language in the search results, e.g. \"translated from fr\".",
+	run: runTranslate,
+}
+
+func runTranslate(cmd *command, args []string) {
+	flags := cmd.flagSet()
+	from := flags.String("from", "", "Language of the subtitles to translate.")
+	to := flags.String("to", defaults.Languages[0], "Language of the translations.")
+	serverURL := flags.String("url", defaults.TranslateURL, "Base URL of the LibreTranslate server, http://localhost:5000 by default.")
+	key := flags.String("key", defaults.TranslateKey, "API key of the LibreTranslate server.")
+	cmd.parseArgs(flags, args, 1)
+
+	if *from == "" || *from == *to {
+		perhapsExit(fmt.Errorf("%w: -from must give the language of the subtitles, other than -to", sininen.ErrInvalidOption), exitUsage)
+	}
+	channelName := flags.Arg(0)
+	folder := filepath.Join(defaults.SubtitlesRoot, channelName)
+	untranslated, err := sininen.UntranslatedSubtitles(folder, *from, *to)
+	perhapsExit(err, exitIndex)
+	inform("%d videos have %s subtitles but no %s subtitles in %s.\n", len(untranslated), *from, *to, folder)
+	if len(untranslated) == 0 {
+		return
+	}
+	ids := make([]string, 0, len(untranslated))
+	for id := range untranslated {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	translator := sininen.LibreTranslate{URL: *serverURL, Key: *key}
+	translated := 0
+	for i, id := range ids {
+		inform("Translating %s (%d/%d).\n", untranslated[id], i+1, len(ids))
+		filename, err := sininen.TranslateSubtitleFile(ctx, translator, untranslated[id], folder, id, *from, *to)
+		if ctx.Err() != nil {
+			perhapsExit(ctx.Err(), exitDownload)
+		}
+		if err != nil {
+			sininen.Log.Warnf("skipping %s: %v", untranslated[id], err)
+			continue
+		}
+		sininen.Log.Infof("translated %s to %s", untranslated[id], filename)
+		translated++
+	}
+	inform("Translated %d videos out of %d in %s.\n", translated, len(ids), folder)
+	if translated == 0 {
+		return
+	}
+
+	indexes, err := openChannelIndexes(channelName, []string{*to})
+	perhapsExit(err, exitIndex)
+	changes, err := sininen.UpdateSubtitleIndex(indexes[0], folder, *to, newProgress("Indexing "+*to))
+	perhapsExit(err, exitIndex)
+	perhapsExit(indexes[0].Close(), exitIndex)
+	inform("Added %d and updated %d videos in the %s index.\n", len(changes.Added), len(changes.Updated), *to)
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mooss/sininen"
+)
+
+var validateCommand = &command{
+	name:        "validate",
+	arguments:   "channel-id",
+	description: "Check that the subtitles and metadata files of a channel can be indexed, without touching the index.",
+	details:     "Each problem is displayed on a line, and the exit code is 8 if any was found.",
+	run:         runValidate,
+}
+
+func runValidate(cmd *command, args []string) {
+	flags := cmd.flagSet()
+	cmd.parseArgs(flags, args, 1)
+
+	problems, err := sininen.ValidateSubtitleFolder(channelFolder(flags.Arg(0)))
+	perhapsExit(err, exitNoChannel)
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+	if len(problems) > 0 {
+		inform("%d problems found.\n", len(problems))
+		os.Exit(exitBadFormat)
+	}
+	inform("No problem found.\n")
+}
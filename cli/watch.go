@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/mooss/sininen"
+)
+
+var watchCommand = &command{
+	name:        "watch",
+	arguments:   "channel-id",
+	description: "Keep the index of a channel up to date while new subtitles are downloaded, until interrupted.",
+	details: "A line is printed for each video added, updated or removed from the index, the webhooks are notified of the added ones " +
+		"and the alerts are checked against them.",
+	run: runWatch,
+}
+
+func runWatch(cmd *command, args []string) {
+	flags := cmd.flagSet()
+	interval := flags.Duration("interval", time.Minute, "Time between two checks of the subtitles folder.")
+	langs := addLangFlag(flags)
+	webhookURLs := addWebhookFlag(flags)
+	cmd.parseArgs(flags, args, 1)
+	if *interval <= 0 {
+		perhapsExit(fmt.Errorf("%w: the interval must be positive", sininen.ErrInvalidOption), exitUsage)
+	}
+
+	channelName := flags.Arg(0)
+	subtitlesFolder := channelFolder(channelName)
+	indexes, err := openChannelIndexes(channelName, langs.orDefault())
+	perhapsExit(err, exitIndex)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithCancel(ctx) // Stops watching the other languages when the update of one fails.
+	defer cancel()
+	webhooks := webhookURLs.webhooks()
+	logger := log.New(os.Stdout, "", log.LstdFlags)
+	var wg sync.WaitGroup
+	errs := make(chan error, len(indexes))
+	for i, lang := range langs.orDefault() {
+		wg.Add(1)
+		go func(index bleve.Index, lang string) {
+			defer wg.Done()
+			defer cancel()
+			errs <- sininen.WatchSubtitleIndex(ctx, index, subtitlesFolder, lang, *interval, func(changes sininen.IndexUpdate) {
+				for _, id := range changes.Added {
+					logger.Printf("added %s (%s)", id, lang)
+				}
+				for _, id := range changes.Updated {
+					logger.Printf("updated %s (%s)", id, lang)
+				}
+				for _, id := range changes.Removed {
+					logger.Printf("removed %s (%s)", id, lang)
+				}
+				notifyIndexed(ctx, webhooks, channelName, subtitlesFolder, lang, changes.Added)
+				checkAlerts(sininen.BleveBackend{Index: index}, channelName, lang, changes.Added)
+			})
+		}(indexes[i], lang)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		perhapsExit(err, exitIndex)
+	}
+	for _, index := range indexes {
+		perhapsExit(index.Close(), exitIndex)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"strings"
+
+	"github.com/mooss/sininen"
+)
+
+// webhookURLs is a repeatable flag listing the URLs notified of the newly indexed videos.
+type webhookURLs []string
+
+func (wu *webhookURLs) String() string { return strings.Join(*wu, ",") }
+
+func (wu *webhookURLs) Set(value string) error {
+	*wu = append(*wu, value)
+	return nil
+}
+
+// addWebhookFlag adds the repeatable -webhook flag to a flag set.
+func addWebhookFlag(flags *flag.FlagSet) *webhookURLs {
+	result := &webhookURLs{}
+	flags.Var(result, "webhook", "URL receiving a POST with the ID, the title and the statistics of the transcript of each newly indexed video, "+
+		"can be repeated (the webhooks of the configuration file by default).")
+	return result
+}
+
+// webhooks returns the webhooks of the URLs, or of the configured ones if none was given.
+func (wu webhookURLs) webhooks() sininen.Webhooks {
+	if len(wu) == 0 {
+		return sininen.Webhooks{URLs: defaults.Webhooks}
+	}
+	return sininen.Webhooks{URLs: wu}
+}
+
+// notifyIndexed posts the videos added to the index of a channel to the webhooks, the failures being only reported.
+func notifyIndexed(ctx context.Context, webhooks sininen.Webhooks, channelName, subtitlesFolder, lang string, ids []string) {
+	if len(webhooks.URLs) == 0 || len(ids) == 0 {
+		return
+	}
+	videos, err := sininen.VideoNotifications(channelName, subtitlesFolder, lang, ids)
+	if err != nil {
+		sininen.Log.Warnf("describing the videos indexed in %s: %v", subtitlesFolder, err)
+		return
+	}
+	for _, video := range videos {
+		if err := webhooks.Notify(ctx, video); err != nil {
+			sininen.Log.Warnf("notifying the indexing of %s: %v", video.ID, err)
+		}
+	}
+}
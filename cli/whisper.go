@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"github.com/mooss/sininen"
+)
+
+var whisperCommand = &command{
+	name:        "whisper",
+	arguments:   "channel-name audio-file-or-url...",
+	description: "Transcribe the videos without subtitles with whisper.cpp or an OpenAI-compatible endpoint, and index them.",
+	details: "The audio files are named after the IDs of their videos, e.g. aq4G-7v-_xI.mp3. The audio of the videos of the URLs " +
+		"(videos, playlists or channels) is downloaded with yt-dlp, skipping the videos already having subtitles in the language.",
+	run: runWhisper,
+}
+
+func runWhisper(cmd *command, args []string) {
+	flags := cmd.flagSet()
+	model := flags.String("model", defaults.WhisperModel, "ggml model of whisper.cpp, e.g. models/ggml-base.bin.")
+	program := flags.String("program", "whisper-cli", "whisper.cpp executable.")
+	threads := flags.Int("threads", 0, "Number of threads of whisper.cpp (its default when 0).")
+	useAPI := flags.Bool("api", false, "Transcribe with an OpenAI-compatible endpoint instead of whisper.cpp.")
+	apiURL := flags.String("api-url", defaults.WhisperAPIURL, "Transcriptions endpoint of -api, the one of OpenAI by default.")
+	apiKey := flags.String("api-key", defaults.WhisperAPIKey, "Key of the transcriptions endpoint of -api.")
+	apiModel := flags.String("api-model", "", "Model of the transcriptions endpoint of -api, whisper-1 by default.")
+	lang := flags.String("lang", defaults.Languages[0], "Language spoken in the videos.")
+	keepAudio := flags.Bool("keep-audio", false, "Keep the audio files downloaded from the URLs in the subtitles folder.")
+	cmd.parseMinArgs(flags, args, 2)
+
+	var transcriber sininen.Transcriber = sininen.WhisperCPP{Program: *program, Model: *model, Threads: *threads}
+	if *useAPI {
+		transcriber = sininen.WhisperAPI{URL: *apiURL, Key: *apiKey, Model: *apiModel}
+	} else if *model == "" {
+		perhapsExit(fmt.Errorf("%w: a whisper.cpp model is needed, given with -model or the whisper_model configuration key", sininen.ErrInvalidOption), exitUsage)
+	}
+	channelName := flags.Arg(0)
+	destFolder := filepath.Join(defaults.SubtitlesRoot, channelName)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	audioFiles := map[string]string{} // By video ID.
+	downloaded := map[string]bool{}
+	for _, source := range flags.Args()[1:] {
+		if info, err := os.Stat(source); err == nil && !info.IsDir() {
+			audioFiles[strings.Split(filepath.Base(source), ".")[0]] = source
+			continue
+		}
+		for id, filename := range downloadAudio(ctx, source, destFolder, *lang) {
+			audioFiles[id] = filename
+			downloaded[filename] = true
+		}
+	}
+
+	transcribed, done := 0, 0
+	for id, audioFile := range audioFiles {
+		done++
+		inform("Transcribing %s (%d/%d).\n", audioFile, done, len(audioFiles))
+		filename, err := sininen.TranscribeAudioFile(ctx, transcriber, audioFile, destFolder, id, *lang)
+		if ctx.Err() != nil {
+			perhapsExit(ctx.Err(), exitDownload)
+		}
+		if err != nil {
+			sininen.Log.Warnf("skipping %s: %v", audioFile, err)
+		} else {
+			sininen.Log.Infof("transcribed %s to %s", audioFile, filename)
+			transcribed++
+		}
+		if downloaded[audioFile] && !*keepAudio {
+			if err := os.Remove(audioFile); err != nil {
+				sininen.Log.Warnf("removing %s: %v", audioFile, err)
+			}
+		}
+	}
+	inform("Transcribed %d videos out of %d in %s.\n", transcribed, len(audioFiles), destFolder)
+	if transcribed == 0 {
+		return
+	}
+
+	indexes, err := openChannelIndexes(channelName, []string{*lang})
+	perhapsExit(err, exitIndex)
+	changes, err := sininen.UpdateSubtitleIndex(indexes[0], destFolder, *lang, newProgress("Indexing "+*lang))
+	perhapsExit(err, exitIndex)
+	perhapsExit(indexes[0].Close(), exitIndex)
+	inform("Added %d and updated %d videos in the %s index.\n", len(changes.Added), len(changes.Updated), *lang)
+}
+
+// downloadAudio downloads with yt-dlp the audio of the videos of a URL that have no subtitles in the given language,
+// returning the audio files by video ID.
+func downloadAudio(ctx context.Context, url, destFolder, lang string) map[string]string {
+	var stderr io.Writer = os.Stderr
+	if quiet() {
+		stderr = nil
+	}
+	options := sininen.YTDLPOptions{Stderr: stderr}
+	ids, err := sininen.ListWithYTDLP(ctx, url, options)
+	perhapsExit(err, exitDownload)
+	missing, err := sininen.MissingSubtitles(destFolder, lang, ids)
+	perhapsExit(err, exitDownload)
+	inform("%d videos out of %d have no %s subtitles in %s.\n", len(missing), len(ids), lang, url)
+	if len(missing) == 0 {
+		return nil
+	}
+	perhapsExit(os.MkdirAll(destFolder, 0755), exitDownload)
+	result, err := sininen.DownloadAudioWithYTDLP(ctx, missing, destFolder, options)
+	perhapsExit(err, exitDownload)
+	return result
+}
@@ -0,0 +1,160 @@
+package sininen
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Clipper cuts segments out of their videos with ffmpeg, e.g. to make compilations of the matching moments.
+// The videos are read from local files when available, and streamed from their platform through yt-dlp otherwise.
+// The zero value is a sensible default.
+type Clipper struct {
+	FFmpeg    string        // Path of the ffmpeg executable, found in the PATH by default.
+	YTDLP     string        // Path of the yt-dlp executable, found in the PATH by default.
+	Videos    string        // Folder of the local video files, named after the ID of their video; every video is streamed when empty.
+	URLs      URLBuilder    // Builds the links to the pages of the videos streamed with yt-dlp, YouTube or Twitch by default.
+	Padding   time.Duration // Duration added before and after each segment.
+	Extension string        // Extension of the clips, which decides their container, mp4 by default.
+
+	// Reencode cuts the clips exactly, rather than copying the streams of the videos which is faster but makes the clips
+	// start at the keyframe preceding the segment.
+	Reencode bool
+}
+
+// Clip is a file cut out of a video, holding a segment.
+type Clip struct {
+	Segment ScoredSegment
+	File    string
+	Start   time.Duration // Start of the clip in the video, padding included.
+	End     time.Duration
+}
+
+// videoExtensions are the extensions of the local video files looked up by Clipper.
+var videoExtensions = []string{"mp4", "mkv", "webm", "mov", "m4v", "avi"}
+
+// withDefaults returns the options with their defaults filled in.
+func (c Clipper) withDefaults() Clipper {
+	if c.FFmpeg == "" {
+		c.FFmpeg = "ffmpeg"
+	}
+	if c.YTDLP == "" {
+		c.YTDLP = "yt-dlp"
+	}
+	if c.Extension == "" {
+		c.Extension = "mp4"
+	}
+	return c
+}
+
+// ClipName returns the name of the file of a clip starting at the given time of a video, to the millisecond so that
+// the clips starting within the same second are kept apart, e.g. dQw4w9WgXcQ_00-01-05-250.mp4.
+func ClipName(id string, start time.Duration, extension string) string {
+	timestamp := strings.ReplaceAll(FormatTimestamp(start), ":", "-")
+	return fmt.Sprintf("%s_%s-%03d.%s", id, timestamp, start.Milliseconds()%1000, extension)
+}
+
+// CutClips cuts the segments out of their videos into a folder, the existing files being overwritten.
+// The sources of the videos are resolved once per video, and the segments whose video cannot be read are reported and
+// left out.
+// progress, if not nil, is called after each segment.
+func (c Clipper) CutClips(ctx context.Context, segments []ScoredSegment, folder string, progress Progress) ([]Clip, error) {
+	c = c.withDefaults()
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		return nil, err
+	}
+	sources := map[string]string{}
+	result := make([]Clip, 0, len(segments))
+	for i, segment := range segments {
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		source, resolved := sources[segment.ID]
+		if !resolved {
+			var err error
+			if source, err = c.source(ctx, segment.ID); err != nil {
+				Log.Warnf("skipping the segments of %s: %v", segment.ID, err)
+			}
+			sources[segment.ID] = source
+		}
+		if source == "" {
+			progress.report(i+1, len(segments))
+			continue
+		}
+		clip := c.bounds(segment)
+		clip.File = filepath.Join(folder, ClipName(segment.ID, segment.StartTime, c.Extension))
+		if err := c.cut(ctx, source, clip); err != nil {
+			return result, err
+		}
+		result = append(result, clip)
+		progress.report(i+1, len(segments))
+	}
+	return result, nil
+}
+
+// bounds returns the clip of a segment, padded without starting before the video.
+func (c Clipper) bounds(segment ScoredSegment) Clip {
+	start := segment.StartTime - c.Padding
+	if start < 0 {
+		start = 0
+	}
+	end := segment.EndTime + c.Padding
+	if segment.VideoDuration > 0 && end > segment.VideoDuration {
+		end = segment.VideoDuration
+	}
+	return Clip{Segment: segment, Start: start, End: end}
+}
+
+// source returns what ffmpeg reads a video from, either its local file or the URL of its stream.
+func (c Clipper) source(ctx context.Context, id string) (string, error) {
+	if c.Videos != "" {
+		for _, extension := range videoExtensions {
+			filename := filepath.Join(c.Videos, id+"."+extension)
+			if _, err := os.Stat(filename); err == nil {
+				return filename, nil
+			}
+		}
+	}
+	// The best format holding both the video and the audio, the best ones being usually split in two streams.
+	ytdlp := exec.CommandContext(ctx, c.YTDLP, "--get-url", "--format", "best[acodec!=none][vcodec!=none]/best", c.pageURL(id))
+	var stderr bytes.Buffer
+	ytdlp.Stderr = &stderr
+	output, err := ytdlp.Output()
+	if err != nil {
+		return "", fmt.Errorf("finding the stream of %s: %v: %s", id, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	lines := strings.Fields(string(output))
+	if len(lines) == 0 {
+		return "", fmt.Errorf("no stream found for %s", id)
+	}
+	return lines[0], nil
+}
+
+// pageURL returns the page of a video streamed with yt-dlp, on the platform of the URL builder of the clipper.
+func (c Clipper) pageURL(id string) string {
+	if _, youtube := unwrap(c.URLs).(YouTube); c.URLs == nil || youtube {
+		return videoPageURL(id) // Also knowing the pages of the Twitch VODs downloaded along YouTube videos.
+	}
+	return unwrap(c.URLs).URL(id, 0)
+}
+
+// cut writes a clip with ffmpeg, seeking in the input before reading it so that streams are not read from the start.
+func (c Clipper) cut(ctx context.Context, source string, clip Clip) error {
+	args := []string{"-nostdin", "-loglevel", "error", "-y", "-ss", formatSeconds(clip.Start), "-i", source,
+		"-t", formatSeconds(clip.End - clip.Start)}
+	if c.Reencode {
+		args = append(args, "-c:v", "libx264", "-c:a", "aac")
+	} else {
+		args = append(args, "-c", "copy", "-avoid_negative_ts", "make_zero")
+	}
+	ffmpeg := exec.CommandContext(ctx, c.FFmpeg, append(args, clip.File)...)
+	if output, err := ffmpeg.CombinedOutput(); err != nil {
+		return fmt.Errorf("cutting %s: %v: %s", clip.File, err, bytes.TrimSpace(output))
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package sininen
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Cursor is the position of a segment in search results ranked by RankedSegments, to page through them from the
+// segment following it.
+// Unlike an offset, a cursor keeps its position when segments are added or removed before it between two pages, e.g.
+// because the index was updated, as long as the segments keep their relative order.
+type Cursor struct {
+	Score    float64       `json:"s"`
+	ID       string        `json:"i"`
+	Start    time.Duration `json:"t"`
+	Search   string        `json:"q,omitempty"` // Identifies the search of the cursor, to reject the cursors of other searches.
+	RankedAt time.Time     `json:"n,omitempty"` // Time of the ranking of the first page, to rank the next ones alike, e.g. by RecencyRanking.
+}
+
+// NewCursor returns the cursor right after a segment of the results of a search ranked at a given time.
+func NewCursor(segment ScoredSegment, search string, rankedAt time.Time) Cursor {
+	return Cursor{Score: segment.Score, ID: segment.ID, Start: segment.StartTime, Search: search, RankedAt: rankedAt}
+}
+
+// Encode returns the cursor as an opaque token, safe in URLs.
+func (c Cursor) Encode() string {
+	raw, _ := json.Marshal(c) // Cannot fail, every field being serializable.
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// ParseCursor decodes a token returned by Cursor.Encode.
+func ParseCursor(token string) (Cursor, error) {
+	var result Cursor
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err == nil {
+		err = json.Unmarshal(raw, &result)
+	}
+	if err != nil {
+		return Cursor{}, fmt.Errorf("%w: malformed cursor %q", ErrInvalidOption, token)
+	}
+	return result, nil
+}
+
+// rankedBefore tells whether a segment comes before another one in the order of RankedSegments, i.e. by decreasing
+// score, and then by video ID and start time.
+func rankedBefore(score float64, id string, start time.Duration, other ScoredSegment) bool {
+	if score != other.Score {
+		return score > other.Score
+	}
+	if id != other.ID {
+		return id < other.ID
+	}
+	return start < other.StartTime
+}
+
+// SegmentsAfter returns the segments following a cursor, the segments being sorted like RankedSegments sorts them.
+// The segment of the cursor is looked up by video and start time, since its score may have changed since, e.g. because
+// the index was updated, its score only locating where it would be when it is no longer among the segments.
+func SegmentsAfter(segments []ScoredSegment, cursor Cursor) []ScoredSegment {
+	for i, segment := range segments {
+		if segment.ID == cursor.ID && segment.StartTime == cursor.Start {
+			return segments[i+1:]
+		}
+	}
+	first := sort.Search(len(segments), func(i int) bool {
+		return rankedBefore(cursor.Score, cursor.ID, cursor.Start, segments[i])
+	})
+	return segments[first:]
+}
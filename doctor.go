@@ -0,0 +1,119 @@
+//go:build !js
+// +build !js
+
+package sininen
+
+import (
+	"context"
+	"sort"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/mapping"
+)
+
+// IndexHealth is the diagnosis of a subtitle index made by CheckSubtitleIndex.
+type IndexHealth struct {
+	OpenError     error    // Why the index cannot be opened, nil if it can.
+	MissingFields []string // Fields of transcriptions absent from the mapping of the index, created by an older version.
+	OmitsText     bool     // The index was created with IndexOptions.OmitText.
+
+	// Differences between the index and the subtitles files, Removed being the orphaned documents.
+	IndexUpdate
+}
+
+// NeedsRebuild tells whether the index must be created again from scratch, as UpdateSubtitleIndex cannot fix it.
+func (ih IndexHealth) NeedsRebuild() bool {
+	return ih.OpenError != nil || len(ih.MissingFields) > 0
+}
+
+// Healthy tells whether the index is consistent with the subtitles files.
+func (ih IndexHealth) Healthy() bool {
+	return !ih.NeedsRebuild() && ih.Empty()
+}
+
+// missingFields returns the fields of transcriptions that are not mapped by an index mapping.
+func missingFields(im mapping.IndexMapping) []string {
+	expected := transcriptionMapping().Properties
+	impl, ok := im.(*mapping.IndexMappingImpl)
+	if !ok {
+		return nil // Cannot be checked.
+	}
+	actual, ok := impl.TypeMapping["Transcription"]
+	result := []string{}
+	for field := range expected {
+		if !ok || actual.Properties[field] == nil {
+			result = append(result, field)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// CheckSubtitleIndex diagnoses the index of the given language saved in indexFolder, by comparing it to the subtitles files of folder.
+// The index is opened and closed, but not modified.
+func CheckSubtitleIndex(folder, indexFolder, lang string) (IndexHealth, error) {
+	var result IndexHealth
+	files, err := subtitleFiles(folder, lang)
+	if err != nil {
+		return result, err
+	}
+	index, err := OpenTranscriptionIndex(indexFolder, lang)
+	if err != nil {
+		result.OpenError = err
+		return result, nil
+	}
+	defer index.Close()
+
+	result.MissingFields = missingFields(index.Mapping())
+	result.OmitsText = !storesText(index)
+	result.IndexUpdate, err = diffSubtitleIndex(index, files)
+	return result, err
+}
+
+// ProbeIndex checks that an open index answers a trivial query, e.g. for a health check, returning its number of
+// documents.
+func ProbeIndex(ctx context.Context, index bleve.Index) (uint64, error) {
+	count, err := index.DocCount()
+	if err != nil {
+		return 0, err
+	}
+	_, err = index.SearchInContext(ctx, bleve.NewSearchRequestOptions(bleve.NewMatchAllQuery(), 1, 0, false))
+	return count, err
+}
+
+// RepairSubtitleIndex diagnoses the index of the given language with CheckSubtitleIndex, and repairs it if needed.
+// The index is rebuilt with the given options when it cannot be opened or lacks fields, still omitting the text when it
+// did, and updated otherwise.
+// The diagnosis made before the repair is returned.
+func RepairSubtitleIndex(folder, lang string, options IndexOptions) (IndexHealth, error) {
+	indexFolder := folder
+	if options.Folder != "" {
+		indexFolder = options.Folder
+	}
+	health, err := CheckSubtitleIndex(folder, indexFolder, lang)
+	if err != nil || health.Healthy() {
+		return health, err
+	}
+
+	if health.NeedsRebuild() {
+		if err := DeleteTranscriptionIndex(indexFolder, lang); err != nil {
+			return health, err
+		}
+		options.OmitText = options.OmitText || health.OmitsText
+		index, err := CreateSubtitleIndex(folder, lang, options)
+		if err != nil {
+			return health, err
+		}
+		return health, index.Close()
+	}
+
+	index, err := OpenTranscriptionIndex(indexFolder, lang)
+	if err != nil {
+		return health, err
+	}
+	if _, err := UpdateSubtitleIndex(index, folder, lang, options.Progress); err != nil {
+		index.Close()
+		return health, err
+	}
+	return health, index.Close()
+}
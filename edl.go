@@ -0,0 +1,43 @@
+package sininen
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// timecode formats a duration as a non-drop frame SMPTE timecode (hh:mm:ss:ff).
+func timecode(d time.Duration, fps int) string {
+	frames := int(math.Round(d.Seconds() * float64(fps)))
+	seconds := frames / fps
+	return fmt.Sprintf("%02d:%02d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60, frames%fps)
+}
+
+// WriteEDL writes scored segments as a CMX 3600 edit decision list, assembling the segments back to back on a timeline.
+// Each event references its video by clip name and carries the matched terms and the text as comments, so that
+// editors can locate the matching moments in the source footage.
+func WriteEDL(w io.Writer, title string, segments []ScoredSegment, fps int) error {
+	if fps <= 0 {
+		return fmt.Errorf("%w: frame rate must be positive, got %d", ErrInvalidOption, fps)
+	}
+	if _, err := fmt.Fprintf(w, "TITLE: %s\nFCM: NON-DROP FRAME\n", title); err != nil {
+		return err
+	}
+
+	var recordIn time.Duration
+	for i, segment := range segments {
+		recordOut := recordIn + segment.EndTime - segment.StartTime
+		_, err := fmt.Fprintf(w, "\n%03d  AX       V     C        %s %s %s %s\n* FROM CLIP NAME: %s\n* COMMENT: %s | %s | %s\n",
+			i+1,
+			timecode(segment.StartTime, fps), timecode(segment.EndTime, fps),
+			timecode(recordIn, fps), timecode(recordOut, fps),
+			segment.ID, segment.DisplayName(), strings.Join(segment.SortedTerms, " "), strings.ReplaceAll(segment.Text, "\n", " "))
+		if err != nil {
+			return err
+		}
+		recordIn = recordOut
+	}
+	return nil
+}
@@ -0,0 +1,260 @@
+//go:build !js
+// +build !js
+
+package sininen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+)
+
+// ElasticExporter pushes transcriptions into an Elasticsearch or OpenSearch index, through the REST API they share.
+// The documents have the same shape as in the bleve indexes, i.e. the fields of Transcription, the segments being
+// stored as a flat array of start time, end time and end position triples.
+type ElasticExporter struct {
+	URL       string       // Base URL of the cluster, e.g. http://localhost:9200, mandatory.
+	Index     string       // Name of the index receiving the documents, mandatory.
+	Username  string       // User of the basic authentication, none when empty.
+	Password  string       // Password of the basic authentication.
+	HTTP      *http.Client // http.DefaultClient when nil.
+	BatchSize int          // Number of documents sent per bulk request, 500 by default.
+}
+
+// elasticAnalyzers associates language codes to the built-in analyzers of Elasticsearch and OpenSearch.
+var elasticAnalyzers = map[string]string{
+	"ar": "arabic",
+	"da": "danish",
+	"de": "german",
+	"en": "english",
+	"es": "spanish",
+	"fa": "persian",
+	"fi": "finnish",
+	"fr": "french",
+	"hi": "hindi",
+	"hu": "hungarian",
+	"it": "italian",
+	"ja": "cjk",
+	"ko": "cjk",
+	"nl": "dutch",
+	"no": "norwegian",
+	"pt": "portuguese",
+	"ro": "romanian",
+	"ru": "russian",
+	"sv": "swedish",
+	"tr": "turkish",
+	"zh": "cjk",
+}
+
+func (ee ElasticExporter) withDefaults() ElasticExporter {
+	if ee.HTTP == nil {
+		ee.HTTP = http.DefaultClient
+	}
+	if ee.BatchSize <= 0 {
+		ee.BatchSize = 500
+	}
+	ee.URL = strings.TrimSuffix(ee.URL, "/")
+	return ee
+}
+
+// do sends a request to the cluster and returns the status of the response along with its body.
+func (ee ElasticExporter) do(ctx context.Context, method, path, contentType string, body io.Reader) (int, []byte, error) {
+	if ee.URL == "" || ee.Index == "" {
+		return 0, nil, fmt.Errorf("%w: the URL of the cluster and the name of the index are needed", ErrInvalidOption)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, ee.URL+path, body)
+	if err != nil {
+		return 0, nil, err
+	}
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+	if ee.Username != "" {
+		request.SetBasicAuth(ee.Username, ee.Password)
+	}
+	response, err := ee.HTTP.Do(request)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer response.Body.Close()
+	raw, err := ioutil.ReadAll(response.Body)
+	return response.StatusCode, raw, err
+}
+
+// CreateIndex creates the index with a mapping equivalent to the one of the bleve indexes of the given language, unless
+// it already exists.
+func (ee ElasticExporter) CreateIndex(ctx context.Context, lang string) error {
+	ee = ee.withDefaults()
+	indexPath := "/" + url.PathEscape(ee.Index)
+	status, _, err := ee.do(ctx, http.MethodHead, indexPath, "", nil)
+	if err != nil || status == http.StatusOK {
+		return err
+	}
+
+	analyzer, exists := elasticAnalyzers[strings.ToLower(strings.SplitN(lang, "-", 2)[0])]
+	if !exists {
+		analyzer = "standard"
+	}
+	notIndexed := func(kind string) map[string]interface{} { return map[string]interface{}{"type": kind, "index": false} }
+	mapping, err := json.Marshal(map[string]interface{}{"mappings": map[string]interface{}{
+		"properties": map[string]interface{}{
+			"Words":      map[string]interface{}{"type": "text", "analyzer": analyzer},
+			"Segments":   notIndexed("double"),
+			"Title":      map[string]interface{}{"type": "text", "analyzer": analyzer},
+			"UploadDate": map[string]interface{}{"type": "date"},
+			"Duration":   notIndexed("double"),
+			"ViewCount":  map[string]interface{}{"type": "long"},
+			"Playlists":  map[string]interface{}{"type": "keyword"},
+
+			"TranslatedFrom": map[string]interface{}{"type": "keyword"},
+		},
+	}})
+	if err != nil {
+		return err
+	}
+	status, raw, err := ee.do(ctx, http.MethodPut, indexPath, "application/json", bytes.NewReader(mapping))
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("creating the index %s: %s", ee.Index, elasticError(status, raw))
+	}
+	return nil
+}
+
+// elasticDocument is a transcription as sent to the cluster, leaving out the unknown upload dates instead of sending
+// the zero time.
+type elasticDocument struct {
+	*Transcription
+	UploadDate *string `json:"UploadDate,omitempty"`
+}
+
+// bulkResponse is the subset of a response of the bulk API that is relevant to sininen.
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		ID    string          `json:"_id"`
+		Error json.RawMessage `json:"error"`
+	} `json:"items"`
+}
+
+// elasticError describes a failed response of the cluster.
+func elasticError(status int, body []byte) string {
+	return fmt.Sprintf("status %d: %s", status, bytes.TrimSpace(body))
+}
+
+// Export sends transcriptions to the index, by video ID, replacing the documents with the same IDs, and returns the
+// number of documents accepted by the cluster.
+// The index is created with the dynamic mapping of the cluster if it does not exist, see CreateIndex to avoid it.
+// Documents rejected by the cluster are reported without stopping the export of the others.
+// progress, if not nil, is called after each bulk request.
+func (ee ElasticExporter) Export(ctx context.Context, transcriptions map[string]*Transcription, progress Progress) (int, error) {
+	ids := make([]string, 0, len(transcriptions))
+	for id := range transcriptions {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ee.exportBatches(ctx, ids, func([]string) (map[string]*Transcription, error) { return transcriptions, nil }, progress)
+}
+
+// ExportIndex sends all the transcriptions of a bleve index to the cluster, like Export.
+// The transcriptions are read from the fields stored in the index, which include the metadata and the playlists, one
+// batch at a time.
+func (ee ElasticExporter) ExportIndex(ctx context.Context, index bleve.Index, progress Progress) (int, error) {
+	videos, err := ListVideos(index)
+	if err != nil {
+		return 0, err
+	}
+	ids := make([]string, 0, len(videos))
+	for _, video := range videos {
+		ids = append(ids, video.ID)
+	}
+	return ee.exportBatches(ctx, ids, func(batch []string) (map[string]*Transcription, error) {
+		return storedTranscriptions(index, batch)
+	}, progress)
+}
+
+// exportBatches sends the transcriptions of the given videos with a bulk request per batch, fetching each batch first.
+func (ee ElasticExporter) exportBatches(ctx context.Context, ids []string, fetch func(batch []string) (map[string]*Transcription, error), progress Progress) (int, error) {
+	ee = ee.withDefaults()
+	accepted := 0
+	progress.report(0, len(ids))
+	for start := 0; start < len(ids); start += ee.BatchSize {
+		end := start + ee.BatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		transcriptions, err := fetch(ids[start:end])
+		if err != nil {
+			return accepted, err
+		}
+		batchAccepted, err := ee.bulk(ctx, ids[start:end], transcriptions)
+		accepted += batchAccepted
+		if err != nil {
+			return accepted, err
+		}
+		progress.report(end, len(ids))
+	}
+	return accepted, nil
+}
+
+// bulk indexes some transcriptions with a single request to the bulk API and returns the number of accepted documents.
+func (ee ElasticExporter) bulk(ctx context.Context, ids []string, transcriptions map[string]*Transcription) (int, error) {
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body) // Writes each value on its own line, as expected by the bulk API.
+	for _, id := range ids {
+		transcription, exists := transcriptions[id]
+		if !exists {
+			continue // Removed from the index since it was listed.
+		}
+		document := elasticDocument{Transcription: transcription}
+		if !document.Transcription.UploadDate.IsZero() {
+			date := document.Transcription.UploadDate.Format(time.RFC3339)
+			document.UploadDate = &date
+		}
+		action := map[string]map[string]string{"index": {"_index": ee.Index, "_id": id}}
+		if err := encoder.Encode(action); err != nil {
+			return 0, err
+		}
+		if err := encoder.Encode(document); err != nil {
+			return 0, err
+		}
+	}
+	if body.Len() == 0 {
+		return 0, nil
+	}
+
+	status, raw, err := ee.do(ctx, http.MethodPost, "/_bulk", "application/x-ndjson", &body)
+	if err != nil {
+		return 0, err
+	}
+	if status != http.StatusOK {
+		return 0, fmt.Errorf("bulk request: %s", elasticError(status, raw))
+	}
+	var response bulkResponse
+	if err := json.Unmarshal(raw, &response); err != nil {
+		return 0, fmt.Errorf("%w: bulk response: %v", ErrBadFormat, err)
+	}
+	accepted := len(response.Items)
+	if response.Errors {
+		for _, item := range response.Items {
+			for _, result := range item {
+				if len(result.Error) > 0 && string(result.Error) != "null" {
+					Log.Warnf("%s was rejected: %s", result.ID, result.Error)
+					accepted--
+				}
+			}
+		}
+	}
+	return accepted, nil
+}
@@ -0,0 +1,418 @@
+//go:build !js
+// +build !js
+
+package sininen
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Embedder computes the vector representations of texts, so that texts with related meanings have close vectors.
+type Embedder interface {
+	// Name identifies the model computing the vectors, which cannot be compared with the vectors of other models.
+	Name() string
+	// Embed returns the vectors of the given texts, in the same order.
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// EmbeddingsAPI computes embeddings with an OpenAI-compatible embeddings endpoint.
+// The zero value is a sensible default, except for the key needed by the OpenAI API.
+type EmbeddingsAPI struct {
+	URL   string       // URL of the embeddings endpoint, https://api.openai.com/v1/embeddings by default.
+	Key   string       // API key, sent as a bearer token when not empty.
+	Model string       // Name of the model, text-embedding-3-small by default.
+	HTTP  *http.Client // http.DefaultClient when nil.
+}
+
+func (ea EmbeddingsAPI) withDefaults() EmbeddingsAPI {
+	if ea.URL == "" {
+		ea.URL = "https://api.openai.com/v1/embeddings"
+	}
+	if ea.Model == "" {
+		ea.Model = "text-embedding-3-small"
+	}
+	if ea.HTTP == nil {
+		ea.HTTP = http.DefaultClient
+	}
+	return ea
+}
+
+func (ea EmbeddingsAPI) Name() string {
+	return "openai:" + ea.withDefaults().Model
+}
+
+func (ea EmbeddingsAPI) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	ea = ea.withDefaults()
+	var response struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	err := postJSON(ctx, ea.HTTP, ea.URL, ea.Key, map[string]interface{}{"model": ea.Model, "input": texts}, &response)
+	if err != nil {
+		return nil, err
+	}
+	result := make([][]float32, len(texts))
+	for _, embedding := range response.Data {
+		if embedding.Index < 0 || embedding.Index >= len(texts) {
+			return nil, fmt.Errorf("%w: embedding of unknown input %d", ErrBadFormat, embedding.Index)
+		}
+		result[embedding.Index] = embedding.Embedding
+	}
+	return result, checkEmbeddings(result)
+}
+
+// Ollama computes embeddings with a local model served by Ollama, see https://ollama.com.
+// The zero value is a sensible default.
+type Ollama struct {
+	URL   string       // Base URL of the Ollama server, http://localhost:11434 by default.
+	Model string       // Name of the model, nomic-embed-text by default.
+	HTTP  *http.Client // http.DefaultClient when nil.
+}
+
+func (o Ollama) withDefaults() Ollama {
+	if o.URL == "" {
+		o.URL = "http://localhost:11434"
+	}
+	if o.Model == "" {
+		o.Model = "nomic-embed-text"
+	}
+	if o.HTTP == nil {
+		o.HTTP = http.DefaultClient
+	}
+	return o
+}
+
+func (o Ollama) Name() string {
+	return "ollama:" + o.withDefaults().Model
+}
+
+func (o Ollama) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	o = o.withDefaults()
+	var response struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	err := postJSON(ctx, o.HTTP, strings.TrimSuffix(o.URL, "/")+"/api/embed", "", map[string]interface{}{"model": o.Model, "input": texts}, &response)
+	if err != nil {
+		return nil, err
+	}
+	if len(response.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("%w: got %d embeddings for %d texts", ErrBadFormat, len(response.Embeddings), len(texts))
+	}
+	return response.Embeddings, checkEmbeddings(response.Embeddings)
+}
+
+// postJSON sends a JSON request and decodes the JSON response, failing on any status other than 200.
+func postJSON(ctx context.Context, client *http.Client, location, key string, body, result interface{}) error {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, location, bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		request.Header.Set("Authorization", "Bearer "+key)
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	raw, err = ioutil.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("POST %s: %s: %s", location, response.Status, bytes.TrimSpace(raw))
+	}
+	if err := json.Unmarshal(raw, result); err != nil {
+		return fmt.Errorf("%w: POST %s: %v", ErrBadFormat, location, err)
+	}
+	return nil
+}
+
+// checkEmbeddings fails when some embeddings are missing.
+func checkEmbeddings(embeddings [][]float32) error {
+	for i, embedding := range embeddings {
+		if len(embedding) == 0 {
+			return fmt.Errorf("%w: missing embedding %d", ErrBadFormat, i)
+		}
+	}
+	return nil
+}
+
+// EmbeddingIndex holds the embeddings of the segments of the transcriptions of a language, so that the segments
+// closest in meaning to a query are found even when they share no word with it.
+// It is kept in memory and searched exhaustively, which is fast enough for the transcriptions of a few channels, and
+// saved as a gob file next to the bleve index.
+type EmbeddingIndex struct {
+	Model  string                    // Name of the embedder, see Embedder.Name.
+	Videos map[string]*EmbeddedVideo // By video ID.
+}
+
+// EmbeddedVideo is a transcription whose segments are embedded.
+type EmbeddedVideo struct {
+	ModTime    time.Time // Modification time of the subtitles file when it was embedded.
+	Title      string
+	UploadDate time.Time
+	Duration   time.Duration
+	ViewCount  int64
+	Segments   []EmbeddedSegment
+}
+
+// EmbeddedSegment is a segment along with its embedding, normalized to unit length.
+type EmbeddedSegment struct {
+	StartTime time.Duration
+	EndTime   time.Duration
+	Text      string
+	Vector    []float32
+}
+
+// embeddingBatchSize is the number of segments embedded per request.
+const embeddingBatchSize = 100
+
+// NewEmbeddingIndex returns an empty index for the embeddings of the given embedder.
+func NewEmbeddingIndex(embedder Embedder) *EmbeddingIndex {
+	return &EmbeddingIndex{Model: embedder.Name(), Videos: map[string]*EmbeddedVideo{}}
+}
+
+// embeddingsPath returns the location of the embeddings of the given language in a folder, made absolute like
+// indexPath.
+func embeddingsPath(folder, lang string) string {
+	result := filepath.Join(folder, lang+".embeddings.gob")
+	if absolute, err := filepath.Abs(result); err == nil {
+		return absolute
+	}
+	return result
+}
+
+// LoadEmbeddingIndex reads the embeddings of a language saved in a folder, failing with ErrIndexNotFound when there
+// are none.
+func LoadEmbeddingIndex(folder, lang string) (*EmbeddingIndex, error) {
+	filename := embeddingsPath(folder, lang)
+	raw, err := ioutil.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrIndexNotFound, filename)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var result EmbeddingIndex
+	if err := gob.NewDecoder(bytes.NewReader(raw)).Decode(&result); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrBadFormat, filename, err)
+	}
+	if result.Videos == nil {
+		result.Videos = map[string]*EmbeddedVideo{}
+	}
+	return &result, nil
+}
+
+// Save writes the embeddings of a language in a folder, replacing the previous ones once they are written.
+func (ei *EmbeddingIndex) Save(folder, lang string) error {
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		return err
+	}
+	var raw bytes.Buffer
+	if err := gob.NewEncoder(&raw).Encode(ei); err != nil {
+		return err
+	}
+	filename := embeddingsPath(folder, lang)
+	if err := ioutil.WriteFile(filename+".part", raw.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(filename+".part", filename)
+}
+
+// Update embeds the segments of the new and modified subtitles files of the given folder, and forgets the videos whose
+// file was deleted, like UpdateSubtitleIndex.
+// The videos embedded before a failure are kept, so that saving the index does not lose them. Parsing errors are
+// reported but do not stop the embedding of the other files.
+// progress, if not nil, is called after each embedded file.
+func (ei *EmbeddingIndex) Update(ctx context.Context, embedder Embedder, folder, lang string, progress Progress) (IndexUpdate, error) {
+	var result IndexUpdate
+	if embedder.Name() != ei.Model {
+		return result, fmt.Errorf("%w: the embeddings were computed by %s, not %s, they must be computed again", ErrInvalidOption, ei.Model, embedder.Name())
+	}
+	files, err := subtitleFiles(folder, lang)
+	if err != nil {
+		return result, err
+	}
+	for id := range ei.Videos {
+		if _, exists := files[id]; !exists {
+			result.Removed = append(result.Removed, id)
+			delete(ei.Videos, id)
+		}
+	}
+	for id, file := range files {
+		video, exists := ei.Videos[id]
+		switch {
+		case !exists:
+			result.Added = append(result.Added, id)
+		case !video.ModTime.Equal(file.ModTime()):
+			result.Updated = append(result.Updated, id)
+		}
+	}
+	sort.Strings(result.Added)
+	sort.Strings(result.Updated)
+	sort.Strings(result.Removed)
+
+	changed := append(append([]string{}, result.Added...), result.Updated...)
+	progress.report(0, len(changed))
+	for i, id := range changed {
+		if err := ei.embedFile(ctx, embedder, folder, id, files[id]); err != nil {
+			return result, err
+		}
+		progress.report(i+1, len(changed))
+	}
+	return result, nil
+}
+
+// embedFile parses a subtitles file and embeds its segments, the segments without text being left out.
+func (ei *EmbeddingIndex) embedFile(ctx context.Context, embedder Embedder, folder, id string, file os.FileInfo) error {
+	filename := filepath.Join(folder, file.Name())
+	document, err := ParseSubtitleFile(filename)
+	if err != nil {
+		Log.Warnf("skipping %s: %v", filename, err)
+		return nil
+	}
+	addMetadata(document, folder, id)
+	video := &EmbeddedVideo{
+		ModTime:    file.ModTime(),
+		Title:      document.Title,
+		UploadDate: document.UploadDate,
+		Duration:   fromSeconds(document.Duration),
+		ViewCount:  document.ViewCount,
+	}
+	start := 0
+	for i := 0; i+2 < len(document.Segments); i += 3 {
+		end := int(document.Segments[i+2])
+		if end < start || end > len(document.Words) {
+			return fmt.Errorf("%w: %s: inconsistent segment %d", ErrBadFormat, filename, i/3)
+		}
+		if text := strings.TrimSpace(document.Words[start:end]); text != "" {
+			video.Segments = append(video.Segments, EmbeddedSegment{
+				StartTime: fromSeconds(document.Segments[i]),
+				EndTime:   fromSeconds(document.Segments[i+1]),
+				Text:      text,
+			})
+		}
+		start = end + 1 // Skip the newline separating the segments.
+	}
+
+	for from := 0; from < len(video.Segments); from += embeddingBatchSize {
+		to := from + embeddingBatchSize
+		if to > len(video.Segments) {
+			to = len(video.Segments)
+		}
+		texts := make([]string, 0, to-from)
+		for _, segment := range video.Segments[from:to] {
+			texts = append(texts, segment.Text)
+		}
+		vectors, err := embedder.Embed(ctx, texts)
+		if err != nil {
+			return fmt.Errorf("embedding %s: %w", filename, err)
+		}
+		for i, vector := range vectors {
+			video.Segments[from+i].Vector = normalize(vector)
+		}
+	}
+	ei.Videos[id] = video
+	Log.Debugf("embedded the %d segments of %s", len(video.Segments), filename)
+	return nil
+}
+
+// normalize scales a vector to unit length, so that the cosine similarity of two vectors is their dot product.
+func normalize(vector []float32) []float32 {
+	var norm float64
+	for _, x := range vector {
+		norm += float64(x) * float64(x)
+	}
+	norm = math.Sqrt(norm)
+	if norm == 0 {
+		return vector
+	}
+	result := make([]float32, len(vector))
+	for i, x := range vector {
+		result[i] = float32(float64(x) / norm)
+	}
+	return result
+}
+
+// Nearest returns the k segments closest to a vector, scored by their cosine similarity, from the closest.
+func (ei *EmbeddingIndex) Nearest(vector []float32, k int) []ScoredSegment {
+	vector = normalize(vector)
+	result := []ScoredSegment{}
+	for id, video := range ei.Videos {
+		for _, segment := range video.Segments {
+			if len(segment.Vector) != len(vector) {
+				continue
+			}
+			var similarity float64
+			for i, x := range segment.Vector {
+				similarity += float64(x) * float64(vector[i])
+			}
+			result = append(result, ScoredSegment{
+				SegmentHit:    SegmentHit{StartTime: segment.StartTime, EndTime: segment.EndTime, Text: segment.Text},
+				Score:         similarity,
+				ID:            id,
+				Title:         video.Title,
+				UploadDate:    video.UploadDate,
+				VideoDuration: video.Duration,
+				ViewCount:     video.ViewCount,
+			})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Score != result[j].Score {
+			return result[i].Score > result[j].Score
+		}
+		if result[i].ID != result[j].ID {
+			return result[i].ID < result[j].ID
+		}
+		return result[i].StartTime < result[j].StartTime
+	})
+	if k > 0 && len(result) > k {
+		result = result[:k]
+	}
+	return result
+}
+
+// SemanticSearch returns the k segments of the given indexes closest in meaning to a query, which is embedded once by
+// the embedder of the indexes.
+func SemanticSearch(ctx context.Context, embedder Embedder, query string, k int, indexes ...*EmbeddingIndex) ([]ScoredSegment, error) {
+	for _, index := range indexes {
+		if index.Model != embedder.Name() {
+			return nil, fmt.Errorf("%w: the embeddings were computed by %s, not %s", ErrInvalidOption, index.Model, embedder.Name())
+		}
+	}
+	vectors, err := embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+	result := []ScoredSegment{}
+	for _, index := range indexes {
+		result = append(result, index.Nearest(vectors[0], k)...)
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Score > result[j].Score })
+	if k > 0 && len(result) > k {
+		result = result[:k]
+	}
+	return result, nil
+}
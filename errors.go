@@ -0,0 +1,16 @@
+package sininen
+
+import "errors"
+
+// Errors wrapped by the functions of the library, so that their causes can be told apart with errors.Is.
+var (
+	ErrIndexNotFound   = errors.New("index not found")      // No index was saved at the given location.
+	ErrNoSubtitles     = errors.New("no subtitles")         // A folder contains no subtitles file of the given language.
+	ErrBadFormat       = errors.New("malformed file")       // A subtitles or metadata file cannot be parsed.
+	ErrInvalidOption   = errors.New("invalid option")       // An option has an unknown or out of range value.
+	ErrBadName         = errors.New("unexpected file name") // A file is not named like <id>.<lang>.<ext> or <id>.info.json.
+	ErrEmptyTranscript = errors.New("empty transcript")     // A subtitles file contains no text.
+	ErrVideoNotFound   = errors.New("video not found")      // An index contains no transcription with the given video ID.
+	ErrTextNotStored   = errors.New("text not stored")      // An index was created with IndexOptions.OmitText.
+	ErrAssembly        = errors.New("assembly failed")      // The hits of a search cannot be built into search results.
+)
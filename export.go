@@ -0,0 +1,179 @@
+package sininen
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// formatSeconds formats a duration as a number of seconds, without needless decimals.
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+}
+
+// groupByVideo groups scored segments by video ID.
+// The videos are ordered by their first appearance and the order of the segments is preserved within videos.
+func groupByVideo(segments []ScoredSegment) [][]ScoredSegment {
+	result := [][]ScoredSegment{}
+	positions := map[string]int{}
+	for _, segment := range segments {
+		pos, exists := positions[segment.ID]
+		if !exists {
+			pos = len(result)
+			positions[segment.ID] = pos
+			result = append(result, nil)
+		}
+		result[pos] = append(result[pos], segment)
+	}
+	return result
+}
+
+// VideoSegments is a video along with its scored segments.
+type VideoSegments struct {
+	ID       string          `json:"id"`
+	Title    string          `json:"title,omitempty"`
+	Score    float64         `json:"score"` // Best score of the segments.
+	Segments []ScoredSegment `json:"segments"`
+}
+
+// GroupByVideo nests scored segments under their video.
+// The videos are ordered by their first appearance and the order of the segments is preserved within videos.
+func GroupByVideo(segments []ScoredSegment) []VideoSegments {
+	groups := groupByVideo(segments)
+	result := make([]VideoSegments, 0, len(groups))
+	for _, group := range groups {
+		video := VideoSegments{ID: group[0].ID, Title: group[0].Title, Score: group[0].Score, Segments: group}
+		for _, segment := range group {
+			if segment.Score > video.Score {
+				video.Score = segment.Score
+			}
+		}
+		result = append(result, video)
+	}
+	return result
+}
+
+// WriteCSV writes scored segments as CSV, preceded by a header line.
+// Times are expressed in seconds and matched terms are separated by spaces.
+func WriteCSV(w io.Writer, segments []ScoredSegment, urls URLBuilder) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"id", "title", "url", "start", "end", "terms", "score", "text"}); err != nil {
+		return err
+	}
+	for _, segment := range segments {
+		err := writer.Write([]string{
+			segment.ID,
+			segment.Title,
+			SegmentURL(urls, segment),
+			formatSeconds(segment.StartTime),
+			formatSeconds(segment.EndTime),
+			strings.Join(segment.SortedTerms, " "),
+			strconv.FormatFloat(segment.Score, 'f', -1, 64),
+			segment.Text,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// markdownEscaper escapes the characters that could be interpreted as Markdown formatting in transcription text.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`, "<", `\<`, ">", `\>`, "#", `\#`,
+)
+
+// WriteMarkdown writes scored segments as a Markdown document, with a section per video.
+// Each segment is a list item starting with a link to its timestamp, followed by its text.
+func WriteMarkdown(w io.Writer, segments []ScoredSegment, urls URLBuilder) error {
+	for i, video := range groupByVideo(segments) {
+		if i > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "## %s\n\n", markdownEscaper.Replace(video[0].DisplayName())); err != nil {
+			return err
+		}
+		for _, segment := range video {
+			_, err := fmt.Fprintf(w, "- [%s](%s) %s\n",
+				FormatTimestamp(segment.StartTime), SegmentURL(urls, segment), markdownEscaper.Replace(segment.Text))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// WriteURLs writes scored segments as plain text, one per line, starting with a link to the segment.
+// The link is followed by the name of the video, the matched terms and the score.
+func WriteURLs(w io.Writer, segments []ScoredSegment, urls URLBuilder) error {
+	for _, segment := range segments {
+		_, err := fmt.Fprintf(w, "%s %s (%v, score=%.3f)\n",
+			SegmentURL(urls, segment), segment.DisplayName(), segment.SortedTerms, segment.Score)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteText writes scored segments as plain text, each one being a line like those of WriteURLs followed by its indented text.
+// The line also gives the view count of the video, when known, the original language of the machine-translated
+// subtitles, the chapter of the segment and the category of the sponsor segment it falls inside.
+// The text of the segment is marked by > and surrounded by its context, and its matched terms are transformed by highlight.
+func WriteText(w io.Writer, segments []ScoredSegment, urls URLBuilder, highlight func(string) string) error {
+	for _, segment := range segments {
+		var details string
+		if segment.ViewCount > 0 {
+			details = fmt.Sprintf(", %d views", segment.ViewCount)
+		}
+		if segment.TranslatedFrom != "" {
+			details += ", translated from " + segment.TranslatedFrom
+		}
+		if segment.Chapter != "" {
+			details += fmt.Sprintf(", chapter %q", segment.Chapter)
+		}
+		if segment.Sponsor != "" {
+			details += ", inside a " + segment.Sponsor + " segment"
+		}
+		_, err := fmt.Fprintf(w, "%s %s (%v, score=%.3f%s)\n",
+			SegmentURL(urls, segment), segment.DisplayName(), segment.SortedTerms, segment.Score, details)
+		if err != nil {
+			return err
+		}
+		for _, excerpt := range segment.Before {
+			if _, err := fmt.Fprintf(w, "    %s %s\n", FormatTimestamp(excerpt.StartTime), excerpt.Text); err != nil {
+				return err
+			}
+		}
+		_, err = fmt.Fprintf(w, "  > %s %s\n", FormatTimestamp(segment.StartTime), segment.HighlightedText(highlight))
+		if err != nil {
+			return err
+		}
+		for _, excerpt := range segment.After {
+			if _, err := fmt.Fprintf(w, "    %s %s\n", FormatTimestamp(excerpt.StartTime), excerpt.Text); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// WriteTable writes scored segments as a plain text table with aligned columns, preceded by a header line.
+// The matched terms of the text, which is the last column, are transformed by highlight.
+func WriteTable(w io.Writer, segments []ScoredSegment, highlight func(string) string) error {
+	writer := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "SCORE\tVIDEO\tSTART\tTERMS\tTEXT")
+	for _, segment := range segments {
+		fmt.Fprintf(writer, "%.3f\t%s\t%s\t%s\t%s\n", segment.Score, segment.DisplayName(),
+			FormatTimestamp(segment.StartTime), strings.Join(segment.SortedTerms, " "), segment.HighlightedText(highlight))
+	}
+	return writer.Flush()
+}
@@ -0,0 +1,89 @@
+//go:build !js
+// +build !js
+
+package sininen
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search"
+)
+
+// FacetCount is a value of a facet along with the number of matching videos having it.
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// Facets counts the videos matching a query by category, e.g. to let users narrow down a search.
+type Facets struct {
+	Total          uint64       // Number of matching videos.
+	Years          []FacetCount // By upload year, most recent first, the videos of unknown upload date being left out.
+	Playlists      []FacetCount // By playlist ID, see TagPlaylist, most frequent first.
+	TranslatedFrom []FacetCount // By original language of the machine-translated videos, see TranslateSubtitleFile.
+}
+
+// facetsFirstYear is the first upload year counted by SearchFacets, older videos being left out of the years.
+const facetsFirstYear = 1990
+
+// SearchFacets counts the videos of an index matching a plain text query, like TextQuery, or like PlaylistTextQuery
+// when playlistID is not empty.
+// size is the maximum number of playlists and languages counted, 10 being used when it is not positive.
+func SearchFacets(index bleve.Index, query, playlistID string, size int) (Facets, error) {
+	if size <= 0 {
+		size = 10
+	}
+	var request *bleve.SearchRequest
+	if playlistID == "" {
+		request = bleve.NewSearchRequestOptions(bleve.NewMatchQuery(query), 0, 0, false)
+	} else {
+		inPlaylist := bleve.NewTermQuery(playlistID)
+		inPlaylist.SetField("Playlists")
+		request = bleve.NewSearchRequestOptions(bleve.NewConjunctionQuery(bleve.NewMatchQuery(query), inPlaylist), 0, 0, false)
+	}
+	lastYear := time.Now().Year()
+	years := bleve.NewFacetRequest("UploadDate", lastYear-facetsFirstYear+1) // The size limits the ranges counted.
+	for year := lastYear; year >= facetsFirstYear; year-- {
+		start := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+		years.AddDateTimeRange(strconv.Itoa(year), start, start.AddDate(1, 0, 0))
+	}
+	request.AddFacet("years", years)
+	request.AddFacet("playlists", bleve.NewFacetRequest("Playlists", size))
+	request.AddFacet("translated", bleve.NewFacetRequest("TranslatedFrom", size))
+	result, err := index.Search(request)
+	if err != nil {
+		return Facets{}, err
+	}
+
+	facets := Facets{
+		Total:          result.Total,
+		Years:          []FacetCount{},
+		Playlists:      termCounts(result.Facets["playlists"]),
+		TranslatedFrom: termCounts(result.Facets["translated"]),
+	}
+	if byYear := result.Facets["years"]; byYear != nil {
+		for _, dateRange := range byYear.DateRanges {
+			facets.Years = append(facets.Years, FacetCount{dateRange.Name, dateRange.Count})
+		}
+	}
+	sort.Slice(facets.Years, func(i, j int) bool { return facets.Years[i].Value > facets.Years[j].Value })
+	return facets, nil
+}
+
+// termCounts returns the counts of a terms facet, most frequent first, the empty terms being left out.
+func termCounts(facet *search.FacetResult) []FacetCount {
+	result := []FacetCount{}
+	if facet == nil || facet.Terms == nil {
+		return result
+	}
+	for _, term := range facet.Terms.Terms() {
+		if term.Term != "" {
+			result = append(result, FacetCount{term.Term, term.Count})
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Count > result[j].Count })
+	return result
+}
@@ -0,0 +1,160 @@
+package sininen
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Feed is a syndication feed of segments, e.g. the matches of a standing query, written by WriteAtom and WriteRSS.
+type Feed struct {
+	Title   string
+	Link    string // URL of the feed itself, empty when unknown.
+	Entries []FeedEntry
+}
+
+// FeedEntry is a segment of a feed.
+type FeedEntry struct {
+	Segment   ScoredSegment
+	Published time.Time // When the segment was added to the feed.
+}
+
+// NewSegmentsFeed returns the feed of scored segments, each of them being published at the upload date of its video,
+// or now when unknown.
+func NewSegmentsFeed(title string, segments []ScoredSegment) Feed {
+	now := time.Now()
+	result := Feed{Title: title, Entries: make([]FeedEntry, 0, len(segments))}
+	for _, segment := range segments {
+		published := segment.UploadDate
+		if published.IsZero() {
+			published = now
+		}
+		result.Entries = append(result.Entries, FeedEntry{segment, published})
+	}
+	return result
+}
+
+// entryTitle is the title of the entry of a segment.
+func entryTitle(segment ScoredSegment) string {
+	return fmt.Sprintf("%s at %s", segment.DisplayName(), FormatTimestamp(segment.StartTime))
+}
+
+// entryID is the unique identifier of the entry of a segment, independent of the platform of its link.
+func entryID(segment ScoredSegment) string {
+	return fmt.Sprintf("urn:sininen:%s:%d", url.PathEscape(segment.ID), segment.StartTime.Milliseconds())
+}
+
+// feedID is the unique identifier of a feed, its URL when known.
+func feedID(feed Feed) string {
+	if feed.Link != "" {
+		return feed.Link
+	}
+	return "urn:sininen:feed:" + url.PathEscape(feed.Title)
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID        string   `xml:"id"`
+	Title     string   `xml:"title"`
+	Link      atomLink `xml:"link"`
+	Published string   `xml:"published"`
+	Updated   string   `xml:"updated"`
+	Summary   string   `xml:"summary"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	XMLNS   string      `xml:"xmlns,attr"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  string      `xml:"author>name"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+// WriteAtom writes a feed in the Atom format, the entries linking to the segments and summarizing them by their text.
+func WriteAtom(w io.Writer, feed Feed, urls URLBuilder) error {
+	result := atomFeed{XMLNS: "http://www.w3.org/2005/Atom", ID: feedID(feed), Title: feed.Title, Author: "sininen"}
+	if feed.Link != "" {
+		result.Links = []atomLink{{Href: feed.Link, Rel: "self"}}
+	}
+	var updated time.Time
+	for _, entry := range feed.Entries {
+		if entry.Published.After(updated) {
+			updated = entry.Published
+		}
+		published := entry.Published.UTC().Format(time.RFC3339)
+		result.Entries = append(result.Entries, atomEntry{
+			ID:        entryID(entry.Segment),
+			Title:     entryTitle(entry.Segment),
+			Link:      atomLink{Href: SegmentURL(urls, entry.Segment)},
+			Published: published,
+			Updated:   published,
+			Summary:   strings.ReplaceAll(entry.Segment.Text, "\n", " "),
+		})
+	}
+	if updated.IsZero() {
+		updated = time.Now()
+	}
+	result.Updated = updated.UTC().Format(time.RFC3339)
+	return writeXML(w, result)
+}
+
+type rssGUID struct {
+	Value       string `xml:",chardata"`
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+	Description string  `xml:"description"`
+}
+
+type rssDocument struct {
+	XMLName     xml.Name  `xml:"rss"`
+	Version     string    `xml:"version,attr"`
+	Title       string    `xml:"channel>title"`
+	Link        string    `xml:"channel>link"`
+	Description string    `xml:"channel>description"`
+	Items       []rssItem `xml:"channel>item"`
+}
+
+// WriteRSS writes a feed in the RSS 2.0 format, like WriteAtom.
+func WriteRSS(w io.Writer, feed Feed, urls URLBuilder) error {
+	result := rssDocument{Version: "2.0", Title: feed.Title, Link: feed.Link, Description: "Segments matching " + feed.Title}
+	for _, entry := range feed.Entries {
+		result.Items = append(result.Items, rssItem{
+			Title:       entryTitle(entry.Segment),
+			Link:        SegmentURL(urls, entry.Segment),
+			GUID:        rssGUID{entryID(entry.Segment), false},
+			PubDate:     entry.Published.UTC().Format(time.RFC1123Z),
+			Description: strings.ReplaceAll(entry.Segment.Text, "\n", " "),
+		})
+	}
+	return writeXML(w, result)
+}
+
+// writeXML writes an indented XML document, preceded by the XML header.
+func writeXML(w io.Writer, document interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(document); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
@@ -0,0 +1,91 @@
+package sininen
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// FormatOptions holds what output formats may need besides the segments.
+// The zero value is a sensible default.
+type FormatOptions struct {
+	Title string     // Title of the document, usually the search query.
+	URLs  URLBuilder // Builder of the links to the segments, YouTube by default.
+	FPS   int        // Frame rate of the timecodes of the edl format, defaults to 25.
+
+	// Highlight transforms the matched terms in the text and table formats, e.g. to colorize them.
+	// They are left untouched by default.
+	Highlight func(string) string
+}
+
+// withDefaults returns the options with their defaults filled in.
+func (fo FormatOptions) withDefaults() FormatOptions {
+	if fo.URLs == nil {
+		fo.URLs = YouTube{}
+	}
+	if fo.FPS <= 0 {
+		fo.FPS = 25
+	}
+	if fo.Highlight == nil {
+		fo.Highlight = func(text string) string { return text }
+	}
+	return fo
+}
+
+// Formatter writes scored segments in an output format.
+type Formatter func(w io.Writer, segments []ScoredSegment, options FormatOptions) error
+
+// formatters are the output formats by name.
+var formatters = map[string]Formatter{
+	"urls": func(w io.Writer, s []ScoredSegment, o FormatOptions) error { return WriteURLs(w, s, o.URLs) },
+	"text": func(w io.Writer, s []ScoredSegment, o FormatOptions) error {
+		return WriteText(w, s, o.URLs, o.Highlight)
+	},
+	"table": func(w io.Writer, s []ScoredSegment, o FormatOptions) error { return WriteTable(w, s, o.Highlight) },
+	"json":  func(w io.Writer, s []ScoredSegment, o FormatOptions) error { return WriteJSON(w, s) },
+	"jsonl": func(w io.Writer, s []ScoredSegment, o FormatOptions) error { return WriteJSONLines(w, s) },
+	"grouped-json": func(w io.Writer, s []ScoredSegment, o FormatOptions) error {
+		return WriteJSON(w, GroupByVideo(s))
+	},
+	"csv":      func(w io.Writer, s []ScoredSegment, o FormatOptions) error { return WriteCSV(w, s, o.URLs) },
+	"markdown": func(w io.Writer, s []ScoredSegment, o FormatOptions) error { return WriteMarkdown(w, s, o.URLs) },
+	"html":     func(w io.Writer, s []ScoredSegment, o FormatOptions) error { return WriteHTML(w, o.Title, s, o.URLs) },
+	"vtt":      func(w io.Writer, s []ScoredSegment, o FormatOptions) error { return WriteWebVTT(w, s) },
+	"srt":      func(w io.Writer, s []ScoredSegment, o FormatOptions) error { return WriteSRT(w, s) },
+	"edl":      func(w io.Writer, s []ScoredSegment, o FormatOptions) error { return WriteEDL(w, o.Title, s, o.FPS) },
+	"m3u":      func(w io.Writer, s []ScoredSegment, o FormatOptions) error { return WriteM3U(w, s, o.URLs) },
+	"xspf":     func(w io.Writer, s []ScoredSegment, o FormatOptions) error { return WriteXSPF(w, o.Title, s, o.URLs) },
+	"anki":     func(w io.Writer, s []ScoredSegment, o FormatOptions) error { return WriteAnki(w, s, o.URLs) },
+	"gob":      func(w io.Writer, s []ScoredSegment, o FormatOptions) error { return EncodeSegments(w, s) },
+	"atom": func(w io.Writer, s []ScoredSegment, o FormatOptions) error {
+		return WriteAtom(w, NewSegmentsFeed(o.Title, s), o.URLs)
+	},
+	"rss": func(w io.Writer, s []ScoredSegment, o FormatOptions) error {
+		return WriteRSS(w, NewSegmentsFeed(o.Title, s), o.URLs)
+	},
+}
+
+// RegisterFormatter makes an output format available under the given name, replacing any format of the same name.
+func RegisterFormatter(name string, formatter Formatter) {
+	formatters[name] = formatter
+}
+
+// FormatterNames returns the names of the available output formats, sorted alphabetically.
+func FormatterNames() []string {
+	result := make([]string, 0, len(formatters))
+	for name := range formatters {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// WriteFormat writes scored segments in the output format of the given name.
+func WriteFormat(w io.Writer, format string, segments []ScoredSegment, options FormatOptions) error {
+	formatter, ok := formatters[format]
+	if !ok {
+		return fmt.Errorf("%w: unknown output format %q, expected one of %s", ErrInvalidOption, format, strings.Join(FormatterNames(), ", "))
+	}
+	return formatter(w, segments, options.withDefaults())
+}
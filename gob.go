@@ -0,0 +1,31 @@
+package sininen
+
+import (
+	"encoding/gob"
+	"io"
+)
+
+// EncodeGob writes the search result sequence to w in the gob binary format, e.g. to cache it on disk.
+// Unlike JSON, gob preserves durations and times exactly and unlike protobuf, it needs no schema compilation step.
+func (srs SearchResultSequence) EncodeGob(w io.Writer) error {
+	return gob.NewEncoder(w).Encode(srs)
+}
+
+// DecodeSearchResults reads a search result sequence written by SearchResultSequence.EncodeGob.
+func DecodeSearchResults(r io.Reader) (SearchResultSequence, error) {
+	var result SearchResultSequence
+	err := gob.NewDecoder(r).Decode(&result)
+	return result, err
+}
+
+// EncodeSegments writes scored segments to w in the gob binary format.
+func EncodeSegments(w io.Writer, segments []ScoredSegment) error {
+	return gob.NewEncoder(w).Encode(segments)
+}
+
+// DecodeSegments reads scored segments written by EncodeSegments.
+func DecodeSegments(r io.Reader) ([]ScoredSegment, error) {
+	var result []ScoredSegment
+	err := gob.NewDecoder(r).Decode(&result)
+	return result, err
+}
@@ -0,0 +1,59 @@
+package sininen
+
+import (
+	_ "embed"
+	"html/template"
+	"io"
+	"net/url"
+	"time"
+)
+
+//go:embed templates/report.html
+var reportTemplateText string
+
+// reportTemplate is the template of the HTML report generated by WriteHTML.
+// The links functions are placeholders, they are replaced when the URL builder is known.
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"timestamp": FormatTimestamp,
+	"url":       func(ScoredSegment) string { return "" },
+	"link":      linkURL,
+	"embedURL":  YouTubeEmbedURL,
+}).Parse(reportTemplateText))
+
+// linkFuncs returns the template functions creating links with the given URL builder.
+func linkFuncs(urls URLBuilder) template.FuncMap {
+	return template.FuncMap{
+		"url":      func(segment ScoredSegment) string { return SegmentURL(urls, segment) },
+		"embedURL": func(id string, start time.Duration) string { return embedURL(urls, id, start) },
+	}
+}
+
+// linkURL returns a location built for a segment as a URL that html/template keeps in the links, which are limited to the
+// http, https and file schemes, the other locations such as the command lines of MPV being rendered as text.
+// The file URLs, which html/template would otherwise replace, are safe since they are built by LocalFile.
+func linkURL(location string) template.URL {
+	parsed, err := url.Parse(location)
+	if err != nil {
+		return ""
+	}
+	switch parsed.Scheme {
+	case "http", "https", "file":
+		return template.URL(location)
+	}
+	return ""
+}
+
+// WriteHTML writes scored segments as a self-contained HTML page, with a section per video.
+// The matched terms are highlighted and each segment links to the video and, when the platform has one, to the embedded
+// player at its timestamp.
+// The segments whose location is not a link, e.g. with MPV, show it as text after their timestamp.
+func WriteHTML(w io.Writer, title string, segments []ScoredSegment, urls URLBuilder) error {
+	tmpl, err := reportTemplate.Clone()
+	if err != nil {
+		return err
+	}
+	return tmpl.Funcs(linkFuncs(urls)).Execute(w, struct {
+		Title  string
+		Videos [][]ScoredSegment
+	}{title, groupByVideo(segments)})
+}
@@ -1,55 +1,514 @@
+//go:build !js
+// +build !js
+
 package sininen
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"os"
-	"path"
+	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/custom"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/keyword"
+	"github.com/blevesearch/bleve/v2/analysis/token/edgengram"
+	"github.com/blevesearch/bleve/v2/analysis/token/lowercase"
+	"github.com/blevesearch/bleve/v2/analysis/token/ngram"
+	"github.com/blevesearch/bleve/v2/analysis/tokenizer/unicode"
+	"github.com/blevesearch/bleve/v2/mapping"
 )
 
+// NGramMode selects how words are split into character n-grams when they are indexed.
+type NGramMode string
+
+const (
+	NoNGram   NGramMode = ""     // Words are indexed with the analyzer of the language.
+	EdgeNGram NGramMode = "edge" // The prefixes of the words are indexed, allowing to match the beginning of words.
+	FullNGram NGramMode = "full" // All the n-grams of the words are indexed, allowing to match any part of words.
+)
+
+// IndexOptions tunes how a subtitle index is created.
+// The zero value is a sensible default.
+type IndexOptions struct {
+	Folder   string    // Folder where the index is saved, the subtitles folder by default.
+	NGram    NGramMode // Splitting of the words into n-grams, useful for partial words and compound words.
+	MinNGram int       // Minimum n-gram length, defaults to 3.
+	MaxNGram int       // Maximum n-gram length, defaults to 10.
+	Progress Progress  // Called after each indexed file, if not nil.
+	// OmitText indexes the words without storing the text of the transcriptions, a large part of the index.
+	// The segments found then have no text nor highlights, and the transcriptions cannot be rebuilt from the index,
+	// e.g. by StoreMetadata, TagPlaylist, NewSnapshot or ElasticExporter.ExportIndex, which fail with ErrTextNotStored.
+	OmitText bool
+}
+
+// ngramAnalyzer is the name of the custom analyzer splitting words into n-grams.
+const ngramAnalyzer = "sininen_ngram"
+
+// addNGramAnalyzer registers the n-gram analyzer described by the options in the given index mapping.
+func addNGramAnalyzer(im *mapping.IndexMappingImpl, options IndexOptions) error {
+	minGram, maxGram := options.MinNGram, options.MaxNGram
+	if minGram <= 0 {
+		minGram = 3
+	}
+	if maxGram <= 0 {
+		maxGram = 10
+	}
+
+	filter := map[string]interface{}{"min": float64(minGram), "max": float64(maxGram)}
+	switch options.NGram {
+	case EdgeNGram:
+		filter["type"] = edgengram.Name
+		filter["back"] = false
+	case FullNGram:
+		filter["type"] = ngram.Name
+	default:
+		return fmt.Errorf("%w: unknown n-gram mode %q", ErrInvalidOption, options.NGram)
+	}
+	if err := im.AddCustomTokenFilter(ngramAnalyzer, filter); err != nil {
+		return err
+	}
+	return im.AddCustomAnalyzer(ngramAnalyzer, map[string]interface{}{
+		"type":          custom.Name,
+		"tokenizer":     unicode.Name,
+		"token_filters": []string{lowercase.Name, ngramAnalyzer},
+	})
+}
+
 // CreateSubtitleIndex opens, parses and indexes the subtitles file in the given folder and the given language.
-// The created index is saved inside the folder.
-func CreateSubtitleIndex(folder, lang string) (bleve.Index, error) {
-	files, err := ioutil.ReadDir(folder)
+// The created index is saved inside the folder, unless another one is given in the options.
+func CreateSubtitleIndex(folder, lang string, options IndexOptions) (bleve.Index, error) {
+	files, err := subtitleFiles(folder, lang)
 	if err != nil {
 		return nil, err
 	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%w in %s for language %s", ErrNoSubtitles, folder, lang)
+	}
 
 	// Define how to index and store data.
-	segmentsMap := bleve.NewNumericFieldMapping()
+	imap := bleve.NewIndexMapping()
+	imap.DefaultAnalyzer = LanguageAnalyzer(lang)
+	if options.NGram != NoNGram {
+		if err := addNGramAnalyzer(imap, options); err != nil {
+			return nil, err
+		}
+		imap.DefaultAnalyzer = ngramAnalyzer
+	}
+	transcriptions := transcriptionMapping()
+	if options.OmitText {
+		wordsMap := bleve.NewTextFieldMapping() // Like the default mapping of Words, except for the storage.
+		wordsMap.Store = false
+		transcriptions.AddFieldMappingsAt("Words", wordsMap)
+	}
+	imap.AddDocumentMapping("Transcription", transcriptions) // This is where Transcription.BleveType is pertinent.
+	indexFolder := folder
+	if options.Folder != "" {
+		indexFolder = options.Folder
+	}
+	index, err := bleve.New(indexPath(indexFolder, lang), imap)
+	if err != nil {
+		return nil, err
+	}
+
+	// Index and store data.
+	done := 0
+	options.Progress.report(done, len(files))
+	for id, file := range files {
+		indexSubtitleFile(index, folder, id, file)
+		done++
+		options.Progress.report(done, len(files))
+	}
+	return index, nil
+}
+
+// transcriptionMapping defines how the fields of transcriptions are indexed and stored.
+func transcriptionMapping() *mapping.DocumentMapping {
+	// The segments are a stored field rather than doc values, which are the sorted and deduplicated terms of a field,
+	// only read by the collectors, whereas the segments are an ordered array read along with each hit.
+	segmentsMap := bleve.NewTextFieldMapping() // Packed by packSegments.
+	segmentsMap.Analyzer = keyword.Name        // Not indexed, but analyzed anyway.
 	segmentsMap.Store = true
 	segmentsMap.Index = false
+	segmentsMap.IncludeInAll = false
+	segmentsMap.DocValues = false
+	uploadDateMap := bleve.NewDateTimeFieldMapping()
+	uploadDateMap.Store = true
 	vtmap := bleve.NewDocumentMapping()
 	vtmap.AddFieldMappingsAt("Segments", segmentsMap) // Default mapping is good enough for Words.
-	mapping := bleve.NewIndexMapping()
-	mapping.DefaultAnalyzer = lang
-	mapping.AddDocumentMapping("Transcription", vtmap) // This is where Transcription.BleveType is pertinent.
-	index, err := bleve.New(path.Join(folder, lang+".bleve"), mapping)
+	vtmap.AddFieldMappingsAt("UploadDate", uploadDateMap)
+	titleMap := bleve.NewTextFieldMapping()
+	titleMap.IncludeInAll = false // Matches in the title cannot be located in the segments.
+	vtmap.AddFieldMappingsAt("Title", titleMap)
+	durationMap := bleve.NewNumericFieldMapping()
+	durationMap.Store = true
+	durationMap.Index = false
+	vtmap.AddFieldMappingsAt("Duration", durationMap)
+	playlistsMap := bleve.NewTextFieldMapping()
+	playlistsMap.Analyzer = keyword.Name // Playlist IDs are matched exactly.
+	playlistsMap.IncludeInAll = false    // Playlist IDs must not match text queries.
+	vtmap.AddFieldMappingsAt("Playlists", playlistsMap)
+	translatedFromMap := bleve.NewTextFieldMapping()
+	translatedFromMap.Analyzer = keyword.Name
+	translatedFromMap.IncludeInAll = false
+	vtmap.AddFieldMappingsAt("TranslatedFrom", translatedFromMap)
+	return vtmap
+}
+
+// packedTranscription is a Transcription as indexed by bleve, with its segments packed by packSegments.
+type packedTranscription struct {
+	Words          string
+	Segments       string
+	Title          string
+	UploadDate     time.Time
+	Duration       float64
+	ViewCount      int64
+	Playlists      []string
+	TranslatedFrom string
+}
+
+func (packedTranscription) BleveType() string {
+	return Transcription{}.BleveType()
+}
+
+// packsSegments tells whether an index stores packed segments, the older indexes storing them as arrays of numbers.
+func packsSegments(index bleve.Index) bool {
+	impl, ok := index.Mapping().(*mapping.IndexMappingImpl)
+	if !ok {
+		return false
+	}
+	transcriptions, exists := impl.TypeMapping[Transcription{}.BleveType()]
+	if !exists || transcriptions.Properties["Segments"] == nil {
+		return false
+	}
+	fields := transcriptions.Properties["Segments"].Fields
+	return len(fields) > 0 && fields[0].Type == "text"
+}
+
+// storesText tells whether an index stores the text of its transcriptions, i.e. was not created with
+// IndexOptions.OmitText.
+func storesText(index bleve.Index) bool {
+	impl, ok := index.Mapping().(*mapping.IndexMappingImpl)
+	if !ok {
+		return true
+	}
+	transcriptions, exists := impl.TypeMapping[Transcription{}.BleveType()]
+	if !exists || transcriptions.Properties["Words"] == nil { // Mapped dynamically, hence stored.
+		return true
+	}
+	fields := transcriptions.Properties["Words"].Fields
+	return len(fields) == 0 || fields[0].Store
+}
+
+// bleveDocument returns a transcription as indexed by bleve, in the format of the segments of the index.
+func bleveDocument(document *Transcription, packed bool) interface{} {
+	if !packed {
+		return document
+	}
+	return packedTranscription{
+		Words:          document.Words,
+		Segments:       packSegments(document.Segments),
+		Title:          document.Title,
+		UploadDate:     document.UploadDate,
+		Duration:       document.Duration,
+		ViewCount:      document.ViewCount,
+		Playlists:      document.Playlists,
+		TranslatedFrom: document.TranslatedFrom,
+	}
+}
+
+// indexPath returns the location of the index of the given language in a folder.
+// The location is made absolute when possible, because the os package only handles Windows paths longer than 260
+// characters when they are absolute.
+func indexPath(folder, lang string) string {
+	result := filepath.Join(folder, lang+".bleve")
+	if absolute, err := filepath.Abs(result); err == nil {
+		return absolute
+	}
+	return result
+}
+
+// modTimeKey is the internal key where the modification time of the indexed subtitles file of a video is stored.
+func modTimeKey(id string) []byte {
+	return []byte("modtime:" + id)
+}
+
+// indexedAtKey is the internal key where the time at which a video was indexed is stored.
+func indexedAtKey(id string) []byte {
+	return []byte("indexed:" + id)
+}
+
+// playlistsKey is the internal key where the playlists of a video are stored, separated by commas.
+// Unlike the other fields of the transcriptions, they cannot be found again in the subtitles folder.
+func playlistsKey(id string) []byte {
+	return []byte("playlists:" + id)
+}
+
+// indexSubtitleFile parses and indexes a subtitles file, remembering its modification time.
+// Parsing errors are reported but do not stop the indexing of the other files.
+func indexSubtitleFile(index bleve.Index, folder, id string, file os.FileInfo) {
+	filename := filepath.Join(folder, file.Name())
+	document, err := ParseSubtitleFile(filename)
 	if err != nil {
-		return nil, err
+		Log.Warnf("skipping %s: %v", filename, err)
+		parseFailures.WithLabelValues("bleve").Inc()
+		return
+	}
+	addMetadata(document, folder, id)
+	if playlists, err := index.GetInternal(playlistsKey(id)); err == nil && len(playlists) > 0 {
+		document.Playlists = strings.Split(string(playlists), ",")
 	}
+	if err := index.Index(id, bleveDocument(document, packsSegments(index))); err != nil {
+		Log.Errorf("indexing %s: %v", filename, err)
+		return
+	}
+	Log.Debugf("indexed %s as %s (%d segments, title %q)", filename, id, len(document.Segments)/3, document.Title)
+	indexedDocuments.WithLabelValues("bleve").Inc()
+	index.SetInternal(modTimeKey(id), []byte(file.ModTime().UTC().Format(time.RFC3339Nano)))
+	index.SetInternal(indexedAtKey(id), []byte(time.Now().UTC().Format(time.RFC3339)))
+}
 
-	// Index and store data.
-	for _, file := range files {
-		splitted := strings.Split(file.Name(), ".")
-		if len(splitted) <= 2 || splitted[len(splitted)-2] != lang {
+// IndexUpdate summarizes the changes made to an index by UpdateSubtitleIndex.
+// Each field lists video IDs.
+type IndexUpdate struct {
+	Added   []string // Videos whose subtitles were not indexed yet.
+	Updated []string // Videos whose subtitles changed since they were indexed.
+	Removed []string // Videos whose subtitles no longer exist.
+}
+
+// Empty tells whether the index was left unchanged.
+func (iu IndexUpdate) Empty() bool {
+	return len(iu.Added) == 0 && len(iu.Updated) == 0 && len(iu.Removed) == 0
+}
+
+// diffSubtitleIndex compares an index with the subtitles files it was created from, without modifying it.
+func diffSubtitleIndex(index bleve.Index, files map[string]os.FileInfo) (IndexUpdate, error) {
+	var result IndexUpdate
+	count, err := index.DocCount()
+	if err != nil {
+		return result, err
+	}
+	request := bleve.NewSearchRequestOptions(bleve.NewMatchAllQuery(), int(count), 0, false)
+	indexed, err := index.Search(request)
+	if err != nil {
+		return result, err
+	}
+	for _, hit := range indexed.Hits {
+		if _, ok := files[hit.ID]; !ok {
+			result.Removed = append(result.Removed, hit.ID)
+		}
+	}
+
+	for id, file := range files {
+		modTime, err := index.GetInternal(modTimeKey(id))
+		if err != nil {
+			return result, err
+		}
+		if string(modTime) == file.ModTime().UTC().Format(time.RFC3339Nano) {
 			continue
 		}
-		filepath := path.Join(folder, file.Name())
-		document, err := ParseSubtitleFile(filepath)
-		if err == nil {
-			index.Index(splitted[0], document)
+		document, err := index.Document(id)
+		if err != nil {
+			return result, err
+		}
+		if document == nil {
+			result.Added = append(result.Added, id)
 		} else {
-			fmt.Fprintln(os.Stderr, err)
+			result.Updated = append(result.Updated, id)
 		}
 	}
-	return index, nil
+	sort.Strings(result.Added)
+	sort.Strings(result.Updated)
+	sort.Strings(result.Removed)
+	return result, nil
+}
+
+// UpdateSubtitleIndex incrementally updates an index created by CreateSubtitleIndex with the subtitles files of the given folder.
+// New and modified files are indexed, and the videos whose file was deleted are removed from the index.
+// progress, if not nil, is called after each indexed file.
+func UpdateSubtitleIndex(index bleve.Index, folder, lang string, progress Progress) (IndexUpdate, error) {
+	files, err := subtitleFiles(folder, lang)
+	if err != nil {
+		return IndexUpdate{}, err
+	}
+	result, err := diffSubtitleIndex(index, files)
+	if err != nil {
+		return result, err
+	}
+
+	for _, id := range result.Removed {
+		if err := index.Delete(id); err != nil {
+			return result, err
+		}
+		index.DeleteInternal(modTimeKey(id))
+		index.DeleteInternal(indexedAtKey(id))
+		index.DeleteInternal(playlistsKey(id))
+		Log.Debugf("removed %s, its subtitles no longer exist", id)
+	}
+	changed := append(append([]string{}, result.Added...), result.Updated...)
+	progress.report(0, len(changed))
+	for i, id := range changed {
+		indexSubtitleFile(index, folder, id, files[id])
+		progress.report(i+1, len(changed))
+	}
+	return result, nil
+}
+
+// storedTranscriptions rebuilds the transcriptions of the given videos from the fields stored in an index, by ID.
+// The videos missing from the index are missing from the result.
+func storedTranscriptions(index bleve.Index, ids []string) (map[string]*Transcription, error) {
+	if !storesText(index) {
+		return nil, fmt.Errorf("%w: the transcriptions cannot be rebuilt from the index", ErrTextNotStored)
+	}
+	result := make(map[string]*Transcription, len(ids))
+	if len(ids) == 0 {
+		return result, nil
+	}
+	request := bleve.NewSearchRequestOptions(bleve.NewDocIDQuery(ids), len(ids), 0, false)
+	request.Fields = []string{"Words", "Segments", "Title", "UploadDate", "Duration", "ViewCount", "Playlists", "TranslatedFrom"}
+	stored, err := index.Search(request)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, hit := range stored.Hits {
+		segments, words, err := storedSegments(hit)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", hit.ID, err)
+		}
+		document := &Transcription{Words: words, Segments: segments}
+		document.Title, _ = hit.Fields["Title"].(string)
+		if raw, exists := hit.Fields["UploadDate"].(string); exists {
+			document.UploadDate, _ = time.Parse(time.RFC3339, raw)
+		}
+		document.Duration, _ = hit.Fields["Duration"].(float64)
+		views, _ := hit.Fields["ViewCount"].(float64)
+		document.ViewCount = int64(views)
+		document.TranslatedFrom, _ = hit.Fields["TranslatedFrom"].(string)
+		switch playlists := hit.Fields["Playlists"].(type) { // A single value is not stored as an array.
+		case string:
+			document.Playlists = []string{playlists}
+		case []interface{}:
+			for _, playlist := range playlists {
+				if id, ok := playlist.(string); ok {
+					document.Playlists = append(document.Playlists, id)
+				}
+			}
+		}
+		result[hit.ID] = document
+	}
+	return result, nil
+}
+
+// StoreMetadata replaces the metadata of the transcriptions of an index, e.g. with the metadata fetched by
+// YouTubeDataAPI, and returns the number of updated transcriptions.
+// Only the known values are replaced and the videos missing from the index are ignored.
+// The metadata is lost when the subtitles file is indexed again, unless the .info.json file contains it too.
+func StoreMetadata(index bleve.Index, metadata map[string]*VideoMetadata) (int, error) {
+	ids := make([]string, 0, len(metadata))
+	for id := range metadata {
+		ids = append(ids, id)
+	}
+	documents, err := storedTranscriptions(index, ids)
+	if err != nil {
+		return 0, err
+	}
+
+	batch := index.NewBatch()
+	packed := packsSegments(index)
+	for id, document := range documents {
+		video := metadata[id]
+		if video.Title != "" {
+			document.Title = video.Title
+		}
+		if !video.UploadDate.IsZero() {
+			document.UploadDate = video.UploadDate
+		}
+		if video.Duration > 0 {
+			document.Duration = video.Duration.Seconds()
+		}
+		if video.ViewCount > 0 {
+			document.ViewCount = video.ViewCount
+		}
+		if err := batch.Index(id, bleveDocument(document, packed)); err != nil {
+			return 0, err
+		}
+	}
+	return len(documents), index.Batch(batch)
+}
+
+// TagPlaylist records that the given videos belong to a playlist, so that PlaylistTextQuery can search through them,
+// and returns the number of tagged transcriptions.
+// The videos missing from the index are ignored, and the tags are kept when the subtitles files are indexed again.
+func TagPlaylist(index bleve.Index, playlistID string, ids []string) (int, error) {
+	documents, err := storedTranscriptions(index, ids)
+	if err != nil {
+		return 0, err
+	}
+
+	batch := index.NewBatch()
+	packed := packsSegments(index)
+	for id, document := range documents {
+		tagged := false
+		for _, playlist := range document.Playlists {
+			tagged = tagged || playlist == playlistID
+		}
+		if tagged {
+			continue
+		}
+		document.Playlists = append(document.Playlists, playlistID)
+		if err := batch.Index(id, bleveDocument(document, packed)); err != nil {
+			return 0, err
+		}
+		batch.SetInternal(playlistsKey(id), []byte(strings.Join(document.Playlists, ",")))
+	}
+	return len(documents), index.Batch(batch)
 }
 
 // OpenTranscriptionIndex opens a stored subtitle index, such as the one created by CreateSubtitleIndex.
+// folder is the folder where the index was saved.
 func OpenTranscriptionIndex(folder, lang string) (bleve.Index, error) {
-	return bleve.Open(path.Join(folder, lang+".bleve"))
+	filename := indexPath(folder, lang)
+	index, err := bleve.Open(filename)
+	if errors.Is(err, bleve.ErrorIndexPathDoesNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrIndexNotFound, filename)
+	}
+	return index, err
+}
+
+// TranscriptionIndexExists tells whether an index of the given language was saved in a folder, without opening it.
+func TranscriptionIndexExists(folder, lang string) bool {
+	_, err := os.Stat(indexPath(folder, lang))
+	return err == nil
+}
+
+// DeleteTranscriptionIndex deletes a stored subtitle index, so that it can be created again from scratch.
+// folder is the folder where the index was saved.
+func DeleteTranscriptionIndex(folder, lang string) error {
+	return os.RemoveAll(indexPath(folder, lang))
+}
+
+// WatchSubtitleIndex keeps an index up to date with the subtitles files of the given folder until the context is done.
+// The folder is checked every interval with UpdateSubtitleIndex, and updated is called whenever the index changed.
+func WatchSubtitleIndex(ctx context.Context, index bleve.Index, folder, lang string, interval time.Duration, updated func(IndexUpdate)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		changes, err := UpdateSubtitleIndex(index, folder, lang, nil)
+		if err != nil {
+			return err
+		}
+		if !changes.Empty() {
+			updated(changes)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
 }
@@ -0,0 +1,202 @@
+package sininen
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dateLayout is the layout of the dates meant to be read by humans or other programs.
+const dateLayout = "2006-01-02"
+
+// FormatTimestamp formats a duration as hh:mm:ss, truncating the fractions of seconds.
+func FormatTimestamp(d time.Duration) string {
+	seconds := int(d.Seconds())
+	return fmt.Sprintf("%02d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+}
+
+// ParseTimestamp parses a timestamp formatted as [[hh:]mm:]ss, the fractions of seconds being allowed, or as a Go
+// duration like 12m34s.
+func ParseTimestamp(timestamp string) (time.Duration, error) {
+	if duration, err := time.ParseDuration(timestamp); err == nil && duration >= 0 {
+		return duration, nil
+	}
+	parts := strings.Split(timestamp, ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("%w: malformed timestamp %q", ErrInvalidOption, timestamp)
+	}
+	seconds := 0.
+	for _, part := range parts {
+		value, err := strconv.ParseFloat(part, 64)
+		if err != nil || value < 0 {
+			return 0, fmt.Errorf("%w: malformed timestamp %q", ErrInvalidOption, timestamp)
+		}
+		seconds = seconds*60 + value
+	}
+	return fromSeconds(seconds), nil
+}
+
+// fromSeconds converts a number of seconds to a duration, rounded to the millisecond.
+func fromSeconds(seconds float64) time.Duration {
+	return time.Duration(math.Round(seconds*1000)) * time.Millisecond
+}
+
+// jsonTiming is the JSON representation of the timing of a segment.
+// Times are expressed in seconds, along with a hh:mm:ss timestamp meant for humans.
+type jsonTiming struct {
+	StartTime      float64 `json:"start_time"`
+	StartTimestamp string  `json:"start_timestamp"`
+	EndTime        float64 `json:"end_time"`
+	EndTimestamp   string  `json:"end_timestamp"`
+}
+
+func newJSONTiming(start, end time.Duration) jsonTiming {
+	return jsonTiming{start.Seconds(), FormatTimestamp(start), end.Seconds(), FormatTimestamp(end)}
+}
+
+// durations returns the start and end times, the timestamps being ignored because they are less precise.
+func (jt jsonTiming) durations() (time.Duration, time.Duration) {
+	return fromSeconds(jt.StartTime), fromSeconds(jt.EndTime)
+}
+
+type jsonSegmentExcerpt struct {
+	jsonTiming
+	Text string `json:"text"`
+}
+
+// MarshalJSON expresses the times in seconds instead of nanoseconds.
+func (se SegmentExcerpt) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonSegmentExcerpt{newJSONTiming(se.StartTime, se.EndTime), se.Text})
+}
+
+// UnmarshalJSON is the inverse of MarshalJSON.
+func (se *SegmentExcerpt) UnmarshalJSON(data []byte) error {
+	var raw jsonSegmentExcerpt
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	se.StartTime, se.EndTime = raw.durations()
+	se.Text = raw.Text
+	return nil
+}
+
+type jsonSegmentHit struct {
+	jsonTiming
+	Text        string           `json:"text"`
+	SortedTerms []string         `json:"sorted_terms"`
+	TermCounts  map[string]int   `json:"term_counts"` // Redundant with SortedTerms, only for the convenience of consumers.
+	Highlights  []TextSpan       `json:"highlights,omitempty"`
+	Before      []SegmentExcerpt `json:"before,omitempty"`
+	After       []SegmentExcerpt `json:"after,omitempty"`
+	Chapter     string           `json:"chapter,omitempty"`
+}
+
+func newJSONSegmentHit(sh SegmentHit) jsonSegmentHit {
+	return jsonSegmentHit{
+		jsonTiming:  newJSONTiming(sh.StartTime, sh.EndTime),
+		Text:        sh.Text,
+		SortedTerms: sh.SortedTerms,
+		TermCounts:  sh.TermCounts(),
+		Highlights:  sh.Highlights,
+		Before:      sh.Before,
+		After:       sh.After,
+		Chapter:     sh.Chapter,
+	}
+}
+
+func (jsh jsonSegmentHit) segmentHit() SegmentHit {
+	start, end := jsh.durations()
+	return SegmentHit{
+		StartTime:   start,
+		EndTime:     end,
+		Text:        jsh.Text,
+		SortedTerms: jsh.SortedTerms,
+		Highlights:  jsh.Highlights,
+		Before:      jsh.Before,
+		After:       jsh.After,
+		Chapter:     jsh.Chapter,
+	}
+}
+
+// MarshalJSON expresses the times in seconds instead of nanoseconds.
+func (sh SegmentHit) MarshalJSON() ([]byte, error) {
+	return json.Marshal(newJSONSegmentHit(sh))
+}
+
+// UnmarshalJSON is the inverse of MarshalJSON.
+func (sh *SegmentHit) UnmarshalJSON(data []byte) error {
+	var raw jsonSegmentHit
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*sh = raw.segmentHit()
+	return nil
+}
+
+type jsonScoredSegment struct {
+	jsonSegmentHit
+	Score  float64 `json:"score"`
+	ID     string  `json:"id"`
+	Title  string  `json:"title,omitempty"`
+	Author string  `json:"author,omitempty"`
+
+	UploadDate    string  `json:"upload_date,omitempty"`    // Formatted as YYYY-MM-DD.
+	VideoDuration float64 `json:"video_duration,omitempty"` // In seconds.
+	ViewCount     int64   `json:"view_count,omitempty"`
+	Thumbnail     string  `json:"thumbnail,omitempty"`
+
+	TranslatedFrom string `json:"translated_from,omitempty"`
+	Sponsor        string `json:"sponsor,omitempty"`
+}
+
+// MarshalJSON expresses the times in seconds instead of nanoseconds.
+// It must be defined because the marshaller of SegmentHit would be used otherwise, omitting the score and the ID.
+func (ss ScoredSegment) MarshalJSON() ([]byte, error) {
+	var uploadDate string
+	if !ss.UploadDate.IsZero() {
+		uploadDate = ss.UploadDate.Format(dateLayout)
+	}
+	return json.Marshal(jsonScoredSegment{
+		newJSONSegmentHit(ss.SegmentHit), ss.Score, ss.ID, ss.Title, ss.Author, uploadDate, ss.VideoDuration.Seconds(), ss.ViewCount, ss.Thumbnail,
+		ss.TranslatedFrom, ss.Sponsor,
+	})
+}
+
+// UnmarshalJSON is the inverse of MarshalJSON.
+func (ss *ScoredSegment) UnmarshalJSON(data []byte) error {
+	var raw jsonScoredSegment
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	var uploadDate time.Time
+	if raw.UploadDate != "" {
+		var err error
+		if uploadDate, err = time.Parse(dateLayout, raw.UploadDate); err != nil {
+			return err
+		}
+	}
+	*ss = ScoredSegment{raw.segmentHit(), raw.Score, raw.ID, raw.Title, raw.Author, uploadDate, fromSeconds(raw.VideoDuration), raw.ViewCount, raw.Thumbnail, raw.TranslatedFrom, raw.Sponsor}
+	return nil
+}
+
+// WriteJSON writes a value, typically scored segments, as a single line of JSON.
+func WriteJSON(w io.Writer, value interface{}) error {
+	return json.NewEncoder(w).Encode(value)
+}
+
+// WriteJSONLines writes scored segments as JSON Lines, i.e. one JSON object per line.
+// The segments are those of a search already assembled, so the lines only start once the search is done, but consumers
+// can still process them one at a time rather than parsing a whole array.
+func WriteJSONLines(w io.Writer, segments []ScoredSegment) error {
+	encoder := json.NewEncoder(w)
+	for _, segment := range segments {
+		if err := encoder.Encode(segment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package sininen
+
+import (
+	"strings"
+
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/standard"
+	"github.com/blevesearch/bleve/v2/analysis/lang/ar"
+	"github.com/blevesearch/bleve/v2/analysis/lang/cjk"
+	"github.com/blevesearch/bleve/v2/analysis/lang/ckb"
+	"github.com/blevesearch/bleve/v2/analysis/lang/da"
+	"github.com/blevesearch/bleve/v2/analysis/lang/de"
+	"github.com/blevesearch/bleve/v2/analysis/lang/en"
+	"github.com/blevesearch/bleve/v2/analysis/lang/es"
+	"github.com/blevesearch/bleve/v2/analysis/lang/fa"
+	"github.com/blevesearch/bleve/v2/analysis/lang/fi"
+	"github.com/blevesearch/bleve/v2/analysis/lang/fr"
+	"github.com/blevesearch/bleve/v2/analysis/lang/hi"
+	"github.com/blevesearch/bleve/v2/analysis/lang/hr"
+	"github.com/blevesearch/bleve/v2/analysis/lang/hu"
+	"github.com/blevesearch/bleve/v2/analysis/lang/it"
+	"github.com/blevesearch/bleve/v2/analysis/lang/nl"
+	"github.com/blevesearch/bleve/v2/analysis/lang/no"
+	"github.com/blevesearch/bleve/v2/analysis/lang/pt"
+	"github.com/blevesearch/bleve/v2/analysis/lang/ro"
+	"github.com/blevesearch/bleve/v2/analysis/lang/ru"
+	"github.com/blevesearch/bleve/v2/analysis/lang/sv"
+	"github.com/blevesearch/bleve/v2/analysis/lang/tr"
+)
+
+// languageAnalyzers associates language codes, as used in subtitle file names, to the bleve analyzers of the languages.
+// Importing the analyzers packages is also what registers them in bleve.
+var languageAnalyzers = map[string]string{
+	"ar":  ar.AnalyzerName,
+	"ckb": ckb.AnalyzerName,
+	"da":  da.AnalyzerName,
+	"de":  de.AnalyzerName,
+	"en":  en.AnalyzerName,
+	"es":  es.AnalyzerName,
+	"fa":  fa.AnalyzerName,
+	"fi":  fi.AnalyzerName,
+	"fr":  fr.AnalyzerName,
+	"hi":  hi.AnalyzerName,
+	"hr":  hr.AnalyzerName,
+	"hu":  hu.AnalyzerName,
+	"it":  it.AnalyzerName,
+	"ja":  cjk.AnalyzerName,
+	"ko":  cjk.AnalyzerName,
+	"nl":  nl.AnalyzerName,
+	"no":  no.AnalyzerName,
+	"pt":  pt.AnalyzerName,
+	"ro":  ro.AnalyzerName,
+	"ru":  ru.AnalyzerName,
+	"sv":  sv.AnalyzerName,
+	"tr":  tr.AnalyzerName,
+	"zh":  cjk.AnalyzerName,
+}
+
+// LanguageAnalyzer returns the name of the bleve analyzer suited to a language code such as en or pt-BR.
+// The standard analyzer, which does no stemming, is used for the languages without a dedicated analyzer.
+func LanguageAnalyzer(lang string) string {
+	base := strings.ToLower(strings.SplitN(lang, "-", 2)[0])
+	if analyzer, exists := languageAnalyzers[base]; exists {
+		return analyzer
+	}
+	return standard.Name
+}
@@ -0,0 +1,84 @@
+//go:build !js
+// +build !js
+
+package sininen
+
+import (
+	"sort"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/mapping"
+)
+
+// IndexedVideo describes a video whose transcription is in an index.
+type IndexedVideo struct {
+	ID         string
+	Title      string
+	UploadDate time.Time     // Zero when unknown.
+	Duration   time.Duration // Duration of the video, or of its transcription when unknown.
+	IndexedAt  time.Time     // Zero when unknown, i.e. for videos indexed by older versions.
+	ViewCount  int64         // Zero when unknown.
+
+	TranslatedFrom string // Original language of the subtitles when they are a machine translation, empty otherwise.
+}
+
+// ListVideos returns the videos of an index, sorted by ID.
+func ListVideos(index bleve.Index) ([]IndexedVideo, error) {
+	count, err := index.DocCount()
+	if err != nil {
+		return nil, err
+	}
+	request := bleve.NewSearchRequestOptions(bleve.NewMatchAllQuery(), int(count), 0, false)
+	request.Fields = []string{"Title", "UploadDate", "Duration", "ViewCount", "TranslatedFrom"}
+	hits, err := index.Search(request)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]IndexedVideo, 0, len(hits.Hits))
+	for _, hit := range hits.Hits {
+		video := IndexedVideo{ID: hit.ID}
+		video.Title, _ = hit.Fields["Title"].(string)
+		seconds, _ := hit.Fields["Duration"].(float64)
+		video.Duration = fromSeconds(seconds)
+		views, _ := hit.Fields["ViewCount"].(float64)
+		video.ViewCount = int64(views)
+		video.TranslatedFrom, _ = hit.Fields["TranslatedFrom"].(string)
+		if raw, exists := hit.Fields["UploadDate"].(string); exists {
+			video.UploadDate, _ = time.Parse(time.RFC3339, raw) // Stays zero when it cannot be parsed.
+		}
+		indexedAt, err := index.GetInternal(indexedAtKey(hit.ID))
+		if err != nil {
+			return nil, err
+		}
+		video.IndexedAt, _ = time.Parse(time.RFC3339, string(indexedAt))
+		result = append(result, video)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result, nil
+}
+
+// NewSnapshot returns a snapshot of all the transcriptions of an index in the given language.
+// The snapshot analyzes the queries with the analyzer of the index, except for the n-gram indexes: their analyzer is
+// not registered in bleve, so the analyzer of the language is used instead and matches only whole words.
+func NewSnapshot(index bleve.Index, lang string) (*Snapshot, error) {
+	videos, err := ListVideos(index)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(videos))
+	for i, video := range videos {
+		ids[i] = video.ID
+	}
+	transcriptions, err := storedTranscriptions(index, ids)
+	if err != nil {
+		return nil, err
+	}
+	analyzer := LanguageAnalyzer(lang)
+	impl, ok := index.Mapping().(*mapping.IndexMappingImpl)
+	if ok && impl.DefaultAnalyzer != "" && impl.DefaultAnalyzer != ngramAnalyzer {
+		analyzer = impl.DefaultAnalyzer
+	}
+	return &Snapshot{Lang: lang, Analyzer: analyzer, Videos: transcriptions}, nil
+}
@@ -0,0 +1,106 @@
+package sininen
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Level is the severity of a log message.
+type Level int
+
+const (
+	LevelDebug   Level = iota // Details about each processed file.
+	LevelInfo                 // Progress of the main steps.
+	LevelWarning              // Problems that do not prevent the rest of the work, e.g. an unreadable subtitles file.
+	LevelError                // Failures.
+	LevelSilent               // Used as a threshold, disables all messages.
+)
+
+var levelNames = map[Level]string{
+	LevelDebug:   "debug",
+	LevelInfo:    "info",
+	LevelWarning: "warning",
+	LevelError:   "error",
+	LevelSilent:  "silent",
+}
+
+func (l Level) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("level(%d)", int(l))
+}
+
+// Logger receives the messages of the library, so that an embedding application can route them to its own logging,
+// see LeveledLogger.Logger.
+// The messages are given without trailing newline.
+type Logger interface {
+	Log(level Level, message string)
+}
+
+// LoggerFunc adapts a function to the Logger interface.
+type LoggerFunc func(level Level, message string)
+
+func (lf LoggerFunc) Log(level Level, message string) {
+	lf(level, message)
+}
+
+// Discard is a Logger ignoring every message.
+var Discard Logger = LoggerFunc(func(Level, string) {})
+
+// StdLogger returns a Logger printing the messages to a logger of the standard library, prefixed by their level.
+func StdLogger(logger *log.Logger) Logger {
+	return LoggerFunc(func(level Level, message string) {
+		logger.Printf("%s: %s", level, message)
+	})
+}
+
+// LeveledLogger writes the messages whose level is at least its threshold, one per line prefixed by the level.
+type LeveledLogger struct {
+	Level  Level // Minimum level of the messages written.
+	Output io.Writer
+	Logger Logger // When not nil, receives the messages instead of Output.
+
+	mutex sync.Mutex
+}
+
+// Log is the logger of the library, writing warnings and errors to stderr by default.
+// Setting its Logger routes the messages elsewhere, e.g. Discard silences the library.
+var Log = &LeveledLogger{Level: LevelWarning, Output: os.Stderr}
+
+// Logf writes a message of the given level, formatted like fmt.Printf.
+func (ll *LeveledLogger) Logf(level Level, format string, args ...interface{}) {
+	if level < ll.Level {
+		return
+	}
+	message := strings.TrimSuffix(fmt.Sprintf(format, args...), "\n")
+	if ll.Logger != nil {
+		ll.Logger.Log(level, message)
+		return
+	}
+	ll.mutex.Lock()
+	defer ll.mutex.Unlock()
+	fmt.Fprintf(ll.Output, "%s: %s\n", level, message)
+}
+
+// Log implements Logger, the message being filtered by level like the ones of Logf.
+func (ll *LeveledLogger) Log(level Level, message string) {
+	ll.Logf(level, "%s", message)
+}
+
+func (ll *LeveledLogger) Debugf(format string, args ...interface{}) {
+	ll.Logf(LevelDebug, format, args...)
+}
+func (ll *LeveledLogger) Infof(format string, args ...interface{}) {
+	ll.Logf(LevelInfo, format, args...)
+}
+func (ll *LeveledLogger) Warnf(format string, args ...interface{}) {
+	ll.Logf(LevelWarning, format, args...)
+}
+func (ll *LeveledLogger) Errorf(format string, args ...interface{}) {
+	ll.Logf(LevelError, format, args...)
+}
@@ -0,0 +1,252 @@
+// Package mcp serves tools to LLM assistants with the Model Context Protocol (https://modelcontextprotocol.io), over
+// newline-delimited JSON-RPC 2.0 messages such as those of the stdio transport.
+// Only the tools capability is implemented, the tools being given by the user of the package independently of sininen.
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ProtocolVersion is the revision of the protocol answered to the clients not asking for another one.
+const ProtocolVersion = "2024-11-05"
+
+// maxMessage is the maximum size of a message received by Serve.
+const maxMessage = 4 << 20
+
+// The error codes of JSON-RPC.
+const (
+	codeParse          = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+// Tool is a function that the assistants can call.
+type Tool struct {
+	Name        string
+	Description string                 // Tells the assistants when and how to use the tool.
+	InputSchema map[string]interface{} // JSON schema of the arguments, an object.
+
+	// Call returns the text answered to a call with the given JSON arguments.
+	// An error is reported to the assistant as the result of the call, so that it can fix its arguments or give up.
+	Call func(ctx context.Context, arguments json.RawMessage) (string, error)
+}
+
+// Server answers the requests of the clients.
+// Only Name is mandatory.
+type Server struct {
+	Name         string // Name of the server, shown by the clients.
+	Version      string // Version of the server, empty by default.
+	Instructions string // Hints given to the assistants about the use of the server, none by default.
+	Tools        []Tool
+}
+
+// message is a JSON-RPC request, notification or response.
+type message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"` // Absent from the notifications.
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  interface{}      `json:"result,omitempty"`
+	Error   *rpcError        `json:"error,omitempty"`
+}
+
+// rpcError is the error of a JSON-RPC response.
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (re *rpcError) Error() string {
+	return re.Message
+}
+
+// nullID is the ID of the responses to the requests whose ID could not be read.
+var nullID = json.RawMessage("null")
+
+// Serve answers the messages read from r, one per line, by writing the responses to w until r is exhausted or ctx is
+// done, which is checked between the messages.
+// A line may also hold a batch of messages, i.e. a JSON array of them. The requests are answered one at a time, in order.
+func (s Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	lines := bufio.NewScanner(r)
+	lines.Buffer(make([]byte, 0, 64*1024), maxMessage)
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false) // Keeps the links of the results readable.
+	for lines.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		line := bytes.TrimSpace(lines.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		response := s.answer(ctx, line)
+		if response == nil {
+			continue // Only notifications.
+		}
+		if err := encoder.Encode(response); err != nil { // Followed by a newline.
+			return err
+		}
+	}
+	return lines.Err()
+}
+
+// answer returns the response to a line holding a message or a batch, nil when there is nothing to answer.
+func (s Server) answer(ctx context.Context, line []byte) interface{} {
+	if line[0] != '[' {
+		var request message
+		if err := json.Unmarshal(line, &request); err != nil {
+			return errorResponse(&nullID, &rpcError{codeParse, "parse error: " + err.Error()})
+		}
+		if response := s.respond(ctx, request); response != nil {
+			return response
+		}
+		return nil // Not a nil *message, which would be marshalled as null.
+	}
+	var batch []message
+	if err := json.Unmarshal(line, &batch); err != nil {
+		return errorResponse(&nullID, &rpcError{codeParse, "parse error: " + err.Error()})
+	}
+	if len(batch) == 0 {
+		return errorResponse(&nullID, &rpcError{codeInvalidRequest, "empty batch"})
+	}
+	responses := []*message{}
+	for _, request := range batch {
+		if response := s.respond(ctx, request); response != nil {
+			responses = append(responses, response)
+		}
+	}
+	if len(responses) == 0 {
+		return nil
+	}
+	return responses
+}
+
+// respond returns the response to a message, nil for the notifications and the responses sent by the client.
+func (s Server) respond(ctx context.Context, request message) *message {
+	if request.ID == nil || request.Method == "" {
+		return nil // The notifications, e.g. notifications/initialized, need no answer.
+	}
+	if request.JSONRPC != "2.0" {
+		return errorResponse(request.ID, &rpcError{codeInvalidRequest, "expected JSON-RPC 2.0"})
+	}
+	result, err := s.call(ctx, request.Method, request.Params)
+	if err != nil {
+		var rpcErr *rpcError
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &rpcError{codeInvalidParams, err.Error()}
+		}
+		return errorResponse(request.ID, rpcErr)
+	}
+	return &message{JSONRPC: "2.0", ID: request.ID, Result: result}
+}
+
+// errorResponse returns the response to a failed request.
+func errorResponse(id *json.RawMessage, err *rpcError) *message {
+	return &message{JSONRPC: "2.0", ID: id, Error: err}
+}
+
+// The results of the methods.
+type (
+	initializeResult struct {
+		ProtocolVersion string                 `json:"protocolVersion"`
+		Capabilities    map[string]interface{} `json:"capabilities"`
+		ServerInfo      serverInfo             `json:"serverInfo"`
+		Instructions    string                 `json:"instructions,omitempty"`
+	}
+	serverInfo struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+	toolInfo struct {
+		Name        string                 `json:"name"`
+		Description string                 `json:"description,omitempty"`
+		InputSchema map[string]interface{} `json:"inputSchema"`
+	}
+	toolsResult struct {
+		Tools []toolInfo `json:"tools"`
+	}
+	content struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	callResult struct {
+		Content []content `json:"content"`
+		IsError bool      `json:"isError"`
+	}
+)
+
+// call returns the result of a method.
+func (s Server) call(ctx context.Context, method string, params json.RawMessage) (interface{}, error) {
+	switch method {
+	case "initialize":
+		var request struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		if err := unmarshalParams(params, &request); err != nil {
+			return nil, err
+		}
+		version := request.ProtocolVersion
+		if version == "" {
+			version = ProtocolVersion
+		}
+		return initializeResult{
+			ProtocolVersion: version, // The tools are the same in every revision.
+			Capabilities:    map[string]interface{}{"tools": map[string]interface{}{}},
+			ServerInfo:      serverInfo{s.Name, s.Version},
+			Instructions:    s.Instructions,
+		}, nil
+	case "ping":
+		return struct{}{}, nil
+	case "tools/list":
+		result := toolsResult{Tools: make([]toolInfo, len(s.Tools))}
+		for i, tool := range s.Tools {
+			result.Tools[i] = toolInfo{tool.Name, tool.Description, tool.InputSchema}
+		}
+		return result, nil
+	case "tools/call":
+		var request struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := unmarshalParams(params, &request); err != nil {
+			return nil, err
+		}
+		for _, tool := range s.Tools {
+			if tool.Name == request.Name {
+				return callTool(ctx, tool, request.Arguments), nil
+			}
+		}
+		return nil, fmt.Errorf("unknown tool %q", request.Name)
+	}
+	return nil, &rpcError{codeMethodNotFound, "method not found: " + method}
+}
+
+// unmarshalParams reads the parameters of a request, which may be omitted.
+func unmarshalParams(params json.RawMessage, value interface{}) error {
+	if len(params) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(params, value); err != nil {
+		return fmt.Errorf("invalid parameters: %v", err)
+	}
+	return nil
+}
+
+// callTool returns the result of a call of a tool, a failure included.
+func callTool(ctx context.Context, tool Tool, arguments json.RawMessage) callResult {
+	if len(arguments) == 0 || string(arguments) == "null" {
+		arguments = json.RawMessage("{}")
+	}
+	text, err := tool.Call(ctx, arguments)
+	if err != nil {
+		return callResult{Content: []content{{"text", err.Error()}}, IsError: true}
+	}
+	return callResult{Content: []content{{"text", text}}}
+}
@@ -0,0 +1,54 @@
+package sininen
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+)
+
+// VideoMetadata holds the information about a video that is not part of its transcription.
+type VideoMetadata struct {
+	Title      string
+	UploadDate time.Time     // Zero when unknown.
+	Duration   time.Duration // Zero when unknown.
+	ViewCount  int64         // Zero when unknown.
+	Chapters   []Chapter     // Sorted by start time, empty when the video has none.
+}
+
+// infoFile is the subset of a youtube-dl .info.json file that is relevant to sininen.
+type infoFile struct {
+	Title      string  `json:"title"`
+	UploadDate string  `json:"upload_date"` // Formatted as YYYYMMDD.
+	Duration   float64 `json:"duration"`    // In seconds.
+	ViewCount  int64   `json:"view_count"`
+	Chapters   []struct {
+		StartTime float64 `json:"start_time"` // In seconds.
+		EndTime   float64 `json:"end_time"`
+		Title     string  `json:"title"`
+	} `json:"chapters"`
+}
+
+// ReadInfoFile extracts video metadata from a .info.json file, as written by youtube-dl --write-info-json.
+func ReadInfoFile(filename string) (*VideoMetadata, error) {
+	raw, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var info infoFile
+	if err := json.Unmarshal(raw, &info); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrBadFormat, filename, err)
+	}
+
+	result := &VideoMetadata{Title: info.Title, Duration: fromSeconds(info.Duration), ViewCount: info.ViewCount}
+	for _, chapter := range info.Chapters {
+		result.Chapters = append(result.Chapters, Chapter{chapter.Title, fromSeconds(chapter.StartTime), fromSeconds(chapter.EndTime)})
+	}
+	if info.UploadDate != "" {
+		result.UploadDate, err = time.Parse("20060102", info.UploadDate)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: upload date: %v", ErrBadFormat, filename, err)
+		}
+	}
+	return result, nil
+}
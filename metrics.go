@@ -0,0 +1,44 @@
+package sininen
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics of the indexing and of the searches, labelled by backend (bleve or sqlite).
+// They are only exported once registered, see RegisterMetrics.
+var (
+	indexedDocuments = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "sininen",
+		Name:      "indexed_documents_total",
+		Help:      "Number of subtitles files indexed.",
+	}, []string{"backend"})
+	parseFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "sininen",
+		Name:      "parse_failures_total",
+		Help:      "Number of subtitles files skipped because they could not be parsed.",
+	}, []string{"backend"})
+	searchDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "sininen",
+		Name:      "search_duration_seconds",
+		Help:      "Duration of the searches, assembly of the results included.",
+		Buckets:   []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5},
+	}, []string{"backend"})
+)
+
+// RegisterMetrics registers the metrics of the library, so that they are exported by the handlers of the registerer,
+// e.g. prometheus.DefaultRegisterer.
+func RegisterMetrics(registerer prometheus.Registerer) error {
+	for _, collector := range []prometheus.Collector{indexedDocuments, parseFailures, searchDuration} {
+		if err := registerer.Register(collector); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// observeSearch records the duration of a search started at the given time, to be deferred.
+func observeSearch(backend string, started time.Time) {
+	searchDuration.WithLabelValues(backend).Observe(time.Since(started).Seconds())
+}
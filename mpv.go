@@ -0,0 +1,249 @@
+package sininen
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MPVClient drives a running mpv through its JSON IPC, mpv being started with --input-ipc-server=<socket>, see
+// https://mpv.io/manual/stable/#json-ipc.
+// Only the Unix sockets are supported, not the named pipes of Windows.
+type MPVClient struct {
+	Timeout time.Duration // Maximum duration of a command, 5 seconds by default.
+
+	mutex  sync.Mutex // Serializes the commands, so that their responses are not mixed up.
+	conn   net.Conn
+	reader *bufio.Reader
+	lastID int
+}
+
+// DialMPV connects to the IPC socket of a running mpv.
+func DialMPV(socket string) (*MPVClient, error) {
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to mpv, which should be started with --input-ipc-server=%s: %w", socket, err)
+	}
+	return &MPVClient{conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+// mpvResponse is the response to a command, or an event when Event is set.
+type mpvResponse struct {
+	Event     string          `json:"event"`
+	RequestID int             `json:"request_id"`
+	Error     string          `json:"error"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Command runs an mpv command, e.g. "seek", 10, "absolute", and returns its data.
+// The events sent by mpv in the meantime are ignored.
+func (mc *MPVClient) Command(args ...interface{}) (json.RawMessage, error) {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	timeout := mc.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	if err := mc.conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+	mc.lastID++
+	request, err := json.Marshal(map[string]interface{}{"command": args, "request_id": mc.lastID})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := mc.conn.Write(append(request, '\n')); err != nil {
+		return nil, err
+	}
+	for {
+		line, err := mc.reader.ReadBytes('\n')
+		if err != nil {
+			return nil, err
+		}
+		var response mpvResponse
+		if err := json.Unmarshal(line, &response); err != nil {
+			return nil, fmt.Errorf("malformed response of mpv: %w", err)
+		}
+		if response.Event != "" || response.RequestID != mc.lastID {
+			continue
+		}
+		if response.Error != "success" {
+			return nil, fmt.Errorf("mpv %v: %s", args[0], response.Error)
+		}
+		return response.Data, nil
+	}
+}
+
+// Property returns the value of a property of mpv, e.g. time-pos, decoded in value.
+func (mc *MPVClient) Property(name string, value interface{}) error {
+	data, err := mc.Command("get_property", name)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, value)
+}
+
+// SetProperty sets a property of mpv, e.g. pause.
+func (mc *MPVClient) SetProperty(name string, value interface{}) error {
+	_, err := mc.Command("set_property", name, value)
+	return err
+}
+
+// Load plays a file or a URL from a given time, replacing the one being played.
+// The start option of mpv is set to do so, and thus also applies to the files loaded afterwards by other means.
+func (mc *MPVClient) Load(location string, start time.Duration) error {
+	// Setting the start option before loading rather than seeking afterwards avoids waiting for the file to be loaded.
+	if err := mc.SetProperty("start", mpvSeconds(start)); err != nil {
+		return err
+	}
+	_, err := mc.Command("loadfile", location, "replace")
+	return err
+}
+
+// Seek jumps to a given time of the file being played.
+func (mc *MPVClient) Seek(at time.Duration) error {
+	_, err := mc.Command("seek", at.Seconds(), "absolute")
+	return err
+}
+
+// Loop repeats the part of the file being played between two times, until Unloop is called.
+func (mc *MPVClient) Loop(start, end time.Duration) error {
+	if err := mc.SetProperty("ab-loop-a", start.Seconds()); err != nil {
+		return err
+	}
+	return mc.SetProperty("ab-loop-b", end.Seconds())
+}
+
+// Unloop stops repeating the part of the file set by Loop.
+func (mc *MPVClient) Unloop() error {
+	if err := mc.SetProperty("ab-loop-a", "no"); err != nil {
+		return err
+	}
+	return mc.SetProperty("ab-loop-b", "no")
+}
+
+// Close closes the connection to mpv, which keeps running.
+func (mc *MPVClient) Close() error {
+	return mc.conn.Close()
+}
+
+// mpvSeconds formats a duration as the number of seconds of an mpv time option.
+func mpvSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+}
+
+// MediaLocation returns what a player should open to play a video of a platform, i.e. the path of the video file for
+// the file and mpv platforms, and its link otherwise.
+func MediaLocation(builder URLBuilder, id string) string {
+	switch platform := unwrap(builder).(type) {
+	case LocalFile:
+		return platform.path(id)
+	case MPV:
+		return platform.path(id)
+	}
+	return builder.URL(id, 0)
+}
+
+// MPVSession guides the viewing of segments in mpv, e.g. of search results, playing them one after the other.
+type MPVSession struct {
+	Client *MPVClient
+	URLs   URLBuilder // Builder of the locations of the videos, see MediaLocation.
+
+	segments []ScoredSegment
+	current  int // Position of the segment being played, -1 before the first one.
+	looping  bool
+}
+
+// NewMPVSession returns a session playing segments with a running mpv.
+func NewMPVSession(client *MPVClient, urls URLBuilder, segments []ScoredSegment) *MPVSession {
+	if urls == nil {
+		urls = YouTube{}
+	}
+	return &MPVSession{Client: client, URLs: urls, segments: segments, current: -1}
+}
+
+// Reset replaces the segments of the session, e.g. by the results of a new search, the segment being played going on
+// until another one is played, without being repeated any more.
+func (ms *MPVSession) Reset(segments []ScoredSegment) error {
+	ms.segments, ms.current = segments, -1
+	if !ms.looping {
+		return nil
+	}
+	ms.looping = false // Even on failure, the segment being no longer current.
+	return ms.Client.Unloop()
+}
+
+// Play plays the segment at the given position, starting from 0, seeking to it when its video is already being
+// played rather than loading the video again.
+// The loop of the previous segment, if any, is stopped.
+func (ms *MPVSession) Play(position int) error {
+	if position < 0 || position >= len(ms.segments) {
+		return fmt.Errorf("no segment at position %d out of %d", position+1, len(ms.segments))
+	}
+	if ms.looping {
+		if err := ms.Client.Unloop(); err != nil {
+			return err
+		}
+		ms.looping = false
+	}
+	segment := ms.segments[position]
+	location := MediaLocation(ms.URLs, segment.ID)
+	var path string
+	if err := ms.Client.Property("path", &path); err == nil && path == location {
+		if err := ms.Client.Seek(segment.StartTime); err != nil {
+			return err
+		}
+	} else if err := ms.Client.Load(location, segment.StartTime); err != nil {
+		return err
+	}
+	ms.current = position
+	return ms.Client.SetProperty("pause", false)
+}
+
+// Next plays the segment following the one being played, or the first one.
+func (ms *MPVSession) Next() error {
+	if ms.current+1 >= len(ms.segments) {
+		return fmt.Errorf("no segment after the last one")
+	}
+	return ms.Play(ms.current + 1)
+}
+
+// Previous plays the segment preceding the one being played.
+func (ms *MPVSession) Previous() error {
+	if ms.current <= 0 {
+		return fmt.Errorf("no segment before the first one")
+	}
+	return ms.Play(ms.current - 1)
+}
+
+// Current returns the segment being played and its position, ok being false before the first one.
+func (ms *MPVSession) Current() (segment ScoredSegment, position int, ok bool) {
+	if ms.current < 0 || ms.current >= len(ms.segments) {
+		return ScoredSegment{}, -1, false
+	}
+	return ms.segments[ms.current], ms.current, true
+}
+
+// ToggleLoop repeats the segment being played, or stops repeating it, and tells whether it is now repeated.
+func (ms *MPVSession) ToggleLoop() (bool, error) {
+	segment, _, ok := ms.Current()
+	if !ok {
+		return false, fmt.Errorf("no segment being played")
+	}
+	if ms.looping {
+		if err := ms.Client.Unloop(); err != nil {
+			return true, err
+		}
+		ms.looping = false
+		return false, nil
+	}
+	if err := ms.Client.Loop(segment.StartTime, segment.EndTime); err != nil {
+		return false, err
+	}
+	ms.looping = true
+	return true, nil
+}
@@ -0,0 +1,90 @@
+package sininen
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// noteNameReplacer removes the characters that are not allowed in note names by Obsidian or by the file systems.
+var noteNameReplacer = strings.NewReplacer(
+	"/", " ", `\`, " ", ":", " ", "*", " ", "?", " ", `"`, " ", "<", " ", ">", " ", "|", " ", "#", " ", "^", " ", "[", " ",
+	"]", " ",
+)
+
+// noteName returns the name of the note of a video, without extension.
+func noteName(segment ScoredSegment) string {
+	name := strings.Join(strings.Fields(noteNameReplacer.Replace(segment.Title)), " ")
+	if name == "" {
+		return segment.ID
+	}
+	return name
+}
+
+// writeFrontmatter writes the YAML frontmatter and the title of a new video note.
+// Strings are quoted like in Go, which is valid YAML.
+func writeFrontmatter(w io.Writer, channel string, segment ScoredSegment, urls URLBuilder) error {
+	_, err := fmt.Fprintf(w, "---\nvideo_id: %s\ntitle: %s\nchannel: %s\n", strconv.Quote(segment.ID),
+		strconv.Quote(segment.Title), strconv.Quote(channel))
+	if err != nil {
+		return err
+	}
+	if !segment.UploadDate.IsZero() {
+		if _, err := fmt.Fprintf(w, "date: %s\n", segment.UploadDate.Format(dateLayout)); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "url: %s\ntags: [sininen]\n---\n\n# %s\n", strconv.Quote(urls.URL(segment.ID, 0)),
+		markdownEscaper.Replace(segment.DisplayName()))
+	return err
+}
+
+// writeQuotes writes the segments of a video as timestamped quotes under a section named after the query.
+func writeQuotes(w io.Writer, query string, segments []ScoredSegment, urls URLBuilder) error {
+	if _, err := fmt.Fprintf(w, "\n## %s\n", markdownEscaper.Replace(query)); err != nil {
+		return err
+	}
+	for _, segment := range segments {
+		_, err := fmt.Fprintf(w, "\n> %s\n> — [%s](%s)\n",
+			markdownEscaper.Replace(segment.Text), FormatTimestamp(segment.StartTime), SegmentURL(urls, segment))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteNotes writes scored segments as Markdown notes in the given folder, such as an Obsidian vault, one note per video.
+// New notes start with a YAML frontmatter describing the video.
+// The quotes are written in a section named after the query, which is appended to the note if it already exists, so
+// that the notes accumulate the results of successive searches.
+func WriteNotes(folder, channel, query string, segments []ScoredSegment, urls URLBuilder) error {
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		return err
+	}
+	for _, video := range groupByVideo(segments) {
+		filename := filepath.Join(folder, noteName(video[0])+".md")
+		_, err := os.Stat(filename)
+		isNew := os.IsNotExist(err)
+		file, err := os.OpenFile(filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		if isNew {
+			err = writeFrontmatter(file, channel, video[0], urls)
+		}
+		if err == nil {
+			err = writeQuotes(file, query, video, urls)
+		}
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package sininen
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// OEmbedVideo is the information about a video given by an oEmbed endpoint.
+type OEmbedVideo struct {
+	Title  string `json:"title"`
+	Author string `json:"author_name"` // Name of the channel of the video.
+}
+
+// OEmbedResolver fetches the titles and authors of YouTube videos from the oEmbed endpoint of YouTube, which needs no
+// API key, caching them in a file so that each video is only fetched once.
+// It is safe for concurrent use.
+type OEmbedResolver struct {
+	HTTP      *http.Client // http.DefaultClient when nil.
+	Endpoint  string       // https://www.youtube.com/oembed by default.
+	CacheFile string       // JSON file caching the resolved videos, none when empty.
+
+	mutex  sync.Mutex
+	videos map[string]*OEmbedVideo // By ID, nil for the videos unknown to the endpoint, e.g. private ones.
+}
+
+// load reads the cache file the first time it is needed, the lock being held.
+func (oe *OEmbedResolver) load() error {
+	if oe.videos != nil {
+		return nil
+	}
+	oe.videos = map[string]*OEmbedVideo{}
+	if oe.CacheFile == "" {
+		return nil
+	}
+	raw, err := ioutil.ReadFile(oe.CacheFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, &oe.videos); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrBadFormat, oe.CacheFile, err)
+	}
+	return nil
+}
+
+// save writes the cache file, replacing it only once it is completely written, the lock being held.
+func (oe *OEmbedResolver) save() error {
+	if oe.CacheFile == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(oe.CacheFile), 0755); err != nil {
+		return err
+	}
+	raw, err := json.Marshal(oe.videos)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(oe.CacheFile+".tmp", raw, 0644); err != nil {
+		return err
+	}
+	return os.Rename(oe.CacheFile+".tmp", oe.CacheFile)
+}
+
+// fetch asks the endpoint about a video, returning nil when the video is unknown to it.
+func (oe *OEmbedResolver) fetch(ctx context.Context, id string) (*OEmbedVideo, error) {
+	endpoint, client := oe.Endpoint, oe.HTTP
+	if endpoint == "" {
+		endpoint = "https://www.youtube.com/oembed"
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	query := url.Values{"url": {"https://www.youtube.com/watch?v=" + id}, "format": {"json"}}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound:
+		return nil, nil // Private, deleted or not a YouTube video.
+	default:
+		return nil, fmt.Errorf("oEmbed: %s: %s", id, response.Status)
+	}
+	var result OEmbedVideo
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("%w: oEmbed response for %s: %v", ErrBadFormat, id, err)
+	}
+	return &result, nil
+}
+
+// Resolve returns the title and author of the given videos, by ID, from the cache or else from the endpoint, the
+// videos unknown to the endpoint being missing from the result.
+// The endpoint is asked without the lock held, so that the slow fetches of a call do not hold back the other calls.
+func (oe *OEmbedResolver) Resolve(ctx context.Context, ids []string) (map[string]OEmbedVideo, error) {
+	result := make(map[string]OEmbedVideo, len(ids))
+	missing := []string{}
+	oe.mutex.Lock()
+	if err := oe.load(); err != nil {
+		oe.mutex.Unlock()
+		return nil, err
+	}
+	for _, id := range ids {
+		if video, cached := oe.videos[id]; !cached {
+			missing = append(missing, id)
+		} else if video != nil {
+			result[id] = *video
+		}
+	}
+	oe.mutex.Unlock()
+	if len(missing) == 0 {
+		return result, nil
+	}
+
+	fetched := map[string]*OEmbedVideo{}
+	var err error
+	for _, id := range missing {
+		var video *OEmbedVideo
+		if video, err = oe.fetch(ctx, id); err != nil {
+			break // The videos already fetched are still cached.
+		}
+		fetched[id] = video
+		if video != nil {
+			result[id] = *video
+		}
+	}
+	if len(fetched) == 0 {
+		return result, err
+	}
+	oe.mutex.Lock()
+	defer oe.mutex.Unlock()
+	for id, video := range fetched {
+		oe.videos[id] = video
+	}
+	if saveErr := oe.save(); saveErr != nil && err == nil {
+		err = saveErr
+	}
+	return result, err
+}
+
+// AddOEmbedTitles sets the titles and authors of the segments whose title is unknown, typically because their video
+// has no metadata file, with a resolver.
+// The given slice is left untouched.
+func AddOEmbedTitles(ctx context.Context, segments []ScoredSegment, resolver *OEmbedResolver) ([]ScoredSegment, error) {
+	result := make([]ScoredSegment, len(segments))
+	copy(result, segments)
+	ids := []string{}
+	seen := map[string]bool{}
+	for _, segment := range result {
+		if segment.Title == "" && !seen[segment.ID] {
+			seen[segment.ID] = true
+			ids = append(ids, segment.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return result, nil
+	}
+	videos, err := resolver.Resolve(ctx, ids)
+	for i := range result {
+		if video, ok := videos[result[i].ID]; ok && result[i].Title == "" {
+			result[i].Title, result[i].Author = video.Title, video.Author
+		}
+	}
+	return result, err
+}
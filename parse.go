@@ -1,6 +1,11 @@
 package sininen
 
 import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -32,8 +37,17 @@ type transcriptionSegment struct {
 // Transcription stores the whole transcription text, as well as all the segments in a manner usable by bleve.
 // The reason for using a slice of float64 rather then a slice of transcriptionSegment is that bleve does not support time.Duration or int, only float64.
 type Transcription struct {
-	Words    string
-	Segments []float64
+	Words      string
+	Segments   []float64
+	Title      string
+	UploadDate time.Time // Left out of the index when unknown.
+	Duration   float64   // Duration of the video in seconds, approximated by the end of the last segment when unknown.
+	ViewCount  int64     // Number of views when the metadata was retrieved, zero when unknown.
+	Playlists  []string  // IDs of the playlists containing the video, see TagPlaylist.
+
+	// TranslatedFrom is the original language of the subtitles when they are a machine translation, see
+	// TranslateSubtitleFile, and is empty for the original subtitles.
+	TranslatedFrom string
 }
 
 // toFloats serialises a transcription segment as three float64, thus helping to construct the slice Transcription.Segments.
@@ -49,9 +63,13 @@ func (Transcription) BleveType() string {
 // ParseSubtitleFile transforms a subtitle file into a Transcription usable by bleve.
 func ParseSubtitleFile(filename string) (*Transcription, error) {
 	st, err := astisub.OpenFile(filename)
-	if err != nil {
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
 		return nil, err
 	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrBadFormat, filename, err)
+	}
 
 	segments := make([]float64, 0, 3*len(st.Items))
 	var sb strings.Builder
@@ -63,5 +81,55 @@ func ParseSubtitleFile(filename string) (*Transcription, error) {
 		f1, f2, f3 := transcriptionSegment{item.StartAt, item.EndAt, sb.Len()}.toFloats()
 		segments = append(segments, f1, f2, f3)
 	}
-	return &Transcription{sb.String(), segments}, nil
+	var duration float64
+	if len(st.Items) > 0 {
+		duration = st.Items[len(st.Items)-1].EndAt.Seconds()
+	}
+	return &Transcription{Words: sb.String(), Segments: segments, Duration: duration, TranslatedFrom: translatedFrom(st)}, nil
+}
+
+// Progress reports that done files out of total were indexed.
+type Progress func(done, total int)
+
+// report calls the progress function, if any.
+func (p Progress) report(done, total int) {
+	if p != nil {
+		p(done, total)
+	}
+}
+
+// subtitleFiles lists the subtitles files of the given language in a folder, by video ID.
+func subtitleFiles(folder, lang string) (map[string]os.FileInfo, error) {
+	files, err := ioutil.ReadDir(folder)
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]os.FileInfo{}
+	for _, file := range files {
+		if !strings.EqualFold(SubtitlesLanguage(file.Name()), lang) { // Case-insensitive file systems may not preserve the case.
+			continue
+		}
+		result[strings.Split(file.Name(), ".")[0]] = file
+	}
+	return result, nil
+}
+
+// addMetadata adds the information found in the video metadata file to a transcription, when this file exists.
+func addMetadata(document *Transcription, folder, id string) {
+	filename := filepath.Join(folder, id+".info.json")
+	if _, err := os.Stat(filename); err != nil {
+		Log.Debugf("no metadata file for %s", id)
+		return // Metadata is optional.
+	}
+	metadata, err := ReadInfoFile(filename)
+	if err != nil {
+		Log.Warnf("ignoring the metadata of %s: %v", id, err)
+		return
+	}
+	document.Title = metadata.Title
+	document.UploadDate = metadata.UploadDate
+	if metadata.Duration > 0 {
+		document.Duration = metadata.Duration.Seconds()
+	}
+	document.ViewCount = metadata.ViewCount
 }
@@ -0,0 +1,203 @@
+package sininen
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// PeerTubeClient downloads the captions of the videos of a PeerTube instance through its REST API, see
+// https://docs.joinpeertube.org/api-rest-reference.html.
+type PeerTubeClient struct {
+	Instance string       // Base URL of the instance, e.g. https://framatube.org, mandatory.
+	HTTP     *http.Client // http.DefaultClient when nil.
+}
+
+// PeerTubeVideo is the subset of the description of a PeerTube video that is relevant to sininen.
+type PeerTubeVideo struct {
+	UUID        string    `json:"uuid"`
+	ShortUUID   string    `json:"shortUUID"` // Empty before PeerTube 3.3.
+	Name        string    `json:"name"`
+	PublishedAt time.Time `json:"publishedAt"`
+	Duration    int       `json:"duration"` // In seconds.
+	Views       int64     `json:"views"`
+}
+
+// ID returns the identifier of a video used in the watch URLs and the subtitles file names, i.e. its short UUID when
+// the instance provides it.
+func (ptv PeerTubeVideo) ID() string {
+	if ptv.ShortUUID != "" {
+		return ptv.ShortUUID
+	}
+	return ptv.UUID
+}
+
+// PeerTubeCaption is a caption file of a PeerTube video.
+type PeerTubeCaption struct {
+	Language struct {
+		ID    string `json:"id"` // Language code, e.g. en or fr.
+		Label string `json:"label"`
+	} `json:"language"`
+	CaptionPath string `json:"captionPath"` // Path of the file on the instance, replaced by FileURL since PeerTube 6.
+	FileURL     string `json:"fileUrl"`
+}
+
+// peertubeVideosPageSize is the number of videos requested per page, the maximum allowed by PeerTube.
+const peertubeVideosPageSize = 100
+
+func (pc PeerTubeClient) withDefaults() PeerTubeClient {
+	if pc.HTTP == nil {
+		pc.HTTP = http.DefaultClient
+	}
+	pc.Instance = strings.TrimSuffix(pc.Instance, "/")
+	return pc
+}
+
+// get fetches a resource of the instance and decodes it as JSON, or returns its body as is when result is nil.
+func (pc PeerTubeClient) get(ctx context.Context, location string, result interface{}) ([]byte, error) {
+	if pc.Instance == "" {
+		return nil, fmt.Errorf("%w: the URL of the PeerTube instance is needed", ErrInvalidOption)
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := pc.HTTP.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", location, response.Status)
+	}
+	raw, err := ioutil.ReadAll(response.Body)
+	if err != nil || result == nil {
+		return raw, err
+	}
+	if err := json.Unmarshal(raw, result); err != nil {
+		return nil, fmt.Errorf("%w: GET %s: %v", ErrBadFormat, location, err)
+	}
+	return raw, nil
+}
+
+// ChannelVideos returns all the videos of a channel, given by its handle (e.g. name or name@instance), from the most
+// recent.
+func (pc PeerTubeClient) ChannelVideos(ctx context.Context, channel string) ([]PeerTubeVideo, error) {
+	pc = pc.withDefaults()
+	result := []PeerTubeVideo{}
+	for {
+		var page struct {
+			Total int             `json:"total"`
+			Data  []PeerTubeVideo `json:"data"`
+		}
+		location := fmt.Sprintf("%s/api/v1/video-channels/%s/videos?sort=-publishedAt&start=%d&count=%d",
+			pc.Instance, url.PathEscape(channel), len(result), peertubeVideosPageSize)
+		if _, err := pc.get(ctx, location, &page); err != nil {
+			return result, err
+		}
+		result = append(result, page.Data...)
+		if len(page.Data) == 0 || len(result) >= page.Total {
+			return result, nil
+		}
+	}
+}
+
+// Captions returns the caption files of a video, given by its UUID or short UUID.
+func (pc PeerTubeClient) Captions(ctx context.Context, id string) ([]PeerTubeCaption, error) {
+	pc = pc.withDefaults()
+	var captions struct {
+		Data []PeerTubeCaption `json:"data"`
+	}
+	_, err := pc.get(ctx, pc.Instance+"/api/v1/videos/"+url.PathEscape(id)+"/captions", &captions)
+	return captions.Data, err
+}
+
+// DownloadChannelCaptions downloads the captions of the videos of a channel in the given languages, named like
+// youtube-dl does it so that they can be indexed, i.e. <id>.<lang>.vtt with the ID given by PeerTubeVideo.ID, and
+// returns the files written.
+// The title, publication date, duration and views of the videos are written alongside as .info.json files.
+// All the languages are downloaded when langs is empty. The captions that were already downloaded are skipped, and the
+// failures are reported without stopping the download of the other videos.
+// progress, if not nil, is called after each video.
+func (pc PeerTubeClient) DownloadChannelCaptions(ctx context.Context, channel, folder string, langs []string, progress Progress) ([]string, error) {
+	pc = pc.withDefaults()
+	videos, err := pc.ChannelVideos(ctx, channel)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		return nil, err
+	}
+
+	result := []string{}
+	progress.report(0, len(videos))
+	for i, video := range videos {
+		captions, err := pc.Captions(ctx, video.UUID)
+		if err != nil {
+			if ctx.Err() != nil {
+				return result, ctx.Err()
+			}
+			Log.Warnf("skipping %s: %v", video.ID(), err)
+		}
+		for _, caption := range captions {
+			if !wantedLanguage(caption.Language.ID, langs) {
+				continue
+			}
+			filename := filepath.Join(folder, video.ID()+"."+caption.Language.ID+".vtt")
+			if _, err := os.Stat(filename); err == nil {
+				Log.Debugf("%s is already downloaded", filename)
+				continue
+			}
+			if err := pc.downloadCaption(ctx, caption, filename); err != nil {
+				if ctx.Err() != nil {
+					return result, ctx.Err()
+				}
+				Log.Warnf("downloading %s: %v", filename, err)
+				continue
+			}
+			if err := writePeerTubeInfo(filepath.Join(folder, video.ID()+".info.json"), video); err != nil {
+				Log.Warnf("writing the metadata of %s: %v", video.ID(), err)
+			}
+			Log.Debugf("downloaded %s", filename)
+			result = append(result, filename)
+		}
+		progress.report(i+1, len(videos))
+	}
+	return result, nil
+}
+
+// downloadCaption downloads a caption file, which is only created once the download is complete.
+func (pc PeerTubeClient) downloadCaption(ctx context.Context, caption PeerTubeCaption, filename string) error {
+	location := caption.FileURL
+	if location == "" {
+		location = pc.Instance + caption.CaptionPath
+	}
+	raw, err := pc.get(ctx, location, nil)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filename+".part", raw, 0644); err != nil {
+		return err
+	}
+	return os.Rename(filename+".part", filename)
+}
+
+// writePeerTubeInfo writes the metadata of a video as a .info.json file, in the format of youtube-dl.
+func writePeerTubeInfo(filename string, video PeerTubeVideo) error {
+	info := infoFile{Title: video.Name, Duration: float64(video.Duration), ViewCount: video.Views}
+	if !video.PublishedAt.IsZero() {
+		info.UploadDate = video.PublishedAt.UTC().Format("20060102")
+	}
+	raw, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, raw, 0644)
+}
@@ -0,0 +1,79 @@
+package sininen
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// playlistTitle is the title of a segment within a playlist.
+func playlistTitle(segment ScoredSegment) string {
+	return fmt.Sprintf("%s %s: %s", segment.DisplayName(), FormatTimestamp(segment.StartTime), strings.ReplaceAll(segment.Text, "\n", " "))
+}
+
+// WriteM3U writes scored segments as an extended M3U playlist, so that the matching moments can be played back to back.
+// Start and stop times are given as VLC options, the URL builder must therefore produce playable locations.
+func WriteM3U(w io.Writer, segments []ScoredSegment, urls URLBuilder) error {
+	if _, err := fmt.Fprintln(w, "#EXTM3U"); err != nil {
+		return err
+	}
+	for _, segment := range segments {
+		_, err := fmt.Fprintf(w, "#EXTINF:%v,%s\n#EXTVLCOPT:start-time=%s\n#EXTVLCOPT:stop-time=%s\n%s\n",
+			int((segment.EndTime - segment.StartTime).Seconds()), playlistTitle(segment),
+			formatSeconds(segment.StartTime), formatSeconds(segment.EndTime),
+			urls.URL(segment.ID, segment.StartTime))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type xspfExtension struct {
+	Application string   `xml:"application,attr"`
+	Options     []string `xml:"vlc:option"`
+}
+
+type xspfTrack struct {
+	Location  string        `xml:"location"`
+	Title     string        `xml:"title"`
+	Duration  int64         `xml:"duration"` // In milliseconds.
+	Extension xspfExtension `xml:"extension"`
+}
+
+type xspfPlaylist struct {
+	XMLName xml.Name    `xml:"playlist"`
+	Version string      `xml:"version,attr"`
+	XMLNS   string      `xml:"xmlns,attr"`
+	VLCNS   string      `xml:"xmlns:vlc,attr"`
+	Title   string      `xml:"title"`
+	Tracks  []xspfTrack `xml:"trackList>track"`
+}
+
+// WriteXSPF writes scored segments as an XSPF playlist, so that the matching moments can be played back to back.
+// Start and stop times are given as VLC options, the URL builder must therefore produce playable locations.
+func WriteXSPF(w io.Writer, title string, segments []ScoredSegment, urls URLBuilder) error {
+	playlist := xspfPlaylist{
+		Version: "1",
+		XMLNS:   "http://xspf.org/ns/0/",
+		VLCNS:   "http://www.videolan.org/vlc/playlist/ns/0/",
+		Title:   title,
+	}
+	for _, segment := range segments {
+		playlist.Tracks = append(playlist.Tracks, xspfTrack{
+			Location: urls.URL(segment.ID, segment.StartTime),
+			Title:    playlistTitle(segment),
+			Duration: (segment.EndTime - segment.StartTime).Milliseconds(),
+			Extension: xspfExtension{
+				Application: "http://www.videolan.org/vlc/playlist/0",
+				Options: []string{
+					"start-time=" + formatSeconds(segment.StartTime),
+					"stop-time=" + formatSeconds(segment.EndTime),
+				},
+			},
+		})
+	}
+
+	return writeXML(w, playlist)
+}
@@ -0,0 +1,320 @@
+package sininen
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/asticode/go-astisub"
+)
+
+// PodcastFeed is the subset of a podcast RSS feed that is relevant to sininen.
+type PodcastFeed struct {
+	Title    string
+	Language string // Language of the feed, e.g. en-us, empty when unknown.
+	Episodes []PodcastEpisode
+}
+
+// PodcastEpisode is an episode of a podcast feed.
+type PodcastEpisode struct {
+	GUID        string
+	Title       string
+	Published   time.Time     // Zero when unknown.
+	Duration    time.Duration // Zero when unknown.
+	Audio       string        // URL of the audio file.
+	Transcripts []PodcastTranscript
+}
+
+// PodcastTranscript is a transcript linked by an episode through the podcast namespace, see
+// https://github.com/Podcastindex-org/podcast-namespace/blob/main/transcripts/transcripts.md.
+type PodcastTranscript struct {
+	URL      string
+	Type     string // MIME type, e.g. text/vtt.
+	Language string // Empty when it is the language of the feed.
+}
+
+// rssFeed is the XML representation of a podcast RSS feed.
+type rssFeed struct {
+	Channel struct {
+		Title    string `xml:"title"`
+		Language string `xml:"language"`
+		Items    []struct {
+			GUID      string `xml:"guid"`
+			Title     string `xml:"title"`
+			PubDate   string `xml:"pubDate"`
+			Duration  string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
+			Enclosure struct {
+				URL string `xml:"url,attr"`
+			} `xml:"enclosure"`
+			Transcripts []struct {
+				URL      string `xml:"url,attr"`
+				Type     string `xml:"type,attr"`
+				Language string `xml:"language,attr"`
+			} `xml:"https://podcastindex.org/namespace/1.0 transcript"`
+		} `xml:"item"`
+	} `xml:"channel"`
+}
+
+// ParsePodcastFeed reads a podcast RSS feed.
+// The episodes without GUID are identified by the URL of their audio file, as podcast players do.
+func ParsePodcastFeed(r io.Reader) (*PodcastFeed, error) {
+	var feed rssFeed
+	if err := xml.NewDecoder(r).Decode(&feed); err != nil {
+		return nil, fmt.Errorf("%w: podcast feed: %v", ErrBadFormat, err)
+	}
+	result := &PodcastFeed{Title: feed.Channel.Title, Language: strings.TrimSpace(feed.Channel.Language)}
+	for _, item := range feed.Channel.Items {
+		episode := PodcastEpisode{GUID: strings.TrimSpace(item.GUID), Title: item.Title, Audio: item.Enclosure.URL}
+		if episode.GUID == "" {
+			episode.GUID = episode.Audio
+		}
+		if episode.GUID == "" {
+			Log.Warnf("skipping the episode %q, which has neither GUID nor audio file", item.Title)
+			continue
+		}
+		if item.PubDate != "" {
+			published, err := parseRSSDate(strings.TrimSpace(item.PubDate))
+			if err != nil {
+				Log.Warnf("ignoring the publication date of %s: %v", episode.GUID, err)
+			}
+			episode.Published = published
+		}
+		if item.Duration != "" {
+			duration, err := ParseTimestamp(strings.TrimSpace(item.Duration))
+			if err != nil {
+				Log.Warnf("ignoring the duration of %s: %v", episode.GUID, err)
+			}
+			episode.Duration = duration
+		}
+		for _, transcript := range item.Transcripts {
+			episode.Transcripts = append(episode.Transcripts, PodcastTranscript(transcript))
+		}
+		result.Episodes = append(result.Episodes, episode)
+	}
+	return result, nil
+}
+
+// parseRSSDate parses the dates of RSS feeds, which follow RFC 822 more or less closely.
+func parseRSSDate(date string) (time.Time, error) {
+	for _, layout := range []string{time.RFC1123Z, time.RFC1123, "Mon, 2 Jan 2006 15:04:05 -0700", "Mon, 2 Jan 2006 15:04:05 MST", time.RFC3339} {
+		if result, err := time.Parse(layout, date); err == nil {
+			return result, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%w: malformed date %q", ErrBadFormat, date)
+}
+
+// safeIDPattern matches the GUIDs that can be used as is in the names of the subtitles files.
+var safeIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// EpisodeID returns the ID of the document of an episode, i.e. its GUID when it can be part of a file name, and its
+// base64url encoding prefixed by b64_ otherwise, e.g. for GUIDs that are URLs.
+func EpisodeID(guid string) string {
+	if safeIDPattern.MatchString(guid) && !strings.HasPrefix(guid, "b64_") {
+		return guid
+	}
+	return "b64_" + base64.RawURLEncoding.EncodeToString([]byte(guid))
+}
+
+// transcriptExtensions associates the MIME types of the supported transcripts to the extension of their files, by
+// order of preference.
+var transcriptExtensions = []struct {
+	mimeType  string
+	extension string
+}{
+	{"text/vtt", ".vtt"},
+	{"application/x-subrip", ".srt"},
+	{"application/srt", ".srt"},
+	{"application/json", ".json"},
+}
+
+// bestTranscript returns the preferred transcript of an episode in a language, along with the extension of its file.
+// The transcripts without language are assumed to be in the language of the feed.
+func bestTranscript(episode PodcastEpisode, feedLang, lang string) (PodcastTranscript, string, bool) {
+	for _, supported := range transcriptExtensions {
+		for _, transcript := range episode.Transcripts {
+			transcriptLang := transcript.Language
+			if transcriptLang == "" {
+				transcriptLang = feedLang
+			}
+			if transcriptLang != "" && !strings.EqualFold(baseLanguage(transcriptLang), lang) {
+				continue
+			}
+			mimeType := strings.TrimSpace(strings.SplitN(transcript.Type, ";", 2)[0])
+			if strings.EqualFold(mimeType, supported.mimeType) {
+				return transcript, supported.extension, true
+			}
+		}
+	}
+	return PodcastTranscript{}, "", false
+}
+
+// baseLanguage returns the language of a language tag such as en-US, i.e. en.
+func baseLanguage(tag string) string {
+	return strings.ToLower(strings.SplitN(tag, "-", 2)[0])
+}
+
+// FeedLanguage returns the language code of a feed as used in subtitle file names, e.g. en for en-us, or an empty
+// string when the feed does not tell it.
+func (pf PodcastFeed) FeedLanguage() string {
+	if pf.Language == "" {
+		return ""
+	}
+	return baseLanguage(pf.Language)
+}
+
+// PodcastClient downloads podcast feeds and the transcripts of their episodes.
+// The zero value is a sensible default.
+type PodcastClient struct {
+	HTTP *http.Client // http.DefaultClient when nil.
+}
+
+// get fetches the body of a URL, failing on any status other than 200.
+func (pc PodcastClient) get(ctx context.Context, location string) ([]byte, error) {
+	client := pc.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", location, response.Status)
+	}
+	return ioutil.ReadAll(response.Body)
+}
+
+// FetchFeed downloads and parses a podcast feed.
+func (pc PodcastClient) FetchFeed(ctx context.Context, feedURL string) (*PodcastFeed, error) {
+	raw, err := pc.get(ctx, feedURL)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePodcastFeed(bytes.NewReader(raw))
+}
+
+// DownloadTranscripts downloads the transcripts of the episodes of a feed in a language, named like the subtitles of
+// videos so that they can be indexed, i.e. <id>.<lang>.<ext> with the ID given by EpisodeID, and returns the number of
+// transcripts written.
+// The title, publication date and duration of the episodes are written alongside as .info.json files. JSON transcripts
+// are converted to WebVTT, and the transcripts already downloaded are skipped. The failures are reported without
+// stopping the download of the other episodes.
+// progress, if not nil, is called after each episode.
+func (pc PodcastClient) DownloadTranscripts(ctx context.Context, feed *PodcastFeed, folder, lang string, progress Progress) (int, error) {
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		return 0, err
+	}
+	written := 0
+	progress.report(0, len(feed.Episodes))
+	for i, episode := range feed.Episodes {
+		transcript, extension, found := bestTranscript(episode, feed.Language, lang)
+		if !found {
+			Log.Debugf("no %s transcript for %s", lang, episode.GUID)
+			progress.report(i+1, len(feed.Episodes))
+			continue
+		}
+		id := EpisodeID(episode.GUID)
+		filename := filepath.Join(folder, id+"."+lang+extension)
+		if extension == ".json" {
+			filename = filepath.Join(folder, id+"."+lang+".vtt")
+		}
+		if _, err := os.Stat(filename); err == nil {
+			Log.Debugf("%s is already downloaded", filename)
+			progress.report(i+1, len(feed.Episodes))
+			continue
+		}
+		if err := pc.downloadTranscript(ctx, transcript, extension, filename); err != nil {
+			if ctx.Err() != nil {
+				return written, ctx.Err()
+			}
+			Log.Warnf("downloading the transcript of %s: %v", episode.GUID, err)
+			progress.report(i+1, len(feed.Episodes))
+			continue
+		}
+		if err := writeEpisodeInfo(filepath.Join(folder, id+".info.json"), episode); err != nil {
+			Log.Warnf("writing the metadata of %s: %v", episode.GUID, err)
+		}
+		Log.Debugf("downloaded the transcript of %s to %s", episode.GUID, filename)
+		written++
+		progress.report(i+1, len(feed.Episodes))
+	}
+	return written, nil
+}
+
+// downloadTranscript downloads a transcript to a file, which is only created once the download is complete.
+func (pc PodcastClient) downloadTranscript(ctx context.Context, transcript PodcastTranscript, extension, filename string) error {
+	raw, err := pc.get(ctx, transcript.URL)
+	if err != nil {
+		return err
+	}
+	if extension == ".json" {
+		if raw, err = jsonTranscriptToWebVTT(raw); err != nil {
+			return err
+		}
+	}
+	if err := ioutil.WriteFile(filename+".part", raw, 0644); err != nil {
+		return err
+	}
+	return os.Rename(filename+".part", filename)
+}
+
+// jsonTranscript is the JSON format of the transcripts of the podcast namespace.
+type jsonTranscript struct {
+	Segments []struct {
+		StartTime float64 `json:"startTime"`
+		EndTime   float64 `json:"endTime"`
+		Body      string  `json:"body"`
+	} `json:"segments"`
+}
+
+// jsonTranscriptToWebVTT converts a JSON transcript to WebVTT.
+func jsonTranscriptToWebVTT(raw []byte) ([]byte, error) {
+	var transcript jsonTranscript
+	if err := json.Unmarshal(raw, &transcript); err != nil {
+		return nil, fmt.Errorf("%w: JSON transcript: %v", ErrBadFormat, err)
+	}
+	subtitles := astisub.NewSubtitles()
+	for _, segment := range transcript.Segments {
+		subtitles.Items = append(subtitles.Items, &astisub.Item{
+			StartAt: fromSeconds(segment.StartTime),
+			EndAt:   fromSeconds(segment.EndTime),
+			Lines:   []astisub.Line{{Items: []astisub.LineItem{{Text: strings.TrimSpace(segment.Body)}}}},
+		})
+	}
+	if len(subtitles.Items) == 0 {
+		return nil, fmt.Errorf("%w: JSON transcript without segments", ErrEmptyTranscript)
+	}
+	var result bytes.Buffer
+	err := subtitles.WriteToWebVTT(&result)
+	return result.Bytes(), err
+}
+
+// writeEpisodeInfo writes the metadata of an episode as a .info.json file, in the format of youtube-dl.
+func writeEpisodeInfo(filename string, episode PodcastEpisode) error {
+	info := infoFile{Title: episode.Title, Duration: episode.Duration.Seconds()}
+	if !episode.Published.IsZero() {
+		info.UploadDate = episode.Published.UTC().Format("20060102")
+	}
+	raw, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, raw, 0644)
+}
@@ -0,0 +1,63 @@
+//go:build !js
+// +build !js
+
+package sininen
+
+import (
+	"github.com/blevesearch/bleve/v2"
+)
+
+// overlaps tells whether a segment hit shares some time with at least one of the given segments.
+func overlaps(hit SegmentHit, segments []SegmentHit) bool {
+	for _, segment := range segments {
+		if hit.StartTime <= segment.EndTime && segment.StartTime <= hit.EndTime {
+			return true
+		}
+	}
+	return false
+}
+
+// Refine applies a follow-up query to the transcriptions of a search result sequence.
+// Only the segments overlapping with the segments of the sequence are kept, so that successive refinements narrow down
+// the results.
+func (srs SearchResultSequence) Refine(query string, index bleve.Index, options AssemblyOptions) (SearchResultSequence, error) {
+	if len(srs) == 0 {
+		return SearchResultSequence{}, nil
+	}
+
+	previous := make(map[string][]SegmentHit, len(srs))
+	ids := make([]string, 0, len(srs))
+	for _, sr := range srs {
+		previous[sr.ID] = sr.Segments
+		ids = append(ids, sr.ID)
+	}
+
+	request := newTranscriptionRequest(bleve.NewConjunctionQuery(bleve.NewMatchQuery(query), bleve.NewDocIDQuery(ids)))
+	request.Size = len(ids)
+	raw, err := index.Search(request)
+	if err != nil {
+		return nil, err
+	}
+	// Capped once filtered, so that the overlapping segments are not left out for the ones filtered out.
+	uncapped := options
+	uncapped.MaxSegmentsPerVideo, uncapped.FirstOccurrenceOnly = 0, false
+	refined, err := AssembleSearchResults(raw, uncapped)
+	if err != nil {
+		return nil, err
+	}
+
+	result := SearchResultSequence{}
+	for _, sr := range refined {
+		kept := []SegmentHit{}
+		for _, segment := range sr.Segments {
+			if overlaps(segment, previous[sr.ID]) {
+				kept = append(kept, segment)
+			}
+		}
+		if len(kept) > 0 {
+			sr.Segments = capSegmentHits(kept, options)
+			result = append(result, sr)
+		}
+	}
+	return result, nil
+}
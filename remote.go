@@ -0,0 +1,162 @@
+package sininen
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RemoteSearcher searches the indexes of a sininen server, i.e. search-yt serve, through its /search endpoint, so that
+// the programs searching a Searcher work the same with the local indexes and the remote ones.
+// The HTTP API is used rather than the gRPC one because its segments include the highlights and the context.
+// Only URL is mandatory.
+type RemoteSearcher struct {
+	URL      string       // Base URL of the server, e.g. http://localhost:8080.
+	Channels []string     // Channels searched, every channel of the server when empty.
+	Langs    []string     // Languages searched, the default languages of the server when empty.
+	APIKey   string       // Key of the servers requiring one, none by default.
+	HTTP     *http.Client // http.DefaultClient when nil.
+}
+
+// Search makes a plain text search through the remote indexes and assembles the segments answered by the server into
+// search results, like BleveBackend.Search.
+// Every page of the segments is fetched, but the server may retrieve fewer than size transcriptions when it limits the
+// searches. When options has both a Context and a ContextDuration, only the duration is used by the server.
+func (rs RemoteSearcher) Search(query string, size int, options AssemblyOptions) (SearchResultSequence, error) {
+	return rs.SearchContext(context.Background(), query, size, options)
+}
+
+// SearchContext is Search with a context cancelling the requests.
+func (rs RemoteSearcher) SearchContext(ctx context.Context, query string, size int, options AssemblyOptions) (SearchResultSequence, error) {
+	if rs.URL == "" {
+		return nil, fmt.Errorf("%w: the URL of the server is needed", ErrInvalidOption)
+	}
+	if size <= 0 {
+		size = 10
+	}
+	parameters := url.Values{
+		"q":          {query},
+		"format":     {"json"},
+		"max-videos": {strconv.Itoa(size)},
+		"context":    {strconv.Itoa(options.Context)},
+		"first":      {strconv.FormatBool(options.FirstOccurrenceOnly)},
+		"ranking":    {"distinct"}, // So that the scores of the transcriptions can be recovered from the ones of the segments.
+		"half-life":  {"0"},
+		"normalize":  {"false"},
+	}
+	if options.ContextDuration > 0 {
+		parameters.Set("context", options.ContextDuration.String())
+	}
+	if options.MaxSegmentsPerVideo > 0 {
+		parameters.Set("max-per-video", strconv.Itoa(options.MaxSegmentsPerVideo))
+	}
+	if len(rs.Channels) == 0 {
+		parameters.Set("all", "true")
+	}
+	for _, channelName := range rs.Channels {
+		parameters.Add("channel", channelName)
+	}
+	for _, lang := range rs.Langs {
+		parameters.Add("lang", lang)
+	}
+
+	segments := []ScoredSegment{}
+	seen := map[string]bool{} // Cursors already followed, so that a server giving one again does not page forever.
+	for {
+		page, next, err := rs.fetch(ctx, parameters)
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments, page...)
+		if next == "" {
+			break
+		}
+		if seen[next] {
+			Log.Warnf("sininen server: the cursor %s was already given, stopping after %d segments", next, len(segments))
+			break
+		}
+		seen[next] = true
+		parameters.Set("cursor", next)
+	}
+	return groupSegments(segments), nil
+}
+
+// fetch returns a page of the segments of a search, along with the cursor of the next page, empty for the last one.
+func (rs RemoteSearcher) fetch(ctx context.Context, parameters url.Values) ([]ScoredSegment, string, error) {
+	client := rs.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		strings.TrimSuffix(rs.URL, "/")+"/search?"+parameters.Encode(), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if rs.APIKey != "" {
+		request.Header.Set("Authorization", "Bearer "+rs.APIKey)
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, "", err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		var failure struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(response.Body).Decode(&failure) != nil || failure.Error == "" {
+			failure.Error = response.Status
+		}
+		if response.StatusCode == http.StatusBadRequest {
+			message := strings.TrimPrefix(failure.Error, ErrInvalidOption.Error()+": ") // Wrapped again below.
+			return nil, "", fmt.Errorf("%w: sininen server: %s", ErrInvalidOption, message)
+		}
+		return nil, "", fmt.Errorf("sininen server: %s", failure.Error)
+	}
+	var segments []ScoredSegment
+	if err := json.NewDecoder(response.Body).Decode(&segments); err != nil {
+		return nil, "", fmt.Errorf("%w: sininen server response: %v", ErrBadFormat, err)
+	}
+	return segments, response.Header.Get("X-Next-Cursor"), nil
+}
+
+// groupSegments assembles segments ranked with DistinctTermsRanking back into the search results of their videos,
+// sorted by score.
+func groupSegments(segments []ScoredSegment) SearchResultSequence {
+	result := SearchResultSequence{}
+	positions := map[string]int{}
+	for _, segment := range segments {
+		i, exists := positions[segment.ID]
+		if !exists {
+			i = len(result)
+			positions[segment.ID] = i
+			score := segment.Score
+			if distinct := segment.NDistinctTerms(); distinct > 0 {
+				score /= float64(distinct) // See DistinctTermsRanking.
+			}
+			result = append(result, SearchResult{
+				ID:             segment.ID,
+				Title:          segment.Title,
+				Score:          score,
+				UploadDate:     segment.UploadDate,
+				Duration:       segment.VideoDuration,
+				ViewCount:      segment.ViewCount,
+				TranslatedFrom: segment.TranslatedFrom,
+			})
+		}
+		result[i].Segments = append(result[i].Segments, segment.SegmentHit) // Already sorted like AssembleSearchResults.
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Score > result[j].Score })
+	return result
+}
+
+// Close does nothing, the server holding the indexes.
+func (rs RemoteSearcher) Close() error {
+	return nil
+}
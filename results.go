@@ -0,0 +1,277 @@
+package sininen
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+////////////////////////////////////
+// Search results data structures //
+////////////////////////////////////
+
+// SegmentExcerpt is the text of a transcription segment along with its timing.
+type SegmentExcerpt struct {
+	StartTime time.Duration `json:"start_time"`
+	EndTime   time.Duration `json:"end_time"`
+	Text      string        `json:"text"`
+}
+
+// SegmentHit represents a transcription segment that matched with a search query.
+type SegmentHit struct {
+	StartTime   time.Duration    `json:"start_time"`
+	EndTime     time.Duration    `json:"end_time"`
+	Text        string           `json:"text"`                 // Text of the segment, empty when the transcription text is not stored.
+	SortedTerms []string         `json:"sorted_terms"`         // Terms in the segment that matched with the search query, sorted in increasing order.
+	Highlights  []TextSpan       `json:"highlights,omitempty"` // Positions of the matched terms within Text, sorted by start position.
+	Before      []SegmentExcerpt `json:"before,omitempty"`     // Segments immediately preceding the hit, in chronological order.
+	After       []SegmentExcerpt `json:"after,omitempty"`      // Segments immediately following the hit, in chronological order.
+	Chapter     string           `json:"chapter,omitempty"`    // Title of the chapter of the video the hit falls in, see TagChapters.
+}
+
+// TextSpan is the position of a part of a text, in bytes.
+type TextSpan struct {
+	Start int `json:"start"`
+	End   int `json:"end"` // Exclusive.
+}
+
+// TextFragment is a part of a text that either matched with a search query or not.
+type TextFragment struct {
+	Text    string
+	Matched bool
+}
+
+// Fragments splits the text of the segment into matched and unmatched fragments, overlapping highlights being merged.
+func (sh SegmentHit) Fragments() []TextFragment {
+	result := []TextFragment{}
+	pos := 0
+	for i := 0; i < len(sh.Highlights); i++ {
+		start, end := sh.Highlights[i].Start, sh.Highlights[i].End
+		for i+1 < len(sh.Highlights) && sh.Highlights[i+1].Start <= end {
+			i++
+			if sh.Highlights[i].End > end {
+				end = sh.Highlights[i].End
+			}
+		}
+		if start < pos || end > len(sh.Text) {
+			continue // Inconsistent highlight, should not happen.
+		}
+		if start > pos {
+			result = append(result, TextFragment{sh.Text[pos:start], false})
+		}
+		result = append(result, TextFragment{sh.Text[start:end], true})
+		pos = end
+	}
+	if pos < len(sh.Text) {
+		result = append(result, TextFragment{sh.Text[pos:], false})
+	}
+	return result
+}
+
+// HighlightedText returns the text of the segment with its matched fragments transformed by highlight.
+func (sh SegmentHit) HighlightedText(highlight func(string) string) string {
+	var result strings.Builder
+	for _, fragment := range sh.Fragments() {
+		if fragment.Matched {
+			result.WriteString(highlight(fragment.Text))
+		} else {
+			result.WriteString(fragment.Text)
+		}
+	}
+	return result.String()
+}
+
+// NDistinctTerms returns the number of distinct terms in the segment that matched with the search query.
+func (sh SegmentHit) NDistinctTerms() int {
+	result := 0
+	var last string
+	for _, term := range sh.SortedTerms {
+		if term != last {
+			last = term
+			result++
+		}
+	}
+	return result
+}
+
+// NTotalTerms returns the number of occurrences of the terms in the segment that matched with the search query.
+func (sh SegmentHit) NTotalTerms() int {
+	return len(sh.SortedTerms)
+}
+
+// TermCounts returns the number of occurrences of each term in the segment that matched with the search query.
+func (sh SegmentHit) TermCounts() map[string]int {
+	result := make(map[string]int, len(sh.SortedTerms))
+	for _, term := range sh.SortedTerms {
+		result[term]++
+	}
+	return result
+}
+
+// SearchResult represents a transcription file that matched with a search query.
+type SearchResult struct {
+	ID         string
+	Title      string // Empty when unknown.
+	Score      float64
+	UploadDate time.Time     // Zero when unknown.
+	Duration   time.Duration // Duration of the video, zero when unknown.
+	ViewCount  int64         // Zero when unknown.
+	Segments   []SegmentHit  // Segments that matched with the search query.
+
+	TranslatedFrom string // Original language of the subtitles when they are a machine translation, empty otherwise.
+}
+
+// SearchResultSequence represents a sequence of transcription files that matched with a search query.
+type SearchResultSequence []SearchResult
+
+func (srs SearchResultSequence) lenSegments() int {
+	result := 0
+	for _, sr := range srs {
+		result += len(sr.Segments)
+	}
+	return result
+}
+
+// ScoredSegment is a SegmentHit with its score and its transcription ID.
+type ScoredSegment struct {
+	SegmentHit
+	Score  float64 `json:"score"`
+	ID     string  `json:"id"`
+	Title  string  `json:"title,omitempty"`  // Title of the video, empty when unknown.
+	Author string  `json:"author,omitempty"` // Name of the channel of the video, only known when resolved by AddOEmbedTitles.
+
+	UploadDate    time.Time     `json:"upload_date,omitempty"`    // Zero when unknown.
+	VideoDuration time.Duration `json:"video_duration,omitempty"` // Zero when unknown.
+	ViewCount     int64         `json:"view_count,omitempty"`     // Zero when unknown.
+	Thumbnail     string        `json:"thumbnail,omitempty"`      // URL of a preview image of the moment, see AddThumbnails.
+
+	TranslatedFrom string `json:"translated_from,omitempty"` // Original language of the machine-translated subtitles.
+	Sponsor        string `json:"sponsor,omitempty"`         // Category of the sponsor segment the hit falls inside, see ApplySponsorSegments.
+}
+
+// DisplayName returns the title of the video of the segment, or its ID when the title is unknown.
+func (ss ScoredSegment) DisplayName() string {
+	if ss.Title != "" {
+		return ss.Title
+	}
+	return ss.ID
+}
+
+// RankingStrategy computes the score of a segment that matched within a transcription.
+type RankingStrategy func(sr SearchResult, segment SegmentHit) float64
+
+// DistinctTermsRanking scores a segment by multiplying the transcription score by the number of distinct matching terms.
+func DistinctTermsRanking(sr SearchResult, segment SegmentHit) float64 {
+	return sr.Score * float64(segment.NDistinctTerms())
+}
+
+// TotalTermsRanking scores a segment by multiplying the transcription score by the number of occurrences of the matching
+// terms, thus favouring segments repeating the terms.
+func TotalTermsRanking(sr SearchResult, segment SegmentHit) float64 {
+	return sr.Score * float64(segment.NTotalTerms())
+}
+
+// NamedRanking returns the ranking strategy with the given name, either distinct (DistinctTermsRanking) or total
+// (TotalTermsRanking).
+func NamedRanking(name string) (RankingStrategy, error) {
+	switch name {
+	case "", "distinct":
+		return DistinctTermsRanking, nil
+	case "total":
+		return TotalTermsRanking, nil
+	}
+	return nil, fmt.Errorf("%w: unknown ranking strategy %q", ErrInvalidOption, name)
+}
+
+// RecencyRanking decays the scores of a base strategy according to the age of the videos at the time now.
+// The score is halved every halfLife, transcriptions with an unknown upload date are not decayed.
+func RecencyRanking(base RankingStrategy, halfLife time.Duration, now time.Time) RankingStrategy {
+	return func(sr SearchResult, segment SegmentHit) float64 {
+		score := base(sr, segment)
+		if sr.UploadDate.IsZero() || halfLife <= 0 {
+			return score
+		}
+		age := now.Sub(sr.UploadDate)
+		if age < 0 {
+			age = 0
+		}
+		return score * math.Pow(0.5, float64(age)/float64(halfLife))
+	}
+}
+
+// ScoredSegments flattens a search results hierarchy by returning the scored segments, sorted by score.
+// The segments are scored using DistinctTermsRanking.
+func (srs SearchResultSequence) ScoredSegments() []ScoredSegment {
+	return srs.RankedSegments(DistinctTermsRanking)
+}
+
+// RankedSegments flattens a search results hierarchy by returning the segments scored by the given strategy, sorted by score.
+func (srs SearchResultSequence) RankedSegments(rank RankingStrategy) []ScoredSegment {
+	result := make([]ScoredSegment, 0, srs.lenSegments())
+	for _, sr := range srs {
+		for _, segment := range sr.Segments {
+			result = append(result, ScoredSegment{
+				SegmentHit: segment,
+				Score:      rank(sr, segment),
+				ID:         sr.ID,
+				Title:      sr.Title,
+
+				UploadDate:    sr.UploadDate,
+				VideoDuration: sr.Duration,
+				ViewCount:     sr.ViewCount,
+
+				TranslatedFrom: sr.TranslatedFrom,
+			})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return rankedBefore(result[i].Score, result[i].ID, result[i].StartTime, result[j])
+	})
+	return result
+}
+
+// NormalizeScores rescales the scores of segments relative to the best one, which gets a score of 1.
+// This makes the scores comparable across different queries.
+// The given slice is left untouched.
+func NormalizeScores(segments []ScoredSegment) []ScoredSegment {
+	result := make([]ScoredSegment, len(segments))
+	copy(result, segments)
+	best := 0.
+	for _, segment := range result {
+		if segment.Score > best {
+			best = segment.Score
+		}
+	}
+	if best <= 0 {
+		return result
+	}
+	for i := range result {
+		result[i].Score /= best
+	}
+	return result
+}
+
+// PaginateSegments returns at most limit segments, starting from offset.
+// There is no limit when limit is not positive.
+func PaginateSegments(segments []ScoredSegment, offset, limit int) []ScoredSegment {
+	if offset >= len(segments) {
+		return []ScoredSegment{}
+	}
+	if offset > 0 {
+		segments = segments[offset:]
+	}
+	if limit > 0 && limit < len(segments) {
+		segments = segments[:limit]
+	}
+	return segments
+}
+
+// Searcher is the searching side of a Backend, also implemented by Backends.
+type Searcher interface {
+	// Search makes a plain text search and assembles the results, at most size transcriptions being retrieved (10 when
+	// size is not positive).
+	Search(query string, size int, options AssemblyOptions) (SearchResultSequence, error)
+	Close() error
+}
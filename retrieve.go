@@ -0,0 +1,250 @@
+package sininen
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ContextExcerpt is a contiguous part of a transcript retrieved as context for a query, e.g. to be fed to a language
+// model along with the link citing it.
+type ContextExcerpt struct {
+	ID         string
+	Title      string // Empty when unknown.
+	UploadDate time.Time
+	StartTime  time.Duration
+	EndTime    time.Duration
+	Text       string  // Text of the consecutive segments of the excerpt, joined by spaces.
+	URL        string  // Link to the start of the excerpt.
+	Score      float64 // Best score of the matching segments of the excerpt.
+	Tokens     int     // Estimated number of tokens of the excerpt as written by WriteContext.
+}
+
+// String returns the excerpt as written by WriteContext: a citation line followed by the text.
+func (ce ContextExcerpt) String() string {
+	name := ce.Title
+	if name == "" {
+		name = ce.ID
+	}
+	date := ""
+	if !ce.UploadDate.IsZero() {
+		date = ", " + ce.UploadDate.Format(dateLayout)
+	}
+	return fmt.Sprintf("[%s%s, %s-%s] %s\n%s\n",
+		name, date, FormatTimestamp(ce.StartTime), FormatTimestamp(ce.EndTime), ce.URL, ce.Text)
+}
+
+// jsonContextExcerpt is the JSON representation of a context excerpt, with times in seconds.
+type jsonContextExcerpt struct {
+	ID         string  `json:"id"`
+	Title      string  `json:"title,omitempty"`
+	UploadDate string  `json:"upload_date,omitempty"`
+	StartTime  float64 `json:"start_time"`
+	EndTime    float64 `json:"end_time"`
+	Text       string  `json:"text"`
+	URL        string  `json:"url"`
+	Score      float64 `json:"score"`
+	Tokens     int     `json:"tokens"`
+}
+
+func (ce ContextExcerpt) MarshalJSON() ([]byte, error) {
+	date := ""
+	if !ce.UploadDate.IsZero() {
+		date = ce.UploadDate.Format(dateLayout)
+	}
+	return json.Marshal(jsonContextExcerpt{
+		ce.ID, ce.Title, date, ce.StartTime.Seconds(), ce.EndTime.Seconds(), ce.Text, ce.URL, ce.Score, ce.Tokens,
+	})
+}
+
+// EstimateTokens estimates the number of tokens of a text for the usual language model tokenizers, which average about
+// four characters per token in English.
+func EstimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// RetrievalOptions are the options of RetrieveContext and SelectContext.
+// The zero value is a sensible default.
+type RetrievalOptions struct {
+	URLs        URLBuilder       // Builder of the citation links, YouTube by default.
+	Rank        RankingStrategy  // Ranking of the segments by RetrieveContext, DistinctTermsRanking by default.
+	Context     int              // Segments retrieved before and after each hit by RetrieveContext, 2 by default.
+	MaxVideos   int              // Transcriptions searched by RetrieveContext, 10 by default.
+	CountTokens func(string) int // Counts the tokens of the written excerpts, EstimateTokens by default.
+}
+
+// withDefaults returns the options with their defaults filled in.
+func (ro RetrievalOptions) withDefaults() RetrievalOptions {
+	if ro.URLs == nil {
+		ro.URLs = YouTube{}
+	}
+	if ro.Rank == nil {
+		ro.Rank = DistinctTermsRanking
+	}
+	if ro.Context <= 0 {
+		ro.Context = 2
+	}
+	if ro.MaxVideos <= 0 {
+		ro.MaxVideos = 10
+	}
+	if ro.CountTokens == nil {
+		ro.CountTokens = EstimateTokens
+	}
+	return ro
+}
+
+// RetrieveContext searches a query and returns the excerpts of the transcripts best matching it that fit in a token
+// budget, see SelectContext, for retrieval-augmented generation.
+func RetrieveContext(searcher Searcher, query string, tokenBudget int, options RetrievalOptions) ([]ContextExcerpt, error) {
+	options = options.withDefaults()
+	videos, err := searcher.Search(query, options.MaxVideos, AssemblyOptions{Context: options.Context})
+	if err != nil {
+		return nil, err
+	}
+	return SelectContext(videos.RankedSegments(options.Rank), tokenBudget, options), nil
+}
+
+// contextRun is a run of consecutive segments of a transcript selected by SelectContext, sorted by start time.
+type contextRun struct {
+	segments []SegmentExcerpt
+	score    float64
+	tokens   int // Of the excerpt of the run, set by contextVideo.add.
+}
+
+func (cr contextRun) start() time.Duration { return cr.segments[0].StartTime }
+func (cr contextRun) end() time.Duration   { return cr.segments[len(cr.segments)-1].EndTime }
+
+// merge returns the union of two overlapping runs, the segments starting at the same time being kept once.
+func (cr contextRun) merge(other contextRun) contextRun {
+	segments := append(append([]SegmentExcerpt{}, cr.segments...), other.segments...)
+	sort.SliceStable(segments, func(i, j int) bool { return segments[i].StartTime < segments[j].StartTime })
+	result := contextRun{segments: segments[:0], score: cr.score}
+	if other.score > result.score {
+		result.score = other.score
+	}
+	for _, segment := range segments {
+		if n := len(result.segments); n == 0 || result.segments[n-1].StartTime != segment.StartTime {
+			result.segments = append(result.segments, segment)
+		}
+	}
+	return result
+}
+
+// contextVideo is the video of the runs selected by SelectContext.
+type contextVideo struct {
+	first ScoredSegment // Best segment of the video, holding its metadata.
+	runs  []contextRun  // Sorted by start time, not overlapping.
+}
+
+// add returns the runs of the video with another one, merged with the runs it overlaps, along with the number of tokens
+// it adds to the excerpts of the video, only the merged run being counted again.
+func (cv contextVideo) add(run contextRun, options RetrievalOptions) ([]contextRun, int) {
+	result := make([]contextRun, 0, len(cv.runs)+1)
+	absorbed := 0
+	for _, existing := range cv.runs {
+		if existing.start() <= run.end() && run.start() <= existing.end() {
+			run = run.merge(existing)
+			absorbed += existing.tokens
+		} else {
+			result = append(result, existing)
+		}
+	}
+	run.tokens = cv.excerpt(run, options).Tokens
+	result = append(result, run)
+	sort.Slice(result, func(i, j int) bool { return result[i].start() < result[j].start() })
+	return result, run.tokens - absorbed
+}
+
+// excerpt returns the excerpt of a run of the video.
+func (cv contextVideo) excerpt(run contextRun, options RetrievalOptions) ContextExcerpt {
+	texts := make([]string, len(run.segments))
+	for i, segment := range run.segments {
+		texts[i] = strings.Join(strings.Fields(segment.Text), " ")
+	}
+	cited := cv.first
+	cited.StartTime, cited.EndTime = run.start(), run.end()
+	result := ContextExcerpt{
+		ID: cited.ID, Title: cited.Title, UploadDate: cited.UploadDate, StartTime: run.start(), EndTime: run.end(),
+		Text: strings.Join(texts, " "), URL: SegmentURL(options.URLs, cited), Score: run.score,
+	}
+	result.Tokens = options.CountTokens(result.String())
+	return result
+}
+
+// SelectContext returns the excerpts of the transcripts around ranked segments, e.g. given by RankedSegments, that fit in
+// a token budget.
+// The segments are taken in order along with their Before and After context, the context being dropped when the whole
+// does not fit and the segment skipped when it does not fit alone, until the budget is spent. The overlapping excerpts
+// of a video are merged so that no part of a transcript is repeated.
+// The excerpts are sorted chronologically, by upload date of their video and then by start time. Since the budget
+// includes the citation lines, the excerpts written by WriteContext fit in it, but for the blank lines separating them.
+func SelectContext(segments []ScoredSegment, tokenBudget int, options RetrievalOptions) []ContextExcerpt {
+	options = options.withDefaults()
+	videos := map[string]*contextVideo{}
+	order := []string{}
+	used := 0
+	for _, segment := range segments {
+		if used >= tokenBudget {
+			break
+		}
+		video, ok := videos[segment.ID]
+		if !ok {
+			video = &contextVideo{first: segment}
+		}
+		hit := SegmentExcerpt{segment.StartTime, segment.EndTime, segment.Text}
+		candidates := []contextRun{{segments: []SegmentExcerpt{hit}, score: segment.Score}}
+		if len(segment.Before) > 0 || len(segment.After) > 0 {
+			whole := append(append(append([]SegmentExcerpt{}, segment.Before...), hit), segment.After...)
+			candidates = append([]contextRun{{segments: whole, score: segment.Score}}, candidates...)
+		}
+		for _, candidate := range candidates {
+			if runs, cost := video.add(candidate, options); used+cost <= tokenBudget {
+				used += cost
+				video.runs = runs
+				if !ok {
+					videos[segment.ID] = video
+					order = append(order, segment.ID)
+				}
+				break
+			}
+		}
+	}
+
+	result := []ContextExcerpt{}
+	for _, id := range order {
+		for _, run := range videos[id].runs {
+			result = append(result, videos[id].excerpt(run, options))
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if !result[i].UploadDate.Equal(result[j].UploadDate) {
+			return result[i].UploadDate.Before(result[j].UploadDate)
+		}
+		if result[i].ID != result[j].ID {
+			return result[i].ID < result[j].ID
+		}
+		return result[i].StartTime < result[j].StartTime
+	})
+	return result
+}
+
+// WriteContext writes context excerpts as plain text, each one being a citation line, with the title of the video, its
+// upload date, the times of the excerpt and its link, followed by its text and separated from the next one by a blank
+// line.
+func WriteContext(w io.Writer, excerpts []ContextExcerpt) error {
+	for i, excerpt := range excerpts {
+		if i > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+		if _, err := io.WriteString(w, excerpt.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
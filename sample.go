@@ -0,0 +1,24 @@
+package sininen
+
+import (
+	"math/rand"
+)
+
+// SampleSegments returns n segments picked at random, in random order, using the given random number generator.
+// All the segments are returned, shuffled, when there are fewer than n of them.
+// No sampling is done when n is not positive, a copy of the segments being returned in their original order.
+// The given slice is left untouched.
+func SampleSegments(segments []ScoredSegment, n int, rng *rand.Rand) []ScoredSegment {
+	shuffled := make([]ScoredSegment, len(segments))
+	copy(shuffled, segments)
+	if n <= 0 {
+		return shuffled
+	}
+	rng.Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+	if n < len(shuffled) {
+		shuffled = shuffled[:n]
+	}
+	return shuffled
+}
@@ -0,0 +1,185 @@
+package sininen
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule tells when a periodic job runs, e.g. the synchronization of the subtitles of channels.
+type Schedule interface {
+	// Next returns the first time the job runs strictly after the given one.
+	Next(after time.Time) time.Time
+}
+
+// Interval is a Schedule running a job at a fixed positive interval, counted from the end of the previous run.
+type Interval time.Duration
+
+func (i Interval) Next(after time.Time) time.Time {
+	return after.Add(time.Duration(i))
+}
+
+// cronSchedule is a Schedule given by a cron expression, each field being the set of its allowed values as bits.
+type cronSchedule struct {
+	minutes, hours, days, months, weekdays uint64
+	anyDay, anyWeekday                     bool // Whether the day of month or the day of week is *.
+}
+
+// cronFields gives the bounds of the fields of a cron expression, 7 being another name of Sunday.
+var cronFields = []struct {
+	name        string
+	first, last int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// scheduleShortcuts are the cron expressions of the usual schedules.
+var scheduleShortcuts = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+// ParseSchedule parses a schedule, either @every followed by a duration parsed by time.ParseDuration (e.g. "@every 6h"),
+// @hourly, @daily, @weekly, @monthly, or a cron expression of five fields: minute, hour, day of month, month and day of
+// week (e.g. "30 */6 * * 1-5").
+// Each field of a cron expression is a list of values, of ranges like 1-5 or of *, optionally followed by a step like
+// /15. A job matching either a restricted day of month or a restricted day of week runs, like with cron, and the times
+// are those of the local time zone.
+func ParseSchedule(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "@every ") {
+		interval, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every ")))
+		if err != nil {
+			return nil, fmt.Errorf("%w: schedule %q: %v", ErrInvalidOption, spec, err)
+		}
+		if interval <= 0 {
+			return nil, fmt.Errorf("%w: schedule %q: the interval must be positive", ErrInvalidOption, spec)
+		}
+		return Interval(interval), nil
+	}
+	expression := spec
+	if shortcut, ok := scheduleShortcuts[spec]; ok {
+		expression = shortcut
+	}
+	fields := strings.Fields(expression)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("%w: schedule %q: %d fields expected, got %d", ErrInvalidOption, spec, len(cronFields), len(fields))
+	}
+	sets := make([]uint64, len(fields))
+	for i, field := range fields {
+		set, err := parseCronField(field, cronFields[i].first, cronFields[i].last)
+		if err != nil {
+			return nil, fmt.Errorf("%w: schedule %q: %s: %v", ErrInvalidOption, spec, cronFields[i].name, err)
+		}
+		sets[i] = set
+	}
+	if sets[4]&(1<<7) != 0 {
+		sets[4] |= 1 // Sunday.
+	}
+	result := cronSchedule{sets[0], sets[1], sets[2], sets[3], sets[4], fields[2] == "*", fields[4] == "*"}
+	if result.Next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("%w: schedule %q never runs", ErrInvalidOption, spec)
+	}
+	return result, nil
+}
+
+// parseCronField returns the set of values of a field of a cron expression, as bits.
+func parseCronField(field string, first, last int) (uint64, error) {
+	var result uint64
+	for _, part := range strings.Split(field, ",") {
+		values, step := part, 1
+		if slash := strings.Index(part, "/"); slash >= 0 {
+			var err error
+			values = part[:slash]
+			if step, err = strconv.Atoi(part[slash+1:]); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+		}
+		low, high := first, last
+		if values != "*" {
+			bounds := strings.SplitN(values, "-", 2)
+			var err error
+			if low, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("invalid value in %q", part)
+			}
+			high = low
+			if len(bounds) == 2 {
+				if high, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, fmt.Errorf("invalid value in %q", part)
+				}
+			} else if step > 1 {
+				high = last // 5/15 means from 5 to the end, every 15.
+			}
+		}
+		if low < first || high > last || low > high {
+			return 0, fmt.Errorf("%q is out of the range %d-%d", part, first, last)
+		}
+		for value := low; value <= high; value += step {
+			result |= 1 << uint(value)
+		}
+	}
+	return result, nil
+}
+
+// inCronSet tells whether a set of values of a cron field contains a value.
+func inCronSet(set uint64, value int) bool {
+	return set&(1<<uint(value)) != 0
+}
+
+// matchesDay tells whether a job runs at some time of a day.
+func (cs cronSchedule) matchesDay(t time.Time) bool {
+	day, weekday := inCronSet(cs.days, t.Day()), inCronSet(cs.weekdays, int(t.Weekday()))
+	if cs.anyDay || cs.anyWeekday {
+		return day && weekday
+	}
+	return day || weekday
+}
+
+// Next returns the next matching minute, or the zero time when there is none in the next five years.
+func (cs cronSchedule) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := after.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case !inCronSet(cs.months, int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !cs.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !inCronSet(cs.hours, t.Hour()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !inCronSet(cs.minutes, t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// RunSchedule runs a job at the times given by a schedule until the context is done.
+// The runs never overlap, the times reached while the job runs being skipped.
+func RunSchedule(ctx context.Context, schedule Schedule, job func(ctx context.Context)) error {
+	for {
+		next := schedule.Next(time.Now())
+		if next.IsZero() {
+			return fmt.Errorf("%w: the schedule never runs", ErrInvalidOption)
+		}
+		Log.Debugf("next run at %s", next.Format(time.RFC3339))
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+		job(ctx)
+	}
+}
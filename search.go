@@ -1,208 +1,103 @@
+//go:build !js
+// +build !js
+
 package sininen
 
 import (
-	"errors"
 	"fmt"
-	"sort"
-	"time"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/blevesearch/bleve/v2"
-	"github.com/blevesearch/bleve/v2/search"
+	"github.com/blevesearch/bleve/v2/search/query"
 )
 
 ///////////////////
 // bleve helpers //
 ///////////////////
 
-// TextQuery makes a plain text search against an transcription index.
-func TextQuery(query string, index bleve.Index) (*bleve.SearchResult, error) {
-	request := bleve.NewSearchRequest(bleve.NewMatchQuery(query))
-	request.Fields = []string{"Segments"} // Include the Segments field without which the timestamps cannot be deduced.
+// newTranscriptionRequest creates a search request including everything needed to assemble transcription search results.
+func newTranscriptionRequest(q query.Query) *bleve.SearchRequest {
+	request := bleve.NewSearchRequest(q)
+	request.Fields = []string{"Segments", "Words", "UploadDate", "Title", "Duration", "ViewCount", "TranslatedFrom"} // Segments are needed to deduce the timestamps and Words to extract the segments text.
 	request.IncludeLocations = true
-	return index.Search(request)
+	return request
 }
 
-////////////////////////////////////
-// Search results data structures //
-////////////////////////////////////
-
-// SegmentHit represents a transcription segment that matched with a search query.
-type SegmentHit struct {
-	StartTime   time.Duration `json:"start_time"`
-	EndTime     time.Duration `json:"end_time"`
-	SortedTerms []string      `json:"sorted_terms"` // Terms in the segment that matched with the search query, sorted in increasing order.
-}
-
-// NDistinctTerms returns the number of distinct terms in the segment that matched with the search query.
-func (sh SegmentHit) NDistinctTerms() int {
-	result := 0
-	var last string
-	for _, term := range sh.SortedTerms {
-		if term != last {
-			last = term
-			result++
-		}
+// PlaylistTextQuery makes a plain text search restricted to the videos of a playlist, tagged by TagPlaylist.
+// size is the maximum number of transcriptions retrieved, bleve's default of 10 being used when it is not positive.
+func PlaylistTextQuery(query, playlistID string, index bleve.Index, size int) (*bleve.SearchResult, error) {
+	inPlaylist := bleve.NewTermQuery(playlistID)
+	inPlaylist.SetField("Playlists")
+	request := newTranscriptionRequest(bleve.NewConjunctionQuery(bleve.NewMatchQuery(query), inPlaylist))
+	if size > 0 {
+		request.Size = size
 	}
-	return result
+	return index.Search(request)
 }
 
-// SearchResult represents a transcription file that matched with a search query.
-type SearchResult struct {
-	ID       string
-	Score    float64
-	Segments []SegmentHit // Segments that matched with the search query.
+// VideosTextQuery makes a plain text search restricted to the videos of the given IDs, all of them being retrieved
+// when they match.
+func VideosTextQuery(query string, ids []string, index bleve.Index) (*bleve.SearchResult, error) {
+	request := newTranscriptionRequest(bleve.NewConjunctionQuery(bleve.NewMatchQuery(query), bleve.NewDocIDQuery(ids)))
+	request.Size = len(ids)
+	return index.Search(request)
 }
 
-// SearchResultSequence represents a sequence of transcription files that matched with a search query.
-type SearchResultSequence []SearchResult
-
-func (srs SearchResultSequence) lenSegments() int {
-	result := 0
-	for _, sr := range srs {
-		result += len(sr.Segments)
+// TextQuery makes a plain text search against an transcription index.
+// size is the maximum number of transcriptions retrieved, bleve's default of 10 being used when it is not positive.
+func TextQuery(query string, index bleve.Index, size int) (*bleve.SearchResult, error) {
+	request := newTranscriptionRequest(bleve.NewMatchQuery(query))
+	if size > 0 {
+		request.Size = size
 	}
-	return result
-}
-
-// ScoredSegment is a SegmentHit with its score and its transcription ID.
-type ScoredSegment struct {
-	SegmentHit
-	Score float64 `json:"score"`
-	ID    string  `json:"id"`
+	return index.Search(request)
 }
 
-// ScoredSegments flattens a search results hierarchy by returning the scored segments, sorted by score.
-func (srs SearchResultSequence) ScoredSegments() []ScoredSegment {
-	result := make([]ScoredSegment, 0, srs.lenSegments())
-	for _, sr := range srs {
-		for _, segment := range sr.Segments {
-			result = append(result, ScoredSegment{
-				SegmentHit: segment,
-				Score:      sr.Score * float64(segment.NDistinctTerms()),
-				ID:         sr.ID,
-			})
-		}
+// minPrefixLength is the minimum number of characters of the last word of a query matched as a prefix by
+// PrefixTextQuery, shorter prefixes matching too many terms to be useful.
+const minPrefixLength = 2
+
+// prefixMatchQuery returns a plain text query whose last word also matches the words it is a prefix of, unless the text
+// ends with a space, meaning that the word is complete.
+func prefixMatchQuery(text string) query.Query {
+	match := bleve.NewMatchQuery(text)
+	words := strings.Fields(text)
+	if len(words) == 0 || strings.TrimRightFunc(text, unicode.IsSpace) != text {
+		return match
 	}
-	sort.Slice(result, func(i, j int) bool {
-		ri, rj := result[i], result[j]
-		if ri.Score != rj.Score {
-			return ri.Score > rj.Score
-		}
-		if ri.ID != rj.ID {
-			return ri.ID < rj.ID
-		}
-		return ri.StartTime < rj.StartTime
-	})
-	return result
-}
-
-/////////////////////////////
-// Search results assembly //
-/////////////////////////////
-// That is to say going from raw bleve results to results catered for audio transcriptions.
-
-// locateSegment returns the index of the segment containing the given location.
-func locateSegment(segments []interface{}, location *search.Location) int {
-	searchFailed := false
-	position := sort.Search(len(segments)/3, func(i int) bool {
-		endPos, isFloat := segments[i*3+2].(float64)
-		if isFloat && endPos > 0 {
-			return uint64(endPos) > location.Start
-		}
-		searchFailed = true
-		return false
-	})
-	if searchFailed {
-		return -1
+	last := strings.ToLower(strings.TrimFunc(words[len(words)-1], func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}))
+	if utf8.RuneCountInString(last) < minPrefixLength {
+		return match
 	}
-	return position
+	prefix := bleve.NewPrefixQuery(last)
+	prefix.SetField("Words")
+	return bleve.NewDisjunctionQuery(match, prefix)
 }
 
-// extractDurations extracts duration information from a serialized segment array.
-func extractDurations(segments []interface{}, segmentPos int) (startTime, endTime time.Duration, err error) {
-	extract := func(i int) (float64, error) {
-		value, valid := segments[i].(float64)
-		if !valid {
-			return -1, fmt.Errorf("expected segments[%v] to be of type float64, got %T", i, segments[i])
-		}
-		return value, nil
-	}
-
-	floatStart, err := extract(segmentPos * 3)
-	if err != nil {
-		return
-	}
-	floatEnd, err := extract(segmentPos*3 + 1)
-	if err != nil {
-		return
+// PrefixTextQuery makes a plain text search against an transcription index like TextQuery, the last word of the query
+// also matching as a prefix, e.g. to search as the query is typed.
+func PrefixTextQuery(query string, index bleve.Index, size int) (*bleve.SearchResult, error) {
+	request := newTranscriptionRequest(prefixMatchQuery(query))
+	if size > 0 {
+		request.Size = size
 	}
-	startTime = time.Duration(int(floatStart) * int(time.Second))
-	endTime = time.Duration(int(floatEnd) * int(time.Second))
-	return
+	return index.Search(request)
 }
 
 // AssembleSearchResults builds transcription search results with timestamp information using raw bleve search results.
-func AssembleSearchResults(bleveResults *bleve.SearchResult) (SearchResultSequence, error) {
+// It fails with ErrAssembly when a hit lacks the stored fields needed to locate its segments.
+func AssembleSearchResults(bleveResults *bleve.SearchResult, options AssemblyOptions) (SearchResultSequence, error) {
 	result := SearchResultSequence{}
 	for _, hit := range bleveResults.Hits {
-		raw, exists := hit.Fields["Segments"]
-		if !exists {
-			return nil, errors.New("segments are missing from bleve search results")
-		}
-		segments, valid := raw.([]interface{})
-		if !valid {
-			return nil, fmt.Errorf("segments should be an array, got %T", raw)
-		}
-		if len(segments)%3 != 0 {
-			return nil, fmt.Errorf("serialized segments should be a multiple of 3, got %v segments", len(segments))
-		}
-
-		// Segment hits are cached because search hits for different terms can orrur in the same segment.
-		hitCache := map[int]*SegmentHit{}
-		for _, locationMap := range hit.Locations {
-			for term, locations := range locationMap {
-				for _, location := range locations {
-					i := locateSegment(segments, location)
-					if i < 0 {
-						return nil, errors.New("failed to locate segment")
-					}
-					start, end, err := extractDurations(segments, i)
-					if err != nil {
-						return nil, err
-					}
-					cachedHit, isCached := hitCache[i]
-					if isCached {
-						cachedHit.SortedTerms = append(cachedHit.SortedTerms, term) // Will sort later.
-					} else {
-						// segmentHit, err := newSegmentHit(segments, i, hit.Score, term)
-						hitCache[i] = &SegmentHit{
-							StartTime:   start,
-							EndTime:     end,
-							SortedTerms: []string{term},
-						}
-					}
-				}
-			}
-		}
-
-		sortedSegments := make([]SegmentHit, 0, len(hitCache))
-		for _, el := range hitCache {
-			sort.Strings(el.SortedTerms)
-			sortedSegments = append(sortedSegments, *el)
+		assembled, err := assembleHit(hit, options)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrAssembly, hit.ID, err)
 		}
-		sort.Slice(sortedSegments, func(i, j int) bool {
-			si, sj := sortedSegments[i], sortedSegments[j]
-			if len(si.SortedTerms) != len(sj.SortedTerms) { // To ensure stability of the sorting operation.
-				return len(si.SortedTerms) > len(sj.SortedTerms)
-			}
-			return si.StartTime < sj.StartTime
-		})
-
-		result = append(result, SearchResult{
-			ID:       hit.ID,
-			Score:    hit.Score,
-			Segments: sortedSegments,
-		})
+		result = append(result, assembled)
 	}
 	return result, nil
 }
@@ -0,0 +1,77 @@
+package sininen
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// packedSegmentsVersion is the first byte of the segments packed by packSegments, to tell future encodings apart.
+const packedSegmentsVersion = 1
+
+// packSegments encodes the segments of a transcription, as serialized in Transcription.Segments, into the compact
+// form stored by the indexes.
+// Each segment is written as three varints: the difference between its start time and the one of the previous
+// segment, its duration, both in milliseconds, and the difference between its end position and the one of the previous
+// segment. The bytes are then encoded in base64, since bleve stores text.
+// Compared to an array of numbers, each stored with its own array position, this shrinks the stored segments and lets a
+// hit decode them at once instead of going through an interface{} per number.
+func packSegments(segments []float64) string {
+	buffer := make([]byte, 0, 1+binary.MaxVarintLen64+len(segments)*3)
+	buffer = append(buffer, packedSegmentsVersion)
+	varint := make([]byte, binary.MaxVarintLen64)
+	buffer = append(buffer, varint[:binary.PutUvarint(varint, uint64(len(segments)/3))]...)
+	var previousStart, previousEnd int64
+	for i := 0; i+2 < len(segments); i += 3 {
+		start := int64(math.Round(segments[i] * 1000))
+		end := int64(math.Round(segments[i+1] * 1000))
+		endPos := int64(segments[i+2])
+		buffer = append(buffer, varint[:binary.PutVarint(varint, start-previousStart)]...)
+		buffer = append(buffer, varint[:binary.PutVarint(varint, end-start)]...)
+		buffer = append(buffer, varint[:binary.PutVarint(varint, endPos-previousEnd)]...)
+		previousStart, previousEnd = start, endPos
+	}
+	return base64.RawStdEncoding.EncodeToString(buffer)
+}
+
+// unpackSegments decodes the segments encoded by packSegments.
+func unpackSegments(packed string) ([]float64, error) {
+	raw, err := base64.RawStdEncoding.DecodeString(packed)
+	if err != nil {
+		return nil, fmt.Errorf("packed segments: %v", err)
+	}
+	if len(raw) == 0 || raw[0] != packedSegmentsVersion {
+		return nil, errors.New("packed segments: unknown encoding")
+	}
+	raw = raw[1:]
+	count, n := binary.Uvarint(raw)
+	if n <= 0 || count > uint64(len(raw))/3 { // Each segment takes at least three bytes.
+		return nil, errors.New("packed segments: malformed count")
+	}
+	raw = raw[n:]
+
+	result := make([]float64, 0, count*3)
+	var start, endPos int64
+	for i := uint64(0); i < count; i++ {
+		var values [3]int64
+		for j := range values {
+			values[j], n = binary.Varint(raw)
+			if n <= 0 {
+				return nil, fmt.Errorf("packed segments: segment %d is truncated", i)
+			}
+			raw = raw[n:]
+		}
+		start += values[0]
+		endPos += values[2]
+		result = append(result, milliseconds(start).Seconds(), milliseconds(start+values[1]).Seconds(), float64(endPos))
+	}
+	return result, nil
+}
+
+// milliseconds returns a number of milliseconds as a duration.
+func milliseconds(value int64) time.Duration {
+	return time.Duration(value) * time.Millisecond
+}
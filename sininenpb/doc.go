@@ -0,0 +1,4 @@
+// Package sininenpb holds the gRPC API of sininen, generated from sininen.proto.
+package sininenpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative sininen.proto
@@ -0,0 +1,276 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             (unknown)
+// source: sininen.proto
+
+package sininenpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// SininenClient is the client API for Sininen service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type SininenClient interface {
+	// Search streams the matching segments of a search, from the best one.
+	Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (Sininen_SearchClient, error)
+	// Index indexes the new and modified subtitles of a channel, creating its indexes if needed.
+	Index(ctx context.Context, in *IndexRequest, opts ...grpc.CallOption) (*IndexResponse, error)
+	// Stats describes the indexes of a channel.
+	Stats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*StatsResponse, error)
+	// Watch keeps the indexes of a channel up to date, streaming their changes until the call is cancelled.
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (Sininen_WatchClient, error)
+}
+
+type sininenClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSininenClient(cc grpc.ClientConnInterface) SininenClient {
+	return &sininenClient{cc}
+}
+
+func (c *sininenClient) Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (Sininen_SearchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Sininen_ServiceDesc.Streams[0], "/sininen.Sininen/Search", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &sininenSearchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Sininen_SearchClient interface {
+	Recv() (*Segment, error)
+	grpc.ClientStream
+}
+
+type sininenSearchClient struct {
+	grpc.ClientStream
+}
+
+func (x *sininenSearchClient) Recv() (*Segment, error) {
+	m := new(Segment)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *sininenClient) Index(ctx context.Context, in *IndexRequest, opts ...grpc.CallOption) (*IndexResponse, error) {
+	out := new(IndexResponse)
+	err := c.cc.Invoke(ctx, "/sininen.Sininen/Index", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sininenClient) Stats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*StatsResponse, error) {
+	out := new(StatsResponse)
+	err := c.cc.Invoke(ctx, "/sininen.Sininen/Stats", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sininenClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (Sininen_WatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Sininen_ServiceDesc.Streams[1], "/sininen.Sininen/Watch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &sininenWatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Sininen_WatchClient interface {
+	Recv() (*IndexChange, error)
+	grpc.ClientStream
+}
+
+type sininenWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *sininenWatchClient) Recv() (*IndexChange, error) {
+	m := new(IndexChange)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// SininenServer is the server API for Sininen service.
+// All implementations must embed UnimplementedSininenServer
+// for forward compatibility
+type SininenServer interface {
+	// Search streams the matching segments of a search, from the best one.
+	Search(*SearchRequest, Sininen_SearchServer) error
+	// Index indexes the new and modified subtitles of a channel, creating its indexes if needed.
+	Index(context.Context, *IndexRequest) (*IndexResponse, error)
+	// Stats describes the indexes of a channel.
+	Stats(context.Context, *StatsRequest) (*StatsResponse, error)
+	// Watch keeps the indexes of a channel up to date, streaming their changes until the call is cancelled.
+	Watch(*WatchRequest, Sininen_WatchServer) error
+	mustEmbedUnimplementedSininenServer()
+}
+
+// UnimplementedSininenServer must be embedded to have forward compatible implementations.
+type UnimplementedSininenServer struct {
+}
+
+func (UnimplementedSininenServer) Search(*SearchRequest, Sininen_SearchServer) error {
+	return status.Errorf(codes.Unimplemented, "method Search not implemented")
+}
+func (UnimplementedSininenServer) Index(context.Context, *IndexRequest) (*IndexResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Index not implemented")
+}
+func (UnimplementedSininenServer) Stats(context.Context, *StatsRequest) (*StatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Stats not implemented")
+}
+func (UnimplementedSininenServer) Watch(*WatchRequest, Sininen_WatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
+func (UnimplementedSininenServer) mustEmbedUnimplementedSininenServer() {}
+
+// UnsafeSininenServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SininenServer will
+// result in compilation errors.
+type UnsafeSininenServer interface {
+	mustEmbedUnimplementedSininenServer()
+}
+
+func RegisterSininenServer(s grpc.ServiceRegistrar, srv SininenServer) {
+	s.RegisterService(&Sininen_ServiceDesc, srv)
+}
+
+func _Sininen_Search_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SearchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SininenServer).Search(m, &sininenSearchServer{stream})
+}
+
+type Sininen_SearchServer interface {
+	Send(*Segment) error
+	grpc.ServerStream
+}
+
+type sininenSearchServer struct {
+	grpc.ServerStream
+}
+
+func (x *sininenSearchServer) Send(m *Segment) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Sininen_Index_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IndexRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SininenServer).Index(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sininen.Sininen/Index",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SininenServer).Index(ctx, req.(*IndexRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Sininen_Stats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SininenServer).Stats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sininen.Sininen/Stats",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SininenServer).Stats(ctx, req.(*StatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Sininen_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SininenServer).Watch(m, &sininenWatchServer{stream})
+}
+
+type Sininen_WatchServer interface {
+	Send(*IndexChange) error
+	grpc.ServerStream
+}
+
+type sininenWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *sininenWatchServer) Send(m *IndexChange) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Sininen_ServiceDesc is the grpc.ServiceDesc for Sininen service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Sininen_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "sininen.Sininen",
+	HandlerType: (*SininenServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Index",
+			Handler:    _Sininen_Index_Handler,
+		},
+		{
+			MethodName: "Stats",
+			Handler:    _Sininen_Stats_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Search",
+			Handler:       _Sininen_Search_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Watch",
+			Handler:       _Sininen_Watch_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "sininen.proto",
+}
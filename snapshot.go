@@ -0,0 +1,236 @@
+package sininen
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/blevesearch/bleve/v2/registry"
+	"github.com/blevesearch/bleve/v2/search"
+)
+
+// Snapshot is a self-contained copy of the transcriptions of an index, searchable without the index, e.g. from the
+// WebAssembly build of sininen in a browser, where bleve cannot open indexes.
+// The queries are analyzed like by the index and matched like by TextQuery, the search results being assembled like
+// the ones of the index so that they can be ranked and written like them.
+// It implements Searcher and is safe for concurrent use.
+type Snapshot struct {
+	Lang     string                    // Language of the transcriptions.
+	Analyzer string                    // Name of the bleve analyzer of the transcriptions, see LanguageAnalyzer.
+	Videos   map[string]*Transcription // By video ID.
+
+	once     sync.Once
+	err      error                            // Of the building of the postings.
+	ids      []string                         // Sorted IDs of the videos.
+	postings map[string][]snapshotPosting     // Occurrences of each term, by video.
+	lengths  []int                            // Number of terms of each video.
+	analyze  func(text string) []snapshotTerm // Set along with the postings.
+}
+
+// snapshotTerm is an occurrence of a term in a text.
+type snapshotTerm struct {
+	term       string
+	start, end int // Byte offsets.
+	position   int // 1-based position of the term in the text.
+}
+
+// snapshotPosting is an occurrence of a term in the transcription of a video of a snapshot.
+type snapshotPosting struct {
+	video int // Position of the video in Snapshot.ids.
+	snapshotTerm
+}
+
+// snapshotFile is the serialized form of a snapshot.
+type snapshotFile struct {
+	Lang     string
+	Analyzer string
+	Videos   map[string]*Transcription
+}
+
+// WriteSnapshot writes a snapshot in the gob binary format, compressed with gzip since transcripts compress well.
+func (s *Snapshot) WriteSnapshot(w io.Writer) error {
+	compressed := gzip.NewWriter(w)
+	if err := gob.NewEncoder(compressed).Encode(snapshotFile{s.Lang, s.Analyzer, s.Videos}); err != nil {
+		return err
+	}
+	return compressed.Close()
+}
+
+// ReadSnapshot reads a snapshot written by Snapshot.WriteSnapshot.
+func ReadSnapshot(r io.Reader) (*Snapshot, error) {
+	decompressed, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: snapshot: %v", ErrBadFormat, err)
+	}
+	var file snapshotFile
+	if err := gob.NewDecoder(decompressed).Decode(&file); err != nil {
+		return nil, fmt.Errorf("%w: snapshot: %v", ErrBadFormat, err)
+	}
+	if file.Videos == nil {
+		file.Videos = map[string]*Transcription{}
+	}
+	return &Snapshot{Lang: file.Lang, Analyzer: file.Analyzer, Videos: file.Videos}, nil
+}
+
+// OpenSnapshot reads a snapshot held in memory, e.g. embedded in a binary with a go:embed directive on a []byte
+// variable, so that the binary can search the transcripts on its own.
+func OpenSnapshot(data []byte) (*Snapshot, error) {
+	return ReadSnapshot(bytes.NewReader(data))
+}
+
+// OpenSnapshotFS reads the snapshot named name in a file system, e.g. an embed.FS holding the snapshots of several
+// channels, see SnapshotName.
+func OpenSnapshotFS(fsys fs.FS, name string) (*Snapshot, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	result, err := ReadSnapshot(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return result, nil
+}
+
+// SnapshotName returns the name of the file of the snapshot of a channel in a language, as written by the snapshot
+// command.
+func SnapshotName(channelName, lang string) string {
+	return channelName + "." + lang + ".snapshot.gz"
+}
+
+// index builds the postings of the snapshot the first time it is searched.
+func (s *Snapshot) index() error {
+	s.once.Do(func() {
+		name := s.Analyzer
+		if name == "" {
+			name = LanguageAnalyzer(s.Lang)
+		}
+		analyzer, err := registry.NewCache().AnalyzerNamed(name)
+		if err != nil {
+			s.err = fmt.Errorf("%w: analyzer of the snapshot: %v", ErrInvalidOption, err)
+			return
+		}
+		s.analyze = func(text string) []snapshotTerm {
+			tokens := analyzer.Analyze([]byte(text))
+			result := make([]snapshotTerm, len(tokens))
+			for i, token := range tokens {
+				result[i] = snapshotTerm{string(token.Term), token.Start, token.End, token.Position}
+			}
+			return result
+		}
+
+		s.ids = make([]string, 0, len(s.Videos))
+		for id := range s.Videos {
+			s.ids = append(s.ids, id)
+		}
+		sort.Strings(s.ids)
+		s.postings = map[string][]snapshotPosting{}
+		s.lengths = make([]int, len(s.ids))
+		for i, id := range s.ids {
+			terms := s.analyze(s.Videos[id].Words)
+			s.lengths[i] = len(terms)
+			for _, term := range terms {
+				s.postings[term.term] = append(s.postings[term.term], snapshotPosting{i, term})
+			}
+		}
+	})
+	return s.err
+}
+
+// Search makes a plain text search through the transcriptions of the snapshot and assembles the results, at most size
+// transcriptions being retrieved (10 when size is not positive), like BleveBackend.Search.
+// The transcriptions are scored with the tf-idf of the terms of the query, like bleve does.
+func (s *Snapshot) Search(query string, size int, options AssemblyOptions) (SearchResultSequence, error) {
+	if err := s.index(); err != nil {
+		return nil, err
+	}
+	if size <= 0 {
+		size = 10
+	}
+	queryTerms := map[string]bool{}
+	for _, term := range s.analyze(query) {
+		queryTerms[term.term] = true
+	}
+
+	// The locations of the terms of each matching video, along with its score.
+	locations := map[int]search.TermLocationMap{}
+	scores := map[int]float64{}
+	matched := map[int]int{} // Number of distinct query terms matched by each video.
+	for term := range queryTerms {
+		postings := s.postings[term]
+		frequencies := map[int]int{}
+		for _, posting := range postings {
+			frequencies[posting.video]++
+			if locations[posting.video] == nil {
+				locations[posting.video] = search.TermLocationMap{}
+			}
+			locations[posting.video][term] = append(locations[posting.video][term], &search.Location{
+				Pos: uint64(posting.position), Start: uint64(posting.start), End: uint64(posting.end),
+			})
+		}
+		idf := 1 + math.Log(float64(len(s.ids))/float64(1+len(frequencies)))
+		for video, frequency := range frequencies {
+			scores[video] += math.Sqrt(float64(frequency)) * idf * idf / math.Sqrt(float64(s.lengths[video]))
+			matched[video]++
+		}
+	}
+	videos := make([]int, 0, len(scores))
+	for video := range scores {
+		scores[video] *= float64(matched[video]) / float64(len(queryTerms)) // Like the coordination factor of bleve.
+		videos = append(videos, video)
+	}
+	sort.Slice(videos, func(i, j int) bool {
+		if scores[videos[i]] != scores[videos[j]] {
+			return scores[videos[i]] > scores[videos[j]]
+		}
+		return videos[i] < videos[j]
+	})
+	if len(videos) > size {
+		videos = videos[:size]
+	}
+
+	result := SearchResultSequence{}
+	for _, video := range videos {
+		assembled, err := assembleHit(s.hit(video, scores[video], locations[video]), options)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", s.ids[video], err)
+		}
+		result = append(result, assembled)
+	}
+	return result, nil
+}
+
+// hit returns the matching video as a bleve hit, with the fields stored by the indexes.
+func (s *Snapshot) hit(video int, score float64, locations search.TermLocationMap) *search.DocumentMatch {
+	transcription := s.Videos[s.ids[video]]
+	fields := map[string]interface{}{
+		"Segments":       transcription.Segments, // Typed, unlike the segments stored by bleve.
+		"Words":          transcription.Words,
+		"Title":          transcription.Title,
+		"Duration":       transcription.Duration,
+		"ViewCount":      float64(transcription.ViewCount),
+		"TranslatedFrom": transcription.TranslatedFrom,
+	}
+	if !transcription.UploadDate.IsZero() {
+		fields["UploadDate"] = transcription.UploadDate.Format(time.RFC3339)
+	}
+	return &search.DocumentMatch{
+		ID:        s.ids[video],
+		Score:     score,
+		Fields:    fields,
+		Locations: search.FieldTermLocationMap{"Words": locations},
+	}
+}
+
+// Close does nothing, a snapshot being held in memory.
+func (s *Snapshot) Close() error {
+	return nil
+}
@@ -0,0 +1,190 @@
+package sininen
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// SponsorSegment is a part of a video submitted to SponsorBlock (https://sponsor.ajay.app), e.g. a sponsor read or an
+// intro, that viewers may want to skip.
+type SponsorSegment struct {
+	Category string // SponsorBlock category, e.g. sponsor, selfpromo or intro.
+	Start    time.Duration
+	End      time.Duration
+}
+
+// SponsorSegments are the sponsor segments of videos, by video ID, sorted by start time.
+// A video without any sponsor segment is recorded with an empty slice, to tell it apart from the videos never fetched.
+type SponsorSegments map[string][]SponsorSegment
+
+// DefaultSponsorCategories are the categories of the segments fetched by SponsorBlockAPI when none is given.
+var DefaultSponsorCategories = []string{"sponsor", "selfpromo", "interaction", "intro", "outro"}
+
+// SponsorBlockAPI fetches the sponsor segments of YouTube videos from SponsorBlock, which needs no API key.
+type SponsorBlockAPI struct {
+	HTTP       *http.Client // A client timing out after sponsorBlockTimeout when nil.
+	Endpoint   string       // URL of the SponsorBlock server, https://sponsor.ajay.app by default.
+	Categories []string     // DefaultSponsorCategories when empty.
+}
+
+// apiSponsorSegment is the subset of a segment returned by the skipSegments endpoint that is relevant to sininen.
+type apiSponsorSegment struct {
+	Category string     `json:"category"`
+	Segment  [2]float64 `json:"segment"` // Start and end, in seconds.
+}
+
+// sponsorBlockTimeout is the time given to SponsorBlock to answer about a video by the default client.
+const sponsorBlockTimeout = 30 * time.Second
+
+// sponsorBlockClient is the default client of SponsorBlockAPI.
+var sponsorBlockClient = &http.Client{Timeout: sponsorBlockTimeout}
+
+// FetchSponsorSegments returns the segments of the given videos that can be skipped, by ID, the videos unknown to
+// SponsorBlock having none.
+// On failure, the segments of the videos fetched before are returned along with the error, so that they can be saved.
+func (api SponsorBlockAPI) FetchSponsorSegments(ctx context.Context, ids []string) (SponsorSegments, error) {
+	result := make(SponsorSegments, len(ids))
+	for _, id := range ids {
+		segments, err := api.fetchVideo(ctx, id)
+		if err != nil {
+			return result, err
+		}
+		result[id] = segments
+	}
+	return result, nil
+}
+
+// fetchVideo returns the sponsor segments of a video, sorted by start time.
+func (api SponsorBlockAPI) fetchVideo(ctx context.Context, id string) ([]SponsorSegment, error) {
+	endpoint, client, categories := api.Endpoint, api.HTTP, api.Categories
+	if endpoint == "" {
+		endpoint = "https://sponsor.ajay.app"
+	}
+	if client == nil {
+		client = sponsorBlockClient
+	}
+	if len(categories) == 0 {
+		categories = DefaultSponsorCategories
+	}
+	rawCategories, _ := json.Marshal(categories) // Cannot fail on strings.
+	query := url.Values{"videoID": {id}, "categories": {string(rawCategories)}, "actionType": {"skip"}}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"/api/skipSegments?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotFound { // No segment was submitted for the video.
+		return []SponsorSegment{}, nil
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("SponsorBlock: %s: %s", id, response.Status)
+	}
+
+	var raw []apiSponsorSegment
+	if err := json.NewDecoder(response.Body).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("%w: SponsorBlock response for %s: %v", ErrBadFormat, id, err)
+	}
+	result := make([]SponsorSegment, 0, len(raw))
+	for _, segment := range raw {
+		result = append(result, SponsorSegment{segment.Category, fromSeconds(segment.Segment[0]), fromSeconds(segment.Segment[1])})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Start < result[j].Start })
+	return result, nil
+}
+
+// sponsorSegmentsFile is the name of the file storing the sponsor segments of the videos of a folder.
+const sponsorSegmentsFile = "sponsorblock.json"
+
+// jsonSponsorSegment is the JSON representation of a sponsor segment, with times in seconds.
+type jsonSponsorSegment struct {
+	Category string  `json:"category"`
+	Start    float64 `json:"start"`
+	End      float64 `json:"end"`
+}
+
+// LoadSponsorSegments reads the sponsor segments saved in a folder by SponsorSegments.Save, none being returned when
+// they were never saved.
+func LoadSponsorSegments(folder string) (SponsorSegments, error) {
+	filename := filepath.Join(folder, sponsorSegmentsFile)
+	raw, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return SponsorSegments{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var stored map[string][]jsonSponsorSegment
+	if err := json.Unmarshal(raw, &stored); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrBadFormat, filename, err)
+	}
+	result := make(SponsorSegments, len(stored))
+	for id, segments := range stored {
+		result[id] = make([]SponsorSegment, len(segments))
+		for i, segment := range segments {
+			result[id][i] = SponsorSegment{segment.Category, fromSeconds(segment.Start), fromSeconds(segment.End)}
+		}
+	}
+	return result, nil
+}
+
+// Save writes the sponsor segments in a folder, typically the subtitles folder of their channel, replacing the file
+// only once it is completely written.
+func (ss SponsorSegments) Save(folder string) error {
+	stored := make(map[string][]jsonSponsorSegment, len(ss))
+	for id, segments := range ss {
+		stored[id] = make([]jsonSponsorSegment, len(segments))
+		for i, segment := range segments {
+			stored[id][i] = jsonSponsorSegment{segment.Category, segment.Start.Seconds(), segment.End.Seconds()}
+		}
+	}
+	raw, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return err
+	}
+	filename := filepath.Join(folder, sponsorSegmentsFile)
+	if err := ioutil.WriteFile(filename+".tmp", raw, 0644); err != nil {
+		return err
+	}
+	return os.Rename(filename+".tmp", filename)
+}
+
+// Covering returns the sponsor segment of a video containing a moment, if any.
+func (ss SponsorSegments) Covering(id string, at time.Duration) (SponsorSegment, bool) {
+	for _, segment := range ss[id] {
+		if segment.Start <= at && at < segment.End {
+			return segment, true
+		}
+	}
+	return SponsorSegment{}, false
+}
+
+// ApplySponsorSegments sets the Sponsor of the segments falling inside sponsor segments, i.e. whose middle is
+// sponsored, so that they can be flagged or filtered out.
+// With skip, the other segments starting within a sponsor segment start at its end instead, so that their links skip
+// straight past the sponsored content preceding the match.
+// The given slice is left untouched.
+func ApplySponsorSegments(segments []ScoredSegment, sponsors SponsorSegments, skip bool) []ScoredSegment {
+	result := make([]ScoredSegment, len(segments))
+	copy(result, segments)
+	for i := range result {
+		segment := &result[i]
+		if sponsor, ok := sponsors.Covering(segment.ID, (segment.StartTime+segment.EndTime)/2); ok {
+			segment.Sponsor = sponsor.Category
+		} else if sponsor, ok := sponsors.Covering(segment.ID, segment.StartTime); ok && skip {
+			segment.StartTime = sponsor.End
+		}
+	}
+	return result
+}
@@ -0,0 +1,447 @@
+//go:build !js
+// +build !js
+
+package sininen
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	_ "github.com/mattn/go-sqlite3" // Registers the sqlite3 driver.
+)
+
+// SQLiteBackend stores the transcriptions of a language in a single SQLite database, searched with the FTS5 extension,
+// for the users who want a portable file and SQL access to their corpus.
+// The videos table holds the metadata of the videos and the segments table their segments, by position, the text of
+// the segments being indexed by the segments_fts table.
+// FTS5 is only compiled in the sqlite3 driver with the sqlite_fts5 build tag, e.g. go build -tags sqlite_fts5 ./cli.
+type SQLiteBackend struct {
+	db *sql.DB
+}
+
+// sqliteSchema creates the tables of a database, the tokenizer of the full-text index being given as argument.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS videos (
+	id TEXT PRIMARY KEY,
+	title TEXT NOT NULL,
+	upload_date TEXT, -- RFC 3339, NULL when unknown.
+	duration REAL NOT NULL, -- In seconds.
+	view_count INTEGER NOT NULL,
+	modtime TEXT NOT NULL, -- Modification time of the indexed subtitles file.
+	indexed_at TEXT NOT NULL,
+	translated_from TEXT NOT NULL DEFAULT '' -- Original language of machine-translated subtitles.
+);
+CREATE TABLE IF NOT EXISTS segments (
+	id INTEGER PRIMARY KEY,
+	video_id TEXT NOT NULL REFERENCES videos (id),
+	position INTEGER NOT NULL,
+	start_time REAL NOT NULL, -- In seconds.
+	end_time REAL NOT NULL,
+	text TEXT NOT NULL,
+	UNIQUE (video_id, position)
+);
+CREATE VIRTUAL TABLE IF NOT EXISTS segments_fts USING fts5 (text, content = 'segments', content_rowid = 'id', tokenize = '%s');
+CREATE TRIGGER IF NOT EXISTS segments_insert AFTER INSERT ON segments BEGIN
+	INSERT INTO segments_fts (rowid, text) VALUES (new.id, new.text);
+END;
+CREATE TRIGGER IF NOT EXISTS segments_delete AFTER DELETE ON segments BEGIN
+	INSERT INTO segments_fts (segments_fts, rowid, text) VALUES ('delete', old.id, old.text);
+END;
+`
+
+// sqliteTokenizer returns the FTS5 tokenizer of a language, only English being stemmed.
+func sqliteTokenizer(lang string) string {
+	if baseLanguage(lang) == "en" {
+		return "porter unicode61 remove_diacritics 2"
+	}
+	return "unicode61 remove_diacritics 2"
+}
+
+// sqlitePath returns the location of the database of the given language in a folder, made absolute like indexPath.
+func sqlitePath(folder, lang string) string {
+	result := filepath.Join(folder, lang+".db")
+	if absolute, err := filepath.Abs(result); err == nil {
+		return absolute
+	}
+	return result
+}
+
+// OpenSQLiteBackend opens the database of a language in a folder, creating it when it does not exist.
+func OpenSQLiteBackend(folder, lang string) (*SQLiteBackend, error) {
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite3", sqlitePath(folder, lang))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(fmt.Sprintf(sqliteSchema, sqliteTokenizer(lang))); err != nil {
+		db.Close()
+		if strings.Contains(err.Error(), "no such module: fts5") {
+			return nil, fmt.Errorf("%w: the SQLite driver was built without FTS5, see the sqlite_fts5 build tag", ErrInvalidOption)
+		}
+		return nil, err
+	}
+	if err := addSQLiteTranslatedFrom(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLiteBackend{db}, nil
+}
+
+// addSQLiteTranslatedFrom adds the translated_from column to the videos table of the databases created before it.
+func addSQLiteTranslatedFrom(db *sql.DB) error {
+	var count int
+	if err := db.QueryRow("SELECT count(*) FROM pragma_table_info('videos') WHERE name = 'translated_from'").Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	_, err := db.Exec("ALTER TABLE videos ADD COLUMN translated_from TEXT NOT NULL DEFAULT ''")
+	return err
+}
+
+// DeleteSQLiteBackend deletes the database of a language in a folder, so that it can be created again from scratch.
+func DeleteSQLiteBackend(folder, lang string) error {
+	if err := os.Remove(sqlitePath(folder, lang)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// DB returns the database, to query the corpus with SQL.
+func (sb *SQLiteBackend) DB() *sql.DB {
+	return sb.db
+}
+
+// VideoCount returns the number of videos in the database.
+func (sb *SQLiteBackend) VideoCount() (int, error) {
+	var result int
+	err := sb.db.QueryRow("SELECT count(*) FROM videos").Scan(&result)
+	return result, err
+}
+
+func (sb *SQLiteBackend) Close() error {
+	return sb.db.Close()
+}
+
+// Update indexes the new and modified subtitles files of the given folder, and removes the videos whose file was
+// deleted, like UpdateSubtitleIndex.
+// Parsing errors are reported but do not stop the indexing of the other files.
+func (sb *SQLiteBackend) Update(folder, lang string, progress Progress) (IndexUpdate, error) {
+	var result IndexUpdate
+	files, err := subtitleFiles(folder, lang)
+	if err != nil {
+		return result, err
+	}
+	indexed := map[string]string{} // Modification times by video ID.
+	rows, err := sb.db.Query("SELECT id, modtime FROM videos")
+	if err != nil {
+		return result, err
+	}
+	for rows.Next() {
+		var id, modTime string
+		if err := rows.Scan(&id, &modTime); err != nil {
+			rows.Close()
+			return result, err
+		}
+		indexed[id] = modTime
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+
+	for id := range indexed {
+		if _, exists := files[id]; !exists {
+			result.Removed = append(result.Removed, id)
+		}
+	}
+	for id, file := range files {
+		modTime, exists := indexed[id]
+		switch {
+		case !exists:
+			result.Added = append(result.Added, id)
+		case modTime != file.ModTime().UTC().Format(time.RFC3339Nano):
+			result.Updated = append(result.Updated, id)
+		}
+	}
+	sort.Strings(result.Added)
+	sort.Strings(result.Updated)
+	sort.Strings(result.Removed)
+
+	tx, err := sb.db.Begin()
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback() // No-op once committed.
+	for _, id := range result.Removed {
+		if err := deleteSQLiteVideo(tx, id); err != nil {
+			return result, err
+		}
+		Log.Debugf("removed %s, its subtitles no longer exist", id)
+	}
+	changed := append(append([]string{}, result.Added...), result.Updated...)
+	progress.report(0, len(changed))
+	for i, id := range changed {
+		if err := indexSQLiteFile(tx, folder, id, files[id]); err != nil {
+			return result, err
+		}
+		progress.report(i+1, len(changed))
+	}
+	return result, tx.Commit()
+}
+
+// deleteSQLiteVideo removes a video and its segments from a database.
+func deleteSQLiteVideo(tx *sql.Tx, id string) error {
+	if _, err := tx.Exec("DELETE FROM segments WHERE video_id = ?", id); err != nil {
+		return err
+	}
+	_, err := tx.Exec("DELETE FROM videos WHERE id = ?", id)
+	return err
+}
+
+// indexSQLiteFile parses a subtitles file and replaces the video in a database.
+// Parsing errors are reported and leave the database untouched, unlike database errors.
+func indexSQLiteFile(tx *sql.Tx, folder, id string, file os.FileInfo) error {
+	filename := filepath.Join(folder, file.Name())
+	document, err := ParseSubtitleFile(filename)
+	if err != nil {
+		Log.Warnf("skipping %s: %v", filename, err)
+		parseFailures.WithLabelValues("sqlite").Inc()
+		return nil
+	}
+	addMetadata(document, folder, id)
+	if err := deleteSQLiteVideo(tx, id); err != nil {
+		return err
+	}
+	var uploadDate interface{}
+	if !document.UploadDate.IsZero() {
+		uploadDate = document.UploadDate.Format(time.RFC3339)
+	}
+	_, err = tx.Exec("INSERT INTO videos (id, title, upload_date, duration, view_count, modtime, indexed_at, translated_from) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		id, document.Title, uploadDate, document.Duration, document.ViewCount,
+		file.ModTime().UTC().Format(time.RFC3339Nano), time.Now().UTC().Format(time.RFC3339), document.TranslatedFrom)
+	if err != nil {
+		return err
+	}
+	insert, err := tx.Prepare("INSERT INTO segments (video_id, position, start_time, end_time, text) VALUES (?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+	start := 0
+	for i := 0; i+2 < len(document.Segments); i += 3 {
+		end := int(document.Segments[i+2])
+		if end < start || end > len(document.Words) {
+			return fmt.Errorf("%w: %s: inconsistent segment %d", ErrBadFormat, filename, i/3)
+		}
+		if _, err := insert.Exec(id, i/3, document.Segments[i], document.Segments[i+1], document.Words[start:end]); err != nil {
+			return err
+		}
+		start = end + 1 // Skip the newline separating the segments.
+	}
+	Log.Debugf("indexed %s as %s (%d segments, title %q)", filename, id, len(document.Segments)/3, document.Title)
+	indexedDocuments.WithLabelValues("sqlite").Inc()
+	return nil
+}
+
+// ftsQuery converts a plain text query to an FTS5 query matching any of its words, like the match queries of bleve.
+func ftsQuery(query string) string {
+	words := strings.FieldsFunc(query, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsNumber(r) })
+	for i, word := range words {
+		words[i] = `"` + word + `"`
+	}
+	return strings.Join(words, " OR ")
+}
+
+// Markers surrounding the matched terms in the text returned by the highlight function of FTS5.
+const (
+	highlightStart = "\x02"
+	highlightEnd   = "\x03"
+)
+
+// parseHighlights returns the positions of the matched terms in a segment text highlighted by FTS5, along with the
+// terms, lowercased and each listed once, so that the segments repeating a word do not rank above the ones matching
+// more words of the query.
+func parseHighlights(highlighted string) ([]TextSpan, []string) {
+	var spans []TextSpan
+	var terms []string
+	seen := map[string]bool{}
+	position := 0
+	for {
+		start := strings.Index(highlighted, highlightStart)
+		if start < 0 {
+			return spans, terms
+		}
+		length := strings.Index(highlighted[start:], highlightEnd)
+		if length < 0 {
+			return spans, terms
+		}
+		term := highlighted[start+len(highlightStart) : start+length]
+		spans = append(spans, TextSpan{position + start, position + start + len(term)})
+		if term := strings.ToLower(term); !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+		position += start + len(term)
+		highlighted = highlighted[start+length+len(highlightEnd):]
+	}
+}
+
+// sqliteMatch is a segment matching a query.
+type sqliteMatch struct {
+	position    int
+	highlighted string
+}
+
+// Search finds the segments matching any word of the query, the transcriptions being scored by the sum of the BM25
+// scores of their matching segments.
+func (sb *SQLiteBackend) Search(query string, size int, options AssemblyOptions) (SearchResultSequence, error) {
+	return sb.search(query, nil, size, options)
+}
+
+// SearchVideos searches like Search among the given videos, returning all of the matching ones.
+func (sb *SQLiteBackend) SearchVideos(query string, ids []string, options AssemblyOptions) (SearchResultSequence, error) {
+	if len(ids) == 0 {
+		return SearchResultSequence{}, nil
+	}
+	return sb.search(query, ids, len(ids), options)
+}
+
+// search makes the searches of Search and SearchVideos, restricted to the given videos unless only is nil.
+func (sb *SQLiteBackend) search(query string, only []string, size int, options AssemblyOptions) (SearchResultSequence, error) {
+	result := SearchResultSequence{}
+	match := ftsQuery(query)
+	if match == "" {
+		return result, nil
+	}
+	started := time.Now()
+	defer observeSearch("sqlite", started)
+	statement := `SELECT s.video_id, s.position, highlight(segments_fts, 0, ?, ?), bm25(segments_fts)
+		FROM segments_fts JOIN segments s ON s.id = segments_fts.rowid
+		WHERE segments_fts MATCH ?`
+	args := []interface{}{highlightStart, highlightEnd, match}
+	if only != nil {
+		statement += " AND s.video_id IN (?" + strings.Repeat(", ?", len(only)-1) + ")"
+		for _, id := range only {
+			args = append(args, id)
+		}
+	}
+	rows, err := sb.db.Query(statement, args...)
+	if err != nil {
+		return nil, err
+	}
+	matches := map[string][]sqliteMatch{}
+	scores := map[string]float64{}
+	for rows.Next() {
+		var id, highlighted string
+		var position int
+		var bm25 float64
+		if err := rows.Scan(&id, &position, &highlighted, &bm25); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		matches[id] = append(matches[id], sqliteMatch{position, highlighted})
+		scores[id] -= bm25 // The better the match, the more negative the BM25 score of FTS5.
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(matches))
+	for id := range matches {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if scores[ids[i]] != scores[ids[j]] {
+			return scores[ids[i]] > scores[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	total := len(ids)
+	if size <= 0 {
+		size = 10
+	}
+	if len(ids) > size {
+		ids = ids[:size]
+	}
+	for _, id := range ids {
+		video, err := sb.assemble(id, scores[id], matches[id], options)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrAssembly, id, err)
+		}
+		result = append(result, video)
+	}
+	Log.Infof("%d matching videos out of %d in %v", len(result), total, time.Since(started))
+	return result, nil
+}
+
+// assemble builds the search result of a video from its matching segments.
+// The segments of the video are loaded in the format of the bleve indexes, to share the assembly of the context.
+func (sb *SQLiteBackend) assemble(id string, score float64, matches []sqliteMatch, options AssemblyOptions) (SearchResult, error) {
+	result := SearchResult{ID: id, Score: score}
+	var uploadDate sql.NullString
+	var duration float64
+	err := sb.db.QueryRow("SELECT title, upload_date, duration, view_count, translated_from FROM videos WHERE id = ?", id).
+		Scan(&result.Title, &uploadDate, &duration, &result.ViewCount, &result.TranslatedFrom)
+	if err != nil {
+		return result, err
+	}
+	result.Duration = fromSeconds(duration)
+	if uploadDate.Valid {
+		result.UploadDate, _ = time.Parse(time.RFC3339, uploadDate.String) // Stays zero when it cannot be parsed.
+	}
+
+	rows, err := sb.db.Query("SELECT start_time, end_time, text FROM segments WHERE video_id = ? ORDER BY position", id)
+	if err != nil {
+		return result, err
+	}
+	segments := []float64{}
+	var words strings.Builder
+	for rows.Next() {
+		var start, end float64
+		var text string
+		if err := rows.Scan(&start, &end, &text); err != nil {
+			rows.Close()
+			return result, err
+		}
+		if len(segments) > 0 {
+			words.WriteRune('\n')
+		}
+		words.WriteString(text)
+		segments = append(segments, start, end, float64(words.Len()))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+
+	hits := make([]SegmentHit, 0, len(matches))
+	for _, match := range matches {
+		if match.position >= len(segments)/3 {
+			return result, fmt.Errorf("segment %d of %s is missing", match.position, id)
+		}
+		start, end := extractDurations(segments, match.position)
+		highlights, terms := parseHighlights(match.highlighted)
+		hit := SegmentHit{
+			StartTime:   start,
+			EndTime:     end,
+			Text:        extractText(words.String(), segments, match.position),
+			SortedTerms: terms,
+			Highlights:  highlights,
+		}
+		addContext(&hit, words.String(), segments, match.position, options)
+		hits = append(hits, hit)
+	}
+	result.Segments = selectSegmentHits(hits, options)
+	return result, nil
+}
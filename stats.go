@@ -0,0 +1,146 @@
+//go:build !js
+// +build !js
+
+package sininen
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+)
+
+// TermCount is a term along with the number of transcriptions containing it.
+// Deleted transcriptions may be counted until the index is compacted.
+type TermCount struct {
+	Term  string `json:"term"`
+	Count int    `json:"count"`
+}
+
+// IndexStats describes the content of a subtitle index.
+type IndexStats struct {
+	Videos             int
+	TranscriptDuration time.Duration // Sum of the durations of the videos.
+	Size               int64         // Disk usage of the index, in bytes.
+	LastUpdate         time.Time     // Last modification of the index files.
+	TopTerms           []TermCount   // Terms contained in the most transcriptions, most frequent first.
+}
+
+// topTerms returns the nterms terms of the transcriptions contained in the most documents.
+func topTerms(index bleve.Index, nterms int) ([]TermCount, error) {
+	dict, err := index.FieldDict("Words")
+	if err != nil {
+		return nil, err
+	}
+	defer dict.Close()
+
+	result := []TermCount{}
+	for {
+		entry, err := dict.Next()
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			break
+		}
+		result = append(result, TermCount{entry.Term, int(entry.Count)})
+	}
+	return mostFrequentTerms(result, nterms), nil
+}
+
+// mostFrequentTerms returns the nterms most frequent terms, sorted by decreasing count and then alphabetically.
+func mostFrequentTerms(terms []TermCount, nterms int) []TermCount {
+	sort.Slice(terms, func(i, j int) bool {
+		if terms[i].Count != terms[j].Count {
+			return terms[i].Count > terms[j].Count
+		}
+		return terms[i].Term < terms[j].Term
+	})
+	if len(terms) > nterms {
+		terms = terms[:nterms]
+	}
+	return terms
+}
+
+// SuggestTerms returns the nterms terms of the transcriptions starting with a prefix, contained in the most documents
+// of the given indexes, e.g. to complete the words of a query as it is typed.
+// The terms are the ones of the index, i.e. lowercased and stemmed, or the n-grams of the indexes made with them.
+func SuggestTerms(prefix string, nterms int, indexes ...bleve.Index) ([]TermCount, error) {
+	prefix = strings.ToLower(prefix)
+	counts := map[string]int{}
+	for _, index := range indexes {
+		dict, err := index.FieldDictPrefix("Words", []byte(prefix))
+		if err != nil {
+			return nil, err
+		}
+		for {
+			entry, err := dict.Next()
+			if err != nil {
+				dict.Close()
+				return nil, err
+			}
+			if entry == nil {
+				break
+			}
+			counts[entry.Term] += int(entry.Count)
+		}
+		if err := dict.Close(); err != nil {
+			return nil, err
+		}
+	}
+	result := make([]TermCount, 0, len(counts))
+	for term, count := range counts {
+		result = append(result, TermCount{term, count})
+	}
+	return mostFrequentTerms(result, nterms), nil
+}
+
+// transcriptDuration returns the total duration of the transcriptions of an index.
+func transcriptDuration(index bleve.Index, videos int) (time.Duration, error) {
+	request := bleve.NewSearchRequestOptions(bleve.NewMatchAllQuery(), videos, 0, false)
+	request.Fields = []string{"Duration"}
+	result, err := index.Search(request)
+	if err != nil {
+		return 0, err
+	}
+	var total time.Duration
+	for _, hit := range result.Hits {
+		if seconds, ok := hit.Fields["Duration"].(float64); ok {
+			total += fromSeconds(seconds)
+		}
+	}
+	return total, nil
+}
+
+// ComputeIndexStats describes the index of the given language saved in folder, reporting the nterms most frequent terms.
+func ComputeIndexStats(index bleve.Index, folder, lang string, nterms int) (IndexStats, error) {
+	var result IndexStats
+	count, err := index.DocCount()
+	if err != nil {
+		return result, err
+	}
+	result.Videos = int(count)
+	if result.TranscriptDuration, err = transcriptDuration(index, result.Videos); err != nil {
+		return result, err
+	}
+	if result.TopTerms, err = topTerms(index, nterms); err != nil {
+		return result, err
+	}
+
+	err = filepath.Walk(indexPath(folder, lang), func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			result.Size += info.Size()
+		}
+		if info.ModTime().After(result.LastUpdate) {
+			result.LastUpdate = info.ModTime()
+		}
+		return nil
+	})
+	return result, err
+}
@@ -0,0 +1,99 @@
+package sininen
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/asticode/go-astisub"
+)
+
+// SegmentsOfVideo returns the scored segments belonging to the video with the given ID, preserving their order.
+func SegmentsOfVideo(segments []ScoredSegment, id string) []ScoredSegment {
+	result := []ScoredSegment{}
+	for _, segment := range segments {
+		if segment.ID == id {
+			result = append(result, segment)
+		}
+	}
+	return result
+}
+
+// FilterSegments returns the scored segments for which keep returns true, preserving their order.
+func FilterSegments(segments []ScoredSegment, keep func(ScoredSegment) bool) []ScoredSegment {
+	result := []ScoredSegment{}
+	for _, segment := range segments {
+		if keep(segment) {
+			result = append(result, segment)
+		}
+	}
+	return result
+}
+
+// toSubtitles converts segments and their context to subtitles in chronological order, which is needed by subtitle
+// players.
+// The segments are expected to belong to a single video, excerpts shared by several segments are only included once.
+func toSubtitles(segments []ScoredSegment) *astisub.Subtitles {
+	excerpts := []SegmentExcerpt{}
+	seen := map[time.Duration]bool{}
+	add := func(excerpt SegmentExcerpt) {
+		if !seen[excerpt.StartTime] {
+			seen[excerpt.StartTime] = true
+			excerpts = append(excerpts, excerpt)
+		}
+	}
+	for _, segment := range segments {
+		for _, excerpt := range segment.Before {
+			add(excerpt)
+		}
+		add(SegmentExcerpt{segment.StartTime, segment.EndTime, segment.Text})
+		for _, excerpt := range segment.After {
+			add(excerpt)
+		}
+	}
+	sort.SliceStable(excerpts, func(i, j int) bool {
+		return excerpts[i].StartTime < excerpts[j].StartTime
+	})
+
+	result := astisub.NewSubtitles()
+	for _, excerpt := range excerpts {
+		result.Items = append(result.Items, &astisub.Item{
+			StartAt: excerpt.StartTime,
+			EndAt:   excerpt.EndTime,
+			Lines:   []astisub.Line{{Items: []astisub.LineItem{{Text: excerpt.Text}}}},
+		})
+	}
+	return result
+}
+
+// singleVideo fails with ErrInvalidOption when the segments belong to several videos, whose timestamps would collide in
+// a subtitle track.
+func singleVideo(segments []ScoredSegment) error {
+	for _, segment := range segments {
+		if segment.ID != segments[0].ID {
+			return fmt.Errorf("%w: subtitles can only hold the segments of one video, got %s and %s",
+				ErrInvalidOption, segments[0].ID, segment.ID)
+		}
+	}
+	return nil
+}
+
+// WriteWebVTT writes the segments of a video as a WebVTT subtitle track, for instance to highlight the hits in a player.
+// It fails with ErrInvalidOption, before writing anything, when the segments belong to several videos.
+func WriteWebVTT(w io.Writer, segments []ScoredSegment) error {
+	if err := singleVideo(segments); err != nil {
+		return err
+	}
+	return toSubtitles(segments).WriteToWebVTT(w)
+}
+
+// WriteSRT writes the segments of a video and their context as SRT subtitles, for instance to caption a clip cut from
+// the video.
+// It fails with ErrInvalidOption, before writing anything, when the segments belong to several videos.
+func WriteSRT(w io.Writer, segments []ScoredSegment) error {
+	if err := singleVideo(segments); err != nil {
+		return err
+	}
+	return toSubtitles(segments).WriteToSRT(w)
+}
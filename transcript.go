@@ -0,0 +1,77 @@
+//go:build !js
+// +build !js
+
+package sininen
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+)
+
+// ReadTranscript reconstructs the whole transcription of a video from the text and segments stored in an index, failing
+// with ErrTextNotStored for the indexes created with IndexOptions.OmitText.
+// When query is not empty, the segments matching it have their Highlights and SortedTerms set like search results.
+func ReadTranscript(index bleve.Index, id, query string) ([]SegmentHit, error) {
+	if !storesText(index) {
+		return nil, fmt.Errorf("%w: the transcript of %s cannot be rebuilt from the index", ErrTextNotStored, id)
+	}
+	raw, err := index.Search(newTranscriptionRequest(bleve.NewDocIDQuery([]string{id})))
+	if err != nil {
+		return nil, err
+	}
+	if len(raw.Hits) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrVideoNotFound, id)
+	}
+	segments, words, err := storedSegments(raw.Hits[0])
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]SegmentHit, 0, len(segments)/3)
+	for i := 0; i < len(segments)/3; i++ {
+		start, end := extractDurations(segments, i)
+		result = append(result, SegmentHit{StartTime: start, EndTime: end, Text: extractText(words, segments, i)})
+	}
+	if query == "" {
+		return result, nil
+	}
+
+	request := newTranscriptionRequest(bleve.NewConjunctionQuery(bleve.NewMatchQuery(query), bleve.NewDocIDQuery([]string{id})))
+	matches, err := index.Search(request)
+	if err != nil {
+		return nil, err
+	}
+	for _, hit := range matches.Hits {
+		for term, locations := range hit.Locations["Words"] {
+			for _, location := range locations {
+				i := locateSegment(segments, location)
+				if i < 0 || i >= len(result) {
+					continue
+				}
+				result[i].SortedTerms = append(result[i].SortedTerms, term)
+				result[i].Highlights = appendHighlight(result[i].Highlights, words, segments, i, location)
+			}
+		}
+	}
+	for i := range result {
+		segment := &result[i]
+		sort.Strings(segment.SortedTerms)
+		sort.Slice(segment.Highlights, func(i, j int) bool { return segment.Highlights[i].Start < segment.Highlights[j].Start })
+	}
+	return result, nil
+}
+
+// TranscriptAround returns the segments of a transcript overlapping the span before and after the given moment.
+// The segment being spoken at that moment is always included, when there is one.
+func TranscriptAround(transcript []SegmentHit, at, span time.Duration) []SegmentHit {
+	result := []SegmentHit{}
+	for _, segment := range transcript {
+		if segment.EndTime >= at-span && segment.StartTime <= at+span {
+			result = append(result, segment)
+		}
+	}
+	return result
+}
@@ -0,0 +1,189 @@
+package sininen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/asticode/go-astisub"
+)
+
+// Translator machine-translates texts, so that the videos of a foreign-language channel can be searched in another
+// language.
+type Translator interface {
+	// Translate returns the translations of texts from the source language to the target one, in the same order.
+	Translate(ctx context.Context, texts []string, source, target string) ([]string, error)
+}
+
+// LibreTranslate translates with a LibreTranslate server, see https://libretranslate.com.
+// The zero value is a sensible default, translating with a server running locally.
+type LibreTranslate struct {
+	URL  string       // Base URL of the server, http://localhost:5000 by default.
+	Key  string       // API key, needed by some servers.
+	HTTP *http.Client // http.DefaultClient when nil.
+}
+
+func (lt LibreTranslate) withDefaults() LibreTranslate {
+	if lt.URL == "" {
+		lt.URL = "http://localhost:5000"
+	}
+	if lt.HTTP == nil {
+		lt.HTTP = http.DefaultClient
+	}
+	return lt
+}
+
+// libreTranslateResponse is the response of the translation endpoint, the translations being in the order of the texts.
+type libreTranslateResponse struct {
+	TranslatedText []string `json:"translatedText"`
+	Error          string   `json:"error"`
+}
+
+// Translate sends the texts to the translation endpoint of the server in a single request.
+func (lt LibreTranslate) Translate(ctx context.Context, texts []string, source, target string) ([]string, error) {
+	lt = lt.withDefaults()
+	body, err := json.Marshal(map[string]interface{}{
+		"q": texts, "source": source, "target": target, "format": "text", "api_key": lt.Key,
+	})
+	if err != nil {
+		return nil, err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(lt.URL, "/")+"/translate", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := lt.HTTP.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	raw, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	var decoded libreTranslateResponse
+	if err := json.Unmarshal(raw, &decoded); err != nil || response.StatusCode != http.StatusOK {
+		if decoded.Error != "" {
+			return nil, fmt.Errorf("translating from %s to %s: %s: %s", source, target, response.Status, decoded.Error)
+		}
+		return nil, fmt.Errorf("translating from %s to %s: %s: %s", source, target, response.Status, bytes.TrimSpace(raw))
+	}
+	if len(decoded.TranslatedText) != len(texts) {
+		return nil, fmt.Errorf("translating from %s to %s: %d translations for %d texts", source, target,
+			len(decoded.TranslatedText), len(texts))
+	}
+	return decoded.TranslatedText, nil
+}
+
+// translationNote prefixes the language of the original subtitles in the WebVTT note flagging their translation.
+const translationNote = "translated from "
+
+// translationBatch is the number of subtitle items sent at once to a translator.
+const translationBatch = 100
+
+// translatedFrom returns the original language of machine-translated subtitles, flagged by a note before their first
+// item, or an empty string for the original subtitles.
+func translatedFrom(st *astisub.Subtitles) string {
+	if len(st.Items) == 0 {
+		return ""
+	}
+	for _, comment := range st.Items[0].Comments {
+		if strings.HasPrefix(comment, translationNote) {
+			return strings.TrimSpace(strings.TrimPrefix(comment, translationNote))
+		}
+	}
+	return ""
+}
+
+// TranslateSubtitleFile machine-translates a subtitles file from the source language to the target one, and writes the
+// translation in a folder, named like youtube-dl does it so that it is indexed with the subtitles of the target language,
+// i.e. <id>.<target>.vtt, returning the written file.
+// The translation keeps the timing of the original subtitles and is flagged as such by a WebVTT note, so that its
+// segments tell their original language once indexed, see Transcription.TranslatedFrom.
+// The file is only created once the translation succeeded.
+func TranslateSubtitleFile(ctx context.Context, translator Translator, filename, folder, id, source, target string) (string, error) {
+	if source == "" || target == "" {
+		return "", fmt.Errorf("%w: the languages of the translation are needed", ErrInvalidOption)
+	}
+	st, err := astisub.OpenFile(filename)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s: %v", ErrBadFormat, filename, err)
+	}
+	if original := translatedFrom(st); original != "" {
+		return "", fmt.Errorf("%w: %s is already translated from %s", ErrInvalidOption, filename, original)
+	}
+	texts := make([]string, len(st.Items))
+	empty := true
+	for i, item := range st.Items {
+		var sb strings.Builder
+		addSubtitleItem(&sb, item)
+		texts[i] = sb.String()
+		empty = empty && strings.TrimSpace(texts[i]) == ""
+	}
+	if empty {
+		return "", fmt.Errorf("%w: nothing to translate in %s", ErrEmptyTranscript, filename)
+	}
+
+	translation := astisub.NewSubtitles()
+	for start := 0; start < len(texts); start += translationBatch {
+		end := start + translationBatch
+		if end > len(texts) {
+			end = len(texts)
+		}
+		translated, err := translator.Translate(ctx, texts[start:end], baseLanguage(source), baseLanguage(target))
+		if err != nil {
+			return "", err
+		}
+		for i, text := range translated {
+			item := st.Items[start+i]
+			translation.Items = append(translation.Items, &astisub.Item{
+				StartAt: item.StartAt,
+				EndAt:   item.EndAt,
+				Lines:   []astisub.Line{{Items: []astisub.LineItem{{Text: text}}}},
+			})
+		}
+	}
+	translation.Items[0].Comments = []string{translationNote + source}
+
+	var subtitles bytes.Buffer
+	if err := translation.WriteToWebVTT(&subtitles); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		return "", err
+	}
+	translationFile := filepath.Join(folder, id+"."+target+".vtt")
+	if err := ioutil.WriteFile(translationFile+".part", subtitles.Bytes(), 0644); err != nil {
+		return "", err
+	}
+	return translationFile, os.Rename(translationFile+".part", translationFile)
+}
+
+// UntranslatedSubtitles returns the subtitles files of the source language in a folder whose videos have no subtitles
+// in the target language, by video ID, i.e. the files that TranslateSubtitleFile can translate.
+func UntranslatedSubtitles(folder, source, target string) (map[string]string, error) {
+	files, err := subtitleFiles(folder, source)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(files))
+	for id := range files {
+		ids = append(ids, id)
+	}
+	missing, err := MissingSubtitles(folder, target, ids)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]string, len(missing))
+	for _, id := range missing {
+		result[id] = filepath.Join(folder, files[id].Name())
+	}
+	return result, nil
+}
@@ -0,0 +1,39 @@
+package sininen
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// twitchVODPattern matches the IDs of Twitch VODs, as given by yt-dlp.
+var twitchVODPattern = regexp.MustCompile(`^v[0-9]+$`)
+
+// IsTwitchVOD tells whether a document ID is the ID of a Twitch VOD.
+// Twitch channels are laid out like YouTube channels: the folder of a channel is named after the login of the
+// streamer, and yt-dlp names the subtitles of VODs after their ID prefixed by v, e.g. v1234567890.en.vtt, which is
+// also the ID of their documents in the indexes.
+func IsTwitchVOD(id string) bool {
+	return twitchVODPattern.MatchString(id)
+}
+
+// TwitchVideoID returns the numeric ID of a Twitch VOD, i.e. its ID without the v prefix added by yt-dlp.
+func TwitchVideoID(id string) string {
+	if IsTwitchVOD(id) {
+		return id[1:]
+	}
+	return id
+}
+
+// TwitchVODsURL returns the URL of the past broadcasts of a Twitch channel, given by the login of the streamer.
+func TwitchVODsURL(login string) string {
+	return "https://www.twitch.tv/" + url.PathEscape(strings.ToLower(login)) + "/videos?filter=archives&sort=time"
+}
+
+// videoPageURL returns the page of a video given by the ID of its document, for yt-dlp.
+func videoPageURL(id string) string {
+	if IsTwitchVOD(id) {
+		return "https://www.twitch.tv/videos/" + TwitchVideoID(id)
+	}
+	return "https://www.youtube.com/watch?v=" + id
+}
@@ -0,0 +1,232 @@
+package sininen
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// URLBuilder creates links to videos at a given time, making the search results independent from the video platform.
+type URLBuilder interface {
+	URL(id string, start time.Duration) string
+}
+
+// EmbedURLBuilder is implemented by the URL builders of platforms providing an embeddable player.
+type EmbedURLBuilder interface {
+	EmbedURL(id string, start time.Duration) string
+}
+
+// ClipURLBuilder is implemented by the URL builders of platforms able to stop the playback at a given time.
+type ClipURLBuilder interface {
+	ClipURL(id string, start, end time.Duration) string
+}
+
+// Clips makes the links to segments stop at the end of the segments, when the platform allows it.
+// See SegmentURL.
+type Clips struct {
+	URLBuilder
+}
+
+// unwrap returns the URL builder wrapped by Clips, so that its optional capabilities remain available.
+func unwrap(builder URLBuilder) URLBuilder {
+	if clips, ok := builder.(Clips); ok {
+		return clips.URLBuilder
+	}
+	return builder
+}
+
+// SegmentURL returns the link to a segment.
+// When the builder is wrapped by Clips and able to build clip URLs, the link stops at the end of the segment.
+func SegmentURL(builder URLBuilder, segment ScoredSegment) string {
+	if clips, ok := builder.(Clips); ok {
+		if clipper, ok := clips.URLBuilder.(ClipURLBuilder); ok {
+			return clipper.ClipURL(segment.ID, segment.StartTime, segment.EndTime)
+		}
+	}
+	return builder.URL(segment.ID, segment.StartTime)
+}
+
+// ThumbnailURLBuilder is implemented by the URL builders of platforms providing preview images of videos.
+type ThumbnailURLBuilder interface {
+	// ThumbnailURL returns the image closest to the moment at, duration being the duration of the video or zero when
+	// unknown.
+	ThumbnailURL(id string, at, duration time.Duration) string
+}
+
+// AddThumbnails sets the thumbnails of segments, if the platform provides them.
+// The given slice is left untouched.
+func AddThumbnails(segments []ScoredSegment, builder URLBuilder) []ScoredSegment {
+	result := make([]ScoredSegment, len(segments))
+	copy(result, segments)
+	thumbnailer, ok := unwrap(builder).(ThumbnailURLBuilder)
+	if !ok {
+		return result
+	}
+	for i := range result {
+		result[i].Thumbnail = thumbnailer.ThumbnailURL(result[i].ID, result[i].StartTime, result[i].VideoDuration)
+	}
+	return result
+}
+
+// embedURL returns the link to the embedded player of a video, or an empty string if the platform has none.
+func embedURL(builder URLBuilder, id string, start time.Duration) string {
+	if embedder, ok := unwrap(builder).(EmbedURLBuilder); ok {
+		return embedder.EmbedURL(id, start)
+	}
+	return ""
+}
+
+// YouTubeURL returns the link to a YouTube video starting at the given time.
+func YouTubeURL(id string, start time.Duration) string {
+	return fmt.Sprintf("https://www.youtube.com/watch?v=%s&t=%vs", id, int(start.Seconds()))
+}
+
+// YouTubeEmbedURL returns the link to the embedded player of a YouTube video, starting at the given time.
+func YouTubeEmbedURL(id string, start time.Duration) string {
+	return fmt.Sprintf("https://www.youtube.com/embed/%s?start=%v", id, int(start.Seconds()))
+}
+
+// hmsTime formats a time like the timestamps of PeerTube and Twitch links, e.g. 1h2m3s or 5m0s, truncated to the second.
+func hmsTime(start time.Duration) string {
+	seconds := int(start.Seconds())
+	switch {
+	case seconds >= 3600:
+		return fmt.Sprintf("%dh%dm%ds", seconds/3600, seconds/60%60, seconds%60)
+	case seconds >= 60:
+		return fmt.Sprintf("%dm%ds", seconds/60, seconds%60)
+	}
+	return fmt.Sprintf("%ds", seconds)
+}
+
+// YouTube builds links to YouTube videos.
+type YouTube struct{}
+
+func (YouTube) URL(id string, start time.Duration) string      { return YouTubeURL(id, start) }
+func (YouTube) EmbedURL(id string, start time.Duration) string { return YouTubeEmbedURL(id, start) }
+
+// ClipURL returns a link to the embedded player, which is the only one able to stop at a given time.
+func (YouTube) ClipURL(id string, start, end time.Duration) string {
+	return fmt.Sprintf("https://www.youtube.com/embed/%s?start=%v&end=%v", id, int(start.Seconds()), int(math.Ceil(end.Seconds())))
+}
+
+// ThumbnailURL returns the automatically generated thumbnail closest to the given moment.
+// YouTube generates three of them, at a quarter, half and three quarters of the video.
+// The storyboards would be more precise but their URLs are signed and can only be obtained by querying YouTube.
+func (YouTube) ThumbnailURL(id string, at, duration time.Duration) string {
+	if duration <= 0 {
+		return fmt.Sprintf("https://i.ytimg.com/vi/%s/hqdefault.jpg", id)
+	}
+	closest := int(math.Round(4 * float64(at) / float64(duration))) // Index of the closest quarter.
+	if closest < 1 {
+		closest = 1
+	}
+	if closest > 3 {
+		closest = 3
+	}
+	return fmt.Sprintf("https://i.ytimg.com/vi/%s/%d.jpg", id, closest)
+}
+
+// PeerTube builds links to the videos of a PeerTube instance.
+type PeerTube struct {
+	Instance string // Base URL of the instance, e.g. https://framatube.org.
+}
+
+func (pt PeerTube) URL(id string, start time.Duration) string {
+	return fmt.Sprintf("%s/w/%s?start=%s", strings.TrimSuffix(pt.Instance, "/"), id, hmsTime(start))
+}
+
+func (pt PeerTube) EmbedURL(id string, start time.Duration) string {
+	return fmt.Sprintf("%s/videos/embed/%s?start=%s", strings.TrimSuffix(pt.Instance, "/"), id, hmsTime(start))
+}
+
+func (pt PeerTube) ClipURL(id string, start, end time.Duration) string {
+	return fmt.Sprintf("%s&stop=%s", pt.URL(id, start), hmsTime(time.Duration(math.Ceil(end.Seconds()))*time.Second))
+}
+
+// Vimeo builds links to Vimeo videos.
+type Vimeo struct{}
+
+func (Vimeo) URL(id string, start time.Duration) string {
+	return fmt.Sprintf("https://vimeo.com/%s#t=%vs", id, int(start.Seconds()))
+}
+
+func (Vimeo) EmbedURL(id string, start time.Duration) string {
+	return fmt.Sprintf("https://player.vimeo.com/video/%s#t=%vs", id, int(start.Seconds()))
+}
+
+// Twitch builds links to Twitch VODs.
+// The IDs can be given with or without the v prefix that yt-dlp adds to the IDs of VODs.
+type Twitch struct{}
+
+func (Twitch) URL(id string, start time.Duration) string {
+	return fmt.Sprintf("https://www.twitch.tv/videos/%s?t=%s", TwitchVideoID(id), hmsTime(start))
+}
+
+// LocalFile builds file:// links to video files stored in a folder, named after their ID.
+// The start time is given as a media fragment, which is understood by most browsers.
+type LocalFile struct {
+	Folder    string
+	Extension string // Extension of the video files, without the leading dot.
+}
+
+// path returns the absolute path of a video file, falling back on the relative path if it cannot be determined.
+func (lf LocalFile) path(id string) string {
+	result := filepath.Join(lf.Folder, id+"."+lf.Extension)
+	if abs, err := filepath.Abs(result); err == nil {
+		result = abs
+	}
+	return result
+}
+
+func (lf LocalFile) URL(id string, start time.Duration) string {
+	location := url.URL{Scheme: "file", Path: filepath.ToSlash(lf.path(id)), Fragment: fmt.Sprintf("t=%v", int(start.Seconds()))}
+	return location.String()
+}
+
+func (lf LocalFile) ClipURL(id string, start, end time.Duration) string {
+	location := url.URL{
+		Scheme:   "file",
+		Path:     filepath.ToSlash(lf.path(id)),
+		Fragment: fmt.Sprintf("t=%v,%v", int(start.Seconds()), int(math.Ceil(end.Seconds()))),
+	}
+	return location.String()
+}
+
+// MPV builds mpv command lines playing local video files from a given time.
+type MPV struct {
+	LocalFile
+}
+
+func (m MPV) URL(id string, start time.Duration) string {
+	return fmt.Sprintf("mpv --start=%v '%s'", int(start.Seconds()), strings.ReplaceAll(m.path(id), "'", `'\''`))
+}
+
+func (m MPV) ClipURL(id string, start, end time.Duration) string {
+	return fmt.Sprintf("%s --end=%v", m.URL(id, start), int(math.Ceil(end.Seconds())))
+}
+
+// NewURLBuilder returns the URL builder of a platform, either youtube, peertube, vimeo, twitch, file or mpv.
+// base is the instance URL for peertube, the folder containing the videos for file and mpv, and is ignored otherwise.
+func NewURLBuilder(platform, base string) (URLBuilder, error) {
+	switch platform {
+	case "", "youtube":
+		return YouTube{}, nil
+	case "peertube":
+		if base == "" {
+			return nil, fmt.Errorf("%w: the peertube platform needs an instance URL", ErrInvalidOption)
+		}
+		return PeerTube{base}, nil
+	case "vimeo":
+		return Vimeo{}, nil
+	case "twitch":
+		return Twitch{}, nil
+	case "file":
+		return LocalFile{base, "mp4"}, nil
+	case "mpv":
+		return MPV{LocalFile{base, "mp4"}}, nil
+	}
+	return nil, fmt.Errorf("%w: unknown platform %q", ErrInvalidOption, platform)
+}
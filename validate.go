@@ -0,0 +1,49 @@
+package sininen
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// subtitleExtensions are the extensions of the subtitles files that can be parsed.
+var subtitleExtensions = map[string]bool{"vtt": true, "srt": true, "ssa": true, "ass": true, "ttml": true, "stl": true}
+
+// ValidateSubtitleFolder parses every subtitles and metadata file of a folder without indexing them, and returns the problems found.
+// Each problem wraps either ErrBadName, ErrBadFormat or ErrEmptyTranscript, and the whole validation fails only if the folder cannot be read.
+func ValidateSubtitleFolder(folder string) ([]error, error) {
+	files, err := ioutil.ReadDir(folder)
+	if err != nil {
+		return nil, err
+	}
+
+	problems := []error{}
+	for _, file := range files {
+		if file.IsDir() {
+			continue // Indexes are saved in subfolders.
+		}
+		filename := filepath.Join(folder, file.Name())
+		if strings.HasSuffix(strings.ToLower(file.Name()), ".info.json") {
+			if _, err := ReadInfoFile(filename); err != nil {
+				problems = append(problems, err)
+			}
+			continue
+		}
+
+		splitted := strings.Split(file.Name(), ".")
+		if len(splitted) != 3 || splitted[0] == "" || splitted[1] == "" || !subtitleExtensions[strings.ToLower(splitted[2])] {
+			problems = append(problems, fmt.Errorf("%w: %s", ErrBadName, filename))
+			continue
+		}
+		transcription, err := ParseSubtitleFile(filename)
+		if err != nil {
+			problems = append(problems, err)
+			continue
+		}
+		if strings.TrimSpace(transcription.Words) == "" {
+			problems = append(problems, fmt.Errorf("%w: %s", ErrEmptyTranscript, filename))
+		}
+	}
+	return problems, nil
+}
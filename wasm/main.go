@@ -0,0 +1,93 @@
+//go:build js && wasm
+// +build js,wasm
+
+// Command wasm searches the snapshots written by search-yt snapshot from a web page, without a server.
+// Once loaded by wasm_exec.js, it defines a global sininen object with two functions:
+//   - load(bytes), taking the content of a snapshot as a Uint8Array;
+//   - search(query, options), returning the matching segments like the /search endpoint of search-yt serve, the
+//     optional options object holding limit (20 by default), context (0 by default) and maxVideos (10 by default).
+//
+// Both return an Error when they fail, Go functions being unable to throw JavaScript exceptions.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"syscall/js"
+
+	"github.com/mooss/sininen"
+)
+
+// snapshot is the snapshot searched, nil until one is loaded.
+var snapshot *sininen.Snapshot
+
+// searchOptions are the options of the search function.
+type searchOptions struct {
+	Limit     int `json:"limit"`
+	Context   int `json:"context"`
+	MaxVideos int `json:"maxVideos"`
+}
+
+// load reads the snapshot given as a Uint8Array.
+func load(args []js.Value) (interface{}, error) {
+	if len(args) < 1 || args[0].Type() != js.TypeObject {
+		return nil, errors.New("load expects the snapshot as a Uint8Array")
+	}
+	raw := make([]byte, args[0].Get("length").Int())
+	js.CopyBytesToGo(raw, args[0])
+	loaded, err := sininen.OpenSnapshot(raw)
+	if err != nil {
+		return nil, err
+	}
+	snapshot = loaded
+	return len(loaded.Videos), nil
+}
+
+// search returns the segments matching a query, as decoded from their JSON.
+func search(args []js.Value) (interface{}, error) {
+	if snapshot == nil {
+		return nil, errors.New("no snapshot is loaded")
+	}
+	if len(args) < 1 || args[0].Type() != js.TypeString {
+		return nil, errors.New("search expects a query")
+	}
+	options := searchOptions{Limit: 20}
+	if len(args) > 1 && args[1].Type() == js.TypeObject {
+		raw := js.Global().Get("JSON").Call("stringify", args[1]).String()
+		if err := json.Unmarshal([]byte(raw), &options); err != nil {
+			return nil, err
+		}
+	}
+	videos, err := snapshot.Search(args[0].String(), options.MaxVideos, sininen.AssemblyOptions{Context: options.Context})
+	if err != nil {
+		return nil, err
+	}
+	segments := videos.RankedSegments(sininen.DistinctTermsRanking)
+	if options.Limit > 0 && len(segments) > options.Limit {
+		segments = segments[:options.Limit]
+	}
+	result, err := json.Marshal(segments)
+	if err != nil {
+		return nil, err
+	}
+	return js.Global().Get("JSON").Call("parse", string(result)), nil
+}
+
+// function wraps a Go function as a JavaScript one, its error being returned as an Error.
+func function(f func(args []js.Value) (interface{}, error)) js.Func {
+	return js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		result, err := f(args)
+		if err != nil {
+			return js.Global().Get("Error").New(err.Error())
+		}
+		return result
+	})
+}
+
+func main() {
+	js.Global().Set("sininen", map[string]interface{}{
+		"load":   function(load),
+		"search": function(search),
+	})
+	select {} // The functions are called until the page is closed.
+}
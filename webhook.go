@@ -0,0 +1,123 @@
+package sininen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// VideoNotification is the notification of a video indexed for the first time, posted as JSON by Webhooks.
+type VideoNotification struct {
+	Event      string  `json:"event"` // Always video_indexed, telling the notifications apart for the receivers.
+	Channel    string  `json:"channel"`
+	Lang       string  `json:"lang"`
+	ID         string  `json:"id"`
+	Title      string  `json:"title"`
+	UploadDate string  `json:"upload_date,omitempty"` // YYYY-MM-DD.
+	Duration   float64 `json:"duration"`              // In seconds.
+	Segments   int     `json:"segments"`              // Number of segments of the transcript.
+	Words      int     `json:"words"`                 // Number of words of the transcript.
+}
+
+// VideoNotifications returns the notifications of videos of a channel indexed from the subtitles files of a folder,
+// e.g. the ones added by UpdateSubtitleIndex, by parsing their subtitles and metadata files again.
+// The videos whose files cannot be read are reported and left out.
+func VideoNotifications(channel, folder, lang string, ids []string) ([]VideoNotification, error) {
+	files, err := subtitleFiles(folder, lang)
+	if err != nil {
+		return nil, err
+	}
+	result := []VideoNotification{}
+	for _, id := range ids {
+		file, ok := files[id]
+		if !ok {
+			Log.Warnf("no %s subtitles for %s in %s", lang, id, folder)
+			continue
+		}
+		filename := filepath.Join(folder, file.Name())
+		document, err := ParseSubtitleFile(filename)
+		if err != nil {
+			Log.Warnf("skipping %s: %v", filename, err)
+			continue
+		}
+		addMetadata(document, folder, id)
+		video := VideoNotification{
+			Event:    "video_indexed",
+			Channel:  channel,
+			Lang:     lang,
+			ID:       id,
+			Title:    document.Title,
+			Duration: document.Duration,
+			Segments: len(document.Segments) / 3,
+			Words:    len(strings.Fields(document.Words)),
+		}
+		if !document.UploadDate.IsZero() {
+			video.UploadDate = document.UploadDate.Format(dateLayout)
+		}
+		result = append(result, video)
+	}
+	return result, nil
+}
+
+// Webhooks posts notifications as JSON to user-defined URLs, e.g. to trigger some automation when new videos are
+// indexed.
+type Webhooks struct {
+	URLs []string
+	HTTP *http.Client // A client timing out after webhookTimeout when nil.
+}
+
+// webhookTimeout is the time given to a URL to answer a notification by the default client, so that a hanging
+// receiver does not block the syncs.
+const webhookTimeout = 10 * time.Second
+
+// webhookClient is the default client of Webhooks.
+var webhookClient = &http.Client{Timeout: webhookTimeout}
+
+// Notify posts a notification to every URL, failing when any of them does not answer with a 2xx status, the other
+// URLs being notified regardless.
+func (wh Webhooks) Notify(ctx context.Context, notification interface{}) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	var result error
+	for _, url := range wh.URLs {
+		if err := wh.post(ctx, url, body); err != nil && result == nil {
+			result = err
+		}
+	}
+	return result
+}
+
+// post posts a JSON body to a URL.
+func (wh Webhooks) post(ctx context.Context, url string, body []byte) error {
+	client := wh.HTTP
+	if client == nil {
+		client = webhookClient
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		raw, _ := ioutil.ReadAll(io.LimitReader(response.Body, 512))
+		if raw = bytes.TrimSpace(raw); len(raw) > 0 {
+			return fmt.Errorf("POST %s: %s: %s", url, response.Status, raw)
+		}
+		return fmt.Errorf("POST %s: %s", url, response.Status)
+	}
+	return nil
+}
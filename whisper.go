@@ -0,0 +1,187 @@
+package sininen
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Transcriber turns speech into subtitles, for the videos that have none.
+type Transcriber interface {
+	// Transcribe returns the WebVTT subtitles of an audio file in the given language, detected when empty.
+	Transcribe(ctx context.Context, audioFile, lang string) ([]byte, error)
+}
+
+// WhisperCPP transcribes audio locally with whisper.cpp, see https://github.com/ggerganov/whisper.cpp.
+// The audio files other than WAV files are converted with ffmpeg first, whisper.cpp expecting 16 kHz WAV files.
+type WhisperCPP struct {
+	Program string // Path of the whisper.cpp executable, whisper-cli found in the PATH by default.
+	Model   string // Path of the ggml model, e.g. models/ggml-base.bin, mandatory.
+	Threads int    // Number of threads, the default of whisper.cpp when zero.
+	FFmpeg  string // Path of the ffmpeg executable, found in the PATH by default.
+}
+
+// Transcribe runs whisper.cpp on an audio file.
+func (wc WhisperCPP) Transcribe(ctx context.Context, audioFile, lang string) ([]byte, error) {
+	if wc.Model == "" {
+		return nil, fmt.Errorf("%w: a whisper.cpp model is needed", ErrInvalidOption)
+	}
+	program, ffmpeg := wc.Program, wc.FFmpeg
+	if program == "" {
+		program = "whisper-cli"
+	}
+	if ffmpeg == "" {
+		ffmpeg = "ffmpeg"
+	}
+	if lang == "" {
+		lang = "auto"
+	}
+	workFolder, err := ioutil.TempDir("", "sininen-whisper")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(workFolder)
+
+	input := audioFile
+	if !strings.EqualFold(filepath.Ext(audioFile), ".wav") {
+		input = filepath.Join(workFolder, "audio.wav")
+		convert := exec.CommandContext(ctx, ffmpeg, "-nostdin", "-loglevel", "error", "-i", audioFile, "-ar", "16000", "-ac", "1", "-c:a", "pcm_s16le", input)
+		if output, err := convert.CombinedOutput(); err != nil {
+			return nil, fmt.Errorf("converting %s: %v: %s", audioFile, err, bytes.TrimSpace(output))
+		}
+	}
+	outputBase := filepath.Join(workFolder, "transcript")
+	args := []string{"-m", wc.Model, "-f", input, "-l", lang, "-ovtt", "-of", outputBase, "-np"}
+	if wc.Threads > 0 {
+		args = append(args, "-t", strconv.Itoa(wc.Threads))
+	}
+	whisper := exec.CommandContext(ctx, program, args...)
+	if output, err := whisper.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("transcribing %s: %v: %s", audioFile, err, bytes.TrimSpace(output))
+	}
+	return ioutil.ReadFile(outputBase + ".vtt")
+}
+
+// WhisperAPI transcribes audio with an OpenAI-compatible transcriptions endpoint.
+// The zero value is a sensible default, except for the key needed by the OpenAI API.
+type WhisperAPI struct {
+	URL   string       // URL of the transcriptions endpoint, https://api.openai.com/v1/audio/transcriptions by default.
+	Key   string       // API key, sent as a bearer token when not empty.
+	Model string       // Name of the model, whisper-1 by default.
+	HTTP  *http.Client // http.DefaultClient when nil.
+}
+
+func (wa WhisperAPI) withDefaults() WhisperAPI {
+	if wa.URL == "" {
+		wa.URL = "https://api.openai.com/v1/audio/transcriptions"
+	}
+	if wa.Model == "" {
+		wa.Model = "whisper-1"
+	}
+	if wa.HTTP == nil {
+		wa.HTTP = http.DefaultClient
+	}
+	return wa
+}
+
+// Transcribe uploads an audio file to the endpoint.
+func (wa WhisperAPI) Transcribe(ctx context.Context, audioFile, lang string) ([]byte, error) {
+	wa = wa.withDefaults()
+	audio, err := os.Open(audioFile)
+	if err != nil {
+		return nil, err
+	}
+	defer audio.Close()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	fields := map[string]string{"model": wa.Model, "response_format": "vtt"}
+	if lang != "" {
+		fields["language"] = lang
+	}
+	for name, value := range fields {
+		if err := form.WriteField(name, value); err != nil {
+			return nil, err
+		}
+	}
+	part, err := form.CreateFormFile("file", filepath.Base(audioFile))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(part, audio); err != nil {
+		return nil, err
+	}
+	if err := form.Close(); err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, wa.URL, &body)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", form.FormDataContentType())
+	if wa.Key != "" {
+		request.Header.Set("Authorization", "Bearer "+wa.Key)
+	}
+	response, err := wa.HTTP.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	raw, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("transcribing %s: %s: %s", audioFile, response.Status, bytes.TrimSpace(raw))
+	}
+	return raw, nil
+}
+
+// TranscribeAudioFile transcribes an audio file and writes the subtitles of the video in a folder, named like
+// youtube-dl does it so that they can be indexed, i.e. <id>.<lang>.vtt, returning the written file.
+// The file is only created once the transcription succeeded.
+func TranscribeAudioFile(ctx context.Context, transcriber Transcriber, audioFile, folder, id, lang string) (string, error) {
+	if lang == "" {
+		return "", fmt.Errorf("%w: the language of the subtitles is needed to name them", ErrInvalidOption)
+	}
+	subtitles, err := transcriber.Transcribe(ctx, audioFile, lang)
+	if err != nil {
+		return "", err
+	}
+	if len(bytes.TrimSpace(bytes.TrimPrefix(bytes.TrimSpace(subtitles), []byte("WEBVTT")))) == 0 {
+		return "", fmt.Errorf("%w: nothing was transcribed from %s", ErrEmptyTranscript, audioFile)
+	}
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		return "", err
+	}
+	filename := filepath.Join(folder, id+"."+lang+".vtt")
+	if err := ioutil.WriteFile(filename+".part", subtitles, 0644); err != nil {
+		return "", err
+	}
+	return filename, os.Rename(filename+".part", filename)
+}
+
+// MissingSubtitles returns the videos, among the given ones, that have no subtitles file of a language in a folder.
+func MissingSubtitles(folder, lang string, ids []string) ([]string, error) {
+	files, err := subtitleFiles(folder, lang)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	result := []string{}
+	for _, id := range ids {
+		if _, exists := files[id]; !exists {
+			result = append(result, id)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,233 @@
+package sininen
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// YouTubeClient downloads the subtitles of YouTube videos through the timedtext endpoints, without youtube-dl.
+// Only the subtitles uploaded by the channels can be downloaded this way, not the automatic captions.
+// The zero value is a sensible default.
+type YouTubeClient struct {
+	HTTP      *http.Client // http.DefaultClient when nil.
+	Site      string       // Base URL of the channel pages, https://www.youtube.com by default.
+	TimedText string       // URL of the timedtext endpoint, https://video.google.com/timedtext by default.
+}
+
+// CaptionTrack describes a subtitles track of a video.
+type CaptionTrack struct {
+	Lang string // Language code, e.g. en or fr.
+	Name string // Name of the track, empty for the default track of the language.
+}
+
+func (yc YouTubeClient) withDefaults() YouTubeClient {
+	if yc.HTTP == nil {
+		yc.HTTP = http.DefaultClient
+	}
+	if yc.Site == "" {
+		yc.Site = "https://www.youtube.com"
+	}
+	if yc.TimedText == "" {
+		yc.TimedText = "https://video.google.com/timedtext"
+	}
+	return yc
+}
+
+// get fetches the body of a page, failing on any status other than 200.
+func (yc YouTubeClient) get(ctx context.Context, location string) ([]byte, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept-Language", "en")
+	request.Header.Set("Cookie", "CONSENT=YES+") // Skips the cookie consent page shown in some countries.
+	response, err := yc.withDefaults().HTTP.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", location, response.Status)
+	}
+	return ioutil.ReadAll(response.Body)
+}
+
+// ChannelURL returns the URL of the videos page of a channel, given either its name, its @handle or its UC... ID.
+func (yc YouTubeClient) ChannelURL(channel string) string {
+	site := strings.TrimSuffix(yc.withDefaults().Site, "/")
+	switch {
+	case strings.HasPrefix(channel, "@"):
+		return site + "/" + url.PathEscape(channel) + "/videos"
+	case strings.HasPrefix(channel, "UC") && len(channel) == 24:
+		return site + "/channel/" + channel + "/videos"
+	}
+	return site + "/c/" + url.PathEscape(channel) + "/videos"
+}
+
+// videoIDPattern matches the video IDs embedded in the data of YouTube pages.
+var videoIDPattern = regexp.MustCompile(`"videoId":"([A-Za-z0-9_-]{11})"`)
+
+// ChannelVideos returns the IDs of the videos listed on the videos page of a channel, from the most recent.
+// Only the videos displayed without scrolling are listed, i.e. the few dozens most recent ones.
+func (yc YouTubeClient) ChannelVideos(ctx context.Context, channel string) ([]string, error) {
+	return yc.pageVideos(ctx, yc.ChannelURL(channel))
+}
+
+// PlaylistURL returns the URL of the page of a playlist.
+func (yc YouTubeClient) PlaylistURL(playlistID string) string {
+	return strings.TrimSuffix(yc.withDefaults().Site, "/") + "/playlist?list=" + url.QueryEscape(playlistID)
+}
+
+// PlaylistVideos returns the IDs of the videos of a playlist, in the order of the playlist.
+// Only the videos displayed without scrolling are listed, i.e. the hundred first ones.
+func (yc YouTubeClient) PlaylistVideos(ctx context.Context, playlistID string) ([]string, error) {
+	return yc.pageVideos(ctx, yc.PlaylistURL(playlistID))
+}
+
+// pageVideos returns the IDs of the videos of a page, in order of appearance.
+func (yc YouTubeClient) pageVideos(ctx context.Context, location string) ([]string, error) {
+	page, err := yc.get(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+	result := []string{}
+	seen := map[string]bool{}
+	for _, match := range videoIDPattern.FindAllSubmatch(page, -1) {
+		if id := string(match[1]); !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result, nil
+}
+
+// timedTextList is the subtitles tracks list returned by the timedtext endpoint.
+type timedTextList struct {
+	Tracks []struct {
+		Lang string `xml:"lang_code,attr"`
+		Name string `xml:"name,attr"`
+	} `xml:"track"`
+}
+
+// CaptionTracks returns the subtitles tracks of a video.
+func (yc YouTubeClient) CaptionTracks(ctx context.Context, id string) ([]CaptionTrack, error) {
+	query := url.Values{"type": {"list"}, "v": {id}}
+	raw, err := yc.get(ctx, yc.withDefaults().TimedText+"?"+query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	var list timedTextList
+	if err := xml.Unmarshal(raw, &list); err != nil {
+		return nil, fmt.Errorf("%w: subtitles tracks of %s: %v", ErrBadFormat, id, err)
+	}
+	result := make([]CaptionTrack, 0, len(list.Tracks))
+	for _, track := range list.Tracks {
+		result = append(result, CaptionTrack{Lang: track.Lang, Name: track.Name})
+	}
+	return result, nil
+}
+
+// DownloadCaptions writes a subtitles track of a video in the WebVTT format.
+func (yc YouTubeClient) DownloadCaptions(ctx context.Context, id string, track CaptionTrack, w io.Writer) error {
+	query := url.Values{"v": {id}, "lang": {track.Lang}, "fmt": {"vtt"}}
+	if track.Name != "" {
+		query.Set("name", track.Name)
+	}
+	raw, err := yc.get(ctx, yc.withDefaults().TimedText+"?"+query.Encode())
+	if err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return fmt.Errorf("%w: no %s subtitles for %s", ErrNoSubtitles, track.Lang, id)
+	}
+	_, err = w.Write(raw)
+	return err
+}
+
+// DownloadChannelSubtitles downloads the subtitles of the videos of a channel with DownloadVideosSubtitles.
+func (yc YouTubeClient) DownloadChannelSubtitles(ctx context.Context, channel, folder string, langs []string, progress Progress) (int, error) {
+	ids, err := yc.ChannelVideos(ctx, channel)
+	if err != nil {
+		return 0, err
+	}
+	return yc.DownloadVideosSubtitles(ctx, ids, folder, langs, progress)
+}
+
+// DownloadVideosSubtitles downloads the subtitles of videos in the given languages, named like youtube-dl does it so
+// that they can be indexed, i.e. <id>.<lang>.vtt, and returns the number of files written.
+// All the languages are downloaded when langs is empty. The subtitles that were already downloaded are skipped, and
+// the failures are reported without stopping the download of the other videos.
+// progress, if not nil, is called after each video.
+func (yc YouTubeClient) DownloadVideosSubtitles(ctx context.Context, ids []string, folder string, langs []string, progress Progress) (int, error) {
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		return 0, err
+	}
+
+	written := 0
+	progress.report(0, len(ids))
+	for i, id := range ids {
+		tracks, err := yc.CaptionTracks(ctx, id)
+		if err != nil {
+			if ctx.Err() != nil {
+				return written, ctx.Err()
+			}
+			Log.Warnf("skipping %s: %v", id, err)
+		}
+		for _, track := range tracks {
+			if !wantedLanguage(track.Lang, langs) {
+				continue
+			}
+			filename := filepath.Join(folder, id+"."+track.Lang+".vtt")
+			if _, err := os.Stat(filename); err == nil {
+				Log.Debugf("%s is already downloaded", filename)
+				continue
+			}
+			if err := yc.downloadFile(ctx, id, track, filename); err != nil {
+				if ctx.Err() != nil {
+					return written, ctx.Err()
+				}
+				Log.Warnf("downloading %s: %v", filename, err)
+				continue
+			}
+			Log.Debugf("downloaded %s", filename)
+			written++
+		}
+		progress.report(i+1, len(ids))
+	}
+	return written, nil
+}
+
+// wantedLanguage tells whether lang is one of langs, every language being wanted when langs is empty.
+func wantedLanguage(lang string, langs []string) bool {
+	if len(langs) == 0 {
+		return true
+	}
+	for _, wanted := range langs {
+		if strings.EqualFold(lang, wanted) {
+			return true
+		}
+	}
+	return false
+}
+
+// downloadFile downloads a subtitles track to a file, which is only created once the download is complete.
+func (yc YouTubeClient) downloadFile(ctx context.Context, id string, track CaptionTrack, filename string) error {
+	var buffer bytes.Buffer
+	if err := yc.DownloadCaptions(ctx, id, track, &buffer); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filename+".part", buffer.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(filename+".part", filename)
+}
@@ -0,0 +1,127 @@
+package sininen
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// YouTubeDataAPI fetches the metadata of videos from the YouTube Data API, which requires an API key.
+// See https://developers.google.com/youtube/v3/getting-started to get one.
+type YouTubeDataAPI struct {
+	Key      string       // API key, mandatory.
+	HTTP     *http.Client // http.DefaultClient when nil.
+	Endpoint string       // URL of the videos endpoint, https://www.googleapis.com/youtube/v3/videos by default.
+}
+
+// apiVideosMaxIDs is the maximum number of videos that can be requested at once from the videos endpoint.
+const apiVideosMaxIDs = 50
+
+// apiVideos is the subset of a response of the videos endpoint that is relevant to sininen.
+type apiVideos struct {
+	Items []struct {
+		ID      string `json:"id"`
+		Snippet struct {
+			Title       string    `json:"title"`
+			PublishedAt time.Time `json:"publishedAt"`
+		} `json:"snippet"`
+		ContentDetails struct {
+			Duration string `json:"duration"` // ISO 8601 duration, e.g. PT1H2M3S.
+		} `json:"contentDetails"`
+		Statistics struct {
+			ViewCount string `json:"viewCount"` // A number written as a string.
+		} `json:"statistics"`
+	} `json:"items"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// FetchMetadata returns the metadata of the given videos, by ID.
+// The videos that do not exist anymore are missing from the result.
+func (api YouTubeDataAPI) FetchMetadata(ctx context.Context, ids []string) (map[string]*VideoMetadata, error) {
+	if api.Key == "" {
+		return nil, fmt.Errorf("%w: a YouTube Data API key is needed", ErrInvalidOption)
+	}
+	result := make(map[string]*VideoMetadata, len(ids))
+	for start := 0; start < len(ids); start += apiVideosMaxIDs {
+		end := start + apiVideosMaxIDs
+		if end > len(ids) {
+			end = len(ids)
+		}
+		if err := api.fetchBatch(ctx, ids[start:end], result); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// fetchBatch adds the metadata of at most apiVideosMaxIDs videos to result.
+func (api YouTubeDataAPI) fetchBatch(ctx context.Context, ids []string, result map[string]*VideoMetadata) error {
+	endpoint, client := api.Endpoint, api.HTTP
+	if endpoint == "" {
+		endpoint = "https://www.googleapis.com/youtube/v3/videos"
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	query := url.Values{"part": {"snippet,contentDetails,statistics"}, "id": {strings.Join(ids, ",")}, "key": {api.Key}}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	var videos apiVideos
+	if err := json.NewDecoder(response.Body).Decode(&videos); err != nil {
+		return fmt.Errorf("%w: YouTube Data API response: %v", ErrBadFormat, err)
+	}
+	if videos.Error != nil {
+		return fmt.Errorf("YouTube Data API: %s", videos.Error.Message)
+	}
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("YouTube Data API: %s", response.Status)
+	}
+	for _, item := range videos.Items {
+		metadata := &VideoMetadata{Title: item.Snippet.Title, UploadDate: item.Snippet.PublishedAt}
+		if metadata.Duration, err = parseISODuration(item.ContentDetails.Duration); err != nil {
+			Log.Warnf("ignoring the duration of %s: %v", item.ID, err)
+		}
+		if item.Statistics.ViewCount != "" {
+			if metadata.ViewCount, err = strconv.ParseInt(item.Statistics.ViewCount, 10, 64); err != nil {
+				Log.Warnf("ignoring the view count of %s: %v", item.ID, err)
+			}
+		}
+		result[item.ID] = metadata
+	}
+	return nil
+}
+
+// isoDurationPattern matches the ISO 8601 durations used by the YouTube Data API, e.g. PT1H2M3S or P1DT2H.
+var isoDurationPattern = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// parseISODuration parses an ISO 8601 duration made of days, hours, minutes and seconds.
+func parseISODuration(duration string) (time.Duration, error) {
+	match := isoDurationPattern.FindStringSubmatch(duration)
+	if match == nil {
+		return 0, fmt.Errorf("%w: malformed duration %q", ErrBadFormat, duration)
+	}
+	seconds := 0.
+	for i, unit := range []float64{24 * 3600, 3600, 60, 1} {
+		if match[i+1] != "" {
+			value, _ := strconv.ParseFloat(match[i+1], 64) // Always valid thanks to the pattern.
+			seconds += value * unit
+		}
+	}
+	return fromSeconds(seconds), nil
+}
@@ -0,0 +1,131 @@
+package sininen
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// YTDLPOptions tunes how subtitles are downloaded with yt-dlp.
+// The zero value is a sensible default.
+type YTDLPOptions struct {
+	Program     string    // Path of the yt-dlp executable, found in the PATH by default.
+	Langs       []string  // Languages of the subtitles, all of them when empty.
+	AutoSubs    bool      // Also download the automatic captions, for the videos without subtitles in a language.
+	ArchiveFile string    // File where yt-dlp records the downloaded videos to skip them next time, none when empty.
+	Stderr      io.Writer // Receives the messages of yt-dlp, discarded when nil.
+}
+
+// ytdlpSubtitlesPattern matches the lines printed by yt-dlp when it writes a subtitles file.
+var ytdlpSubtitlesPattern = regexp.MustCompile(`^\[info\] Writing video subtitles to: (.+)$`)
+
+// ytdlpArgs returns the arguments making yt-dlp download the subtitles and metadata of the videos of a URL in a folder.
+func ytdlpArgs(url, folder string, options YTDLPOptions) []string {
+	langs := "all,-live_chat,-rechat" // The chat replays of YouTube and Twitch are listed as subtitles.
+	if len(options.Langs) > 0 {
+		langs = strings.Join(options.Langs, ",")
+	}
+	result := []string{"--skip-download", "--write-subs", "--sub-langs", langs, "--sub-format", "vtt/srt/best",
+		"--write-info-json", "--no-progress", "--newline", "-o", filepath.Join(folder, "%(id)s.%(ext)s")}
+	if options.AutoSubs {
+		result = append(result, "--write-auto-subs")
+	}
+	if options.ArchiveFile != "" {
+		// Without --force-write-archive, yt-dlp does not record the videos when --skip-download is given.
+		result = append(result, "--download-archive", options.ArchiveFile, "--force-write-archive")
+	}
+	return append(result, url)
+}
+
+// DownloadWithYTDLP downloads the subtitles and the metadata of the videos of a URL (a video, a playlist or a channel)
+// in a folder with yt-dlp, and returns the subtitles files it wrote.
+// downloaded, if not nil, is called as soon as each subtitles file is written.
+func DownloadWithYTDLP(ctx context.Context, url, folder string, options YTDLPOptions, downloaded func(filename string)) ([]string, error) {
+	ytdlp := exec.CommandContext(ctx, options.program(), ytdlpArgs(url, folder, options)...)
+	ytdlp.Stderr = options.Stderr
+	stdout, err := ytdlp.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := ytdlp.Start(); err != nil {
+		return nil, err
+	}
+
+	result := []string{}
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		Log.Debugf("yt-dlp: %s", scanner.Text())
+		match := ytdlpSubtitlesPattern.FindStringSubmatch(scanner.Text())
+		if match == nil {
+			continue
+		}
+		result = append(result, match[1])
+		if downloaded != nil {
+			downloaded(match[1])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		ytdlp.Wait()
+		return result, err
+	}
+	return result, ytdlp.Wait()
+}
+
+// program returns the yt-dlp executable of the options.
+func (options YTDLPOptions) program() string {
+	if options.Program == "" {
+		return "yt-dlp"
+	}
+	return options.Program
+}
+
+// ListWithYTDLP returns the IDs of the videos of a URL (a video, a playlist or a channel) with yt-dlp, without
+// downloading anything.
+func ListWithYTDLP(ctx context.Context, url string, options YTDLPOptions) ([]string, error) {
+	ytdlp := exec.CommandContext(ctx, options.program(), "--flat-playlist", "--print", "id", url)
+	ytdlp.Stderr = options.Stderr
+	output, err := ytdlp.Output()
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(output)), nil
+}
+
+// DownloadAudioWithYTDLP downloads the audio and the metadata of YouTube videos or Twitch VODs in a folder with yt-dlp,
+// as 16 kHz mono MP3 files suited to speech recognition, and returns the downloaded audio files by video ID.
+// The Langs and AutoSubs options are ignored.
+func DownloadAudioWithYTDLP(ctx context.Context, ids []string, folder string, options YTDLPOptions) (map[string]string, error) {
+	args := []string{"--extract-audio", "--audio-format", "mp3", "--postprocessor-args", "ExtractAudio:-ac 1 -ar 16000",
+		"--write-info-json", "--no-progress", "-o", filepath.Join(folder, "%(id)s.%(ext)s"),
+		"--print", "after_move:%(id)s %(filepath)s"}
+	if options.ArchiveFile != "" {
+		args = append(args, "--download-archive", options.ArchiveFile)
+	}
+	for _, id := range ids {
+		args = append(args, videoPageURL(id))
+	}
+	ytdlp := exec.CommandContext(ctx, options.program(), args...)
+	ytdlp.Stderr = options.Stderr
+	output, err := ytdlp.Output()
+	result := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if splitted := strings.SplitN(line, " ", 2); len(splitted) == 2 {
+			result[splitted[0]] = splitted[1]
+		}
+	}
+	return result, err
+}
+
+// SubtitlesLanguage returns the language of a subtitles file named like <id>.<lang>.<ext>, or an empty string when the
+// file is not named this way.
+func SubtitlesLanguage(filename string) string {
+	splitted := strings.Split(filepath.Base(filename), ".")
+	if len(splitted) <= 2 {
+		return ""
+	}
+	return splitted[len(splitted)-2]
+}